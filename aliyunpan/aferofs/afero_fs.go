@@ -0,0 +1,174 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package aferofs 基于 PanClient 实现的 afero.Fs，已经使用 afero 的应用可以直接切换存储到阿里云盘。
+// 写入的文件会先缓存到本地临时文件，Close 时整体上传，不支持追加写入已有文件
+package aferofs
+
+import (
+	"context"
+	"errors"
+	"github.com/spf13/afero"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/webdavfs"
+	"os"
+	"path"
+	"time"
+)
+
+type (
+	// Fs 网盘afero文件系统
+	Fs struct {
+		fs *webdavfs.FileSystem
+	}
+)
+
+var (
+	// ErrNotSupported 网盘不支持该操作
+	ErrNotSupported = errors.New("operation not supported by aliyunpan")
+)
+
+var _ afero.Fs = (*Fs)(nil)
+
+// NewFs 创建网盘afero文件系统
+func NewFs(panClient *aliyunpan.PanClient, driveId string) *Fs {
+	return &Fs{
+		fs: webdavfs.NewFileSystem(panClient, driveId),
+	}
+}
+
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func (a *Fs) Name() string {
+	return "AliyunpanFs"
+}
+
+func (a *Fs) Create(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+}
+
+func (a *Fs) Mkdir(name string, perm os.FileMode) error {
+	return wrapPathError("mkdir", name, a.fs.Mkdir(context.Background(), name, perm))
+}
+
+// MkdirAll 递归创建文件夹，已存在的文件夹会被忽略
+func (a *Fs) MkdirAll(p string, perm os.FileMode) error {
+	p = cleanPath(p)
+	if p == "/" {
+		return nil
+	}
+	if fi, err := a.Stat(p); err == nil {
+		if fi.IsDir() {
+			return nil
+		}
+		return &os.PathError{Op: "mkdir", Path: p, Err: os.ErrExist}
+	}
+	if err := a.MkdirAll(path.Dir(p), perm); err != nil {
+		return err
+	}
+	err := a.Mkdir(p, perm)
+	if err != nil && os.IsExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (a *Fs) Open(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile 打开文件。只有 O_TRUNC 写模式打开已存在的文件，或者 O_CREATE 创建不存在的文件时可以写入，
+// Close 时整体上传。网盘文件不能原地修改，不带 O_TRUNC 写模式打开已存在的文件返回 ErrNotSupported
+func (a *Fs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	name = cleanPath(name)
+	if flag&os.O_APPEND != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotSupported}
+	}
+	ctx := context.Background()
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 && flag&os.O_TRUNC == 0 && !(flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0) {
+		if fi, err := a.fs.Stat(ctx, name); err == nil && !fi.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotSupported}
+		}
+	}
+	f, err := a.fs.OpenFile(ctx, name, flag, perm)
+	if err != nil {
+		return nil, wrapPathError("open", name, err)
+	}
+	return &file{File: f, name: name}, nil
+}
+
+// Remove 删除文件或者空文件夹到回收站
+func (a *Fs) Remove(name string) error {
+	fi, err := a.Stat(name)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		f, err := a.Open(name)
+		if err != nil {
+			return err
+		}
+		names, err := f.Readdirnames(1)
+		f.Close()
+		if err == nil && len(names) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	return a.RemoveAll(name)
+}
+
+// RemoveAll 删除文件或文件夹到回收站，不存在则忽略
+func (a *Fs) RemoveAll(p string) error {
+	err := a.fs.RemoveAll(context.Background(), p)
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return wrapPathError("remove", p, err)
+}
+
+func (a *Fs) Rename(oldname, newname string) error {
+	return wrapPathError("rename", oldname, a.fs.Rename(context.Background(), oldname, newname))
+}
+
+func (a *Fs) Stat(name string) (os.FileInfo, error) {
+	fi, err := a.fs.Stat(context.Background(), name)
+	if err != nil {
+		return nil, wrapPathError("stat", name, err)
+	}
+	return fi, nil
+}
+
+func (a *Fs) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: ErrNotSupported}
+}
+
+func (a *Fs) Chown(name string, uid, gid int) error {
+	return &os.PathError{Op: "chown", Path: name, Err: ErrNotSupported}
+}
+
+func (a *Fs) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: ErrNotSupported}
+}
+
+func wrapPathError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*os.PathError); ok {
+		return err
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aferofs
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+)
+
+const testContent = "hello aliyunpan"
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newTestFs 文件信息从元数据缓存读取，下载链接和文件数据由模拟的 Transport 返回，其他请求计入 uploads
+func newTestFs(t *testing.T, uploads *int32) *Fs {
+	aliyunpan.SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			body := ""
+			status := http.StatusOK
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/get_download_url"):
+				body = `{"url":"https://data.example.com/f1"}`
+			case r.URL.Host == "data.example.com":
+				body = testContent
+				if rg := strings.TrimPrefix(r.Header.Get("range"), "bytes="); rg != "" {
+					parts := strings.SplitN(rg, "-", 2)
+					start, _ := strconv.Atoi(parts[0])
+					end := len(testContent) - 1
+					if parts[1] != "" {
+						end, _ = strconv.Atoi(parts[1])
+					}
+					body = testContent[start : end+1]
+					status = http.StatusPartialContent
+				}
+			default:
+				atomic.AddInt32(uploads, 1)
+				status = http.StatusInternalServerError
+			}
+			return &http.Response{StatusCode: status, Status: http.StatusText(status), Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+		})
+	})
+	t.Cleanup(func() { aliyunpan.SetTransportWrapper(nil) })
+
+	pc := aliyunpan.NewPanClient(aliyunpan.WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1"}, aliyunpan.AppLoginToken{})
+	store := aliyunpan.NewMemoryMetaStore()
+	store.PutChildren("d1", aliyunpan.DefaultRootParentFileId, aliyunpan.FileList{
+		{DriveId: "d1", FileId: "f1", FileName: "a.txt", FileType: "file", FileSize: int64(len(testContent)), ParentFileId: aliyunpan.DefaultRootParentFileId},
+	})
+	pc.SetMetaStore(store)
+	return NewFs(pc, "d1")
+}
+
+func TestOpenFileWriteModes(t *testing.T) {
+	var uploads int32
+	fs := newTestFs(t, &uploads)
+
+	// 不带 O_TRUNC 写模式打开已存在的文件不支持
+	for _, flag := range []int{os.O_RDWR, os.O_WRONLY, os.O_WRONLY | os.O_CREATE} {
+		_, err := fs.OpenFile("/a.txt", flag, 0644)
+		assert.True(t, errors.Is(err, ErrNotSupported), "flag %d", flag)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&uploads))
+
+	// O_TRUNC 打开后没有写入数据，关闭时也会上传空文件
+	f, err := fs.OpenFile("/a.txt", os.O_WRONLY|os.O_TRUNC, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteAt([]byte("x"), 0)
+	assert.True(t, errors.Is(err, ErrNotSupported))
+	assert.Error(t, f.Close())
+	assert.NotEqual(t, int32(0), atomic.LoadInt32(&uploads))
+
+	_, err = fs.OpenFile("/missing.txt", os.O_WRONLY, 0644)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadAtIndependent(t *testing.T) {
+	var uploads int32
+	fs := newTestFs(t, &uploads)
+	f, err := fs.Open("/a.txt")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(f, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(buf))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := make([]byte, 9)
+			n, err := f.ReadAt(p, 6)
+			assert.NoError(t, err)
+			assert.Equal(t, "aliyunpan", string(p[:n]))
+		}()
+	}
+	wg.Wait()
+
+	// ReadAt 不影响 Read 的偏移位置
+	_, err = io.ReadFull(f, buf[:1])
+	assert.NoError(t, err)
+	assert.Equal(t, " ", string(buf[:1]))
+
+	p := make([]byte, 10)
+	n, err := f.ReadAt(p, 10)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "unpan", string(p[:n]))
+}
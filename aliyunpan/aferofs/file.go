@@ -0,0 +1,75 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aferofs
+
+import (
+	"github.com/spf13/afero"
+	"golang.org/x/net/webdav"
+	"io"
+	"os"
+)
+
+type (
+	// file 在 webdav.File 的基础上补充 afero.File 需要的方法
+	file struct {
+		webdav.File
+		name string
+	}
+)
+
+var _ afero.File = (*file)(nil)
+
+func (f *file) Name() string {
+	return f.name
+}
+
+// ReadAt 独立的范围读取，不修改 Read 使用的偏移位置，可以并发调用
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, &os.PathError{Op: "readat", Path: f.name, Err: ErrNotSupported}
+	}
+	return r.ReadAt(p, off)
+}
+
+// WriteAt 写入的数据先缓存到临时文件再整体上传，不支持不影响偏移位置的随机写入
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	return 0, &os.PathError{Op: "writeat", Path: f.name, Err: ErrNotSupported}
+}
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *file) Readdirnames(n int) ([]string, error) {
+	fis, err := f.Readdir(n)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names, nil
+}
+
+// Sync 数据在 Close 时才会上传，这里无需处理
+func (f *file) Sync() error {
+	return nil
+}
+
+func (f *file) Truncate(size int64) error {
+	return &os.PathError{Op: "truncate", Path: f.name, Err: ErrNotSupported}
+}
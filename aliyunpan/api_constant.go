@@ -18,4 +18,8 @@ const (
 	WEB_URL string = "https://www.aliyundrive.com"
 	AUTH_URL string = "https://auth.aliyundrive.com"
 	API_URL string = "https://api.aliyundrive.com"
+	// APP_API_URL 手机客户端接口地址
+	APP_API_URL string = "https://api.alipan.com"
+	// OPENAPI_URL 开放平台接口地址
+	OPENAPI_URL string = "https://openapi.alipan.com"
 )
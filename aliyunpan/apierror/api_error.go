@@ -14,7 +14,10 @@
 
 package apierror
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"sync"
+)
 
 const (
 	// 成功
@@ -22,7 +25,7 @@ const (
 	// 失败
 	ApiCodeFailed ApiCode = 999
 
-	// 验证码
+	// 验证码，需要完成风控验证
 	ApiCodeNeedCaptchaCode ApiCode = 10
 	// 会话/Token已过期
 	ApiCodeTokenExpiredCode ApiCode = 11
@@ -52,6 +55,20 @@ const (
 	ApiCodeNotFoundView ApiCode = 23
 	// ApiCodeBadRequest 请求非法
 	ApiCodeBadRequest ApiCode = 24
+	// ApiCodeScopeNotGranted 开放平台授权范围不包含该接口
+	ApiCodeScopeNotGranted ApiCode = 25
+	// ApiCodeQuotaExceeded 超过接口调用频率或者每日调用次数限制
+	ApiCodeQuotaExceeded ApiCode = 26
+	// ApiCodeDeviceSessionSignatureInvalid 设备会话签名无效 DeviceSessionSignatureInvalid
+	ApiCodeDeviceSessionSignatureInvalid ApiCode = 27
+	// ApiCodeUserDeviceOffline 设备已下线 UserDeviceOffline
+	ApiCodeUserDeviceOffline ApiCode = 28
+)
+
+var (
+	// challengeErrorCodes 需要用户完成风控验证（验证码、短信等）的错误码，只包含已经确认的错误码
+	challengeErrorCodes   = map[string]bool{"NeedCaptcha": true}
+	challengeErrorCodesMu sync.RWMutex
 )
 
 type ApiCode int
@@ -59,12 +76,23 @@ type ApiCode int
 type ApiError struct {
 	Code ApiCode
 	Err  string
+	// Cause 原始错误，可以通过 errors.As 获取详细信息，为nil代表没有
+	Cause error
 }
 
 func NewApiError(code ApiCode, err string) *ApiError {
 	return &ApiError{
-		code,
-		err,
+		Code: code,
+		Err:  err,
+	}
+}
+
+// NewApiErrorWithCause 保留原始错误的 ApiError，错误信息使用原始错误的信息
+func NewApiErrorWithCause(code ApiCode, cause error) *ApiError {
+	return &ApiError{
+		Code:  code,
+		Err:   cause.Error(),
+		Cause: cause,
 	}
 }
 
@@ -97,6 +125,11 @@ func (a *ApiError) ErrCode() ApiCode {
 	return a.Code
 }
 
+// Unwrap 返回原始错误，支持 errors.Is 和 errors.As
+func (a *ApiError) Unwrap() error {
+	return a.Cause
+}
+
 // ParseCommonApiError 解析公共错误，如果没有错误则返回nil
 func ParseCommonApiError(data []byte) *ApiError {
 	errResp := &ErrorResp{}
@@ -104,6 +137,10 @@ func ParseCommonApiError(data []byte) *ApiError {
 		if errResp.ErrorCode != "" {
 			if "AccessTokenInvalid" == errResp.ErrorCode {
 				return NewApiError(ApiCodeAccessTokenInvalid, errResp.ErrorMsg)
+			} else if "AccessTokenExpired" == errResp.ErrorCode {
+				return NewApiError(ApiCodeTokenExpiredCode, errResp.ErrorMsg)
+			} else if "TooManyRequests" == errResp.ErrorCode {
+				return NewApiError(ApiCodeQuotaExceeded, errResp.ErrorMsg)
 			} else if "NotFound.File" == errResp.ErrorCode || "NotFound.FileId" == errResp.ErrorCode {
 				return NewApiError(ApiCodeFileNotFoundCode, errResp.ErrorMsg)
 			} else if "AlreadyExist.File" == errResp.ErrorCode {
@@ -120,9 +157,29 @@ func ParseCommonApiError(data []byte) *ApiError {
 				return NewApiError(ApiCodeNotFoundView, errResp.ErrorMsg)
 			} else if "BadRequest" == errResp.ErrorCode {
 				return NewApiError(ApiCodeBadRequest, errResp.ErrorMsg)
+			} else if "DeviceSessionSignatureInvalid" == errResp.ErrorCode {
+				return NewApiError(ApiCodeDeviceSessionSignatureInvalid, errResp.ErrorMsg)
+			} else if "UserDeviceOffline" == errResp.ErrorCode {
+				return NewApiError(ApiCodeUserDeviceOffline, errResp.ErrorMsg)
+			} else if IsChallengeErrorCode(errResp.ErrorCode) {
+				return NewApiError(ApiCodeNeedCaptchaCode, errResp.ErrorMsg)
 			}
 			return NewFailedApiError(errResp.ErrorMsg)
 		}
 	}
 	return nil
 }
+
+// IsChallengeErrorCode 错误码是否为需要完成风控验证的挑战
+func IsChallengeErrorCode(code string) bool {
+	challengeErrorCodesMu.RLock()
+	defer challengeErrorCodesMu.RUnlock()
+	return challengeErrorCodes[code]
+}
+
+// RegisterChallengeErrorCode 添加需要完成风控验证的错误码，服务器返回新的风控错误码时使用
+func RegisterChallengeErrorCode(code string) {
+	challengeErrorCodesMu.Lock()
+	defer challengeErrorCodesMu.Unlock()
+	challengeErrorCodes[code] = true
+}
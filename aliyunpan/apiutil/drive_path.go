@@ -0,0 +1,66 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiutil
+
+import "strings"
+
+// 网盘路径都是以"/"开头的绝对路径，文件名中可以包含"."、".."等 path.Clean 会处理的内容，
+// 所以不能直接使用 path 包拼接和拆分
+
+// JoinDrivePath 拼接网盘路径，忽略多余的"/"，不会解析文件名中的"."和".."
+func JoinDrivePath(parent string, names ...string) string {
+	p := strings.TrimRight(parent, "/")
+	if p != "" && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	for _, name := range names {
+		name = strings.Trim(name, "/")
+		if name == "" {
+			continue
+		}
+		p += "/" + name
+	}
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// RelDrivePath 获取 target 相对于 root 的路径，target 等于 root 时返回空字符串，不在 root 下时返回false
+func RelDrivePath(root, target string) (string, bool) {
+	root = strings.TrimRight(root, "/")
+	target = strings.TrimRight(target, "/")
+	if target == root {
+		return "", true
+	}
+	if strings.HasPrefix(target, root+"/") {
+		return target[len(root)+1:], true
+	}
+	return "", false
+}
+
+// SplitDrivePath 拆分网盘路径为上级文件夹路径和文件名，根目录返回 "/" 和空文件名
+func SplitDrivePath(p string) (dir, name string) {
+	p = strings.TrimRight(p, "/")
+	idx := strings.LastIndex(p, "/")
+	if idx < 0 {
+		return "/", p
+	}
+	dir, name = p[:idx], p[idx+1:]
+	if dir == "" {
+		dir = "/"
+	}
+	return dir, name
+}
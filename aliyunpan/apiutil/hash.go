@@ -0,0 +1,65 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiutil
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/crc64"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// crc64Table 阿里云盘(OSS)使用的CRC64算法，即 CRC-64/XZ (ECMA-182)
+	crc64Table = crc64.MakeTable(crc64.ECMA)
+)
+
+// ComputeHashes 计算数据的SHA1和CRC64值，格式和rclone一致：SHA1为小写十六进制，CRC64为十进制字符串（和网盘 crc64_hash 一致）
+func ComputeHashes(r io.Reader) (sha1Str, crc64Str string, err error) {
+	sha1w := sha1.New()
+	crc64w := crc64.New(crc64Table)
+	if _, err = io.Copy(io.MultiWriter(sha1w, crc64w), r); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(sha1w.Sum(nil)), strconv.FormatUint(crc64w.Sum64(), 10), nil
+}
+
+// ComputeFileHashes 计算本地文件的SHA1和CRC64值
+func ComputeFileHashes(filePath string) (sha1Str, crc64Str string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	return ComputeHashes(f)
+}
+
+// NormalizeSha1 转换为rclone使用的小写SHA1格式
+func NormalizeSha1(sha1Str string) string {
+	return strings.ToLower(strings.TrimSpace(sha1Str))
+}
+
+// Crc64ToHex 十进制CRC64转换为16位小写十六进制格式，格式错误返回空字符串
+func Crc64ToHex(crc64Str string) string {
+	v, err := strconv.ParseUint(strings.TrimSpace(crc64Str), 10, 64)
+	if err != nil {
+		return ""
+	}
+	s := strconv.FormatUint(v, 16)
+	return strings.Repeat("0", 16-len(s)) + s
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiutil
+
+import (
+	"strings"
+)
+
+const (
+	// nameEncodingQuote 转义符，原文件名中出现的替换字符会加上该前缀，保证可以还原
+	nameEncodingQuote = '‛'
+	// trailingDotReplacement 文件名末尾的"."的替换字符
+	trailingDotReplacement = '．'
+	// trailingSpaceReplacement 文件名末尾的空格的替换字符
+	trailingSpaceReplacement = '␠'
+)
+
+var (
+	// nameEncodingMap 网盘不允许的字符替换为对应的全角字符，和rclone的编码方式一致
+	nameEncodingMap = map[rune]rune{
+		'\\': '＼',
+		':':  '：',
+		'*':  '＊',
+		'?':  '？',
+		'"':  '＂',
+		'<':  '＜',
+		'>':  '＞',
+		'|':  '｜',
+	}
+	nameDecodingMap = map[rune]rune{
+		trailingDotReplacement:   '.',
+		trailingSpaceReplacement: ' ',
+	}
+)
+
+func init() {
+	for k, v := range nameEncodingMap {
+		nameDecodingMap[v] = k
+	}
+}
+
+// EncodeFileName 编码文件名，把网盘不允许的字符以及末尾的"."和空格替换为全角字符，可以通过 DecodeFileName 还原
+func EncodeFileName(name string) string {
+	runes := []rune(name)
+	// 末尾连续的"."和空格的起始位置
+	trailing := len(runes)
+	for trailing > 0 && (runes[trailing-1] == '.' || runes[trailing-1] == ' ') {
+		trailing--
+	}
+
+	builder := &strings.Builder{}
+	for i, r := range runes {
+		if v, ok := nameEncodingMap[r]; ok {
+			builder.WriteRune(v)
+			continue
+		}
+		if i >= trailing {
+			if r == '.' {
+				builder.WriteRune(trailingDotReplacement)
+			} else {
+				builder.WriteRune(trailingSpaceReplacement)
+			}
+			continue
+		}
+		if _, ok := nameDecodingMap[r]; ok || r == nameEncodingQuote {
+			builder.WriteRune(nameEncodingQuote)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// DecodeFileName 还原 EncodeFileName 编码的文件名
+func DecodeFileName(name string) string {
+	runes := []rune(name)
+	builder := &strings.Builder{}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == nameEncodingQuote && i+1 < len(runes) {
+			i++
+			builder.WriteRune(runes[i])
+			continue
+		}
+		if v, ok := nameDecodingMap[r]; ok {
+			builder.WriteRune(v)
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// EncodePath 按"/"分隔，编码路径中的每一级文件名
+func EncodePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = EncodeFileName(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+// DecodePath 还原 EncodePath 编码的路径
+func DecodePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = DecodeFileName(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
@@ -15,6 +15,7 @@
 package apiutil
 
 import (
+	"errors"
 	"fmt"
 	jsoniter "github.com/json-iterator/go"
 	uuid "github.com/satori/go.uuid"
@@ -22,10 +23,35 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
 	FileNameSpecialChars = "\\/:*?\"<>|"
+
+	// MaxFileNameLength 文件名最大长度，UTF-8编码的字节数
+	MaxFileNameLength = 1024
+
+	// DefaultTimeLayout 默认的时间格式，实体中的时间字符串都使用该格式
+	DefaultTimeLayout = "2006-01-02 15:04:05"
+)
+
+var (
+	// ErrFileNameEmpty 文件名为空
+	ErrFileNameEmpty = errors.New("文件名不能为空")
+	// ErrFileNameTooLong 文件名超过最大长度
+	ErrFileNameTooLong = fmt.Errorf("文件名长度不能超过%d字节", MaxFileNameLength)
+	// ErrFileNameSpecialChars 文件名包含特殊字符
+	ErrFileNameSpecialChars = errors.New("文件名不能包含特殊字符：" + FileNameSpecialChars)
+	// ErrFileNameControlChars 文件名包含控制字符
+	ErrFileNameControlChars = errors.New("文件名不能包含控制字符")
+	// ErrFileNameReserved 文件名为保留名称
+	ErrFileNameReserved = errors.New("文件名不能为 . 或者 ..")
+	// ErrFileNameTrailing 文件名以"."或者空格结尾
+	ErrFileNameTrailing = errors.New("文件名不能以 . 或者空格结尾")
+	// ErrFileNameInvalidUtf8 文件名不是有效的UTF-8编码
+	ErrFileNameInvalidUtf8 = errors.New("文件名不是有效的UTF-8编码")
 )
 
 func init() {
@@ -57,12 +83,70 @@ func Uuid() string {
 	return u4.String()
 }
 
-// CheckFileNameValid 检测文件名是否有效，包含特殊字符则无效
+// CheckFileNameValid 检测文件名是否有效，不符合 ValidateFileName 规则则无效。空字符串代表不指定文件名，视为有效
 func CheckFileNameValid(name string) bool {
 	if name == "" {
 		return true
 	}
-	return !strings.ContainsAny(name, FileNameSpecialChars)
+	return ValidateFileName(name) == nil
+}
+
+// ValidateFileName 按网盘的命名规则检测文件名，返回不符合的原因，上传前检测可以避免无效的上传请求
+func ValidateFileName(name string) error {
+	if name == "" {
+		return ErrFileNameEmpty
+	}
+	if !utf8.ValidString(name) {
+		return ErrFileNameInvalidUtf8
+	}
+	if len(name) > MaxFileNameLength {
+		return ErrFileNameTooLong
+	}
+	if name == "." || name == ".." {
+		return ErrFileNameReserved
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return ErrFileNameTrailing
+	}
+	if strings.ContainsAny(name, FileNameSpecialChars) {
+		return ErrFileNameSpecialChars
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return ErrFileNameControlChars
+		}
+	}
+	return nil
+}
+
+// NormalizeFileName 把文件名转换为符合网盘命名规则的名称：特殊字符和控制字符替换为"_"，去掉首尾空格和末尾的"."，超长则截断。
+// 和 EncodeFileName 不同，转换是不可还原的
+func NormalizeFileName(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(FileNameSpecialChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if len(name) > MaxFileNameLength {
+		// 保留扩展名，按字符边界截断
+		ext := ""
+		if idx := strings.LastIndex(name, "."); idx > 0 && len(name)-idx <= 16 {
+			ext = name[idx:]
+		}
+		base := name[:MaxFileNameLength-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		name = "_"
+	}
+	return name
 }
 
 // UtcTime2LocalFormat UTC时间转换为本地时间
@@ -75,6 +159,18 @@ func UtcTime2LocalFormat(timeStr string) string {
 	return time.Unix(timeUint, 0).Format("2006-01-02 15:04:05")
 }
 
+// ParseUtcTime 解析服务器返回的UTC时间并转换为本地时区，为空或者格式错误时返回零值
+func ParseUtcTime(timeStr string) time.Time {
+	if timeStr == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		return time.Time{}
+	}
+	return t.In(time.Local)
+}
+
 // LocalTime2UtcFormat 本地时间转换为UTC时间
 func LocalTime2UtcFormat(utcTimeStr string) string {
 	if utcTimeStr == "" {
@@ -89,6 +185,62 @@ func UnixTime2LocalFormat(unixTime int64) string {
 	return time.Unix(unixTime/1000, 0).Format("2006-01-02 15:04:05")
 }
 
+// TimeFormat 时间转换选项，零值和 UtcTime2LocalFormat 一致：进程本地时区、DefaultTimeLayout 格式。
+// 运行在UTC时区的服务可以指定用户所在的时区，保证展示给用户的时间一致
+type TimeFormat struct {
+	// Location 目标时区，为nil代表进程本地时区
+	Location *time.Location
+	// Layout 时间格式，为空代表 DefaultTimeLayout
+	Layout string
+}
+
+func (tf TimeFormat) location() *time.Location {
+	if tf.Location == nil {
+		return time.Local
+	}
+	return tf.Location
+}
+
+// Format 把时间转换到目标时区并格式化，零值返回空字符串
+func (tf TimeFormat) Format(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	layout := tf.Layout
+	if layout == "" {
+		layout = DefaultTimeLayout
+	}
+	return t.In(tf.location()).Format(layout)
+}
+
+// Time 解析服务器返回的UTC时间并转换到目标时区，为空或者格式错误时返回零值
+func (tf TimeFormat) Time(utcTimeStr string) time.Time {
+	t := ParseUtcTime(utcTimeStr)
+	if t.IsZero() {
+		return t
+	}
+	return t.In(tf.location())
+}
+
+// FormatUtc 服务器返回的UTC时间转换为目标时区和格式，为空或者格式错误时返回空字符串
+func (tf TimeFormat) FormatUtc(utcTimeStr string) string {
+	return tf.Format(tf.Time(utcTimeStr))
+}
+
+// FormatLocal 实体中 UtcTime2LocalFormat 转换出的本地时间字符串转换为目标时区和格式，格式错误时返回空字符串
+func (tf TimeFormat) FormatLocal(localTimeStr string) string {
+	t, err := time.ParseInLocation(DefaultTimeLayout, localTimeStr, time.Local)
+	if err != nil {
+		return ""
+	}
+	return tf.Format(t)
+}
+
+// FormatUnix 毫秒时间戳转换为目标时区和格式
+func (tf TimeFormat) FormatUnix(unixTime int64) string {
+	return tf.Format(time.Unix(unixTime/1000, 0))
+}
+
 // AddCommonHeader 增加公共header
 func AddCommonHeader(headers map[string]string) map[string]string {
 	commonHeaders := map[string]string{
@@ -17,7 +17,9 @@ package apiutil
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRand(t *testing.T) {
@@ -36,6 +38,17 @@ func TestUtcTime2LocalFormat(t *testing.T) {
 	fmt.Println(r) // 2021-07-30 07:18:07
 }
 
+func TestTimeFormat(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	tf := TimeFormat{Location: shanghai}
+	assert.Equal(t, "2021-07-30 07:18:07", tf.FormatUtc("2021-07-29T23:18:07.000Z"))
+	assert.Equal(t, "2021-07-29T23:18:07Z", TimeFormat{Location: time.UTC, Layout: time.RFC3339}.FormatUtc("2021-07-29T23:18:07.000Z"))
+	assert.Equal(t, "", tf.FormatUtc("bad"))
+	assert.Equal(t, shanghai, tf.Time("2021-07-29T23:18:07.000Z").Location())
+	assert.Equal(t, "2021-07-30 07:18:07", tf.FormatLocal(UtcTime2LocalFormat("2021-07-29T23:18:07.000Z")))
+	assert.Equal(t, "2022-04-24 17:43:53", tf.FormatUnix(1650793433058))
+}
+
 func TestLocalTime2UtcFormat(t *testing.T) {
 	r := LocalTime2UtcFormat("2021-07-30 07:18:07")
 	fmt.Println(r) // 2021-07-29T23:18:07.000Z
@@ -45,3 +58,79 @@ func TestUnixTime2LocalFormat(t *testing.T) {
 	r := UnixTime2LocalFormat(1650793433058)
 	fmt.Println(r) // 2022-04-24 17:43:53
 }
+
+func TestComputeHashes(t *testing.T) {
+	sha1Str, crc64Str, err := ComputeHashes(strings.NewReader("123456789"))
+	assert.Nil(t, err)
+	assert.Equal(t, "f7c3bc1d808e04732adf679965ccc34ca7ae3441", sha1Str)
+	assert.Equal(t, "11051210869376104954", crc64Str)
+	assert.Equal(t, "995dc9bbdf1939fa", Crc64ToHex(crc64Str))
+}
+
+func TestEncodeFileName(t *testing.T) {
+	names := []string{"a:b*c?.txt", "dir.", "name. . ", "a＼b‛", "．txt", "normal.txt", ""}
+	for _, name := range names {
+		encoded := EncodeFileName(name)
+		assert.True(t, CheckFileNameValid(encoded))
+		assert.Equal(t, name, DecodeFileName(encoded))
+	}
+	assert.Equal(t, "a：b＊c？.txt", EncodeFileName("a:b*c?.txt"))
+	assert.Equal(t, "dir．", EncodeFileName("dir."))
+	assert.Equal(t, "/a/c：", EncodePath("/a/c:"))
+	assert.Equal(t, "/x?/y", DecodePath(EncodePath("/x?/y")))
+}
+
+func TestValidateFileName(t *testing.T) {
+	assert.Nil(t, ValidateFileName("报告 2021.docx"))
+	assert.Equal(t, ErrFileNameEmpty, ValidateFileName(""))
+	assert.Equal(t, ErrFileNameReserved, ValidateFileName(".."))
+	assert.Equal(t, ErrFileNameSpecialChars, ValidateFileName("a:b"))
+	assert.Equal(t, ErrFileNameControlChars, ValidateFileName("a\tb"))
+	assert.Equal(t, ErrFileNameTooLong, ValidateFileName(strings.Repeat("a", MaxFileNameLength+1)))
+	assert.Equal(t, ErrFileNameTrailing, ValidateFileName("a."))
+	assert.Equal(t, ErrFileNameTrailing, ValidateFileName("a "))
+	assert.Equal(t, ErrFileNameTrailing, ValidateFileName("a. ."))
+	assert.Nil(t, ValidateFileName(".hidden"))
+	assert.Nil(t, ValidateFileName(" a.txt"))
+
+	assert.Equal(t, "a_b_.txt", NormalizeFileName(" a:b?.txt "))
+	assert.Equal(t, "_", NormalizeFileName(".."))
+	assert.Equal(t, "a", NormalizeFileName("a. ."))
+	assert.Nil(t, ValidateFileName(NormalizeFileName("a"+strings.Repeat(" .", MaxFileNameLength))))
+	long := NormalizeFileName(strings.Repeat("文", MaxFileNameLength) + ".txt")
+	assert.Nil(t, ValidateFileName(long))
+	assert.True(t, strings.HasSuffix(long, ".txt"))
+}
+
+func TestParseUtcTime(t *testing.T) {
+	r := ParseUtcTime("2021-07-29T23:18:07.000Z")
+	assert.Equal(t, "2021-07-29 23:18:07", r.UTC().Format("2006-01-02 15:04:05"))
+	assert.True(t, ParseUtcTime("").IsZero())
+	assert.True(t, ParseUtcTime("invalid").IsZero())
+}
+
+func TestDrivePath(t *testing.T) {
+	assert.Equal(t, "/a", JoinDrivePath("/", "a"))
+	assert.Equal(t, "/", JoinDrivePath("", ""))
+	assert.Equal(t, "/目录/../文件.txt", JoinDrivePath("/目录/", "..", "/文件.txt"))
+	assert.Equal(t, "/a/b", JoinDrivePath("a", "b"))
+
+	rel, ok := RelDrivePath("/a", "/a/b/c")
+	assert.True(t, ok)
+	assert.Equal(t, "b/c", rel)
+	_, ok = RelDrivePath("/a", "/ab/c")
+	assert.False(t, ok)
+	rel, ok = RelDrivePath("/", "/中文")
+	assert.True(t, ok)
+	assert.Equal(t, "中文", rel)
+
+	dir, name := SplitDrivePath("/a/中文.txt")
+	assert.Equal(t, "/a", dir)
+	assert.Equal(t, "中文.txt", name)
+	dir, name = SplitDrivePath("/a")
+	assert.Equal(t, "/", dir)
+	assert.Equal(t, "a", name)
+	dir, name = SplitDrivePath("/")
+	assert.Equal(t, "/", dir)
+	assert.Equal(t, "", name)
+}
@@ -16,6 +16,13 @@
 package aliyunpan
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
 	"github.com/tickstep/library-go/requester"
 )
 
@@ -23,9 +30,137 @@ type (
 	AppLoginToken struct {
 		AccessToken string `json:"accessToken"`
 		RefreshToken string `json:"refreshToken"`
+		// ExpireTime access token 过期时间，格式为 2006-01-02 15:04:05，为空代表未知
+		ExpireTime string `json:"expireTime"`
 	}
 )
 
+const (
+	// appUserAgent 手机客户端请求使用的 user-agent
+	appUserAgent = "AliApp(AYSD/5.8.0) com.alicloud.databox/34760760 Channel/36176727979800@rimet_android_5.8.0 language/zh-CN /Android Mobile/Xiaomi Redmi"
+	// appCanary 手机客户端请求使用的 x-canary
+	appCanary = "client=Android,app=adrive,version=v5.8.0"
+)
+
 var (
 	appClient = requester.NewHTTPClient()
 )
+
+// GetAuthorizationStr 手机客户端 token 的授权信息
+func (a *AppLoginToken) GetAuthorizationStr() string {
+	return "Bearer " + a.AccessToken
+}
+
+// webLoginToken 转换为客户端内部使用的 token，手机客户端和网页版的授权信息格式相同
+func (a *AppLoginToken) webLoginToken() WebLoginToken {
+	return WebLoginToken{
+		AccessTokenType: "Bearer",
+		AccessToken:     a.AccessToken,
+		RefreshToken:    a.RefreshToken,
+		ExpireTime:      a.ExpireTime,
+	}
+}
+
+// GetAppAccessTokenFromRefreshToken 使用手机客户端的 refresh token 获取新的 token
+func GetAppAccessTokenFromRefreshToken(refreshToken string) (*AppLoginToken, *apierror.ApiError) {
+	header := map[string]string{}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/account/token", AUTH_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+	postData := map[string]string{
+		"refresh_token": refreshToken,
+		"grant_type":    "refresh_token",
+	}
+
+	body, err := appClient.Fetch("POST", fullUrl.String(), postData, appHeader(apiutil.AddCommonHeader(header)))
+	if err != nil {
+		logger.Verboseln("get app access token error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	r := &refreshTokenResult{}
+	if err1 := json.Unmarshal(body, r); err1 != nil {
+		logger.Verboseln("parse refresh token result json error ", err1)
+		return nil, apierror.NewFailedApiError(err1.Error())
+	}
+	return &AppLoginToken{
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+		ExpireTime:   apiutil.UtcTime2LocalFormat(r.ExpireTime),
+	}, nil
+}
+
+// refreshAppToken 手机客户端 token 的自动刷新函数
+func refreshAppToken(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
+	t, err := GetAppAccessTokenFromRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	w := t.webLoginToken()
+	return &w, nil
+}
+
+// NewPanClientWithAppToken 使用从安卓/iOS客户端获取的 token 创建客户端。请求使用手机客户端的接口地址和请求头，
+// 开启自动刷新时使用手机客户端的方式刷新 token，刷新回调中收到的 WebLoginToken 可以通过 AppToken 转换
+func NewPanClientWithAppToken(token AppLoginToken) *PanClient {
+	pc := NewPanClient(token.webLoginToken(), token)
+	pc.appMode = true
+	return pc
+}
+
+// IsAppMode 是否使用手机客户端 token
+func (pc *PanClient) IsAppMode() bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.appMode
+}
+
+// AppToken 返回手机客户端 token，自动刷新后为刷新得到的 token。不是手机客户端模式时返回创建时传入的 token
+func (pc *PanClient) AppToken() AppLoginToken {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if !pc.appMode {
+		return pc.appToken
+	}
+	return AppLoginToken{
+		AccessToken:  pc.webToken.AccessToken,
+		RefreshToken: pc.webToken.RefreshToken,
+		ExpireTime:   pc.webToken.ExpireTime,
+	}
+}
+
+// UpdateAppToken 更新手机客户端 token
+func (pc *PanClient) UpdateAppToken(token AppLoginToken) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.appToken = token
+	if pc.appMode {
+		pc.webToken = token.webLoginToken()
+	}
+}
+
+// appRequest 手机客户端模式下把网页版接口地址替换为手机客户端接口地址，并使用手机客户端的请求头
+func (pc *PanClient) appRequest(urlStr string, header map[string]string) (string, map[string]string) {
+	if !pc.IsAppMode() {
+		return urlStr, header
+	}
+	if strings.HasPrefix(urlStr, API_URL) {
+		urlStr = APP_API_URL + strings.TrimPrefix(urlStr, API_URL)
+	}
+	return urlStr, appHeader(copyHeader(header))
+}
+
+// appHeader 替换为手机客户端的请求头，会修改传入的 header
+func appHeader(header map[string]string) map[string]string {
+	delete(header, "origin")
+	delete(header, "referer")
+	header["user-agent"] = appUserAgent
+	header["x-canary"] = appCanary
+	return header
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// BrowserSession 从已登录的浏览器导出的网页版会话，用于无法扫码登录的无界面环境
+	BrowserSession struct {
+		// LocalStorage 网盘网页 localStorage 中的键值，登录信息保存在 token 键中
+		LocalStorage map[string]string
+		// Cookies 导出的网盘网站 cookie，只使用 aliyundrive.com 和 alipan.com 的 cookie
+		Cookies []*http.Cookie
+	}
+
+	// SessionImporter 导入浏览器会话，通过探测请求确认登录信息有效
+	SessionImporter struct {
+		// refresh 使用 refresh token 获取新的 token
+		refresh aliyunpan.TokenRefreshFunc
+		// probe 探测请求，token 有效时返回nil
+		probe func(token aliyunpan.WebLoginToken) *apierror.ApiError
+	}
+
+	// localStorageToken 网页 localStorage 中 token 键保存的登录信息
+	localStorageToken struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		TokenType    string      `json:"token_type"`
+		ExpiresIn    json.Number `json:"expires_in"`
+		ExpireTime   string      `json:"expire_time"`
+	}
+
+	// exportedCookie 浏览器扩展导出的 JSON 格式 cookie
+	exportedCookie struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Domain string `json:"domain"`
+		Path   string `json:"path"`
+	}
+)
+
+var (
+	// ErrNoSessionToken 导出的浏览器数据中没有登录信息
+	ErrNoSessionToken = errors.New("浏览器数据中没有找到登录信息，请确认已经登录网盘网页版")
+)
+
+// sessionCookieDomains 保存登录信息的网盘网站，其他网站的同名 cookie 会被忽略
+var sessionCookieDomains = []string{"aliyundrive.com", "alipan.com"}
+
+// NewSessionImporter 创建浏览器会话导入，使用网页版接口探测登录信息
+func NewSessionImporter() *SessionImporter {
+	return &SessionImporter{
+		refresh: aliyunpan.GetAccessTokenFromRefreshToken,
+		probe: func(token aliyunpan.WebLoginToken) *apierror.ApiError {
+			_, err := aliyunpan.NewPanClient(token, aliyunpan.AppLoginToken{}).GetUserInfo()
+			return err
+		},
+	}
+}
+
+// ParseLocalStorage 解析浏览器开发者工具中导出的 localStorage，格式为 JSON 对象，
+// 例如在控制台执行 JSON.stringify(localStorage) 的结果。值不是字符串时保留原始 JSON
+func ParseLocalStorage(data string) (map[string]string, error) {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &raw); err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(raw))
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			result[k] = s
+		} else {
+			result[k] = string(v)
+		}
+	}
+	return result, nil
+}
+
+// ParseCookies 解析导出的 cookie，支持浏览器扩展导出的 JSON 数组、Netscape 格式的 cookies.txt
+// 和请求头中的 "name=value; name2=value2" 格式
+func ParseCookies(data string) ([]*http.Cookie, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(data, "[") {
+		var exported []exportedCookie
+		if err := json.Unmarshal([]byte(data), &exported); err != nil {
+			return nil, err
+		}
+		cookies := make([]*http.Cookie, 0, len(exported))
+		for _, c := range exported {
+			cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value, Domain: c.Domain, Path: c.Path})
+		}
+		return cookies, nil
+	}
+	if strings.Contains(data, "\t") {
+		// Netscape 格式：domain flag path secure expiration name value
+		var cookies []*http.Cookie
+		scanner := bufio.NewScanner(strings.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "#HttpOnly_"))
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			fields := strings.Split(line, "\t")
+			if len(fields) < 7 {
+				continue
+			}
+			cookies = append(cookies, &http.Cookie{Name: fields[5], Value: fields[6], Domain: fields[0], Path: fields[2]})
+		}
+		return cookies, scanner.Err()
+	}
+	header := http.Header{}
+	header.Add("Cookie", data)
+	return (&http.Request{Header: header}).Cookies(), nil
+}
+
+// Token 从导出的数据中提取登录信息，不请求接口。只有 refresh token 时返回的 AccessToken 为空
+func (s *BrowserSession) Token() (*aliyunpan.WebLoginToken, error) {
+	if v, ok := s.LocalStorage["token"]; ok {
+		t := &localStorageToken{}
+		if err := json.Unmarshal([]byte(v), t); err == nil && (t.AccessToken != "" || t.RefreshToken != "") {
+			tokenType := t.TokenType
+			if tokenType == "" {
+				tokenType = "Bearer"
+			}
+			expiresIn, _ := strconv.Atoi(t.ExpiresIn.String())
+			return &aliyunpan.WebLoginToken{
+				AccessTokenType: tokenType,
+				AccessToken:     t.AccessToken,
+				RefreshToken:    t.RefreshToken,
+				ExpiresIn:       expiresIn,
+				ExpireTime:      apiutil.UtcTime2LocalFormat(t.ExpireTime),
+			}, nil
+		}
+	}
+	accessToken := s.lookup("access_token")
+	refreshToken := s.lookup("refresh_token")
+	if accessToken == "" && refreshToken == "" {
+		return nil, ErrNoSessionToken
+	}
+	return &aliyunpan.WebLoginToken{
+		AccessTokenType: "Bearer",
+		AccessToken:     accessToken,
+		RefreshToken:    refreshToken,
+	}, nil
+}
+
+// lookup 依次在 localStorage 和网盘网站的 cookie 中查找 key
+func (s *BrowserSession) lookup(key string) string {
+	if v := s.LocalStorage[key]; v != "" {
+		return v
+	}
+	for _, c := range s.Cookies {
+		if c != nil && c.Name == key && c.Value != "" && isSessionCookieDomain(c.Domain) {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// isSessionCookieDomain cookie 是否属于网盘网站。请求头格式的 cookie 没有域名，认为属于网盘网站
+func isSessionCookieDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	if domain == "" {
+		return true
+	}
+	for _, d := range sessionCookieDomains {
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// Import 从浏览器会话中提取登录信息，并通过探测请求确认有效。access token 缺少、过期或者探测返回 token 失效时，
+// 使用 refresh token 获取新的 token 后再次探测，刷新后浏览器中的 refresh token 失效。返回可以用于创建 PanClient 的 token
+func (i *SessionImporter) Import(s *BrowserSession) (*aliyunpan.WebLoginToken, *apierror.ApiError) {
+	token, err := s.Token()
+	if err != nil {
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+	if token.AccessToken != "" && !expired(token) {
+		probeErr := i.probe(*token)
+		if probeErr == nil {
+			return token, nil
+		}
+		if token.RefreshToken == "" || (probeErr.Code != apierror.ApiCodeAccessTokenInvalid && probeErr.Code != apierror.ApiCodeTokenExpiredCode) {
+			// 不是 token 失效的错误，刷新也无法解决
+			return nil, probeErr
+		}
+	}
+	if token.RefreshToken == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeTokenExpiredCode, "access token 已过期，并且没有 refresh token")
+	}
+	refreshed, apiErr := i.refresh(token.RefreshToken)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr = i.probe(*refreshed); apiErr != nil {
+		return nil, apiErr
+	}
+	return refreshed, nil
+}
+
+// ImportBrowserSession 使用默认的 SessionImporter 导入浏览器会话
+func ImportBrowserSession(s *BrowserSession) (*aliyunpan.WebLoginToken, *apierror.ApiError) {
+	return NewSessionImporter().Import(s)
+}
+
+// expired token 记录了过期时间并且已经过期
+func expired(token *aliyunpan.WebLoginToken) bool {
+	expiresAt := token.ExpiresAt()
+	return !expiresAt.IsZero() && time.Now().After(expiresAt)
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestParseCookies(t *testing.T) {
+	cookies, err := ParseCookies(`[{"name":"refresh_token","value":"rt","domain":".aliyundrive.com","path":"/"}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(cookies))
+	assert.Equal(t, "rt", cookies[0].Value)
+
+	cookies, err = ParseCookies("# Netscape HTTP Cookie File\n.aliyundrive.com\tTRUE\t/\tTRUE\t0\taccess_token\tat\n#HttpOnly_.aliyundrive.com\tTRUE\t/\tTRUE\t0\trefresh_token\trt\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(cookies))
+	assert.Equal(t, "refresh_token", cookies[1].Name)
+
+	cookies, err = ParseCookies("a=1; refresh_token=rt")
+	assert.NoError(t, err)
+	assert.Equal(t, "rt", (&BrowserSession{Cookies: cookies}).lookup("refresh_token"))
+
+	_, err = ParseCookies("[not json")
+	assert.Error(t, err)
+}
+
+func TestBrowserSessionCookieDomain(t *testing.T) {
+	cookies, err := ParseCookies(`[{"name":"access_token","value":"evil","domain":".example.com","path":"/"},` +
+		`{"name":"refresh_token","value":"evil","domain":"aliyundrive.com.example.com","path":"/"},` +
+		`{"name":"access_token","value":"at","domain":"www.alipan.com","path":"/"}]`)
+	assert.NoError(t, err)
+	s := &BrowserSession{Cookies: cookies}
+	// 其他网站的同名 cookie 被忽略
+	assert.Equal(t, "at", s.lookup("access_token"))
+	assert.Equal(t, "", s.lookup("refresh_token"))
+
+	_, err = (&BrowserSession{Cookies: cookies[:2]}).Token()
+	assert.Equal(t, ErrNoSessionToken, err)
+}
+
+func TestBrowserSessionToken(t *testing.T) {
+	storage, err := ParseLocalStorage(`{"token":"{\"access_token\":\"at\",\"refresh_token\":\"rt\",\"token_type\":\"Bearer\",\"expires_in\":7200,\"expire_time\":\"2099-01-01T00:00:00Z\"}","other":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", storage["other"])
+
+	token, err := (&BrowserSession{LocalStorage: storage}).Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "at", token.AccessToken)
+	assert.Equal(t, "rt", token.RefreshToken)
+	assert.Equal(t, 7200, token.ExpiresIn)
+	assert.False(t, token.ExpiresAt().IsZero())
+
+	_, err = (&BrowserSession{}).Token()
+	assert.Equal(t, ErrNoSessionToken, err)
+}
+
+func TestSessionImporter(t *testing.T) {
+	var probed []string
+	refreshed := 0
+	i := &SessionImporter{
+		refresh: func(refreshToken string) (*aliyunpan.WebLoginToken, *apierror.ApiError) {
+			refreshed++
+			assert.Equal(t, "rt", refreshToken)
+			return &aliyunpan.WebLoginToken{AccessTokenType: "Bearer", AccessToken: "new", RefreshToken: "rt2"}, nil
+		},
+		probe: func(token aliyunpan.WebLoginToken) *apierror.ApiError {
+			probed = append(probed, token.AccessToken)
+			if token.AccessToken != "new" && token.AccessToken != "valid" {
+				return apierror.NewApiError(apierror.ApiCodeAccessTokenInvalid, "AccessToken is invalid")
+			}
+			return nil
+		},
+	}
+
+	// access token 有效时直接使用
+	token, err := i.Import(&BrowserSession{LocalStorage: map[string]string{"access_token": "valid", "refresh_token": "rt"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "valid", token.AccessToken)
+	assert.Equal(t, 0, refreshed)
+
+	// access token 失效时刷新后再次探测
+	token, err = i.Import(&BrowserSession{LocalStorage: map[string]string{"access_token": "old", "refresh_token": "rt"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "new", token.AccessToken)
+	assert.Equal(t, []string{"valid", "old", "new"}, probed)
+
+	// 只有 refresh token
+	token, err = i.Import(&BrowserSession{LocalStorage: map[string]string{"refresh_token": "rt"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "rt2", token.RefreshToken)
+	assert.Equal(t, 2, refreshed)
+
+	// 没有 refresh token 时返回探测错误
+	_, err = i.Import(&BrowserSession{LocalStorage: map[string]string{"access_token": "old"}})
+	assert.Equal(t, apierror.ApiCode(apierror.ApiCodeAccessTokenInvalid), err.Code)
+
+	_, err = i.Import(&BrowserSession{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth 阿里云盘网页版扫码登录和浏览器会话导入，获取创建 PanClient 需要的 WebLoginToken
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"github.com/tickstep/library-go/requester"
+)
+
+type (
+	// QrCodeLogin 网页版扫码登录
+	QrCodeLogin struct {
+		client *requester.HTTPClient
+		// passportUrl 登录接口地址，默认为 PassportUrl
+		passportUrl string
+	}
+
+	// QrCode 登录二维码
+	QrCode struct {
+		// CodeContent 二维码内容，生成二维码图片展示给用户使用阿里云盘App扫码
+		CodeContent string `json:"codeContent"`
+		// T 和 Ck 用于查询扫码状态
+		T  string `json:"t"`
+		Ck string `json:"ck"`
+	}
+
+	// QrCodeStatus 二维码扫码状态
+	QrCodeStatus string
+
+	// QrCodeStatusResult 二维码扫码状态查询结果
+	QrCodeStatusResult struct {
+		Status QrCodeStatus
+		// Token 登录成功后的token，状态为 CONFIRMED 时才有
+		Token *aliyunpan.WebLoginToken
+	}
+
+	passportResult struct {
+		Content struct {
+			Data    json.RawMessage `json:"data"`
+			Success bool            `json:"success"`
+		} `json:"content"`
+		HasError bool `json:"hasError"`
+	}
+
+	qrCodeData struct {
+		T           json.Number `json:"t"`
+		Ck          string      `json:"ck"`
+		CodeContent string      `json:"codeContent"`
+		TitleMsg    string      `json:"titleMsg"`
+	}
+
+	qrCodeQueryData struct {
+		QrCodeStatus QrCodeStatus `json:"qrCodeStatus"`
+		BizExt       string       `json:"bizExt"`
+	}
+
+	// pdsLoginResult bizExt 中的登录结果
+	pdsLoginResult struct {
+		PdsLoginResult struct {
+			AccessToken  string `json:"accessToken"`
+			RefreshToken string `json:"refreshToken"`
+			TokenType    string `json:"tokenType"`
+			ExpiresIn    int    `json:"expiresIn"`
+			ExpireTime   string `json:"expireTime"`
+		} `json:"pds_login_result"`
+	}
+)
+
+const (
+	// PassportUrl 登录接口地址
+	PassportUrl = "https://passport.aliyundrive.com"
+
+	// QrCodeStatusNew 等待扫码
+	QrCodeStatusNew QrCodeStatus = "NEW"
+	// QrCodeStatusScanned 已扫码，等待用户确认
+	QrCodeStatusScanned QrCodeStatus = "SCANED"
+	// QrCodeStatusConfirmed 用户已确认登录
+	QrCodeStatusConfirmed QrCodeStatus = "CONFIRMED"
+	// QrCodeStatusExpired 二维码已过期
+	QrCodeStatusExpired QrCodeStatus = "EXPIRED"
+	// QrCodeStatusCanceled 用户取消登录
+	QrCodeStatusCanceled QrCodeStatus = "CANCELED"
+)
+
+// NewQrCodeLogin 创建扫码登录
+func NewQrCodeLogin() *QrCodeLogin {
+	return &QrCodeLogin{
+		client:      aliyunpan.NewHTTPClient(),
+		passportUrl: PassportUrl,
+	}
+}
+
+// doRequest 请求登录接口，解析返回的 content.data 到 result
+func (l *QrCodeLogin) doRequest(method, path string, postData map[string]string, result interface{}) *apierror.ApiError {
+	header := map[string]string{
+		"referer": aliyunpan.WEB_URL + "/",
+	}
+	if postData != nil {
+		header["content-type"] = "application/x-www-form-urlencoded"
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s%s", l.passportUrl, path)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	// request
+	var post interface{}
+	if postData != nil {
+		post = postData
+	}
+	body, err := l.client.Fetch(method, fullUrl.String(), post, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("qrcode login request error ", err)
+		return apierror.NewFailedApiError(err.Error())
+	}
+
+	// parse result
+	r := &passportResult{}
+	if err1 := json.Unmarshal(body, r); err1 != nil {
+		logger.Verboseln("parse qrcode login result json error ", err1)
+		return apierror.NewFailedApiError(err1.Error())
+	}
+	if r.HasError || len(r.Content.Data) == 0 {
+		return apierror.NewFailedApiError("登录接口返回错误：" + string(body))
+	}
+	if err2 := json.Unmarshal(r.Content.Data, result); err2 != nil {
+		logger.Verboseln("parse qrcode login data json error ", err2)
+		return apierror.NewFailedApiError(err2.Error())
+	}
+	return nil
+}
+
+// Generate 生成登录二维码
+func (l *QrCodeLogin) Generate() (*QrCode, *apierror.ApiError) {
+	r := &qrCodeData{}
+	if err := l.doRequest("GET", "/newlogin/qrcode/generate.do?appName=aliyun_drive&fromSite=52&appEntrance=web&isMobile=false&lang=zh_CN&returnUrl=&bizParams=", nil, r); err != nil {
+		return nil, err
+	}
+	if r.CodeContent == "" {
+		return nil, apierror.NewFailedApiError("获取二维码失败：" + r.TitleMsg)
+	}
+	return &QrCode{
+		CodeContent: r.CodeContent,
+		T:           r.T.String(),
+		Ck:          r.Ck,
+	}, nil
+}
+
+// Query 查询二维码扫码状态，用户确认后返回登录的token
+func (l *QrCodeLogin) Query(qr *QrCode) (*QrCodeStatusResult, *apierror.ApiError) {
+	postData := map[string]string{
+		"t":  qr.T,
+		"ck": qr.Ck,
+	}
+	r := &qrCodeQueryData{}
+	if err := l.doRequest("POST", "/newlogin/qrcode/query.do?appName=aliyun_drive&fromSite=52", postData, r); err != nil {
+		return nil, err
+	}
+	result := &QrCodeStatusResult{Status: r.QrCodeStatus}
+	if r.QrCodeStatus == QrCodeStatusConfirmed {
+		token, err := parseBizExt(r.BizExt)
+		if err != nil {
+			logger.Verboseln("parse qrcode login bizExt error ", err)
+			return nil, apierror.NewFailedApiError("解析登录结果失败：" + err.Error())
+		}
+		result.Token = token
+	}
+	return result, nil
+}
+
+// Wait 轮询二维码扫码状态，用户确认登录后返回token。interval 为轮询间隔，默认为2秒。
+// onStatus 不为nil时每次查询到状态都会回调，可以用于提示用户；二维码过期、用户取消或者 ctx 取消时返回错误
+func (l *QrCodeLogin) Wait(ctx context.Context, qr *QrCode, interval time.Duration, onStatus func(status QrCodeStatus)) (*aliyunpan.WebLoginToken, *apierror.ApiError) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r, err := l.Query(qr)
+		if err != nil {
+			return nil, err
+		}
+		if onStatus != nil {
+			onStatus(r.Status)
+		}
+		switch r.Status {
+		case QrCodeStatusConfirmed:
+			return r.Token, nil
+		case QrCodeStatusExpired:
+			return nil, apierror.NewFailedApiError("二维码已过期")
+		case QrCodeStatusCanceled:
+			return nil, apierror.NewFailedApiError("用户取消登录")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, apierror.NewApiErrorWithError(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseBizExt 解析登录成功后返回的 bizExt，内容为base64编码的JSON
+func parseBizExt(bizExt string) (*aliyunpan.WebLoginToken, error) {
+	data, err := base64.StdEncoding.DecodeString(bizExt)
+	if err != nil {
+		return nil, err
+	}
+	r := &pdsLoginResult{}
+	if err = json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	t := r.PdsLoginResult
+	if t.AccessToken == "" || t.RefreshToken == "" {
+		return nil, fmt.Errorf("token not found")
+	}
+	tokenType := t.TokenType
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+	return &aliyunpan.WebLoginToken{
+		AccessTokenType: tokenType,
+		AccessToken:     t.AccessToken,
+		RefreshToken:    t.RefreshToken,
+		ExpiresIn:       t.ExpiresIn,
+		ExpireTime:      apiutil.UtcTime2LocalFormat(t.ExpireTime),
+	}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/library-go/requester"
+)
+
+const testBizExt = `{"pds_login_result":{"accessToken":"at","refreshToken":"rt","tokenType":"Bearer","expiresIn":7200,"expireTime":"2021-01-01T02:00:00Z"}}`
+
+func TestParseBizExt(t *testing.T) {
+	token, err := parseBizExt(base64.StdEncoding.EncodeToString([]byte(testBizExt)))
+	assert.NoError(t, err)
+	assert.Equal(t, "at", token.AccessToken)
+	assert.Equal(t, "rt", token.RefreshToken)
+	assert.Equal(t, "Bearer", token.AccessTokenType)
+	assert.Equal(t, 7200, token.ExpiresIn)
+
+	_, err = parseBizExt("not base64!")
+	assert.Error(t, err)
+	_, err = parseBizExt(base64.StdEncoding.EncodeToString([]byte(`{"pds_login_result":{}}`)))
+	assert.Error(t, err)
+}
+
+func TestQrCodeLoginWait(t *testing.T) {
+	var queries int32
+	bizExt := base64.StdEncoding.EncodeToString([]byte(testBizExt))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/newlogin/qrcode/generate.do":
+			fmt.Fprint(w, `{"content":{"data":{"t":1650000000000,"ck":"ck1","codeContent":"https://qr.example/abc"},"success":true},"hasError":false}`)
+		case "/newlogin/qrcode/query.do":
+			r.ParseForm()
+			assert.Equal(t, "1650000000000", r.PostForm.Get("t"))
+			assert.Equal(t, "ck1", r.PostForm.Get("ck"))
+			switch atomic.AddInt32(&queries, 1) {
+			case 1:
+				fmt.Fprint(w, `{"content":{"data":{"qrCodeStatus":"NEW"},"success":true},"hasError":false}`)
+			case 2:
+				fmt.Fprint(w, `{"content":{"data":{"qrCodeStatus":"SCANED"},"success":true},"hasError":false}`)
+			default:
+				fmt.Fprintf(w, `{"content":{"data":{"qrCodeStatus":"CONFIRMED","bizExt":"%s"},"success":true},"hasError":false}`, bizExt)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	l := &QrCodeLogin{client: requester.NewHTTPClient(), passportUrl: srv.URL}
+	qr, err := l.Generate()
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "https://qr.example/abc", qr.CodeContent)
+	assert.Equal(t, "1650000000000", qr.T)
+
+	var statuses []QrCodeStatus
+	token, err := l.Wait(context.Background(), qr, time.Millisecond, func(status QrCodeStatus) {
+		statuses = append(statuses, status)
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "at", token.AccessToken)
+	assert.Equal(t, []QrCodeStatus{QrCodeStatusNew, QrCodeStatusScanned, QrCodeStatusConfirmed}, statuses)
+}
+
+func TestQrCodeLoginWaitExpired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"content":{"data":{"qrCodeStatus":"EXPIRED"},"success":true},"hasError":false}`)
+	}))
+	defer srv.Close()
+
+	l := &QrCodeLogin{client: requester.NewHTTPClient(), passportUrl: srv.URL}
+	token, err := l.Wait(context.Background(), &QrCode{T: "1", Ck: "ck"}, time.Millisecond, nil)
+	assert.Nil(t, token)
+	assert.NotNil(t, err)
+}
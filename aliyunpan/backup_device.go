@@ -0,0 +1,100 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+)
+
+type (
+	// BackupDevice 备份到当前账号的设备
+	BackupDevice struct {
+		DeviceId string `json:"device_id"`
+		// DeviceName 设备名称，例如：iPhone 12
+		DeviceName string `json:"device_name"`
+		// Platform 平台，例如：ios / android / windows / mac
+		Platform string `json:"platform"`
+		// DriveId 备份所在的网盘ID
+		DriveId string `json:"drive_id"`
+		// RootFileId 设备备份的根文件夹ID
+		RootFileId string `json:"root_file_id"`
+		// UpdatedAt 最近一次备份时间
+		UpdatedAt string `json:"updated_at"`
+	}
+
+	backupDeviceListResult struct {
+		Items []*BackupDevice `json:"items"`
+	}
+)
+
+// RootFolder 返回设备备份根文件夹的文件信息，可以作为浏览该设备备份文件的起点
+func (d *BackupDevice) RootFolder() *FileEntity {
+	if d == nil {
+		return nil
+	}
+	return &FileEntity{
+		DriveId:      d.DriveId,
+		FileId:       d.RootFileId,
+		FileType:     "folder",
+		FileName:     d.DeviceName,
+		ParentFileId: DefaultRootParentFileId,
+		Path:         "/" + d.DeviceName,
+	}
+}
+
+// BackupDeviceList 获取备份到当前账号的设备列表
+func (p *PanClient) BackupDeviceList() ([]*BackupDevice, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v1/backup/device/list", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+	postData := map[string]string{}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get backup device list error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &backupDeviceListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse backup device list result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	devices := []*BackupDevice{}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		item.UpdatedAt = apiutil.UtcTime2LocalFormat(item.UpdatedAt)
+		devices = append(devices, item)
+	}
+	return devices, nil
+}
@@ -37,6 +37,17 @@ type (
 	}
 )
 
+// batchAsyncTaskId 获取批量请求中异步处理的任务ID
+func batchAsyncTaskId(item *BatchResponse) string {
+	if item == nil || item.Body == nil {
+		return ""
+	}
+	if id, ok := item.Body["async_task_id"].(string); ok {
+		return id
+	}
+	return ""
+}
+
 // BatchTask 批量请求任务。多选操作基本都是批量任务
 func (p *PanClient) BatchTask(url string, param *BatchRequestParam) (*BatchResponseResult, *apierror.ApiError) {
 	if param == nil {
@@ -45,7 +56,7 @@ func (p *PanClient) BatchTask(url string, param *BatchRequestParam) (*BatchRespo
 
 	// header
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -57,7 +68,7 @@ func (p *PanClient) BatchTask(url string, param *BatchRequestParam) (*BatchRespo
 	postData := param
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("batch request error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
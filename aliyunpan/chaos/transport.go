@@ -0,0 +1,304 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chaos 注入故障的 http.RoundTripper，用于验证应用（以及SDK自身的重试、断点续传逻辑）
+// 在网络延迟、限流、服务端错误、响应体截断和下载链接过期等情况下的表现。仅用于测试，例如：
+//
+//	injector := chaos.New(chaos.Config{ErrorRate: 0.1, BurstSize: 3, TruncateRate: 0.05})
+//	aliyunpan.SetTransportWrapper(injector.Wrap)
+package chaos
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Config 故障注入配置。各种故障的概率取值为0~1，为0时不注入该故障
+	Config struct {
+		// Seed 随机数种子，相同的种子和请求顺序得到相同的故障序列。为0时使用当前时间
+		Seed int64
+		// Match 为nil时所有请求都可能被注入故障，否则只对返回true的请求注入
+		Match func(req *http.Request) bool
+
+		// Latency 每个请求增加的固定延迟，LatencyJitter 为额外随机延迟的最大值
+		Latency       time.Duration
+		LatencyJitter time.Duration
+
+		// ErrorRate 直接返回错误状态码的概率，ErrorStatuses 为可选的状态码，默认为 429、500、502、503
+		ErrorRate     float64
+		ErrorStatuses []int
+		// BurstSize 触发错误后连续返回同一错误的请求数，用于模拟持续一段时间的限流或者服务故障，默认为1
+		BurstSize int
+		// RetryAfter 返回429时 Retry-After 响应头的值，为0时不设置
+		RetryAfter time.Duration
+
+		// TruncateRate 响应体被截断的概率。截断位置为响应体长度的一半（长度未知时不返回任何数据），之后读取返回 io.ErrUnexpectedEOF
+		TruncateRate float64
+
+		// ExpiredURLRate 对带签名的文件数据链接返回链接已过期（403 AccessDenied）的概率
+		ExpiredURLRate float64
+	}
+
+	// Stats 故障注入统计
+	Stats struct {
+		// Requests 经过的请求数
+		Requests int64
+		// Delayed 增加了延迟的请求数
+		Delayed int64
+		// Errors 返回错误状态码的请求数
+		Errors int64
+		// Truncated 响应体被截断的请求数
+		Truncated int64
+		// Expired 返回链接过期的请求数
+		Expired int64
+	}
+
+	// Injector 故障注入器。多个 Transport 共享同一个注入器时，共享随机序列、突发错误状态和统计
+	Injector struct {
+		mu      sync.Mutex
+		cfg     Config
+		rnd     *rand.Rand
+		enabled bool
+		stats   Stats
+
+		// burstStatus 正在突发的错误状态码，burstLeft 为剩余的请求数
+		burstStatus int
+		burstLeft   int
+	}
+
+	// Transport 注入故障的 http.RoundTripper
+	Transport struct {
+		injector *Injector
+		base     http.RoundTripper
+	}
+
+	// fault 单个请求要注入的故障
+	fault struct {
+		delay    time.Duration
+		status   int
+		expired  bool
+		truncate bool
+	}
+
+	// truncatedBody 只返回前 remain 个字节，之后返回 io.ErrUnexpectedEOF
+	truncatedBody struct {
+		rc     io.ReadCloser
+		remain int64
+	}
+)
+
+// DefaultErrorStatuses 默认注入的错误状态码
+var DefaultErrorStatuses = []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+
+const (
+	expiredURLBody = `<?xml version="1.0" encoding="UTF-8"?>
+<Error>
+  <Code>AccessDenied</Code>
+  <Message>Request has expired.</Message>
+</Error>`
+)
+
+// New 创建故障注入器，创建后即开启
+func New(cfg Config) *Injector {
+	if len(cfg.ErrorStatuses) == 0 {
+		cfg.ErrorStatuses = DefaultErrorStatuses
+	}
+	if cfg.BurstSize <= 0 {
+		cfg.BurstSize = 1
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{
+		cfg:     cfg,
+		rnd:     rand.New(rand.NewSource(seed)),
+		enabled: true,
+	}
+}
+
+// Wrap 包装 base，返回注入故障的 Transport。base 为nil时使用 http.DefaultTransport。
+// 签名和 aliyunpan.TransportWrapper 一致，可以直接传给 aliyunpan.SetTransportWrapper
+func (i *Injector) Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{injector: i, base: base}
+}
+
+// SetEnabled 开启或者关闭故障注入，关闭后请求直接透传，同时清除正在进行的突发错误
+func (i *Injector) SetEnabled(enabled bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.enabled = enabled
+	if !enabled {
+		i.burstLeft = 0
+	}
+}
+
+// Stats 返回故障注入统计
+func (i *Injector) Stats() Stats {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.stats
+}
+
+// plan 决定请求要注入的故障
+func (i *Injector) plan(req *http.Request) fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stats.Requests++
+	f := fault{}
+	if !i.enabled || (i.cfg.Match != nil && !i.cfg.Match(req)) {
+		return f
+	}
+
+	f.delay = i.cfg.Latency
+	if i.cfg.LatencyJitter > 0 {
+		f.delay += time.Duration(i.rnd.Int63n(int64(i.cfg.LatencyJitter)))
+	}
+	if f.delay > 0 {
+		i.stats.Delayed++
+	}
+
+	switch {
+	case i.burstLeft > 0:
+		i.burstLeft--
+		f.status = i.burstStatus
+	case i.hit(i.cfg.ErrorRate):
+		f.status = i.cfg.ErrorStatuses[i.rnd.Intn(len(i.cfg.ErrorStatuses))]
+		i.burstStatus = f.status
+		i.burstLeft = i.cfg.BurstSize - 1
+	case isSignedURL(req) && i.hit(i.cfg.ExpiredURLRate):
+		f.expired = true
+		i.stats.Expired++
+	case i.hit(i.cfg.TruncateRate):
+		f.truncate = true
+		i.stats.Truncated++
+	}
+	if f.status != 0 {
+		i.stats.Errors++
+	}
+	return f
+}
+
+func (i *Injector) hit(rate float64) bool {
+	return rate > 0 && i.rnd.Float64() < rate
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f := t.injector.plan(req)
+	if f.delay > 0 {
+		timer := time.NewTimer(f.delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			closeRequestBody(req)
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	switch {
+	case f.status != 0:
+		closeRequestBody(req)
+		resp := newResponse(req, f.status, "application/json", errorBody(f.status))
+		if f.status == http.StatusTooManyRequests && t.injector.cfg.RetryAfter > 0 {
+			resp.Header.Set("Retry-After", strconv.Itoa(int(t.injector.cfg.RetryAfter/time.Second)))
+		}
+		return resp, nil
+	case f.expired:
+		closeRequestBody(req)
+		return newResponse(req, http.StatusForbidden, "application/xml", expiredURLBody), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || !f.truncate {
+		return resp, err
+	}
+	remain := int64(0)
+	if resp.ContentLength > 0 {
+		remain = resp.ContentLength / 2
+	}
+	resp.Body = &truncatedBody{rc: resp.Body, remain: remain}
+	return resp, nil
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remain <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remain {
+		p = p[:b.remain]
+	}
+	n, err := b.rc.Read(p)
+	b.remain -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *truncatedBody) Close() error {
+	return b.rc.Close()
+}
+
+// isSignedURL 是否是带签名的文件数据链接（下载、上传链接）
+func isSignedURL(req *http.Request) bool {
+	for k := range req.URL.Query() {
+		switch strings.ToLower(k) {
+		case "x-oss-signature", "x-oss-expires", "signature", "expires":
+			return true
+		}
+	}
+	return false
+}
+
+// errorBody 错误状态码对应的响应体，格式和网盘接口的错误一致
+func errorBody(status int) string {
+	code := "InternalError"
+	if status == http.StatusTooManyRequests {
+		code = "TooManyRequests"
+	}
+	return fmt.Sprintf(`{"code":"%s","message":"%s"}`, code, http.StatusText(status))
+}
+
+func newResponse(req *http.Request, status int, contentType, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{contentType}},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
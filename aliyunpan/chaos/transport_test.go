@@ -0,0 +1,139 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func newTestServer(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		fmt.Fprint(w, "hello world")
+	}))
+}
+
+func TestErrorBurst(t *testing.T) {
+	var hits int32
+	srv := newTestServer(&hits)
+	defer srv.Close()
+
+	injector := New(Config{Seed: 1, ErrorRate: 1, ErrorStatuses: []int{http.StatusTooManyRequests}, BurstSize: 3, RetryAfter: 2 * time.Second})
+	c := &http.Client{Transport: injector.Wrap(nil)}
+	for n := 0; n < 3; n++ {
+		resp, err := c.Get(srv.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("Retry-After"))
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		apiErr := apierror.ParseCommonApiError(body)
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, apierror.ApiCodeQuotaExceeded, apiErr.Code)
+		}
+	}
+	assert.Equal(t, int32(0), hits)
+	assert.Equal(t, int64(3), injector.Stats().Errors)
+
+	// 关闭后直接透传
+	injector.SetEnabled(false)
+	resp, err := c.Get(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, int32(1), hits)
+}
+
+func TestTruncatedBody(t *testing.T) {
+	var hits int32
+	srv := newTestServer(&hits)
+	defer srv.Close()
+
+	c := &http.Client{Transport: New(Config{Seed: 1, TruncateRate: 1}).Wrap(nil)}
+	resp, err := c.Get(srv.URL)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, "hello", string(body))
+}
+
+func TestExpiredURL(t *testing.T) {
+	var hits int32
+	srv := newTestServer(&hits)
+	defer srv.Close()
+
+	injector := New(Config{Seed: 1, ExpiredURLRate: 1})
+	c := &http.Client{Transport: injector.Wrap(nil)}
+	resp, err := c.Get(srv.URL + "/file?x-oss-expires=1650000000&x-oss-signature=abc")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp.Body.Close()
+
+	// 非签名链接不受影响
+	resp, err = c.Get(srv.URL + "/adrive/v3/file/list")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	assert.Equal(t, int64(1), injector.Stats().Expired)
+	assert.Equal(t, int32(1), hits)
+}
+
+func TestLatencyAndMatch(t *testing.T) {
+	var hits int32
+	srv := newTestServer(&hits)
+	defer srv.Close()
+
+	injector := New(Config{
+		Seed:    1,
+		Latency: 50 * time.Millisecond,
+		Match: func(req *http.Request) bool {
+			return req.URL.Path == "/slow"
+		},
+	})
+	c := &http.Client{Transport: injector.Wrap(nil)}
+
+	start := time.Now()
+	resp, err := c.Get(srv.URL + "/slow")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	resp, err = c.Get(srv.URL + "/fast")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, Stats{Requests: 2, Delayed: 1}, injector.Stats())
+
+	// 延迟期间取消请求
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", srv.URL+"/slow", nil)
+	_, err = c.Do(req.WithContext(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), hits)
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chunkcache 网盘文件分块缓存，用于视频播放器等随机读取的场景，拖动进度条时无需重复下载相同的数据
+package chunkcache
+
+import (
+	"container/list"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/library-go/requester"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Config 缓存配置
+	Config struct {
+		// ChunkSize 分块大小，默认为1MB
+		ChunkSize int64
+		// MaxMemoryChunks 内存中最多缓存的分块数量，默认为64
+		MaxMemoryChunks int
+		// DiskDir 磁盘缓存目录，为空则不使用磁盘缓存
+		DiskDir string
+		// MaxDiskChunks 磁盘最多缓存的分块数量，默认为1024
+		MaxDiskChunks int
+	}
+
+	// Cache 分块缓存，内存和磁盘都按LRU淘汰。可以被多个 Reader 共享
+	Cache struct {
+		config Config
+
+		mu   sync.Mutex
+		mem  *lru
+		disk *lru
+		// inflight 正在下载的分块，避免并发读取时重复下载
+		inflight map[string]*fetchCall
+
+		// httpClient 下载分块使用的http客户端，所有 Reader 共享同一个连接池
+		httpClientOnce sync.Once
+		httpClient     *requester.HTTPClient
+	}
+
+	fetchCall struct {
+		wg   sync.WaitGroup
+		data []byte
+		err  error
+	}
+
+	lruEntry struct {
+		key  string
+		data []byte
+	}
+
+	// lru 最近最少使用淘汰
+	lru struct {
+		max     int
+		ll      *list.List
+		items   map[string]*list.Element
+		onEvict func(key string)
+	}
+)
+
+const (
+	// DefaultChunkSize 默认分块大小
+	DefaultChunkSize int64 = 1024 * 1024
+)
+
+// New 创建分块缓存
+func New(config Config) *Cache {
+	if config.ChunkSize <= 0 {
+		config.ChunkSize = DefaultChunkSize
+	}
+	if config.MaxMemoryChunks <= 0 {
+		config.MaxMemoryChunks = 64
+	}
+	if config.MaxDiskChunks <= 0 {
+		config.MaxDiskChunks = 1024
+	}
+	c := &Cache{
+		config:   config,
+		mem:      newLru(config.MaxMemoryChunks),
+		inflight: map[string]*fetchCall{},
+	}
+	if config.DiskDir != "" {
+		c.disk = newLru(config.MaxDiskChunks)
+		c.disk.onEvict = func(name string) {
+			os.Remove(filepath.Join(c.config.DiskDir, name))
+		}
+		c.loadDisk()
+	}
+	return c
+}
+
+// loadDisk 从磁盘缓存目录恢复索引，按修改时间从旧到新加入，超过 MaxDiskChunks 的旧分块被删除
+func (c *Cache) loadDisk() {
+	infos, err := ioutil.ReadDir(c.config.DiskDir)
+	if err != nil {
+		return
+	}
+	files := make([]os.FileInfo, 0, len(infos))
+	for _, fi := range infos {
+		if !fi.Mode().IsRegular() || !isDiskName(fi.Name()) {
+			continue
+		}
+		files = append(files, fi)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, fi := range files {
+		c.disk.add(fi.Name(), nil)
+	}
+}
+
+// isDiskName 是否为分块缓存文件名，写入中的临时文件不是
+func isDiskName(name string) bool {
+	if len(name) != sha1.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// ChunkSize 分块大小
+func (c *Cache) ChunkSize() int64 {
+	return c.config.ChunkSize
+}
+
+// diskName 分块在磁盘缓存目录中的文件名，磁盘缓存的索引也使用文件名，重新打开时可以从目录恢复
+func diskName(key string) string {
+	h := sha1.Sum([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+func (c *Cache) diskPath(key string) string {
+	return filepath.Join(c.config.DiskDir, diskName(key))
+}
+
+// get 获取分块，缓存中不存在则调用 fetch 下载并缓存
+func (c *Cache) get(key string, fetch func() ([]byte, error)) ([]byte, error) {
+	c.mu.Lock()
+	if data, ok := c.mem.get(key); ok {
+		c.mu.Unlock()
+		return data, nil
+	}
+	if c.disk != nil {
+		if _, ok := c.disk.get(diskName(key)); ok {
+			c.mu.Unlock()
+			if data, err := ioutil.ReadFile(c.diskPath(key)); err == nil {
+				// 更新修改时间，重新打开时按修改时间恢复使用顺序
+				now := time.Now()
+				os.Chtimes(c.diskPath(key), now, now)
+				c.mu.Lock()
+				c.mem.add(key, data)
+				c.mu.Unlock()
+				return data, nil
+			}
+			c.mu.Lock()
+			c.disk.remove(diskName(key))
+		}
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.data, call.err
+	}
+	call := &fetchCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.mu.Unlock()
+
+	call.data, call.err = fetch()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.mem.add(key, call.data)
+		if c.disk != nil {
+			if err := c.writeDisk(key, call.data); err == nil {
+				c.disk.add(diskName(key), nil)
+			}
+		}
+	}
+	c.mu.Unlock()
+	call.wg.Done()
+	return call.data, call.err
+}
+
+// writeDisk 写入磁盘缓存，先写入临时文件再重命名，避免进程退出时留下不完整的分块
+func (c *Cache) writeDisk(key string, data []byte) error {
+	if err := os.MkdirAll(c.config.DiskDir, 0755); err != nil {
+		return err
+	}
+	tmpPath := c.diskPath(key) + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, c.diskPath(key))
+}
+
+// Clear 清空内存和磁盘缓存
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mem.clear()
+	if c.disk != nil {
+		c.disk.clear()
+	}
+}
+
+func newLru(max int) *lru {
+	return &lru{
+		max:   max,
+		ll:    list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		return e.Value.(*lruEntry).data, true
+	}
+	return nil, false
+}
+
+func (l *lru) add(key string, data []byte) {
+	if e, ok := l.items[key]; ok {
+		l.ll.MoveToFront(e)
+		e.Value.(*lruEntry).data = data
+		return
+	}
+	l.items[key] = l.ll.PushFront(&lruEntry{key: key, data: data})
+	for l.ll.Len() > l.max {
+		l.remove(l.ll.Back().Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) remove(key string) {
+	if e, ok := l.items[key]; ok {
+		l.ll.Remove(e)
+		delete(l.items, key)
+		if l.onEvict != nil {
+			l.onEvict(key)
+		}
+	}
+}
+
+func (l *lru) clear() {
+	for len(l.items) > 0 {
+		l.remove(l.ll.Back().Value.(*lruEntry).key)
+	}
+}
+
+// getHTTPClient 第一次下载分块时创建http客户端，之后复用
+func (c *Cache) getHTTPClient() *requester.HTTPClient {
+	c.httpClientOnce.Do(func() {
+		c.httpClient = aliyunpan.NewHTTPClient()
+		c.httpClient.SetTimeout(0)
+	})
+	return c.httpClient
+}
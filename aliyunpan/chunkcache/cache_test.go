@@ -0,0 +1,90 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunkcache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chunkcache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c := New(Config{MaxMemoryChunks: 1, DiskDir: dir, MaxDiskChunks: 2})
+	fetched := 0
+	fetch := func(v string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			fetched++
+			return []byte(v), nil
+		}
+	}
+
+	data, _ := c.get("a", fetch("A"))
+	assert.Equal(t, "A", string(data))
+	c.get("b", fetch("B"))
+	// a 已经被移出内存，但是还在磁盘缓存中
+	data, _ = c.get("a", fetch("A"))
+	assert.Equal(t, "A", string(data))
+	assert.Equal(t, 2, fetched)
+
+	// 磁盘最多缓存2个分块，最久未使用的 b 被淘汰
+	c.get("c", fetch("C"))
+	c.get("a", fetch("A"))
+	assert.Equal(t, 3, fetched)
+	c.get("b", fetch("B"))
+	assert.Equal(t, 4, fetched)
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 2, len(files))
+}
+
+func TestCacheReopenDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chunkcache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	fetched := 0
+	fetch := func(v string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			fetched++
+			return []byte(v), nil
+		}
+	}
+	c := New(Config{MaxMemoryChunks: 1, DiskDir: dir, MaxDiskChunks: 3})
+	for i, key := range []string{"a", "b", "c"} {
+		c.get(key, fetch(key))
+		mtime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(c.diskPath(key), mtime, mtime)
+	}
+	// 写入中断留下的临时文件不会被当作分块
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, diskName("d")+".tmp"), []byte("D"), 0600))
+
+	// 重新打开时恢复索引，超过上限的最旧的 a 被删除
+	c = New(Config{MaxMemoryChunks: 1, DiskDir: dir, MaxDiskChunks: 2})
+	_, err = os.Stat(c.diskPath("a"))
+	assert.True(t, os.IsNotExist(err))
+	data, _ := c.get("b", fetch("b"))
+	assert.Equal(t, "b", string(data))
+	c.get("c", fetch("c"))
+	assert.Equal(t, 3, fetched)
+	c.get("a", fetch("a"))
+	assert.Equal(t, 4, fetched)
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chunkcache
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+type (
+	// Reader 通过分块缓存读取网盘文件，实现 io.ReaderAt 和 io.ReadSeeker
+	Reader struct {
+		cache     *Cache
+		panClient *aliyunpan.PanClient
+		fe        *aliyunpan.FileEntity
+		// keyPrefix 分块缓存key前缀，包含文件的修改时间和哈希值，文件修改后缓存自动失效
+		keyPrefix string
+
+		mu          sync.Mutex
+		offset      int64
+		downloadUrl string
+	}
+)
+
+// NewReader 创建读取指定网盘文件的 Reader
+func (c *Cache) NewReader(panClient *aliyunpan.PanClient, fe *aliyunpan.FileEntity) *Reader {
+	return &Reader{
+		cache:     c,
+		panClient: panClient,
+		fe:        fe,
+		keyPrefix: fe.DriveId + "/" + fe.FileId + "/" + fe.ContentHash + "/" + fe.UpdatedAt + "/",
+	}
+}
+
+// Size 文件大小
+func (r *Reader) Size() int64 {
+	return r.fe.FileSize
+}
+
+// ReadAt 实现 io.ReaderAt，可以并发调用
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, os.ErrInvalid
+	}
+	chunkSize := r.cache.ChunkSize()
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.fe.FileSize {
+			return n, io.EOF
+		}
+		index := pos / chunkSize
+		data, err := r.chunk(index)
+		if err != nil {
+			return n, err
+		}
+		inChunk := pos - index*chunkSize
+		if inChunk >= int64(len(data)) {
+			return n, io.ErrUnexpectedEOF
+		}
+		n += copy(p[n:], data[inChunk:])
+	}
+	return n, nil
+}
+
+// Read 实现 io.Reader
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	off := r.offset
+	r.mu.Unlock()
+
+	n, err := r.ReadAt(p, off)
+	r.mu.Lock()
+	r.offset = off + int64(n)
+	r.mu.Unlock()
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek 实现 io.Seeker，不会发起任何请求
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newOffset := r.offset
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset += offset
+	case io.SeekEnd:
+		newOffset = r.fe.FileSize + offset
+	default:
+		return 0, os.ErrInvalid
+	}
+	if newOffset < 0 {
+		return 0, os.ErrInvalid
+	}
+	r.offset = newOffset
+	return newOffset, nil
+}
+
+// Close 实现 io.Closer，缓存的数据不会被清除
+func (r *Reader) Close() error {
+	return nil
+}
+
+func (r *Reader) chunk(index int64) ([]byte, error) {
+	return r.cache.get(r.keyPrefix+strconv.FormatInt(index, 10), func() ([]byte, error) {
+		data, err := r.fetch(index)
+		if err != nil {
+			// 下载链接可能已经过期，重新获取后重试一次
+			r.mu.Lock()
+			r.downloadUrl = ""
+			r.mu.Unlock()
+			data, err = r.fetch(index)
+		}
+		return data, err
+	})
+}
+
+// fetch 下载指定分块
+func (r *Reader) fetch(index int64) ([]byte, error) {
+	r.mu.Lock()
+	downloadUrl := r.downloadUrl
+	r.mu.Unlock()
+	if downloadUrl == "" {
+		u, apierr := r.panClient.GetFileDownloadUrl(&aliyunpan.GetFileDownloadUrlParam{
+			DriveId: r.fe.DriveId,
+			FileId:  r.fe.FileId,
+		})
+		if apierr != nil {
+			return nil, apierr
+		}
+		downloadUrl = u.Url
+		r.mu.Lock()
+		r.downloadUrl = downloadUrl
+		r.mu.Unlock()
+	}
+
+	chunkSize := r.cache.ChunkSize()
+	start := index * chunkSize
+	end := start + chunkSize
+	if end > r.fe.FileSize {
+		end = r.fe.FileSize
+	}
+
+	httpClient := r.cache.getHTTPClient()
+	var resp *http.Response
+	apierr := r.panClient.DownloadFileData(downloadUrl, aliyunpan.FileDownloadRange{Offset: start, End: end - 1}, func(httpMethod, fullUrl string, headers map[string]string) (*http.Response, error) {
+		resp2, err := httpClient.Req(httpMethod, fullUrl, nil, headers)
+		resp = resp2
+		return resp2, err
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	skip := int64(0)
+	switch resp.StatusCode {
+	case 206:
+	case 200:
+		// 服务器不支持Range请求，跳过前面的数据
+		skip = start
+	default:
+		return nil, fmt.Errorf("unexpected http status code, %d, %s", resp.StatusCode, resp.Status)
+	}
+	if skip > 0 {
+		if _, err := io.CopyN(ioutil.Discard, resp.Body, skip); err != nil {
+			return nil, err
+		}
+	}
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
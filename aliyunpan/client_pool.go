@@ -0,0 +1,168 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+type (
+	// AccountLimit 单个账号的请求限制，字段为0代表不限制
+	AccountLimit struct {
+		// MaxInFlight 最多同时进行的请求数量
+		MaxInFlight int
+		// RequestsPerSecond 每秒最多发起的请求数量，Burst 为允许的突发请求数量
+		RequestsPerSecond float64
+		Burst             int
+	}
+
+	// PoolAccount 客户端池中的账号
+	PoolAccount struct {
+		Name   string
+		Client *PanClient
+	}
+
+	// ClientPool 管理多个账号的客户端，用于聚合多个网盘账号的应用。每个账号使用独立的请求限制，
+	// 只读操作可以通过 Next 或者 Read 在账号之间轮流分配。可以在多个 goroutine 中并发使用
+	ClientPool struct {
+		mu       sync.RWMutex
+		accounts []*PoolAccount
+		// next 下一次轮询的位置
+		next int
+	}
+)
+
+// NewClientPool 创建客户端池
+func NewClientPool() *ClientPool {
+	return &ClientPool{}
+}
+
+// Add 添加账号，name 不能重复。limit 中设置的限制会应用到客户端上
+func (p *ClientPool) Add(name string, client *PanClient, limit AccountLimit) error {
+	if client == nil {
+		return fmt.Errorf("client of account %s is nil", name)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, a := range p.accounts {
+		if a.Name == name {
+			return fmt.Errorf("account %s already exists", name)
+		}
+	}
+	if limit.MaxInFlight > 0 {
+		client.SetRequestScheduler(NewRequestScheduler(limit.MaxInFlight))
+	}
+	if limit.RequestsPerSecond > 0 {
+		client.SetRateLimiter(NewRateLimiter(limit.RequestsPerSecond, limit.Burst))
+	}
+	p.accounts = append(p.accounts, &PoolAccount{Name: name, Client: client})
+	return nil
+}
+
+// Remove 移除账号，返回移除的客户端，账号不存在返回nil
+func (p *ClientPool) Remove(name string) *PanClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, a := range p.accounts {
+		if a.Name == name {
+			p.accounts = append(p.accounts[:i:i], p.accounts[i+1:]...)
+			return a.Client
+		}
+	}
+	return nil
+}
+
+// Get 获取账号的客户端，账号不存在返回nil
+func (p *ClientPool) Get(name string) *PanClient {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, a := range p.accounts {
+		if a.Name == name {
+			return a.Client
+		}
+	}
+	return nil
+}
+
+// Accounts 返回所有账号，按添加顺序排列
+func (p *ClientPool) Accounts() []*PoolAccount {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]*PoolAccount{}, p.accounts...)
+}
+
+// Len 账号数量
+func (p *ClientPool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.accounts)
+}
+
+// Next 轮询返回下一个账号，用于把只读操作分配到多个账号。没有账号返回nil
+func (p *ClientPool) Next() *PoolAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.accounts) == 0 {
+		return nil
+	}
+	a := p.accounts[p.next%len(p.accounts)]
+	p.next = (p.next + 1) % len(p.accounts)
+	return a
+}
+
+// Read 轮询选择账号执行只读操作。操作因为限流、token失效或者网络错误失败时换下一个账号重试，
+// 每个账号最多尝试一次，返回最后一个错误。只适用于任意账号都能完成的操作，例如浏览公开的分享
+func (p *ClientPool) Read(fn func(account *PoolAccount) *apierror.ApiError) *apierror.ApiError {
+	n := p.Len()
+	if n == 0 {
+		return apierror.NewFailedApiError("客户端池中没有账号")
+	}
+	var lastErr *apierror.ApiError
+	for i := 0; i < n; i++ {
+		a := p.Next()
+		if a == nil {
+			break
+		}
+		lastErr = fn(a)
+		if lastErr == nil || !isPoolFailoverError(lastErr) {
+			return lastErr
+		}
+	}
+	return lastErr
+}
+
+// Close 关闭所有账号的客户端，返回第一个错误
+func (p *ClientPool) Close(ctx context.Context) error {
+	var firstErr error
+	for _, a := range p.Accounts() {
+		if err := a.Client.Close(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// isPoolFailoverError 换一个账号可能成功的错误
+func isPoolFailoverError(err *apierror.ApiError) bool {
+	switch err.Code {
+	case apierror.ApiCodeQuotaExceeded, apierror.ApiCodeAccessTokenInvalid, apierror.ApiCodeTokenExpiredCode, apierror.ApiCodeFailed:
+		return true
+	}
+	return false
+}
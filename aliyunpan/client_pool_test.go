@@ -0,0 +1,99 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestClientPool(t *testing.T) {
+	pool := NewClientPool()
+	a := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a"}, AppLoginToken{})
+	b := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "b"}, AppLoginToken{})
+	if err := pool.Add("a", a, AccountLimit{MaxInFlight: 2, RequestsPerSecond: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add("b", b, AccountLimit{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pool.Add("a", b, AccountLimit{}); err == nil {
+		t.Fatal("expected duplicate account error")
+	}
+	if a.scheduler == nil || a.limiter == nil || b.scheduler != nil || b.limiter != nil {
+		t.Fatal("unexpected account limits")
+	}
+	if pool.Get("b") != b || pool.Get("c") != nil {
+		t.Fatal("unexpected get result")
+	}
+
+	names := ""
+	for i := 0; i < 4; i++ {
+		names += pool.Next().Name
+	}
+	if names != "abab" {
+		t.Fatalf("unexpected round robin order %s", names)
+	}
+
+	// 第一个账号限流时换下一个账号
+	tried := ""
+	err := pool.Read(func(account *PoolAccount) *apierror.ApiError {
+		tried += account.Name
+		if account.Name == "a" {
+			return apierror.NewApiError(apierror.ApiCodeQuotaExceeded, "too many requests")
+		}
+		return nil
+	})
+	if err != nil || tried != "ab" {
+		t.Fatalf("unexpected read result %v, tried %s", err, tried)
+	}
+	// 其他错误不换账号
+	tried = ""
+	err = pool.Read(func(account *PoolAccount) *apierror.ApiError {
+		tried += account.Name
+		return apierror.NewApiError(apierror.ApiCodeFileNotFoundCode, "not found")
+	})
+	if err == nil || len(tried) != 1 {
+		t.Fatalf("unexpected read result %v, tried %s", err, tried)
+	}
+
+	if pool.Remove("a") != a || pool.Len() != 1 {
+		t.Fatal("unexpected remove result")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(100, 1)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Fatalf("expected rate limited, elapsed %s", elapsed)
+	}
+
+	slow := NewRateLimiter(0.1, 1)
+	slow.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := slow.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
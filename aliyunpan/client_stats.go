@@ -0,0 +1,216 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"net/url"
+	"sync"
+)
+
+type (
+	// ClientStats 客户端流量和请求统计
+	ClientStats struct {
+		// BytesUp 上传的文件数据字节数
+		BytesUp int64
+		// BytesDown 接口响应和下载的文件数据字节数
+		BytesDown int64
+		// Requests 每个接口的请求次数，key为接口路径，例如：/adrive/v3/file/list
+		Requests map[string]int64
+		// Errors 请求失败次数
+		Errors int64
+		// CacheHits 缓存命中次数
+		CacheHits int64
+		// CacheMisses 缓存未命中次数
+		CacheMisses int64
+	}
+
+	// statsRecorder 统计数据，派生的客户端共享同一个 statsRecorder
+	statsRecorder struct {
+		mu    sync.Mutex
+		stats ClientStats
+	}
+)
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{stats: ClientStats{Requests: map[string]int64{}}}
+}
+
+// TotalRequests 所有接口的请求次数
+func (s ClientStats) TotalRequests() int64 {
+	total := int64(0)
+	for _, n := range s.Requests {
+		total += n
+	}
+	return total
+}
+
+// CacheHitRate 缓存命中率，没有缓存访问时为0
+func (s ClientStats) CacheHitRate() float64 {
+	total := s.CacheHits + s.CacheMisses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.CacheHits) / float64(total)
+}
+
+func (r *statsRecorder) request(urlStr string, bytesDown int, failed bool) {
+	if r == nil {
+		return
+	}
+	endpoint := urlStr
+	if u, err := url.Parse(urlStr); err == nil {
+		endpoint = u.Path
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Requests[endpoint]++
+	r.stats.BytesDown += int64(bytesDown)
+	if failed {
+		r.stats.Errors++
+	}
+}
+
+func (r *statsRecorder) upload(n int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.BytesUp += n
+}
+
+func (r *statsRecorder) download(n int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.BytesDown += n
+}
+
+func (r *statsRecorder) cache(hit bool) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if hit {
+		r.stats.CacheHits++
+	} else {
+		r.stats.CacheMisses++
+	}
+}
+
+// Stats 返回客户端的流量和请求统计，派生的客户端(CloneWithToken / WithContext)共享统计数据
+func (pc *PanClient) Stats() ClientStats {
+	r := pc.stats
+	if r == nil {
+		return ClientStats{Requests: map[string]int64{}}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.stats
+	s.Requests = make(map[string]int64, len(r.stats.Requests))
+	for k, v := range r.stats.Requests {
+		s.Requests[k] = v
+	}
+	return s
+}
+
+// ResetStats 清空统计数据
+func (pc *PanClient) ResetStats() {
+	if r := pc.stats; r != nil {
+		r.mu.Lock()
+		r.stats = ClientStats{Requests: map[string]int64{}}
+		r.mu.Unlock()
+	}
+}
+
+// doFetch 发起一次http请求并记录统计，设置了请求调度器时先申请名额，开启设备会话时请求携带签名
+func (pc *PanClient) doFetch(method string, urlStr string, post interface{}, header map[string]string) ([]byte, error) {
+	if pc.isClosed() {
+		return nil, ErrClientClosed
+	}
+	if _, ok := header["authorization"]; ok && pc.webTokenMissing() {
+		return nil, ErrWebTokenRequired
+	}
+	if _, ok := header["authorization"]; ok && pc.IsShareOnly() {
+		return nil, ErrLoginRequired
+	}
+	if authorization, ok := header["authorization"]; ok && pc.tokenExpiringSoon(authorization) {
+		// token 即将过期，提前刷新
+		if newAuthorization, ok := pc.refreshToken(authorization); ok {
+			header = copyHeader(header)
+			header["authorization"] = newAuthorization
+		}
+	}
+	urlStr, header = pc.appRequest(urlStr, header)
+	header, apiErr := pc.signDeviceHeader(header)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	release, err := pc.acquire(pc.requestClass)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	generation := pc.challengeGeneration()
+	body, err := client.Fetch(method, urlStr, post, header)
+	pc.stats.request(urlStr, len(body), err != nil)
+	if err == nil {
+		// 设备会话签名无效或者设备下线，恢复会话后重试一次
+		if retryHeader, ok := pc.recoverDeviceSession(header, body); ok {
+			header = retryHeader
+			body, err = client.Fetch(method, urlStr, post, header)
+			pc.stats.request(urlStr, len(body), err != nil)
+		}
+	}
+	if err == nil {
+		// 风控验证，用户完成验证后重试一次
+		if retryHeader, ok := pc.resolveChallenge(generation, urlStr, header, body); ok {
+			header = retryHeader
+			body, err = client.Fetch(method, urlStr, post, header)
+			pc.stats.request(urlStr, len(body), err != nil)
+		}
+	}
+	if err != nil || !isTokenInvalidBody(body) {
+		return body, err
+	}
+
+	// token 失效，刷新后重试一次
+	authorization, ok := pc.refreshToken(header["authorization"])
+	if !ok {
+		return body, nil
+	}
+	// 对冲请求会共享 header，复制后再修改
+	retryHeader := copyHeader(header)
+	retryHeader["authorization"] = authorization
+	// 签名属于旧 token 的设备会话，需要重新签名
+	retryHeader, apiErr = pc.resignDeviceHeader(retryHeader)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	body, err = client.Fetch(method, urlStr, post, retryHeader)
+	pc.stats.request(urlStr, len(body), err != nil)
+	return body, err
+}
+
+func copyHeader(header map[string]string) map[string]string {
+	c := make(map[string]string, len(header))
+	for k, v := range header {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,372 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crypt 客户端加密，上传前使用 AES-256-GCM 加密文件内容，可选加密文件名，下载后解密
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type (
+	// KeyProvider 密钥管理接口，应用可以实现该接口从系统钥匙串、KMS等获取密钥。密钥必须为32字节
+	KeyProvider interface {
+		// CurrentKey 返回加密使用的密钥ID和密钥
+		CurrentKey() (keyId string, key []byte, err error)
+		// Key 根据密钥ID返回解密使用的密钥，密钥轮换后旧文件仍然可以解密
+		Key(keyId string) ([]byte, error)
+	}
+
+	// staticKeyProvider 固定密钥
+	staticKeyProvider struct {
+		keyId string
+		key   []byte
+	}
+
+	// Cipher 加密器
+	Cipher struct {
+		keys KeyProvider
+		// obfuscateNames 是否加密文件名
+		obfuscateNames bool
+	}
+
+	// encryptReader 流式加密
+	encryptReader struct {
+		src     *bufio.Reader
+		aead    cipher.AEAD
+		nonce   []byte
+		counter uint64
+		buf     bytes.Buffer
+		plain   []byte
+		done    bool
+	}
+
+	// decryptReader 流式解密
+	decryptReader struct {
+		src     *bufio.Reader
+		aead    cipher.AEAD
+		nonce   []byte
+		counter uint64
+		buf     bytes.Buffer
+		sealed  []byte
+		done    bool
+	}
+)
+
+const (
+	// KeySize 密钥长度，AES-256
+	KeySize = 32
+	// ChunkSize 每个加密块的明文大小
+	ChunkSize = 64 * 1024
+
+	headerMagic   = "APCR"
+	formatVersion = 1
+	nonceSize     = 12
+	tagSize       = 16
+)
+
+var (
+	// ErrInvalidKey 密钥长度错误
+	ErrInvalidKey = errors.New("crypt: key must be 32 bytes")
+	// ErrNotEncrypted 数据不是加密格式
+	ErrNotEncrypted = errors.New("crypt: data is not encrypted")
+	// ErrTruncated 加密数据不完整
+	ErrTruncated = errors.New("crypt: encrypted data is truncated")
+
+	nameEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// NewStaticKeyProvider 使用固定密钥
+func NewStaticKeyProvider(keyId string, key []byte) KeyProvider {
+	return &staticKeyProvider{keyId: keyId, key: key}
+}
+
+func (s *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	return s.keyId, s.key, nil
+}
+
+func (s *staticKeyProvider) Key(keyId string) ([]byte, error) {
+	if keyId != s.keyId {
+		return nil, fmt.Errorf("crypt: unknown key id: %s", keyId)
+	}
+	return s.key, nil
+}
+
+// NewCipher 创建加密器，obfuscateNames 为true时同时加密文件名
+func NewCipher(keys KeyProvider, obfuscateNames bool) *Cipher {
+	return &Cipher{
+		keys:           keys,
+		obfuscateNames: obfuscateNames,
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkNonce 每个块的nonce为基础nonce和块序号异或，最后一个块使用附加数据标记，防止截断
+func chunkNonce(base []byte, counter uint64) []byte {
+	nonce := make([]byte, nonceSize)
+	copy(nonce, base)
+	c := make([]byte, 8)
+	binary.BigEndian.PutUint64(c, counter)
+	for i := 0; i < 8; i++ {
+		nonce[nonceSize-8+i] ^= c[i]
+	}
+	return nonce
+}
+
+func chunkAdditionalData(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// EncryptedSize 加密后的数据大小
+func EncryptedSize(size int64, keyId string) int64 {
+	chunks := (size + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return int64(len(headerMagic)+2+len(keyId)+nonceSize) + size + chunks*tagSize
+}
+
+// EncryptReader 返回加密后的数据流
+func (c *Cipher) EncryptReader(r io.Reader) (io.Reader, error) {
+	keyId, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(keyId) > 255 {
+		return nil, errors.New("crypt: key id is too long")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	er := &encryptReader{
+		src:   bufio.NewReaderSize(r, ChunkSize),
+		aead:  aead,
+		nonce: nonce,
+		plain: make([]byte, ChunkSize),
+	}
+	er.buf.WriteString(headerMagic)
+	er.buf.WriteByte(formatVersion)
+	er.buf.WriteByte(byte(len(keyId)))
+	er.buf.WriteString(keyId)
+	er.buf.Write(nonce)
+	return er, nil
+}
+
+func (er *encryptReader) Read(p []byte) (int, error) {
+	for er.buf.Len() == 0 {
+		if er.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(er.src, er.plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		last := err != nil
+		if !last {
+			if _, perr := er.src.Peek(1); perr == io.EOF {
+				last = true
+			} else if perr != nil {
+				return 0, perr
+			}
+		}
+		sealed := er.aead.Seal(nil, chunkNonce(er.nonce, er.counter), er.plain[:n], chunkAdditionalData(last))
+		er.buf.Write(sealed)
+		er.counter++
+		er.done = last
+	}
+	return er.buf.Read(p)
+}
+
+// DecryptReader 返回解密后的数据流，数据被篡改或者不完整时读取会返回错误
+func (c *Cipher) DecryptReader(r io.Reader) (io.Reader, error) {
+	src := bufio.NewReaderSize(r, ChunkSize+tagSize)
+	header := make([]byte, len(headerMagic)+2)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, ErrNotEncrypted
+	}
+	if string(header[:len(headerMagic)]) != headerMagic {
+		return nil, ErrNotEncrypted
+	}
+	if header[len(headerMagic)] != formatVersion {
+		return nil, fmt.Errorf("crypt: unsupported format version: %d", header[len(headerMagic)])
+	}
+	keyId := make([]byte, header[len(headerMagic)+1])
+	if _, err := io.ReadFull(src, keyId); err != nil {
+		return nil, ErrTruncated
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(src, nonce); err != nil {
+		return nil, ErrTruncated
+	}
+	key, err := c.keys.Key(string(keyId))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		src:    src,
+		aead:   aead,
+		nonce:  nonce,
+		sealed: make([]byte, ChunkSize+tagSize),
+	}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for dr.buf.Len() == 0 {
+		if dr.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(dr.src, dr.sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		last := err != nil
+		if !last {
+			if _, perr := dr.src.Peek(1); perr == io.EOF {
+				last = true
+			} else if perr != nil {
+				return 0, perr
+			}
+		}
+		if n < tagSize {
+			return 0, ErrTruncated
+		}
+		plain, oerr := dr.aead.Open(nil, chunkNonce(dr.nonce, dr.counter), dr.sealed[:n], chunkAdditionalData(last))
+		if oerr != nil {
+			if last {
+				// 最后一个块校验失败，可能是数据被截断
+				return 0, ErrTruncated
+			}
+			return 0, oerr
+		}
+		dr.buf.Write(plain)
+		dr.counter++
+		dr.done = last
+	}
+	return dr.buf.Read(p)
+}
+
+// nameKeys 从密钥派生文件名加密使用的密钥
+func nameKeys(key []byte) (encKey, sivKey []byte) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("aliyunpan-crypt-name-enc"))
+	encKey = mac.Sum(nil)
+	mac = hmac.New(sha256.New, key)
+	mac.Write([]byte("aliyunpan-crypt-name-siv"))
+	sivKey = mac.Sum(nil)
+	return
+}
+
+// EncryptName 加密文件名。没有开启文件名加密时原样返回。
+// 相同的文件名加密结果相同，因此可以通过加密后的文件名查找文件。文件名使用当前密钥加密，
+// 加密结果中包含密钥ID，密钥轮换后使用旧密钥加密的文件名仍然可以解密
+func (c *Cipher) EncryptName(name string) (string, error) {
+	if !c.obfuscateNames || name == "" {
+		return name, nil
+	}
+	keyId, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+	if len(keyId) > 255 {
+		return "", errors.New("crypt: key id too long")
+	}
+	encKey, sivKey := nameKeys(key)
+	aead, err := newAEAD(encKey)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, sivKey)
+	mac.Write([]byte(name))
+	nonce := mac.Sum(nil)[:nonceSize]
+	// 格式：密钥ID长度(1字节) + 密钥ID + nonce + 密文，密钥ID同时作为附加数据防止被替换
+	data := make([]byte, 0, 1+len(keyId)+nonceSize+len(name)+tagSize)
+	data = append(data, byte(len(keyId)))
+	data = append(data, keyId...)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, []byte(name), []byte(keyId))
+	return strings.ToLower(nameEncoding.EncodeToString(data)), nil
+}
+
+// DecryptName 解密 EncryptName 加密的文件名，根据文件名中的密钥ID获取密钥。没有开启文件名加密时原样返回
+func (c *Cipher) DecryptName(name string) (string, error) {
+	if !c.obfuscateNames || name == "" {
+		return name, nil
+	}
+	data, err := nameEncoding.DecodeString(strings.ToUpper(name))
+	if err != nil || len(data) < 1+nonceSize+tagSize {
+		return "", ErrNotEncrypted
+	}
+	idLen := int(data[0])
+	if len(data) < 1+idLen+nonceSize+tagSize {
+		return "", ErrNotEncrypted
+	}
+	keyId := data[1 : 1+idLen]
+	key, err := c.keys.Key(string(keyId))
+	if err != nil {
+		return "", err
+	}
+	plain, err := openName(key, data[1+idLen:], keyId)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// openName 解密 nonce + 密文 格式的文件名
+func openName(key, data, additionalData []byte) ([]byte, error) {
+	if len(data) < nonceSize+tagSize {
+		return nil, ErrNotEncrypted
+	}
+	encKey, _ := nameKeys(key)
+	aead, err := newAEAD(encKey)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, data[:nonceSize], data[nonceSize:], additionalData)
+}
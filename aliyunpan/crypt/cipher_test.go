@@ -0,0 +1,110 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func testCipher() *Cipher {
+	return NewCipher(NewStaticKeyProvider("k1", bytes.Repeat([]byte{7}, KeySize)), true)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := testCipher()
+	for _, size := range []int{0, 1, ChunkSize, ChunkSize + 1, 3*ChunkSize - 5} {
+		plain := bytes.Repeat([]byte{'a'}, size)
+		er, err := c.EncryptReader(bytes.NewReader(plain))
+		assert.Nil(t, err)
+		sealed, err := ioutil.ReadAll(er)
+		assert.Nil(t, err)
+		assert.Equal(t, EncryptedSize(int64(size), "k1"), int64(len(sealed)))
+
+		dr, err := c.DecryptReader(bytes.NewReader(sealed))
+		assert.Nil(t, err)
+		r, err := ioutil.ReadAll(dr)
+		assert.Nil(t, err)
+		assert.Equal(t, plain, r)
+
+		if size > ChunkSize {
+			// 截断最后一个块
+			dr, err = c.DecryptReader(bytes.NewReader(sealed[:len(headerMagic)+2+2+nonceSize+ChunkSize+tagSize]))
+			assert.Nil(t, err)
+			_, err = ioutil.ReadAll(dr)
+			assert.Equal(t, ErrTruncated, err)
+		}
+	}
+}
+
+func TestEncryptName(t *testing.T) {
+	c := testCipher()
+	enc, err := c.EncryptName("报告.docx")
+	assert.Nil(t, err)
+	enc2, _ := c.EncryptName("报告.docx")
+	assert.Equal(t, enc, enc2)
+	name, err := c.DecryptName(enc)
+	assert.Nil(t, err)
+	assert.Equal(t, "报告.docx", name)
+
+	_, err = c.DecryptName("plain.txt")
+	assert.NotNil(t, err)
+}
+
+// rotatingKeyProvider 测试使用的多密钥 KeyProvider，current 为当前密钥ID
+type rotatingKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (p *rotatingKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.current, p.keys[p.current], nil
+}
+
+func (p *rotatingKeyProvider) Key(keyId string) ([]byte, error) {
+	if key, ok := p.keys[keyId]; ok {
+		return key, nil
+	}
+	return nil, ErrInvalidKey
+}
+
+func TestEncryptNameKeyRotation(t *testing.T) {
+	keys := &rotatingKeyProvider{current: "k1", keys: map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, KeySize),
+		"k2": bytes.Repeat([]byte{2}, KeySize),
+	}}
+	c := NewCipher(keys, true)
+	old, err := c.EncryptName("报告.docx")
+	assert.Nil(t, err)
+
+	// 轮换密钥后旧文件名仍然可以解密，新文件名使用新密钥
+	keys.current = "k2"
+	name, err := c.DecryptName(old)
+	assert.Nil(t, err)
+	assert.Equal(t, "报告.docx", name)
+	enc, err := c.EncryptName("报告.docx")
+	assert.Nil(t, err)
+	assert.NotEqual(t, old, enc)
+	name, err = c.DecryptName(enc)
+	assert.Nil(t, err)
+	assert.Equal(t, "报告.docx", name)
+
+	// 旧密钥被移除后无法解密
+	delete(keys.keys, "k1")
+	_, err = c.DecryptName(old)
+	assert.NotNil(t, err)
+}
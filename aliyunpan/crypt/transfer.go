@@ -0,0 +1,133 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+// UploadFile 加密本地文件后上传到网盘指定文件夹。fileName 为明文文件名，开启文件名加密时会被加密。
+// 加密数据先写入系统临时目录，上传完成后删除
+func (c *Cipher) UploadFile(ctx context.Context, panClient *aliyunpan.PanClient, driveId, parentFileId, localPath, fileName string, option *transfer.FileOption) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError) {
+	remoteName, err := c.EncryptName(fileName)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	tmpPath, err := c.encryptToTemp(localPath)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	defer os.Remove(tmpPath)
+
+	r, apierr := transfer.UploadFile(ctx, panClient, driveId, parentFileId, tmpPath, remoteName, option)
+	if apierr != nil {
+		return nil, apierr
+	}
+	r.Name = fileName
+	return r, nil
+}
+
+func (c *Cipher) encryptToTemp(localPath string) (string, error) {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	er, err := c.EncryptReader(src)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile("", "aliyunpan-crypt-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(tmp, er); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// DownloadFile 下载网盘加密文件并解密到本地指定路径。解密失败时不会生成本地文件
+func (c *Cipher) DownloadFile(ctx context.Context, panClient *aliyunpan.PanClient, fe *aliyunpan.FileEntity, localPath string, option *transfer.FileOption) *apierror.ApiError {
+	// 加密数据下载到目标文件旁边，支持断点续传
+	encPath := localPath + ".aliyunpan-crypt"
+	if apierr := transfer.DownloadFile(ctx, panClient, fe, encPath, option); apierr != nil {
+		return apierr
+	}
+
+	src, err := os.Open(encPath)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	defer func() {
+		src.Close()
+		os.Remove(encPath)
+	}()
+	dr, err := c.DecryptReader(src)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+
+	tmpPath := localPath + transfer.DownloadTmpSuffix
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	if _, err = io.Copy(dst, dr); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return apierror.NewApiErrorWithError(err)
+	}
+	if err = dst.Close(); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	if err = os.Rename(tmpPath, localPath); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	if info, err := os.Stat(encPath); err == nil {
+		// 保留网盘文件的修改时间
+		os.Chtimes(localPath, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// DecryptFileEntity 解密文件信息中的文件名，没有开启文件名加密时不做任何修改
+func (c *Cipher) DecryptFileEntity(fe *aliyunpan.FileEntity) error {
+	if fe == nil || !c.obfuscateNames || fe.IsDriveRootFolder() {
+		return nil
+	}
+	name, err := c.DecryptName(fe.FileName)
+	if err != nil {
+		return err
+	}
+	fe.FileName = name
+	dir, _ := path.Split(fe.Path)
+	fe.Path = dir + name
+	return nil
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+)
+
+const (
+	// DeviceSessionAppId 网页版设备会话签名使用的 appId
+	DeviceSessionAppId = "5dde4e1bdf9e4966b387ba58f4b3fdc3"
+)
+
+type (
+	// DeviceSession 设备会话。服务端开启设备签名校验后，请求需要携带 x-device-id 和 x-signature，
+	// 否则返回 DeviceSessionSignatureInvalid 错误。派生的客户端共享同一个设备会话
+	DeviceSession struct {
+		mu sync.Mutex
+
+		userId   string
+		deviceId string
+		// DeviceName 设备名称，在网盘的登录设备列表中展示
+		DeviceName string
+		// ModelName 设备型号
+		ModelName string
+
+		key       *secpPrivateKey
+		nonce     int
+		signature string
+		// created 会话是否已经在服务端创建
+		created bool
+	}
+
+	deviceSessionResult struct {
+		Result  bool   `json:"result"`
+		Success bool   `json:"success"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+)
+
+var (
+	// deviceSessionUrl 设备会话接口地址
+	deviceSessionUrl = API_URL + "/users/v1/users/device"
+)
+
+// NewDeviceSession 创建设备会话，userId 为网盘用户ID，可以通过 GetUserInfo 获取。
+// deviceId 为空时生成新的设备ID，需要保持设备不变时传入之前的 DeviceId()
+func NewDeviceSession(userId, deviceId string) (*DeviceSession, error) {
+	if deviceId == "" {
+		deviceId = apiutil.Uuid()
+	}
+	key, err := newSecpPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return &DeviceSession{
+		userId:     userId,
+		deviceId:   deviceId,
+		DeviceName: "Chrome浏览器",
+		ModelName:  "Windows网页版",
+		key:        key,
+	}, nil
+}
+
+// DeviceId 设备ID
+func (s *DeviceSession) DeviceId() string {
+	return s.deviceId
+}
+
+// PublicKey 签名公钥，十六进制编码的非压缩格式
+func (s *DeviceSession) PublicKey() string {
+	return s.key.publicKeyHex()
+}
+
+// Signature 当前的签名，会话还没有创建时返回空
+func (s *DeviceSession) Signature() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signature
+}
+
+// sign 计算 nonce 对应的签名
+func (s *DeviceSession) sign(nonce int) (string, error) {
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s:%s:%s:%d", DeviceSessionAppId, s.deviceId, s.userId, nonce)))
+	sig, err := s.key.sign(digest[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// request 请求设备会话接口，action 为 create_session 或者 renew_session
+func (s *DeviceSession) request(action, authorization, refreshToken string) *apierror.ApiError {
+	signature, err := s.sign(s.nonce)
+	if err != nil {
+		return apierror.NewFailedApiError(err.Error())
+	}
+	header := map[string]string{
+		"authorization": authorization,
+		"x-device-id":   s.deviceId,
+		"x-signature":   signature,
+	}
+	postData := map[string]interface{}{}
+	if action == "create_session" {
+		postData = map[string]interface{}{
+			"deviceName":   s.DeviceName,
+			"modelName":    s.ModelName,
+			"nonce":        s.nonce,
+			"pubKey":       s.key.publicKeyHex(),
+			"refreshToken": refreshToken,
+		}
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/%s", deviceSessionUrl, action)
+	logger.Verboseln("do request url: " + fullUrl.String())
+	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("device session error ", err)
+		return apierror.NewFailedApiError(err.Error())
+	}
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return err1
+	}
+	r := &deviceSessionResult{}
+	if err1 := json.Unmarshal(body, r); err1 != nil {
+		logger.Verboseln("parse device session result json error ", err1)
+		return apierror.NewFailedApiError(err1.Error())
+	}
+	if !r.Result && !r.Success {
+		return apierror.NewFailedApiError("设备会话请求失败: " + r.Message)
+	}
+	s.signature = signature
+	s.created = true
+	return nil
+}
+
+// ensure 会话没有创建时先创建，返回当前的签名
+func (s *DeviceSession) ensure(authorization, refreshToken string) (string, *apierror.ApiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.created {
+		if err := s.request("create_session", authorization, refreshToken); err != nil {
+			return "", err
+		}
+	}
+	return s.signature, nil
+}
+
+// recover 请求返回设备会话错误后恢复会话，usedSignature 为请求失败时使用的签名。
+// 签名无效时使用新的 nonce 重新创建会话，设备下线时续期会话。其他请求已经恢复过时直接返回新的签名
+func (s *DeviceSession) recover(code apierror.ApiCode, usedSignature, authorization, refreshToken string) (string, *apierror.ApiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created && s.signature != usedSignature {
+		return s.signature, nil
+	}
+	var err *apierror.ApiError
+	if code == apierror.ApiCodeUserDeviceOffline && s.created {
+		err = s.request("renew_session", authorization, refreshToken)
+	} else {
+		s.nonce++
+		err = s.request("create_session", authorization, refreshToken)
+	}
+	if err != nil {
+		return "", err
+	}
+	return s.signature, nil
+}
+
+// recreate token 刷新后使用新的 token 重新创建会话，usedSignature 为旧 token 请求使用的签名。
+// 其他请求已经重新创建过时直接返回新的签名
+func (s *DeviceSession) recreate(usedSignature, authorization, refreshToken string) (string, *apierror.ApiError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created && s.signature != usedSignature {
+		return s.signature, nil
+	}
+	s.nonce++
+	if err := s.request("create_session", authorization, refreshToken); err != nil {
+		return "", err
+	}
+	return s.signature, nil
+}
+
+// EnableDeviceSession 开启设备会话签名，之后网页版请求自动携带设备ID和签名，第一次请求时创建会话，
+// 返回签名无效或者设备下线时重新创建或续期会话并重试一次。传入nil关闭
+func (pc *PanClient) EnableDeviceSession(session *DeviceSession) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.device = session
+}
+
+// DeviceSession 返回开启的设备会话，没有开启返回nil
+func (pc *PanClient) DeviceSession() *DeviceSession {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.device
+}
+
+// CreateDeviceSession 立即创建设备会话，会话已经创建时使用新的 nonce 重新创建。没有开启设备会话时返回错误
+func (pc *PanClient) CreateDeviceSession() *apierror.ApiError {
+	pc.mu.RLock()
+	s := pc.device
+	authorization := pc.webToken.GetAuthorizationStr()
+	refreshToken := pc.webToken.RefreshToken
+	pc.mu.RUnlock()
+	if s == nil {
+		return apierror.NewFailedApiError("没有开启设备会话")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created {
+		s.nonce++
+	}
+	return s.request("create_session", authorization, refreshToken)
+}
+
+// signDeviceHeader 开启设备会话时返回携带设备ID和签名的请求头，没有开启时原样返回
+func (pc *PanClient) signDeviceHeader(header map[string]string) (map[string]string, *apierror.ApiError) {
+	pc.mu.RLock()
+	s := pc.device
+	refreshToken := pc.webToken.RefreshToken
+	pc.mu.RUnlock()
+	authorization, ok := header["authorization"]
+	if s == nil || !ok {
+		return header, nil
+	}
+	signature, err := s.ensure(authorization, refreshToken)
+	if err != nil {
+		return header, err
+	}
+	header = copyHeader(header)
+	header["x-device-id"] = s.deviceId
+	header["x-signature"] = signature
+	return header, nil
+}
+
+// resignDeviceHeader token 刷新后重新签名请求头。会话和 token 绑定，使用新的 token 重新创建会话
+func (pc *PanClient) resignDeviceHeader(header map[string]string) (map[string]string, *apierror.ApiError) {
+	pc.mu.RLock()
+	s := pc.device
+	refreshToken := pc.webToken.RefreshToken
+	pc.mu.RUnlock()
+	usedSignature, ok := header["x-signature"]
+	if s == nil || !ok {
+		return header, nil
+	}
+	signature, err := s.recreate(usedSignature, header["authorization"], refreshToken)
+	if err != nil {
+		return header, err
+	}
+	header = copyHeader(header)
+	header["x-signature"] = signature
+	return header, nil
+}
+
+// recoverDeviceSession 响应为设备会话错误时恢复会话，返回重试使用的请求头，不需要重试返回false
+func (pc *PanClient) recoverDeviceSession(header map[string]string, body []byte) (map[string]string, bool) {
+	if !bytes.Contains(body, []byte("Device")) {
+		return nil, false
+	}
+	e := apierror.ParseCommonApiError(body)
+	if e == nil || (e.Code != apierror.ApiCodeDeviceSessionSignatureInvalid && e.Code != apierror.ApiCodeUserDeviceOffline) {
+		return nil, false
+	}
+	pc.mu.RLock()
+	s := pc.device
+	refreshToken := pc.webToken.RefreshToken
+	pc.mu.RUnlock()
+	if s == nil {
+		return nil, false
+	}
+	signature, err := s.recover(e.Code, header["x-signature"], header["authorization"], refreshToken)
+	if err != nil {
+		logger.Verboseln("recover device session error ", err)
+		return nil, false
+	}
+	retryHeader := copyHeader(header)
+	retryHeader["x-signature"] = signature
+	return retryHeader, true
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestSecp256k1(t *testing.T) {
+	p := secpMul(secpG, big.NewInt(2))
+	if x := strings.ToUpper(hex.EncodeToString(p.x.Bytes())); x != "C6047F9441ED7D6D3045406E95C07CD85C778E4B8CEF3CA7ABAC09B95C709EE5" {
+		t.Fatalf("unexpected 2G x %s", x)
+	}
+	if y := strings.ToUpper(hex.EncodeToString(p.y.Bytes())); y != "1AE168FEA63DC339A3C58419466CEAEEF7F632653266D0E1236431A950CFE52A" {
+		t.Fatalf("unexpected 2G y %s", y)
+	}
+	if secpMul(secpG, secpN) != nil {
+		t.Fatal("nG should be infinity")
+	}
+
+	// 公开的 secp256k1 测试向量：私钥 -> 公钥
+	vectors := []struct{ d, x, y string }{
+		{"3", "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9", "388F7B0F632DE8140FE337E62A37F3566500A99934C2231B6CB9FD7584B8E672"},
+		{"FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364140", "79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", "B7C52588D95C3B9AA25B0403F1EEF75702E84BB7597AABE663B82F6F04EF2777"},
+		{"AA5E28D6A97A2479A65527F7290311A3624D4CC0FA1578598EE3C2613BF99522", "34F9460F0E4F08393D192B3C5133A6BA099AA0AD9FD54EBCCFACDFA239FF49C6", "0B71EA9BD730FD8923F6D25A7A91E7DD7728A960686CB5A901BB419E0F2CA232"},
+		{"7E2B897B8CEBC6361663AD410835639826D590F393D90A9538881735256DFAE3", "D74BF844B0862475103D96A611CF2D898447E288D34B360BC885CB8CE7C00575", "131C670D414C4546B88AC3FF664611B1C38CEB1C21D76369D7A7A0969D61D97D"},
+	}
+	for _, v := range vectors {
+		d, _ := new(big.Int).SetString(v.d, 16)
+		if pub := strings.ToUpper(secpPrivateKeyFromScalar(d).publicKeyHex()); pub != "04"+v.x+v.y {
+			t.Fatalf("unexpected public key of %s: %s", v.d, pub)
+		}
+	}
+
+	// 固定随机数的签名结果
+	d, _ := new(big.Int).SetString(vectors[2].d, 16)
+	nonce, _ := new(big.Int).SetString(vectors[3].d, 16)
+	hello := sha256.Sum256([]byte("hello"))
+	if sig := hex.EncodeToString(secpPrivateKeyFromScalar(d).signWithNonce(hello[:], nonce)); sig != "d74bf844b0862475103d96a611cf2d898447e288d34b360bc885cb8ce7c0057568e210d4c1ae8fc92712fefe4b30eb5d2b220008e65ef0c4d041c43e6277566201" {
+		t.Fatalf("unexpected signature %s", sig)
+	}
+
+	key, err := newSecpPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub := key.publicKeyHex(); len(pub) != 130 || !strings.HasPrefix(pub, "04") {
+		t.Fatalf("unexpected public key %s", pub)
+	}
+	digest := sha256.Sum256([]byte("hello"))
+	sig, err := key.sign(digest[:])
+	if err != nil || len(sig) != 65 || sig[64] > 3 {
+		t.Fatalf("unexpected signature %x %v", sig, err)
+	}
+	if new(big.Int).SetBytes(sig[32:64]).Cmp(secpHalfN) > 0 {
+		t.Fatal("signature s should be low")
+	}
+	if !secpVerify(key.pub, digest[:], sig) {
+		t.Fatal("signature should verify")
+	}
+	other := sha256.Sum256([]byte("world"))
+	if secpVerify(key.pub, other[:], sig) {
+		t.Fatal("signature should not verify other digest")
+	}
+}
+
+func TestDeviceSession(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		creates  []int
+		renews   int
+		validSig string
+		offline  bool
+		apiCalls int
+		// sessions 签名对应的会话创建时使用的 token
+		sessions = map[string]string{}
+		expired  bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/create_session":
+			body := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["refreshToken"] != "r"+strings.TrimPrefix(r.Header.Get("authorization"), "Bearer a") || r.Header.Get("x-device-id") != "dev1" {
+				t.Errorf("unexpected create session request %v %v", body, r.Header)
+			}
+			creates = append(creates, int(body["nonce"].(float64)))
+			validSig = r.Header.Get("x-signature")
+			sessions[validSig] = r.Header.Get("authorization")
+			w.Write([]byte(`{"result":true,"success":true}`))
+		case "/renew_session":
+			renews++
+			offline = false
+			validSig = r.Header.Get("x-signature")
+			sessions[validSig] = r.Header.Get("authorization")
+			w.Write([]byte(`{"result":true,"success":true}`))
+		default:
+			apiCalls++
+			if expired && r.Header.Get("authorization") == "Bearer a1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"code":"AccessTokenInvalid","message":"AccessToken is invalid"}`))
+				return
+			}
+			if r.Header.Get("x-device-id") != "dev1" || r.Header.Get("x-signature") != validSig || sessions[validSig] != r.Header.Get("authorization") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":"DeviceSessionSignatureInvalid","message":"signature invalid"}`))
+				return
+			}
+			if offline {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":"UserDeviceOffline","message":"device offline"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true}`))
+		}
+	}))
+	defer server.Close()
+	oldUrl := deviceSessionUrl
+	deviceSessionUrl = server.URL
+	defer func() { deviceSessionUrl = oldUrl }()
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1", RefreshToken: "r1"}, AppLoginToken{})
+	if p.CreateDeviceSession() == nil {
+		t.Fatal("expected error without device session")
+	}
+	s, err := NewDeviceSession("u1", "dev1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.EnableDeviceSession(s)
+	header := map[string]string{"authorization": p.authorizationStr()}
+
+	// 第一次请求时创建会话
+	body, err := p.fetch("POST", server.URL+"/api", map[string]string{}, header)
+	if err != nil || string(body) != `{"ok":true}` || len(creates) != 1 || creates[0] != 0 {
+		t.Fatalf("unexpected result %s %v, creates %v", body, err, creates)
+	}
+	if _, ok := header["x-signature"]; ok {
+		t.Fatal("caller header should not be modified")
+	}
+
+	// 签名失效，使用新的 nonce 重新创建会话后重试
+	mu.Lock()
+	validSig = "expired"
+	mu.Unlock()
+	body, err = p.WithContext(p.Context()).fetch("POST", server.URL+"/api", map[string]string{}, header)
+	if err != nil || string(body) != `{"ok":true}` || len(creates) != 2 || creates[1] != 1 || apiCalls != 3 {
+		t.Fatalf("unexpected result %s %v, creates %v, calls %d", body, err, creates, apiCalls)
+	}
+
+	// 设备下线，续期会话后重试
+	mu.Lock()
+	offline = true
+	mu.Unlock()
+	body, err = p.fetch("POST", server.URL+"/api", map[string]string{}, header)
+	if err != nil || string(body) != `{"ok":true}` || renews != 1 || len(creates) != 2 {
+		t.Fatalf("unexpected result %s %v, renews %d", body, err, renews)
+	}
+
+	// token 失效，刷新后使用新的 token 重新创建会话并重新签名
+	mu.Lock()
+	expired = true
+	mu.Unlock()
+	p.SetTokenRefreshFunc(func(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
+		return &WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a2", RefreshToken: "r2"}, nil
+	}, nil)
+	body, err = p.fetch("POST", server.URL+"/api", map[string]string{}, header)
+	if err != nil || string(body) != `{"ok":true}` || len(creates) != 3 || creates[2] != 2 {
+		t.Fatalf("unexpected result after token refresh %s %v, creates %v", body, err, creates)
+	}
+
+	if p.CloneWithToken(WebLoginToken{}).DeviceSession() != nil {
+		t.Fatal("clone with other token should not use device session")
+	}
+	p.EnableDeviceSession(nil)
+	body, _ = p.fetch("POST", server.URL+"/api", map[string]string{}, header)
+	if !strings.Contains(string(body), "DeviceSessionSignatureInvalid") {
+		t.Fatalf("expected signature error without device session, got %s", body)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import "github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+
+type (
+	// DriveAPI 网页端客户端 *PanClient 和开放平台客户端 *OpenPanClient 共有的网盘接口。
+	// 应用可以依赖该接口，用同一套代码同时支持个人账号登录(refresh_token)和开放平台应用授权两种方式
+	DriveAPI interface {
+		// DriveUserInfo 获取用户信息和网盘ID
+		DriveUserInfo() (*DriveUserInfo, *apierror.ApiError)
+
+		// 文件列表和文件信息
+		FileList(param *FileListParam) (*FileListResult, *apierror.ApiError)
+		FileListGetAll(param *FileListParam) (FileList, *apierror.ApiError)
+		FileInfoById(driveId, fileId string) (*FileEntity, *apierror.ApiError)
+		FileInfoByPath(driveId string, pathStr string) (*FileEntity, *apierror.ApiError)
+
+		// 文件操作
+		Mkdir(driveId, parentFileId, dirName string) (*MkdirResult, *apierror.ApiError)
+		FileRename(driveId, renameFileId, newName string) (bool, *apierror.ApiError)
+		FileMove(param []*FileMoveParam) ([]*FileMoveResult, *apierror.ApiError)
+		FileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+		RecycleBinFileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+
+		// 下载
+		GetFileDownloadUrl(param *GetFileDownloadUrlParam) (*GetFileDownloadUrlResult, *apierror.ApiError)
+	}
+
+	// DriveUserInfo 两种客户端共有的用户信息，客户端不支持的字段为空
+	DriveUserInfo struct {
+		// UserId 用户UID
+		UserId string `json:"userId"`
+		// Nickname 昵称
+		Nickname string `json:"nickname"`
+		// FileDriveId 文件网盘ID
+		FileDriveId string `json:"fileDriveId"`
+		// ResourceDriveId 资源库网盘ID，仅开放平台支持
+		ResourceDriveId string `json:"resourceDriveId"`
+		// AlbumDriveId 相册网盘ID，仅网页端支持
+		AlbumDriveId string `json:"albumDriveId"`
+	}
+
+	// webDriveAPI 网页端客户端适配器
+	webDriveAPI struct {
+		*PanClient
+	}
+
+	// openDriveAPI 开放平台客户端适配器
+	openDriveAPI struct {
+		*OpenPanClient
+	}
+)
+
+// 编译期检查适配器实现了 DriveAPI
+var (
+	_ DriveAPI = webDriveAPI{}
+	_ DriveAPI = openDriveAPI{}
+)
+
+// NewWebDriveAPI 使用网页端客户端创建 DriveAPI
+func NewWebDriveAPI(p *PanClient) DriveAPI {
+	return webDriveAPI{PanClient: p}
+}
+
+// NewOpenDriveAPI 使用开放平台客户端创建 DriveAPI
+func NewOpenDriveAPI(p *OpenPanClient) DriveAPI {
+	return openDriveAPI{OpenPanClient: p}
+}
+
+func (w webDriveAPI) DriveUserInfo() (*DriveUserInfo, *apierror.ApiError) {
+	u, err := w.GetUserInfo()
+	if err != nil {
+		return nil, err
+	}
+	return &DriveUserInfo{
+		UserId:       u.UserId,
+		Nickname:     u.Nickname,
+		FileDriveId:  u.FileDriveId,
+		AlbumDriveId: u.AlbumDriveId,
+	}, nil
+}
+
+func (o openDriveAPI) DriveUserInfo() (*DriveUserInfo, *apierror.ApiError) {
+	d, err := o.GetDriveInfo()
+	if err != nil {
+		return nil, err
+	}
+	return &DriveUserInfo{
+		UserId:          d.UserId,
+		Nickname:        d.Name,
+		FileDriveId:     d.DefaultDriveId,
+		ResourceDriveId: d.ResourceDriveId,
+	}, nil
+}
@@ -137,9 +137,15 @@ const (
 )
 
 func (a *AlbumEntity) CreatedAtStr() string {
+	if a == nil {
+		return ""
+	}
 	return apiutil.UnixTime2LocalFormat(a.CreatedAt)
 }
 func (a *AlbumEntity) UpdatedAtStr() string {
+	if a == nil {
+		return ""
+	}
 	return apiutil.UnixTime2LocalFormat(a.UpdatedAt)
 }
 
@@ -171,26 +177,20 @@ func (p *PanClient) AlbumListGetAll(param *AlbumListParam) (AlbumList, *apierror
 		Limit:          param.Limit,
 		Marker:         param.Marker,
 	}
-	if internalParam.Limit <= 0 {
-		internalParam.Limit = 100
-	}
+	internalParam.Limit = p.pageSize(internalParam.Limit)
 
 	fileList := AlbumList{}
-	result, err := p.AlbumList(internalParam)
-	if err != nil || result == nil {
-		return nil, err
-	}
-	fileList = append(fileList, result.Items...)
-
-	// more page?
-	for len(result.NextMarker) > 0 {
-		internalParam.Marker = result.NextMarker
-		result, err = p.AlbumList(internalParam)
-		if err == nil && result != nil {
-			fileList = append(fileList, result.Items...)
-		} else {
-			break
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.AlbumList(internalParam)
+		if err != nil {
+			return "", err
 		}
+		fileList = append(fileList, result.Items...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
 	}
 	return fileList, nil
 }
@@ -215,17 +215,14 @@ func (p *PanClient) AlbumList(param *AlbumListParam) (*AlbumListResult, *apierro
 
 func (p *PanClient) albumListReq(param *AlbumListParam) (*AlbumListResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
 	fmt.Fprintf(fullUrl, "%s/adrive/v1/album/list", API_URL)
 	logger.Verboseln("do request url: " + fullUrl.String())
 
-	limit := param.Limit
-	if limit <= 0 {
-		limit = 100
-	}
+	limit := p.pageSize(param.Limit)
 	if param.OrderBy == "" {
 		param.OrderBy = AlbumOrderByCreatedAt
 	}
@@ -242,7 +239,7 @@ func (p *PanClient) albumListReq(param *AlbumListParam) (*AlbumListResult, *apie
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get album list error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -265,7 +262,7 @@ func (p *PanClient) albumListReq(param *AlbumListParam) (*AlbumListResult, *apie
 // AlbumEdit 相簿编辑
 func (p *PanClient) AlbumCreate(param *AlbumCreateParam) (*AlbumEntity, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -282,7 +279,7 @@ func (p *PanClient) AlbumCreate(param *AlbumCreateParam) (*AlbumEntity, *apierro
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("create album error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -305,7 +302,7 @@ func (p *PanClient) AlbumCreate(param *AlbumCreateParam) (*AlbumEntity, *apierro
 // AlbumEdit 相簿编辑
 func (p *PanClient) AlbumEdit(param *AlbumEditParam) (*AlbumEntity, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -326,7 +323,7 @@ func (p *PanClient) AlbumEdit(param *AlbumEditParam) (*AlbumEntity, *apierror.Ap
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("edit album error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -349,7 +346,7 @@ func (p *PanClient) AlbumEdit(param *AlbumEditParam) (*AlbumEntity, *apierror.Ap
 // AlbumDelete 相簿删除
 func (p *PanClient) AlbumDelete(param *AlbumDeleteParam) (bool, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -365,7 +362,7 @@ func (p *PanClient) AlbumDelete(param *AlbumDeleteParam) (bool, *apierror.ApiErr
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("delete album error ", err)
 		return false, apierror.NewFailedApiError(err.Error())
@@ -382,7 +379,7 @@ func (p *PanClient) AlbumDelete(param *AlbumDeleteParam) (bool, *apierror.ApiErr
 // AlbumGet 获取相簿信息
 func (p *PanClient) AlbumGet(param *AlbumGetParam) (*AlbumEntity, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -398,7 +395,7 @@ func (p *PanClient) AlbumGet(param *AlbumGetParam) (*AlbumEntity, *apierror.ApiE
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get album error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -422,7 +419,7 @@ func (p *PanClient) AlbumGet(param *AlbumGetParam) (*AlbumEntity, *apierror.ApiE
 func (p *PanClient) AlbumShareCreate(param *AlbumShareCreateParam) (*AlbumShareCreateResult, *apierror.ApiError) {
 	// header
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -444,7 +441,7 @@ func (p *PanClient) AlbumShareCreate(param *AlbumShareCreateParam) (*AlbumShareC
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("create album share error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -472,26 +469,20 @@ func (p *PanClient) AlbumListFileGetAll(param *AlbumListFileParam) (FileList, *a
 		Limit:   param.Limit,
 		Marker:  param.Marker,
 	}
-	if internalParam.Limit <= 0 {
-		internalParam.Limit = 100
-	}
+	internalParam.Limit = p.pageSize(internalParam.Limit)
 
 	fileList := FileList{}
-	result, err := p.AlbumListFile(internalParam)
-	if err != nil || result == nil {
-		return nil, err
-	}
-	fileList = append(fileList, result.FileList...)
-
-	// more page?
-	for len(result.NextMarker) > 0 {
-		internalParam.Marker = result.NextMarker
-		result, err = p.AlbumListFile(internalParam)
-		if err == nil && result != nil {
-			fileList = append(fileList, result.FileList...)
-		} else {
-			break
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.AlbumListFile(internalParam)
+		if err != nil {
+			return "", err
 		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
 	}
 	return fileList, nil
 }
@@ -507,7 +498,7 @@ func (p *PanClient) AlbumListFile(param *AlbumListFileParam) (*FileListResult, *
 			if flr.Items[k] == nil {
 				continue
 			}
-			result.FileList = append(result.FileList, createFileEntity(flr.Items[k]))
+			result.FileList = append(result.FileList, p.newFileEntity(flr.Items[k]))
 		}
 		result.NextMarker = flr.NextMarker
 	}
@@ -516,25 +507,23 @@ func (p *PanClient) AlbumListFile(param *AlbumListFileParam) (*FileListResult, *
 
 func (p *PanClient) albumListFileReq(param *AlbumListFileParam) (*fileListResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
 	fmt.Fprintf(fullUrl, "%s/adrive/v1/album/list_files", API_URL)
 	logger.Verboseln("do request url: " + fullUrl.String())
 
-	limit := param.Limit
-	if limit <= 0 {
-		limit = 100
-	}
+	defaults := p.RequestDefaults()
+	limit := p.pageSize(param.Limit)
 	postData := map[string]interface{}{
 		"album_id":                param.AlbumId,
-		"image_thumbnail_process": "image/resize,w_400/format,jpeg",
-		"video_thumbnail_process": "video/snapshot,t_0,f_jpg,ar_auto,w_1000",
-		"image_url_process":       "image/resize,w_1920/format,jpeg",
+		"image_thumbnail_process": defaults.ImageThumbnailProcess,
+		"video_thumbnail_process": defaults.albumVideoThumbnailProcess(),
+		"image_url_process":       defaults.ImageUrlProcess,
 		"filter":                  "",
 		"fields":                  "*",
-		"limit":                   param.Limit,
+		"limit":                   limit,
 		"order_by":                "joined_at",
 		"order_direction":         "DESC",
 	}
@@ -543,7 +532,7 @@ func (p *PanClient) albumListFileReq(param *AlbumListFileParam) (*fileListResult
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get album file list error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -566,7 +555,7 @@ func (p *PanClient) albumListFileReq(param *AlbumListFileParam) (*fileListResult
 // AlbumDeleteFile 相簿删除文件列表
 func (p *PanClient) AlbumDeleteFile(param *AlbumDeleteFileParam) (bool, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -579,7 +568,7 @@ func (p *PanClient) AlbumDeleteFile(param *AlbumDeleteFileParam) (bool, *apierro
 	postData := param
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("delete album file error ", err)
 		return false, apierror.NewFailedApiError(err.Error())
@@ -596,7 +585,7 @@ func (p *PanClient) AlbumDeleteFile(param *AlbumDeleteFileParam) (bool, *apierro
 // AlbumAddFile 相簿增加文件列表
 func (p *PanClient) AlbumAddFile(param *AlbumAddFileParam) (*FileList, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -609,7 +598,7 @@ func (p *PanClient) AlbumAddFile(param *AlbumAddFileParam) (*FileList, *apierror
 	postData := param
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("add album file error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -622,7 +611,7 @@ func (p *PanClient) AlbumAddFile(param *AlbumAddFileParam) (*FileList, *apierror
 
 	// parse result
 	type fileListResult struct {
-		Items []*fileEntityResult `json:"file_list"`
+		Items []*FileEntityRaw `json:"file_list"`
 	}
 	r := &fileListResult{}
 	if err2 := json.Unmarshal(body, r); err2 != nil {
@@ -634,7 +623,7 @@ func (p *PanClient) AlbumAddFile(param *AlbumAddFileParam) (*FileList, *apierror
 		if r.Items[k] == nil {
 			continue
 		}
-		fileList = append(fileList, createFileEntity(r.Items[k]))
+		fileList = append(fileList, p.newFileEntity(r.Items[k]))
 	}
 	return &fileList, nil
 }
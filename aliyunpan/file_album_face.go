@@ -0,0 +1,185 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strconv"
+	"strings"
+)
+
+type (
+	// FaceGroupListParam 获取人物分组参数
+	FaceGroupListParam struct {
+		// DriveId 相册网盘ID
+		DriveId string `json:"drive_id"`
+		Limit   int    `json:"limit"`
+		// Marker 下一页参数
+		Marker string `json:"marker"`
+	}
+
+	// FaceGroup 人物分组，服务器按人脸自动聚类
+	FaceGroup struct {
+		// GroupId 分组ID
+		GroupId string `json:"group_id"`
+		// GroupName 人物名称，没有命名时为空
+		GroupName string `json:"group_name"`
+		// ImageCount 照片数量
+		ImageCount int `json:"image_count"`
+		// CoverUrl 封面地址
+		CoverUrl  string `json:"group_cover_url"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+	}
+
+	// FaceGroupListResult 人物分组列表
+	FaceGroupListResult struct {
+		Items []*FaceGroup `json:"items"`
+		// NextMarker 不为空，说明还有下一页
+		NextMarker string `json:"next_marker"`
+	}
+
+	// FaceGroupFileListParam 获取人物分组照片参数
+	FaceGroupFileListParam struct {
+		// DriveId 相册网盘ID
+		DriveId string `json:"drive_id"`
+		// GroupId 人物分组ID
+		GroupId string `json:"group_id"`
+		Limit   int    `json:"limit"`
+		// Marker 下一页参数
+		Marker string `json:"marker"`
+	}
+)
+
+// FaceGroupList 获取人物分组列表，对应手机客户端的"人物"相册
+func (p *PanClient) FaceGroupList(param *FaceGroupListParam) (*FaceGroupListResult, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/image/list_facegroups", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if param.DriveId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id cannot be empty")
+	}
+
+	postData := map[string]interface{}{
+		"drive_id": param.DriveId,
+		"limit":    p.pageSize(param.Limit),
+	}
+	if param.Marker != "" {
+		postData["marker"] = param.Marker
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get face group list error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &FaceGroupListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse face group list result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		item.CreatedAt = apiutil.UtcTime2LocalFormat(item.CreatedAt)
+		item.UpdatedAt = apiutil.UtcTime2LocalFormat(item.UpdatedAt)
+	}
+	return r, nil
+}
+
+// FaceGroupListGetAll 获取所有人物分组
+func (p *PanClient) FaceGroupListGetAll(param *FaceGroupListParam) ([]*FaceGroup, *apierror.ApiError) {
+	internalParam := *param
+	groups := []*FaceGroup{}
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.FaceGroupList(&internalParam)
+		if err != nil {
+			return "", err
+		}
+		groups = append(groups, result.Items...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// FaceGroupRename 修改人物分组的名称
+func (p *PanClient) FaceGroupRename(driveId, groupId, name string) (bool, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/image/update_facegroup", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if driveId == "" || groupId == "" {
+		return false, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id and group id cannot be empty")
+	}
+
+	postData := map[string]interface{}{
+		"drive_id":   driveId,
+		"group_id":   groupId,
+		"group_name": name,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("rename face group error ", err)
+		return false, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return false, err1
+	}
+	return true, nil
+}
+
+// FaceGroupFileList 获取人物分组下的照片
+func (p *PanClient) FaceGroupFileList(param *FaceGroupFileListParam) (*FileListResult, *apierror.ApiError) {
+	if param.DriveId == "" || param.GroupId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id and group id cannot be empty")
+	}
+	return p.FileSearch(&FileSearchParam{
+		DriveId: param.DriveId,
+		Query:   "face_group_id = " + strconv.Quote(param.GroupId),
+		OrderBy: "created_at DESC",
+		Limit:   param.Limit,
+		Marker:  param.Marker,
+	})
+}
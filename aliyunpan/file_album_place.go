@@ -0,0 +1,114 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+)
+
+type (
+	// PlaceLevel 地点分组的级别
+	PlaceLevel string
+
+	// AlbumPlaceListParam 获取地点分组参数
+	AlbumPlaceListParam struct {
+		// DriveId 相册网盘ID
+		DriveId string `json:"drive_id"`
+		// Level 分组级别，为空默认按城市分组
+		Level PlaceLevel `json:"address_level"`
+	}
+
+	// AlbumPlace 地点分组
+	AlbumPlace struct {
+		// Name 地点名称，例如：杭州市
+		Name string `json:"name"`
+		// Count 照片数量
+		Count int `json:"count"`
+		// Location 经纬度，格式：纬度,经度
+		Location string `json:"location"`
+		// CoverFileId 代表照片的文件ID
+		CoverFileId string `json:"cover_file_id"`
+		// CoverUrl 代表照片的缩略图地址
+		CoverUrl string `json:"cover_url"`
+	}
+
+	albumPlaceListResult struct {
+		Items []*AlbumPlace `json:"items"`
+	}
+)
+
+const (
+	// PlaceLevelCountry 按国家分组
+	PlaceLevelCountry PlaceLevel = "country"
+	// PlaceLevelProvince 按省份分组
+	PlaceLevelProvince PlaceLevel = "province"
+	// PlaceLevelCity 按城市分组
+	PlaceLevelCity PlaceLevel = "city"
+	// PlaceLevelDistrict 按区县分组
+	PlaceLevelDistrict PlaceLevel = "district"
+)
+
+// AlbumPlaceList 获取按地理位置分组的照片地点列表，对应手机客户端的"地点"页面
+func (p *PanClient) AlbumPlaceList(param *AlbumPlaceListParam) ([]*AlbumPlace, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/image/list_address_groups", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if param.DriveId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id cannot be empty")
+	}
+	level := param.Level
+	if level == "" {
+		level = PlaceLevelCity
+	}
+
+	postData := map[string]interface{}{
+		"drive_id":                param.DriveId,
+		"address_level":           level,
+		"image_thumbnail_process": p.RequestDefaults().ImageThumbnailProcess,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get album place list error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &albumPlaceListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse album place list result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	if r.Items == nil {
+		r.Items = []*AlbumPlace{}
+	}
+	return r.Items, nil
+}
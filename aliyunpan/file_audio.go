@@ -0,0 +1,130 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+)
+
+type (
+	// AudioPlayInfoParam 获取音频播放信息参数
+	AudioPlayInfoParam struct {
+		DriveId string `json:"drive_id"`
+		FileId  string `json:"file_id"`
+	}
+
+	// AudioTemplate 音频转码格式
+	AudioTemplate struct {
+		// TemplateId 转码格式，例如：LQ / HQ
+		TemplateId string `json:"template_id"`
+		// Status 转码状态，finished 代表可以播放
+		Status string `json:"status"`
+		// Url 播放地址
+		Url string `json:"url"`
+	}
+
+	// AudioMeta 音频元数据，服务器没有解析出来的字段为空
+	AudioMeta struct {
+		// Duration 时长，单位秒
+		Duration float64 `json:"duration"`
+		// Bitrate 码率，单位bps
+		Bitrate int64 `json:"bitrate"`
+		// Title 标题，来自ID3标签
+		Title string `json:"title"`
+		// Artist 艺术家，来自ID3标签
+		Artist string `json:"artist"`
+		// Album 专辑，来自ID3标签
+		Album string `json:"album"`
+	}
+
+	// AudioPlayInfo 音频播放信息
+	AudioPlayInfo struct {
+		DriveId      string           `json:"drive_id"`
+		FileId       string           `json:"file_id"`
+		TemplateList []*AudioTemplate `json:"template_list"`
+		Meta         AudioMeta        `json:"meta"`
+	}
+)
+
+// PlayUrl 返回可以播放的地址，优先返回高音质，没有可以播放的格式返回空字符串
+func (a *AudioPlayInfo) PlayUrl() string {
+	if a == nil {
+		return ""
+	}
+	url := ""
+	for _, t := range a.TemplateList {
+		if t == nil || t.Status != VideoTranscodeStatusFinished || t.Url == "" {
+			continue
+		}
+		if t.TemplateId == "HQ" {
+			return t.Url
+		}
+		if url == "" {
+			url = t.Url
+		}
+	}
+	return url
+}
+
+// GetAudioPlayInfo 获取音频文件的播放地址和元数据
+func (p *PanClient) GetAudioPlayInfo(param *AudioPlayInfoParam) (*AudioPlayInfo, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/databox/get_audio_play_info", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if param.DriveId == "" || param.FileId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id and file id cannot be empty")
+	}
+
+	postData := map[string]interface{}{
+		"drive_id": param.DriveId,
+		"file_id":  param.FileId,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get audio play info error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &AudioPlayInfo{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse audio play info result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	if r.DriveId == "" {
+		r.DriveId = param.DriveId
+	}
+	if r.FileId == "" {
+		r.FileId = param.FileId
+	}
+	return r, nil
+}
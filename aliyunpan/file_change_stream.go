@@ -0,0 +1,147 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"sync"
+	"time"
+)
+
+type (
+	// ChangeStreamConfig 文件变更事件流配置
+	ChangeStreamConfig struct {
+		// Cursor 起始游标，为空则从当前最新的游标开始，只接收之后的变更
+		Cursor string
+		// MinInterval 有变更时的查询间隔，默认为2秒
+		MinInterval time.Duration
+		// MaxInterval 长时间没有变更时逐渐延长查询间隔，最长为 MaxInterval，默认为1分钟
+		MaxInterval time.Duration
+		// OnError 查询变更出错，会在 MaxInterval 后重试
+		OnError func(err *apierror.ApiError)
+	}
+
+	// ChangeStream 文件变更事件流
+	ChangeStream struct {
+		source  changeSource
+		driveId string
+		config  ChangeStreamConfig
+		events  chan *FileChangeEvent
+
+		mu     sync.Mutex
+		cursor string
+	}
+
+	// changeSource 获取文件变更的接口，由 *PanClient 实现
+	changeSource interface {
+		FileGetLastCursor(driveId string) (string, *apierror.ApiError)
+		FileListDeltaGetAll(param *FileListDeltaParam) ([]*FileChangeEvent, string, *apierror.ApiError)
+	}
+)
+
+// WatchChanges 以事件流的形式获取网盘的文件变更。
+// 网页端没有可用的推送通道，这里使用文件变更接口自适应轮询：有变更时以 MinInterval 频繁查询，空闲时逐渐延长到 MaxInterval，
+// 通常几秒内就能收到变更，同时空闲时不会产生大量请求。ctx 取消后事件流关闭
+func (p *PanClient) WatchChanges(ctx context.Context, driveId string, config ChangeStreamConfig) (*ChangeStream, *apierror.ApiError) {
+	return newChangeStream(ctx, p, driveId, config)
+}
+
+func newChangeStream(ctx context.Context, source changeSource, driveId string, config ChangeStreamConfig) (*ChangeStream, *apierror.ApiError) {
+	if config.MinInterval <= 0 {
+		config.MinInterval = 2 * time.Second
+	}
+	if config.MaxInterval < config.MinInterval {
+		config.MaxInterval = time.Minute
+		if config.MaxInterval < config.MinInterval {
+			config.MaxInterval = config.MinInterval
+		}
+	}
+	cursor := config.Cursor
+	if cursor == "" {
+		c, err := source.FileGetLastCursor(driveId)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+	s := &ChangeStream{
+		source:  source,
+		driveId: driveId,
+		config:  config,
+		events:  make(chan *FileChangeEvent, 100),
+		cursor:  cursor,
+	}
+	go s.run(ctx)
+	return s, nil
+}
+
+// Events 变更事件，事件流停止后关闭
+func (s *ChangeStream) Events() <-chan *FileChangeEvent {
+	return s.events
+}
+
+// Cursor 已经处理到的游标，可以保存下来，下次通过 ChangeStreamConfig.Cursor 继续
+func (s *ChangeStream) Cursor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor
+}
+
+func (s *ChangeStream) run(ctx context.Context) {
+	defer close(s.events)
+	interval := s.config.MinInterval
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		events, cursor, err := s.source.FileListDeltaGetAll(&FileListDeltaParam{DriveId: s.driveId, Cursor: s.Cursor()})
+		for _, e := range events {
+			select {
+			case s.events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if cursor != "" {
+			s.mu.Lock()
+			s.cursor = cursor
+			s.mu.Unlock()
+		}
+
+		switch {
+		case err != nil:
+			logger.Verboseln("change stream list delta error ", err)
+			if s.config.OnError != nil {
+				s.config.OnError(err)
+			}
+			interval = s.config.MaxInterval
+		case len(events) > 0:
+			interval = s.config.MinInterval
+		default:
+			interval *= 2
+			if interval > s.config.MaxInterval {
+				interval = s.config.MaxInterval
+			}
+		}
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeChangeSource struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *fakeChangeSource) FileGetLastCursor(driveId string) (string, *apierror.ApiError) {
+	return "0", nil
+}
+
+func (f *fakeChangeSource) FileListDeltaGetAll(param *FileListDeltaParam) ([]*FileChangeEvent, string, *apierror.ApiError) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	n, _ := strconv.Atoi(param.Cursor)
+	if n >= 2 {
+		return nil, param.Cursor, nil
+	}
+	return []*FileChangeEvent{{Op: FileChangeOpCreate, FileId: strconv.Itoa(n)}}, strconv.Itoa(n + 1), nil
+}
+
+func TestChangeStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := newChangeStream(ctx, &fakeChangeSource{}, "1", ChangeStreamConfig{MinInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for e := range s.Events() {
+		ids = append(ids, e.FileId)
+		if len(ids) == 2 {
+			cancel()
+		}
+	}
+	if len(ids) != 2 || ids[0] != "0" || ids[1] != "1" || s.Cursor() != "2" {
+		t.Fatalf("unexpected events %v cursor %s", ids, s.Cursor())
+	}
+}
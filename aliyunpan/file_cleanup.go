@@ -0,0 +1,138 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+)
+
+type (
+	// CleanupSuggestionType 清理建议类型
+	CleanupSuggestionType string
+
+	// CleanupSuggestionGroup 一组清理建议
+	CleanupSuggestionGroup struct {
+		// Type 建议类型
+		Type CleanupSuggestionType `json:"type"`
+		// Title 显示名称，例如：重复文件
+		Title string `json:"title"`
+		// TotalSize 可以释放的空间大小
+		TotalSize int64 `json:"total_size"`
+		// FileList 建议删除的文件
+		FileList FileList `json:"-"`
+	}
+
+	cleanupSuggestionGroupResult struct {
+		Type      CleanupSuggestionType `json:"type"`
+		Title     string                `json:"title"`
+		TotalSize int64                 `json:"total_size"`
+		Items     []*FileEntityRaw      `json:"items"`
+	}
+
+	cleanupSuggestionResult struct {
+		Items []*cleanupSuggestionGroupResult `json:"items"`
+	}
+)
+
+const (
+	// CleanupSuggestionDuplicate 重复文件
+	CleanupSuggestionDuplicate CleanupSuggestionType = "duplicate"
+	// CleanupSuggestionLargeFile 大文件
+	CleanupSuggestionLargeFile CleanupSuggestionType = "large_file"
+	// CleanupSuggestionBlurryScreenshot 模糊照片和截图
+	CleanupSuggestionBlurryScreenshot CleanupSuggestionType = "screenshot"
+)
+
+// BatchActionParam 转换为批量操作参数，可以直接用于 FileDelete 等批量接口
+func (g *CleanupSuggestionGroup) BatchActionParam() []*FileBatchActionParam {
+	r := []*FileBatchActionParam{}
+	if g == nil {
+		return r
+	}
+	for _, f := range g.FileList {
+		if f == nil {
+			continue
+		}
+		r = append(r, &FileBatchActionParam{
+			DriveId: f.DriveId,
+			FileId:  f.FileId,
+		})
+	}
+	return r
+}
+
+// CleanupSuggestionList 获取服务器给出的空间清理建议，例如重复文件、大文件、模糊截图
+func (p *PanClient) CleanupSuggestionList(driveId string) ([]*CleanupSuggestionGroup, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v1/space/clean_suggestion/list", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if driveId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id cannot be empty")
+	}
+
+	postData := map[string]interface{}{
+		"drive_id":                driveId,
+		"image_thumbnail_process": p.RequestDefaults().ImageThumbnailProcess,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get cleanup suggestion error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &cleanupSuggestionResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse cleanup suggestion result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	groups := []*CleanupSuggestionGroup{}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		g := &CleanupSuggestionGroup{
+			Type:      item.Type,
+			Title:     item.Title,
+			TotalSize: item.TotalSize,
+			FileList:  FileList{},
+		}
+		for _, f := range item.Items {
+			if f == nil {
+				continue
+			}
+			g.FileList = append(g.FileList, p.newFileEntity(f))
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
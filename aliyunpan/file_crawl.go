@@ -0,0 +1,196 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// CrawlFolder 遍历队列中等待获取的文件夹
+	CrawlFolder struct {
+		FileId string `json:"i"`
+		Path   string `json:"p"`
+		// Marker 下一页参数，为空代表从第一页开始
+		Marker string `json:"m,omitempty"`
+	}
+
+	// CrawlState 可以恢复的目录遍历进度。遍历按页推进，每获取一页更新一次，
+	// 保存之后中断的遍历任务可以加载进度从中断的位置继续，不需要从根目录重新开始
+	CrawlState struct {
+		// Version 进度格式版本
+		Version  int    `json:"version"`
+		DriveId  string `json:"driveId"`
+		RootPath string `json:"rootPath"`
+		// Queue 等待获取的文件夹，第一个是正在获取的文件夹
+		Queue []*CrawlFolder `json:"queue"`
+		// Started 是否已经获取了根目录信息
+		Started bool `json:"started"`
+		// FolderCount 已经获取完的文件夹数量
+		FolderCount int64 `json:"folderCount"`
+		// FileCount 已经获取到的文件和文件夹数量
+		FileCount int64 `json:"fileCount"`
+	}
+
+	// CrawlPageFunc 每获取一页文件调用一次，调用时 state 已经更新为下一页的位置，
+	// 调用方可以在回调中同时保存文件和进度。返回错误则停止遍历
+	CrawlPageFunc func(state *CrawlState, files FileList) error
+
+	// crawlPageFetcher 获取一页文件列表
+	crawlPageFetcher func(param *FileListParam) (*FileListResult, *apierror.ApiError)
+)
+
+const (
+	// CrawlStateVersion 当前进度格式版本
+	CrawlStateVersion = 1
+)
+
+// NewCrawlState 创建从 rootPath 开始的遍历进度
+func NewCrawlState(driveId, rootPath string) *CrawlState {
+	return &CrawlState{
+		Version:  CrawlStateVersion,
+		DriveId:  driveId,
+		RootPath: rootPath,
+		Queue:    []*CrawlFolder{},
+	}
+}
+
+// IsFinished 是否已经遍历完成
+func (s *CrawlState) IsFinished() bool {
+	return s.Started && len(s.Queue) == 0
+}
+
+// Save 保存进度，使用gzip压缩的JSON格式
+func (s *CrawlState) Save(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	if err := json.NewEncoder(gw).Encode(s); err != nil {
+		gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+// SaveFile 保存进度到文件，先写临时文件再重命名，避免写入中断导致文件损坏
+func (s *CrawlState) SaveFile(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	tmpPath := filePath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err = s.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// LoadCrawlState 加载 Save 保存的进度
+func LoadCrawlState(r io.Reader) (*CrawlState, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	s := &CrawlState{}
+	if err = json.NewDecoder(gr).Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Version > CrawlStateVersion {
+		return nil, fmt.Errorf("unsupported crawl state version: %d", s.Version)
+	}
+	return s, nil
+}
+
+// LoadCrawlStateFile 从文件加载进度，文件不存在时返回 os.ErrNotExist
+func LoadCrawlStateFile(filePath string) (*CrawlState, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadCrawlState(f)
+}
+
+// Crawl 从 state 记录的位置继续广度优先遍历目录，每获取一页调用一次 onPage。
+// 页面在进度保存之前中断的话，恢复后该页会重新获取，调用方需要能处理重复的文件。
+// ctx 取消或者 onPage 返回错误时停止，state 保持在可以继续的位置
+func (p *PanClient) Crawl(ctx context.Context, state *CrawlState, onPage CrawlPageFunc) *apierror.ApiError {
+	c := p.WithContext(ctx).WithRequestClass(RequestClassBulk)
+	if !state.Started {
+		root, err := c.FileInfoByPath(state.DriveId, state.RootPath)
+		if err != nil {
+			return err
+		}
+		if !root.IsFolder() {
+			return apierror.NewApiError(apierror.ApiCodeBadRequest, "rootPath必须是文件夹")
+		}
+		state.Queue = []*CrawlFolder{{FileId: root.FileId, Path: state.RootPath}}
+		state.Started = true
+	}
+	return crawl(ctx, state, c.FileList, onPage)
+}
+
+func crawl(ctx context.Context, state *CrawlState, fetch crawlPageFetcher, onPage CrawlPageFunc) *apierror.ApiError {
+	for len(state.Queue) > 0 {
+		if ctx.Err() != nil {
+			return apierror.NewApiErrorWithError(ctx.Err())
+		}
+		folder := state.Queue[0]
+		r, err := fetch(&FileListParam{
+			DriveId:      state.DriveId,
+			ParentFileId: folder.FileId,
+			Marker:       folder.Marker,
+		})
+		if err != nil {
+			return err
+		}
+		for _, fi := range r.FileList {
+			fi.Path = apiutil.JoinDrivePath(folder.Path, fi.FileName)
+			if fi.IsFolder() {
+				state.Queue = append(state.Queue, &CrawlFolder{FileId: fi.FileId, Path: fi.Path})
+			}
+		}
+		state.FileCount += int64(len(r.FileList))
+		// 服务器返回重复的 marker 时当作最后一页，避免死循环
+		if r.NextMarker == "" || r.NextMarker == folder.Marker {
+			state.Queue = state.Queue[1:]
+			state.FolderCount++
+		} else {
+			folder.Marker = r.NextMarker
+		}
+		if onPage != nil {
+			if e := onPage(state, r.FileList); e != nil {
+				return apierror.NewFailedApiError(e.Error())
+			}
+		}
+	}
+	return nil
+}
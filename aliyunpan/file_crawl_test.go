@@ -0,0 +1,108 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestCrawlResume(t *testing.T) {
+	folder := func(id, name string) *FileEntity {
+		return &FileEntity{FileId: id, FileName: name, FileType: "folder"}
+	}
+	file := func(id, name string) *FileEntity {
+		return &FileEntity{FileId: id, FileName: name, FileType: "file"}
+	}
+	// key 为 文件夹ID + marker
+	pages := map[string]*FileListResult{
+		"root": {FileList: FileList{folder("a", "a"), file("b", "b.txt")}},
+		"a":    {FileList: FileList{file("c", "c.txt")}, NextMarker: "m1"},
+		"a/m1": {FileList: FileList{folder("d", "d")}},
+		"d":    {FileList: FileList{file("e", "e.txt")}},
+	}
+	fetch := func(param *FileListParam) (*FileListResult, *apierror.ApiError) {
+		key := param.ParentFileId
+		if param.Marker != "" {
+			key += "/" + param.Marker
+		}
+		r := pages[key]
+		// 每次返回新的文件对象，模拟重新请求
+		fl := FileList{}
+		for _, f := range r.FileList {
+			c := *f
+			fl = append(fl, &c)
+		}
+		return &FileListResult{FileList: fl, NextMarker: r.NextMarker}, nil
+	}
+
+	state := NewCrawlState("d1", "/")
+	state.Queue = []*CrawlFolder{{FileId: "root", Path: "/"}}
+	state.Started = true
+
+	var paths []string
+	stop := errors.New("stop")
+	var saved bytes.Buffer
+	err := crawl(context.Background(), state, fetch, func(s *CrawlState, files FileList) error {
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		saved.Reset()
+		if e := s.Save(&saved); e != nil {
+			t.Fatal(e)
+		}
+		if len(paths) >= 3 {
+			return stop
+		}
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected crawl to stop")
+	}
+
+	resumed, e := LoadCrawlState(&saved)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if resumed.IsFinished() || resumed.Queue[0].FileId != "a" || resumed.Queue[0].Marker != "m1" {
+		t.Fatalf("unexpected resumed state %+v", resumed.Queue[0])
+	}
+	if err = crawl(context.Background(), resumed, fetch, func(s *CrawlState, files FileList) error {
+		for _, f := range files {
+			paths = append(paths, f.Path)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	want := []string{"/a", "/a/c.txt", "/a/d", "/a/d/e.txt", "/b.txt"}
+	if len(paths) != len(want) {
+		t.Fatalf("unexpected paths %v", paths)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("unexpected paths %v", paths)
+		}
+	}
+	if !resumed.IsFinished() || resumed.FolderCount != 3 || resumed.FileCount != 5 {
+		t.Fatalf("unexpected final state %+v", resumed)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// DedupeScanParam 重复文件扫描参数
+	DedupeScanParam struct {
+		// DriveIds 需要扫描的网盘ID，可以同时指定文件网盘、相册网盘等，实现全账号扫描
+		DriveIds []string
+		// Path 扫描的目录，默认为根目录
+		Path string
+		// DeleteDuplicates 是否删除重复文件到回收站，每组只保留一个文件
+		DeleteDuplicates bool
+	}
+
+	// DuplicateSet 一组内容相同的文件
+	DuplicateSet struct {
+		ContentHash string
+		FileSize    int64
+		// Files 第一个文件为保留的文件
+		Files FileList
+		// ReclaimableSize 删除重复文件后可以释放的空间
+		ReclaimableSize int64
+	}
+
+	// DedupeScanResult 重复文件扫描结果
+	DedupeScanResult struct {
+		Sets []*DuplicateSet
+		// ReclaimableSize 所有重复文件可以释放的空间
+		ReclaimableSize int64
+		// DeletedFiles 已经删除的文件
+		DeletedFiles FileList
+	}
+)
+
+const (
+	// dedupeDeleteBatchSize 每次批量删除的文件数量
+	dedupeDeleteBatchSize = 100
+)
+
+// DedupeScan 扫描重复文件，按 ContentHash+大小 分组。每组保留创建时间最早的文件
+func (p *PanClient) DedupeScan(param *DedupeScanParam) (*DedupeScanResult, *apierror.ApiError) {
+	if param == nil || len(param.DriveIds) == 0 {
+		return nil, apierror.NewFailedApiError("网盘ID不能为空")
+	}
+	scanPath := param.Path
+	if scanPath == "" {
+		scanPath = "/"
+	}
+
+	groups := map[string]FileList{}
+	for _, driveId := range param.DriveIds {
+		var walkErr *apierror.ApiError
+		p.FilesDirectoriesRecurseList(driveId, scanPath, func(depth int, fdPath string, fd *FileEntity, apierr *apierror.ApiError) bool {
+			if apierr != nil {
+				walkErr = apierr
+				return false
+			}
+			if fd.IsFile() && fd.ContentHash != "" {
+				key := strings.ToUpper(fd.ContentHash) + "_" + strconv.FormatInt(fd.FileSize, 10)
+				groups[key] = append(groups[key], fd)
+			}
+			return true
+		})
+		if walkErr != nil {
+			return nil, walkErr
+		}
+	}
+
+	result := &DedupeScanResult{
+		Sets:         []*DuplicateSet{},
+		DeletedFiles: FileList{},
+	}
+	for _, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		sort.SliceStable(files, func(i, j int) bool {
+			if files[i].CreatedAt != files[j].CreatedAt {
+				return files[i].CreatedAt < files[j].CreatedAt
+			}
+			return len(files[i].Path) < len(files[j].Path)
+		})
+		set := &DuplicateSet{
+			ContentHash:     strings.ToUpper(files[0].ContentHash),
+			FileSize:        files[0].FileSize,
+			Files:           files,
+			ReclaimableSize: files[0].FileSize * int64(len(files)-1),
+		}
+		result.Sets = append(result.Sets, set)
+		result.ReclaimableSize += set.ReclaimableSize
+	}
+	sort.Slice(result.Sets, func(i, j int) bool {
+		return result.Sets[i].ReclaimableSize > result.Sets[j].ReclaimableSize
+	})
+
+	if !param.DeleteDuplicates {
+		return result, nil
+	}
+	toDelete := FileList{}
+	for _, set := range result.Sets {
+		toDelete = append(toDelete, set.Files[1:]...)
+	}
+	for start := 0; start < len(toDelete); start += dedupeDeleteBatchSize {
+		end := start + dedupeDeleteBatchSize
+		if end > len(toDelete) {
+			end = len(toDelete)
+		}
+		batch := toDelete[start:end]
+		batchParam := []*FileBatchActionParam{}
+		fileMap := map[string]*FileEntity{}
+		for _, f := range batch {
+			batchParam = append(batchParam, &FileBatchActionParam{DriveId: f.DriveId, FileId: f.FileId})
+			fileMap[f.FileId] = f
+		}
+		r, err := p.FileDelete(batchParam)
+		if err != nil {
+			logger.Verboseln("delete duplicate file error ", err)
+			return result, err
+		}
+		for _, item := range r {
+			if item.Success && fileMap[item.FileId] != nil {
+				result.DeletedFiles = append(result.DeletedFiles, fileMap[item.FileId])
+			}
+		}
+	}
+	return result, nil
+}
@@ -35,11 +35,16 @@ type(
 		FileId string
 		// 是否成功
 		Success bool
+		// AsyncTaskId 文件夹较大时服务器以异步任务处理，任务ID不为空
+		AsyncTaskId string
 	}
 )
 
 // FileDelete 删除文件到回收站
 func (p *PanClient) FileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/recyclebin/trash"); o != nil {
+		return p.openFileDelete(o, param)
+	}
 	// url
 	fullUrl := &strings.Builder{}
 	fmt.Fprintf(fullUrl, "%s/v2/batch", API_URL)
@@ -94,11 +99,47 @@ func (p *PanClient) doFileBatchRequest(url, actionUrl string, param []*FileBatch
 		r = append(r, &FileBatchActionResult{
 			FileId: item.Id,
 			Success: item.Status == 204 || item.Status == 202 || item.Status == 200,
+			AsyncTaskId: batchAsyncTaskId(item),
 		})
 	}
+	for _, dp := range param {
+		p.fileChanged(dp.DriveId, dp.FileId)
+	}
+	if actionUrl == "/recyclebin/restore" {
+		p.restoredChildrenChanged(param, result.Responses)
+	}
 	return r, nil
 }
 
+// restoredChildrenChanged 还原的文件已经不在缓存中，通过批量响应中的 parent_file_id 使原来所在文件夹的文件列表缓存失效，
+// 响应中没有时查询文件信息获取
+func (p *PanClient) restoredChildrenChanged(param []*FileBatchActionParam, responses BatchResponseList) {
+	if p.MetaStore() == nil {
+		return
+	}
+	parents := map[string]string{}
+	for _, item := range responses {
+		if item == nil || item.Body == nil {
+			continue
+		}
+		if id, ok := item.Body["parent_file_id"].(string); ok && id != "" {
+			parents[item.Id] = id
+		}
+	}
+	for _, dp := range param {
+		parentFileId, ok := parents[dp.FileId]
+		if !ok {
+			fe, err := p.WithCacheBypass().FileInfoById(dp.DriveId, dp.FileId)
+			if err != nil {
+				logger.Verboseln("get restored file info error ", err)
+				continue
+			}
+			parentFileId = fe.ParentFileId
+		}
+		p.childrenChanged(dp.DriveId, parentFileId)
+	}
+}
+
 func (p *PanClient) getFileDeleteBatchRequestList(actionUrl string, param []*FileBatchActionParam) (BatchRequestList, *apierror.ApiError) {
 	if param == nil {
 		return nil, apierror.NewFailedApiError("参数不能为空")
@@ -0,0 +1,210 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+)
+
+type (
+	// FileChangeOp 文件变更类型
+	FileChangeOp string
+
+	// FileListDeltaParam 文件变更列表参数
+	FileListDeltaParam struct {
+		DriveId string `json:"drive_id"`
+		// Cursor 游标，通过 FileGetLastCursor 获取，或者上一次 FileListDelta 返回的游标
+		Cursor string `json:"cursor"`
+		Limit  int    `json:"limit"`
+	}
+
+	// FileChangeEvent 文件变更事件
+	FileChangeEvent struct {
+		// Op 变更类型
+		Op FileChangeOp `json:"op"`
+		// FileId 变更的文件ID
+		FileId string `json:"fileId"`
+		// File 变更后的文件信息，删除事件可能为空
+		File *FileEntity `json:"file"`
+	}
+
+	// FileListDeltaResult 文件变更列表返回值
+	FileListDeltaResult struct {
+		Items []*FileChangeEvent `json:"items"`
+		// Cursor 下一次请求使用的游标
+		Cursor string `json:"cursor"`
+		// HasMore 是否还有更多变更
+		HasMore bool `json:"hasMore"`
+	}
+
+	fileDeltaItemResult struct {
+		Op     string         `json:"op"`
+		FileId string         `json:"file_id"`
+		File   *FileEntityRaw `json:"file"`
+	}
+
+	fileListDeltaResult struct {
+		Items   []*fileDeltaItemResult `json:"items"`
+		Cursor  string                 `json:"cursor"`
+		HasMore bool                   `json:"has_more"`
+	}
+
+	fileLastCursorResult struct {
+		Cursor string `json:"cursor"`
+	}
+)
+
+const (
+	// FileChangeOpCreate 创建
+	FileChangeOpCreate FileChangeOp = "create"
+	// FileChangeOpUpdate 修改
+	FileChangeOpUpdate FileChangeOp = "update"
+	// FileChangeOpDelete 删除
+	FileChangeOpDelete FileChangeOp = "delete"
+	// FileChangeOpMove 移动
+	FileChangeOpMove FileChangeOp = "move"
+	// FileChangeOpRename 重命名
+	FileChangeOpRename FileChangeOp = "rename"
+	// FileChangeOpTrash 移入回收站
+	FileChangeOpTrash FileChangeOp = "trash"
+	// FileChangeOpRestore 从回收站还原
+	FileChangeOpRestore FileChangeOp = "restore"
+)
+
+// IsRemoved 文件是否已经从网盘移除（删除或者移入回收站）
+func (e *FileChangeEvent) IsRemoved() bool {
+	return e != nil && (e.Op == FileChangeOpDelete || e.Op == FileChangeOpTrash)
+}
+
+// FileGetLastCursor 获取网盘当前最新的变更游标，用于后续增量获取变更
+func (p *PanClient) FileGetLastCursor(driveId string) (string, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/file/get_last_cursor", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	postData := map[string]interface{}{
+		"drive_id": driveId,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get last cursor error ", err)
+		return "", apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return "", err1
+	}
+
+	// parse result
+	r := &fileLastCursorResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse last cursor result json error ", err2)
+		return "", apierror.NewFailedApiError(err2.Error())
+	}
+	return r.Cursor, nil
+}
+
+// FileListDelta 获取游标之后的文件变更列表
+func (p *PanClient) FileListDelta(param *FileListDeltaParam) (*FileListDeltaResult, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/file/list_delta", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	limit := p.pageSize(param.Limit)
+	postData := map[string]interface{}{
+		"drive_id": param.DriveId,
+		"limit":    limit,
+	}
+	if len(param.Cursor) > 0 {
+		postData["cursor"] = param.Cursor
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get file list delta error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &fileListDeltaResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse file list delta result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+
+	result := &FileListDeltaResult{
+		Items:   []*FileChangeEvent{},
+		Cursor:  r.Cursor,
+		HasMore: r.HasMore,
+	}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		result.Items = append(result.Items, &FileChangeEvent{
+			Op:     FileChangeOp(item.Op),
+			FileId: item.FileId,
+			File:   p.newFileEntity(item.File),
+		})
+	}
+	return result, nil
+}
+
+// FileListDeltaGetAll 获取游标之后的所有文件变更，返回变更列表以及最新的游标
+func (p *PanClient) FileListDeltaGetAll(param *FileListDeltaParam) ([]*FileChangeEvent, string, *apierror.ApiError) {
+	internalParam := &FileListDeltaParam{
+		DriveId: param.DriveId,
+		Cursor:  param.Cursor,
+		Limit:   param.Limit,
+	}
+
+	events := []*FileChangeEvent{}
+	for {
+		result, err := p.FileListDelta(internalParam)
+		if err != nil {
+			return events, internalParam.Cursor, err
+		}
+		events = append(events, result.Items...)
+		if result.Cursor != "" {
+			internalParam.Cursor = result.Cursor
+		}
+		if !result.HasMore || result.Cursor == "" {
+			break
+		}
+	}
+	return events, internalParam.Cursor, nil
+}
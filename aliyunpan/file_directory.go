@@ -15,6 +15,7 @@
 package aliyunpan
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
@@ -22,6 +23,7 @@ import (
 	"github.com/tickstep/library-go/logger"
 	"path"
 	"strings"
+	"time"
 )
 
 type (
@@ -37,6 +39,8 @@ type (
 		Limit          int                `json:"limit"`
 		// Marker 下一页参数
 		Marker string `json:"marker"`
+		// ReturnPartialOnError 获取所有文件列表时，中途出错是否返回已经获取到的文件，默认出错时只返回错误
+		ReturnPartialOnError bool `json:"-"`
 	}
 
 	// FileListResult 文件列表返回值
@@ -66,6 +70,10 @@ type (
 		CreatedAt string `json:"createdAt"`
 		// 最后修改时间
 		UpdatedAt string `json:"updatedAt"`
+		// CreatedTime 创建时间，用于排序和比较
+		CreatedTime time.Time `json:"createdTime"`
+		// UpdatedTime 最后修改时间，用于排序和比较
+		UpdatedTime time.Time `json:"updatedTime"`
 		// 后缀名，例如：dmg
 		FileExtension string `json:"fileExtension"`
 		// 文件上传ID
@@ -86,9 +94,17 @@ type (
 		SyncFlag bool `json:"syncFlag"`
 		// SyncMeta 如果是同步盘的文件夹，则这里会记录该文件对应的同步机器和目录等信息
 		SyncMeta string `json:"syncMeta"`
+		// TrashedAt 移入回收站的时间，只有回收站的文件才有
+		TrashedAt string `json:"trashedAt"`
+		// Raw 服务器返回的原始文件信息，包含 FileEntity 没有映射的字段，例如：mime_type、status、encrypt_mode
+		Raw *FileEntityRaw `json:"-"`
+
+		// lang String() 使用的语言，为空代表中文
+		lang Language
 	}
 
-	fileEntityResult struct {
+	// FileEntityRaw 服务器返回的原始文件信息
+	FileEntityRaw struct {
 		DriveId         string `json:"drive_id"`
 		DomainId        string `json:"domain_id"`
 		FileId          string `json:"file_id"`
@@ -116,10 +132,13 @@ type (
 		PunishFlag      int    `json:"punish_flag"`
 		SyncFlag        bool   `json:"sync_flag"`
 		SyncMeta        string `json:"sync_meta"`
+		TrashedAt       string `json:"trashed_at"`
+		// Fields 服务器返回的全部字段，包含上面没有定义的字段，接口新增字段时可以从这里读取
+		Fields map[string]json.RawMessage `json:"-"`
 	}
 
 	fileListResult struct {
-		Items []*fileEntityResult `json:"items"`
+		Items []*FileEntityRaw `json:"items"`
 		// NextMarker 不为空，说明还有下一页
 		NextMarker string `json:"next_marker"`
 	}
@@ -153,7 +172,21 @@ func NewFileEntityForRootDir() *FileEntity {
 	}
 }
 
-func createFileEntity(f *fileEntityResult) *FileEntity {
+// UnmarshalJSON 解析已定义的字段，同时把全部字段保存到 Fields
+func (f *FileEntityRaw) UnmarshalJSON(data []byte) error {
+	type fileEntityRaw FileEntityRaw
+	if err := json.Unmarshal(data, (*fileEntityRaw)(f)); err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	f.Fields = fields
+	return nil
+}
+
+func createFileEntity(f *FileEntityRaw) *FileEntity {
 	if f == nil {
 		return nil
 	}
@@ -166,6 +199,8 @@ func createFileEntity(f *fileEntityResult) *FileEntity {
 		FileType:        f.Type,
 		CreatedAt:       apiutil.UtcTime2LocalFormat(f.CreatedAt),
 		UpdatedAt:       apiutil.UtcTime2LocalFormat(f.UpdatedAt),
+		CreatedTime:     apiutil.ParseUtcTime(f.CreatedAt),
+		UpdatedTime:     apiutil.ParseUtcTime(f.UpdatedAt),
 		FileExtension:   f.FileExtension,
 		UploadId:        f.UploadId,
 		ParentFileId:    f.ParentFileId,
@@ -176,35 +211,48 @@ func createFileEntity(f *fileEntityResult) *FileEntity {
 		Category:        f.Category,
 		SyncFlag:        f.SyncFlag,
 		SyncMeta:        f.SyncMeta,
+		TrashedAt:       apiutil.UtcTime2LocalFormat(f.TrashedAt),
+		Raw:             f,
 	}
 }
 
-// IsFolder 是否是文件夹
+// IsFolder 是否是文件夹，f 为nil时返回false
 func (f *FileEntity) IsFolder() bool {
-	return f.FileType == "folder"
+	return f != nil && f.FileType == "folder"
 }
 
-// 是否是文件
+// 是否是文件，f 为nil时返回false
 func (f *FileEntity) IsFile() bool {
-	return f.FileType == "file"
+	return f != nil && f.FileType == "file"
 }
 
-// 是否是网盘根目录
+// 是否是网盘根目录，f 为nil时返回false
 func (f *FileEntity) IsDriveRootFolder() bool {
-	return f.FileId == DefaultRootParentFileId
+	return f != nil && f.FileId == DefaultRootParentFileId
 }
 
-// 文件展示信息
+// 文件展示信息，使用获取该文件的客户端设置的语言
 func (f *FileEntity) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.Format(f.lang)
+}
+
+// Format 使用指定语言的标签生成展示信息
+func (f *FileEntity) Format(lang Language) string {
+	if f == nil {
+		return ""
+	}
 	builder := &strings.Builder{}
-	builder.WriteString("文件ID: " + f.FileId + "\n")
-	builder.WriteString("文件名: " + f.FileName + "\n")
+	builder.WriteString(lang.Message(MessageFileId) + ": " + f.FileId + "\n")
+	builder.WriteString(lang.Message(MessageFileName) + ": " + f.FileName + "\n")
 	if f.IsFolder() {
-		builder.WriteString("文件类型: 目录\n")
+		builder.WriteString(lang.Message(MessageFileType) + ": " + lang.Message(MessageTypeFolder) + "\n")
 	} else {
-		builder.WriteString("文件类型: 文件\n")
+		builder.WriteString(lang.Message(MessageFileType) + ": " + lang.Message(MessageTypeFile) + "\n")
 	}
-	builder.WriteString("文件路径: " + f.Path + "\n")
+	builder.WriteString(lang.Message(MessageFilePath) + ": " + f.Path + "\n")
 	return builder.String()
 }
 
@@ -239,26 +287,50 @@ func (fl FileList) Count() (fileN, directoryN int64) {
 
 // FileList 获取文件列表
 func (p *PanClient) FileList(param *FileListParam) (*FileListResult, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/list"); o != nil {
+		return p.openFileListPage(o, param)
+	}
+	if err := param.Validate(); err != nil {
+		return nil, apierror.NewApiErrorWithCause(apierror.ApiCodeBadRequest, err)
+	}
 	result := &FileListResult{
 		FileList:   FileList{},
 		NextMarker: "",
 	}
-	if flr, err := p.fileListReq(param); err == nil {
-		for k := range flr.Items {
-			if flr.Items[k] == nil {
-				continue
-			}
-
-			result.FileList = append(result.FileList, createFileEntity(flr.Items[k]))
+	flr, err := p.fileListReq(param)
+	if err != nil {
+		return nil, err
+	}
+	for k := range flr.Items {
+		if flr.Items[k] == nil {
+			continue
 		}
-		result.NextMarker = flr.NextMarker
+
+		result.FileList = append(result.FileList, p.newFileEntity(flr.Items[k]))
 	}
+	result.NextMarker = flr.NextMarker
 	return result, nil
 }
 
 func (p *PanClient) fileListReq(param *FileListParam) (*fileListResult, *apierror.ApiError) {
+	body, err := p.fileListBody(param)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse result
+	r := &fileListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse file list result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	return r, nil
+}
+
+// fileListBody 请求一页文件列表，返回未解析的响应
+func (p *PanClient) fileListBody(param *FileListParam) ([]byte, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -269,10 +341,8 @@ func (p *PanClient) fileListReq(param *FileListParam) (*fileListResult, *apierro
 	if pFileId == "" {
 		pFileId = DefaultRootParentFileId
 	}
-	limit := param.Limit
-	if limit <= 0 {
-		limit = 100
-	}
+	defaults := p.RequestDefaults()
+	limit := p.pageSize(param.Limit)
 	if param.OrderBy == "" {
 		param.OrderBy = FileOrderByUpdatedAt
 	}
@@ -284,10 +354,10 @@ func (p *PanClient) fileListReq(param *FileListParam) (*fileListResult, *apierro
 		"parent_file_id":          pFileId,
 		"limit":                   limit,
 		"all":                     false,
-		"url_expire_sec":          1600,
-		"image_thumbnail_process": "image/resize,w_400/format,jpeg",
-		"image_url_process":       "image/resize,w_1920/format,jpeg",
-		"video_thumbnail_process": "video/snapshot,t_0,f_jpg,ar_auto,w_800",
+		"url_expire_sec":          defaults.UrlExpireSec,
+		"image_thumbnail_process": defaults.ImageThumbnailProcess,
+		"image_url_process":       defaults.ImageUrlProcess,
+		"video_thumbnail_process": defaults.VideoThumbnailProcess,
 		"fields":                  "*",
 		"order_by":                param.OrderBy,
 		"order_direction":         param.OrderDirection,
@@ -297,7 +367,7 @@ func (p *PanClient) fileListReq(param *FileListParam) (*fileListResult, *apierro
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.hedgedFetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get file list error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -307,20 +377,22 @@ func (p *PanClient) fileListReq(param *FileListParam) (*fileListResult, *apierro
 	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
 		return nil, err1
 	}
-
-	// parse result
-	r := &fileListResult{}
-	if err2 := json.Unmarshal(body, r); err2 != nil {
-		logger.Verboseln("parse file list result json error ", err2)
-		return nil, apierror.NewFailedApiError(err2.Error())
-	}
-	return r, nil
+	return body, nil
 }
 
 // FileInfoById 通过FileId获取文件信息
 func (p *PanClient) FileInfoById(driveId, fileId string) (*FileEntity, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/get"); o != nil {
+		return p.openFileInfoById(o, driveId, fileId)
+	}
+	return p.retryAfterWrite(func() (*FileEntity, *apierror.ApiError) {
+		return p.fileInfoById(driveId, fileId)
+	})
+}
+
+func (p *PanClient) fileInfoById(driveId, fileId string) (*FileEntity, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -331,13 +403,16 @@ func (p *PanClient) FileInfoById(driveId, fileId string) (*FileEntity, *apierror
 	if pFileId == "" {
 		pFileId = DefaultRootParentFileId
 	}
+	if fe, ok := p.cachedFileInfo(driveId, pFileId); ok {
+		return fe, nil
+	}
 	postData := map[string]interface{}{
 		"drive_id": driveId,
 		"file_id":  pFileId,
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.hedgedFetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get file info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -349,16 +424,21 @@ func (p *PanClient) FileInfoById(driveId, fileId string) (*FileEntity, *apierror
 	}
 
 	// parse result
-	r := &fileEntityResult{}
+	r := &FileEntityRaw{}
 	if err2 := json.Unmarshal(body, r); err2 != nil {
 		logger.Verboseln("parse file info result json error ", err2)
 		return nil, apierror.NewFailedApiError(err2.Error())
 	}
-	return createFileEntity(r), nil
+	fe := p.newFileEntity(r)
+	p.storeFileInfo(fe)
+	return fe, nil
 }
 
 // FileInfoByPath 通过路径获取文件详情，pathStr是绝对路径
 func (p *PanClient) FileInfoByPath(driveId string, pathStr string) (fileInfo *FileEntity, error *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/get_by_path"); o != nil {
+		return p.openFileInfoByPath(o, driveId, pathStr)
+	}
 	if pathStr == "" {
 		pathStr = "/"
 	}
@@ -370,6 +450,10 @@ func (p *PanClient) FileInfoByPath(driveId string, pathStr string) (fileInfo *Fi
 		pathStr = path.Clean(pathStr)
 	}
 
+	if fe, ok := p.cachedFileInfoByPath(driveId, pathStr); ok {
+		return fe, nil
+	}
+
 	var pathSlice []string
 	if pathStr == "/" {
 		pathSlice = []string{""}
@@ -379,7 +463,9 @@ func (p *PanClient) FileInfoByPath(driveId string, pathStr string) (fileInfo *Fi
 			return nil, apierror.NewFailedApiError("pathStr必须是绝对路径")
 		}
 	}
-	fileInfo, error = p.getFileInfoByPath(driveId, 0, &pathSlice, nil)
+	fileInfo, error = p.retryAfterWrite(func() (*FileEntity, *apierror.ApiError) {
+		return p.getFileInfoByPath(driveId, 0, &pathSlice, nil)
+	})
 	if fileInfo != nil {
 		fileInfo.Path = pathStr
 	}
@@ -423,12 +509,23 @@ func (p *PanClient) getFileInfoByPath(driveId string, index int, pathSlice *[]st
 
 // FilesDirectoriesRecurseList 递归获取目录下的文件和目录列表
 func (p *PanClient) FilesDirectoriesRecurseList(driveId string, path string, handleFileDirectoryFunc HandleFileDirectoryFunc) FileList {
-	targetFileInfo, er := p.FileInfoByPath(driveId, path)
+	fld, _ := p.FilesDirectoriesRecurseListWithContext(context.Background(), driveId, path, handleFileDirectoryFunc)
+	return fld
+}
+
+// FilesDirectoriesRecurseListWithContext 递归获取目录下的文件和目录列表，ctx 取消后立即返回取消的错误，
+// 正在进行的文件列表请求不再等待，其结果会被丢弃
+func (p *PanClient) FilesDirectoriesRecurseListWithContext(ctx context.Context, driveId string, path string, handleFileDirectoryFunc HandleFileDirectoryFunc) (FileList, *apierror.ApiError) {
+	if p.requestClass != RequestClassBulk {
+		// 递归获取大量文件属于批量请求，不能影响交互请求
+		return p.WithRequestClass(RequestClassBulk).FilesDirectoriesRecurseListWithContext(ctx, driveId, path, handleFileDirectoryFunc)
+	}
+	targetFileInfo, er := p.fileInfoByPathContext(ctx, driveId, path)
 	if er != nil {
 		if handleFileDirectoryFunc != nil {
 			handleFileDirectoryFunc(0, path, nil, er)
 		}
-		return nil
+		return nil, er
 	}
 	if targetFileInfo.IsFolder() {
 		// folder
@@ -440,52 +537,124 @@ func (p *PanClient) FilesDirectoriesRecurseList(driveId string, path string, han
 		if handleFileDirectoryFunc != nil {
 			handleFileDirectoryFunc(0, path, targetFileInfo, nil)
 		}
-		return FileList{targetFileInfo}
+		return FileList{targetFileInfo}, nil
 	}
 
 	fld := &FileList{}
-	ok := p.recurseList(driveId, targetFileInfo, 1, handleFileDirectoryFunc, fld)
+	ok, er := p.recurseList(ctx, driveId, targetFileInfo, 1, handleFileDirectoryFunc, fld)
 	if !ok {
-		return nil
+		return nil, er
 	}
-	return *fld
+	return *fld, nil
 }
 
-func (p *PanClient) recurseList(driveId string, folderInfo *FileEntity, depth int, handleFileDirectoryFunc HandleFileDirectoryFunc, fld *FileList) bool {
+func (p *PanClient) recurseList(ctx context.Context, driveId string, folderInfo *FileEntity, depth int, handleFileDirectoryFunc HandleFileDirectoryFunc, fld *FileList) (bool, *apierror.ApiError) {
 	flp := &FileListParam{
 		DriveId:      driveId,
 		ParentFileId: folderInfo.FileId,
 	}
-	r, apiError := p.FileListGetAll(flp)
+	r, apiError := p.fileListGetAllContext(ctx, flp)
 	if apiError != nil {
 		if handleFileDirectoryFunc != nil {
 			handleFileDirectoryFunc(depth, folderInfo.Path, nil, apiError)
 		}
-		return false
+		return false, apiError
 	}
 	ok := true
 	for _, fi := range r {
-		fi.Path = strings.ReplaceAll(folderInfo.Path+PathSeparator+fi.FileName, "//", "/")
+		fi.Path = apiutil.JoinDrivePath(folderInfo.Path, fi.FileName)
 		*fld = append(*fld, fi)
 		if fi.IsFolder() {
 			if handleFileDirectoryFunc != nil {
 				ok = handleFileDirectoryFunc(depth, fi.Path, fi, nil)
 			}
-			ok = p.recurseList(driveId, fi, depth+1, handleFileDirectoryFunc, fld)
+			var er *apierror.ApiError
+			if ok, er = p.recurseList(ctx, driveId, fi, depth+1, handleFileDirectoryFunc, fld); er != nil {
+				return false, er
+			}
 		} else {
 			if handleFileDirectoryFunc != nil {
 				ok = handleFileDirectoryFunc(depth, fi.Path, fi, nil)
 			}
 		}
 		if !ok {
-			return false
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fileInfoByPathContext 获取文件信息，ctx 取消后立即返回
+func (p *PanClient) fileInfoByPathContext(ctx context.Context, driveId string, pathStr string) (*FileEntity, *apierror.ApiError) {
+	if ctx.Err() != nil {
+		return nil, apierror.NewApiErrorWithError(ctx.Err())
+	}
+	type result struct {
+		fe  *FileEntity
+		err *apierror.ApiError
+	}
+	// 缓冲为1，ctx 取消后请求返回时不会阻塞
+	resultChan := make(chan *result, 1)
+	go func() {
+		fe, err := p.FileInfoByPath(driveId, pathStr)
+		resultChan <- &result{fe: fe, err: err}
+	}()
+	select {
+	case r := <-resultChan:
+		return r.fe, r.err
+	case <-ctx.Done():
+		return nil, apierror.NewApiErrorWithError(ctx.Err())
+	}
+}
+
+// fileListGetAllContext 获取所有文件列表，ctx 取消后立即返回，不再等待正在进行的分页请求
+func (p *PanClient) fileListGetAllContext(ctx context.Context, param *FileListParam) (FileList, *apierror.ApiError) {
+	type result struct {
+		r   *FileListResult
+		err *apierror.ApiError
+	}
+	internalParam := *param
+	if fl, ok := p.cachedChildren(&internalParam); ok {
+		return fl, nil
+	}
+	fileList := FileList{}
+	pg := NewPaginator(param.Marker, func(marker string) (string, *apierror.ApiError) {
+		if ctx.Err() != nil {
+			return "", apierror.NewApiErrorWithError(ctx.Err())
+		}
+		pageParam := internalParam
+		pageParam.Marker = marker
+		resultChan := make(chan *result, 1)
+		go func() {
+			r, err := p.FileList(&pageParam)
+			resultChan <- &result{r: r, err: err}
+		}()
+		select {
+		case r := <-resultChan:
+			if r.err != nil {
+				return "", r.err
+			}
+			fileList = append(fileList, r.r.FileList...)
+			return r.r.NextMarker, nil
+		case <-ctx.Done():
+			return "", apierror.NewApiErrorWithError(ctx.Err())
 		}
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
+	}
+	if param.Marker == "" {
+		p.storeChildren(param.DriveId, param.ParentFileId, fileList)
 	}
-	return true
+	return fileList, nil
 }
 
-// GetAllFileList 获取指定目录下的所有文件列表
+// FileListGetAll 获取指定目录下的所有文件列表。中途出错时默认只返回错误，
+// 设置 ReturnPartialOnError 后同时返回已经获取到的文件
 func (p *PanClient) FileListGetAll(param *FileListParam) (FileList, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/list"); o != nil {
+		return p.openFileListGetAll(o, param)
+	}
 	internalParam := &FileListParam{
 		OrderBy:        param.OrderBy,
 		OrderDirection: param.OrderDirection,
@@ -494,26 +663,29 @@ func (p *PanClient) FileListGetAll(param *FileListParam) (FileList, *apierror.Ap
 		Limit:          param.Limit,
 		Marker:         param.Marker,
 	}
-	if internalParam.Limit <= 0 {
-		internalParam.Limit = 100
+	internalParam.Limit = p.pageSize(internalParam.Limit)
+	if fl, ok := p.cachedChildren(internalParam); ok {
+		return fl, nil
 	}
 
 	fileList := FileList{}
-	result, err := p.FileList(internalParam)
-	if err != nil || result == nil {
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.FileList(internalParam)
+		if err != nil {
+			return "", err
+		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		if param.ReturnPartialOnError {
+			return fileList, err
+		}
 		return nil, err
 	}
-	fileList = append(fileList, result.FileList...)
-
-	// more page?
-	for len(result.NextMarker) > 0 {
-		internalParam.Marker = result.NextMarker
-		result, err = p.FileList(internalParam)
-		if err == nil && result != nil {
-			fileList = append(fileList, result.FileList...)
-		} else {
-			break
-		}
+	if param.Marker == "" {
+		p.storeChildren(param.DriveId, param.ParentFileId, fileList)
 	}
 	return fileList, nil
 }
@@ -0,0 +1,165 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFileEntityNilSafety(t *testing.T) {
+	var f *FileEntity
+	if f.IsFolder() || f.IsFile() || f.IsDriveRootFolder() || f.String() != "" || len(f.Hashes()) != 0 {
+		t.Fatal("nil FileEntity should be treated as empty")
+	}
+	if createFileEntity(nil) != nil {
+		t.Fatal("expected nil entity")
+	}
+	var e *FileChangeEvent
+	if e.IsRemoved() {
+		t.Fatal("nil event should not be removed")
+	}
+	var a *AlbumEntity
+	if a.CreatedAtStr() != "" || a.UpdatedAtStr() != "" {
+		t.Fatal("nil album should have empty time")
+	}
+
+	fl := FileList{nil, {FileType: "file", FileSize: 10}, nil, {FileType: "folder"}}
+	if fl.TotalSize() != 10 {
+		t.Fatalf("unexpected total size %d", fl.TotalSize())
+	}
+	if fileN, dirN := fl.Count(); fileN != 1 || dirN != 1 {
+		t.Fatalf("unexpected count %d %d", fileN, dirN)
+	}
+	buf := &bytes.Buffer{}
+	if err := fl.Export(buf, ExportFormatNdjson); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(buf.Bytes(), []byte("\n")) != 2 {
+		t.Fatalf("unexpected export %s", buf.String())
+	}
+
+	var nilList FileList
+	if nilList.TotalSize() != 0 {
+		t.Fatal("nil list should be empty")
+	}
+}
+
+func TestFileEntityCloneEqual(t *testing.T) {
+	f := createFileEntity(&FileEntityRaw{FileId: "1", Name: "a.txt", Type: "file", Size: 3, ContentHash: "abc", UpdatedAt: "2021-07-29T23:18:07.000Z"})
+	c := f.Clone()
+	if !f.Equal(c) || c.Raw == f.Raw {
+		t.Fatal("clone should be equal and deep")
+	}
+	c.Raw.Name = "b.txt"
+	if f.Raw.Name != "a.txt" {
+		t.Fatal("clone should not share raw")
+	}
+	// 展示语言不同的文件信息相等
+	c.lang = LanguageEn
+	if !f.Equal(c) {
+		t.Fatal("language should not affect equality")
+	}
+	c.Path = "/dir/a.txt"
+	if f.Equal(c) || !f.Equal(c, FileFieldFileId, FileFieldHash, FileFieldUpdatedAt) {
+		t.Fatal("unexpected field compare")
+	}
+	var nilEntity *FileEntity
+	if !nilEntity.Equal(nil) || f.Equal(nil) || nilEntity.Clone() != nil {
+		t.Fatal("unexpected nil compare")
+	}
+
+	fl := FileList{f, nil}
+	cl := fl.Clone()
+	if !fl.Equal(cl) || cl[0] == fl[0] || fl.Equal(cl[:1]) {
+		t.Fatal("unexpected list compare")
+	}
+}
+
+func TestFileEntityMarshal(t *testing.T) {
+	f := createFileEntity(&FileEntityRaw{FileId: "1", Name: "a b.txt", Type: "file", Size: 3, UpdatedAt: "2021-07-29T23:18:07.000Z"})
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"createdTime":""`)) || bytes.Contains(data, []byte("Raw")) {
+		t.Fatalf("unexpected json %s", data)
+	}
+	r := &FileEntity{}
+	if err = json.Unmarshal(data, r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal(f) {
+		t.Fatalf("unexpected unmarshal result %+v", r)
+	}
+
+	text, _ := f.MarshalText()
+	if !strings.HasPrefix(string(text), `file id=1 name="a b.txt" size=3 path="a b.txt" updated=`) {
+		t.Fatalf("unexpected text %s", text)
+	}
+}
+
+func TestParseSyncMeta(t *testing.T) {
+	info, err := ParseSyncMeta(`{"device_id":"d1","device_name":"MacBook","platform":"mac","local_path":"/Users/tick/Documents"}`)
+	if err != nil || info.DeviceName != "MacBook" || info.Platform != "mac" || info.SourceFolder != "/Users/tick/Documents" {
+		t.Fatalf("unexpected sync meta %+v %v", info, err)
+	}
+	if _, err = (&FileEntity{SyncMeta: `{"device_id":"d1"}`}).SyncMetaInfo(); err != ErrSyncMetaEmpty {
+		t.Fatalf("expected ErrSyncMetaEmpty for non sync folder, got %v", err)
+	}
+	if _, err = ParseSyncMeta("not json"); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
+
+func TestFileEntityLanguage(t *testing.T) {
+	raw := &FileEntityRaw{FileId: "1", Name: "dir", Type: "folder"}
+	if s := createFileEntity(raw).String(); !strings.HasPrefix(s, "文件ID: 1\n") || !strings.Contains(s, "文件类型: 目录") {
+		t.Fatalf("unexpected default string %q", s)
+	}
+
+	pc := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	pc.SetLanguage(LanguageEn)
+	fe := pc.CloneWithToken(WebLoginToken{}).newFileEntity(raw)
+	if s := fe.String(); s != "File ID: 1\nName: dir\nType: Folder\nPath: dir\n" {
+		t.Fatalf("unexpected english string %q", s)
+	}
+
+	RegisterMessages("fr", map[string]string{MessageFileName: "Nom"})
+	if s := fe.Format("fr"); !strings.Contains(s, "Nom: dir") || !strings.Contains(s, "文件路径: dir") {
+		t.Fatalf("unexpected registered string %q", s)
+	}
+}
+
+func TestFileEntityRawKeepsUnknownFields(t *testing.T) {
+	data := `{"items":[{"drive_id":"d1","file_id":"1","name":"a.txt","type":"file","new_field":{"k":1}}]}`
+	r := &fileListResult{}
+	if err := json.Unmarshal([]byte(data), r); err != nil {
+		t.Fatal(err)
+	}
+	f := createFileEntity(r.Items[0])
+	if f.FileName != "a.txt" || string(f.Raw.Fields["new_field"]) != `{"k":1}` || string(f.Raw.Fields["file_id"]) != `"1"` {
+		t.Fatalf("unexpected raw %+v", f.Raw)
+	}
+
+	// 拷贝不共享字段
+	c := f.Clone()
+	c.Raw.Fields["new_field"] = json.RawMessage(`2`)
+	if string(f.Raw.Fields["new_field"]) != `{"k":1}` {
+		t.Fatal("clone should not share raw fields")
+	}
+}
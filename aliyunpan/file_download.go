@@ -21,7 +21,6 @@ import (
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
 	"github.com/tickstep/library-go/cachepool"
 	"github.com/tickstep/library-go/logger"
-	"github.com/tickstep/library-go/requester"
 	"io"
 	"net/http"
 	"strconv"
@@ -66,9 +65,12 @@ const(
 
 // GetFileDownloadUrl 获取文件下载URL路径
 func (p *PanClient) GetFileDownloadUrl(param *GetFileDownloadUrlParam) (*GetFileDownloadUrlResult, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/getDownloadUrl"); o != nil {
+		return o.GetFileDownloadUrl(param)
+	}
 	// header
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -88,7 +90,7 @@ func (p *PanClient) GetFileDownloadUrl(param *GetFileDownloadUrlParam) (*GetFile
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get file download url error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -148,7 +150,13 @@ func (p *PanClient) DownloadFileData(downloadFileUrl string, fileRange FileDownl
 func (p *PanClient) DownloadFileDataAndSave(downloadFileUrl string, fileRange FileDownloadRange, writerAt io.WriterAt) *apierror.ApiError {
 	var resp *http.Response
 	var err error
-	var client = requester.NewHTTPClient()
+	var client = NewHTTPClient()
+
+	release, err := p.acquire(RequestClassBulk)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	defer release()
 
 	apierr := p.DownloadFileData(
 		downloadFileUrl,
@@ -199,6 +207,9 @@ func (p *PanClient) DownloadFileDataAndSave(downloadFileUrl string, fileRange Fi
 
 	var readErr error
 	totalCount = 0
+	defer func() {
+		p.stats.download(int64(totalCount))
+	}()
 
 	for true {
 		readByteCount, readErr = resp.Body.Read(buf)
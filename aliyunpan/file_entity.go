@@ -0,0 +1,216 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// FileField FileEntity 中用于比较的字段
+	FileField string
+
+	// fileEntityAlias 避免 MarshalJSON 递归调用
+	fileEntityAlias FileEntity
+
+	// fileEntityJSON FileEntity 的JSON格式，字段名保持稳定。时间字段使用RFC3339格式，零值为空字符串
+	fileEntityJSON struct {
+		*fileEntityAlias
+		CreatedTime string `json:"createdTime"`
+		UpdatedTime string `json:"updatedTime"`
+	}
+)
+
+const (
+	FileFieldDriveId      FileField = "driveId"
+	FileFieldFileId       FileField = "fileId"
+	FileFieldFileName     FileField = "fileName"
+	FileFieldFileSize     FileField = "fileSize"
+	FileFieldFileType     FileField = "fileType"
+	FileFieldParentFileId FileField = "parentFileId"
+	FileFieldPath         FileField = "path"
+	// FileFieldHash 比较 ContentHash 和 Crc64Hash
+	FileFieldHash FileField = "hash"
+	// FileFieldCreatedAt 比较 CreatedAt 和 CreatedTime
+	FileFieldCreatedAt FileField = "createdAt"
+	// FileFieldUpdatedAt 比较 UpdatedAt 和 UpdatedTime
+	FileFieldUpdatedAt FileField = "updatedAt"
+)
+
+// Clone 深拷贝文件信息，修改拷贝不会影响原来的对象
+func (f *FileEntity) Clone() *FileEntity {
+	if f == nil {
+		return nil
+	}
+	c := *f
+	if f.Raw != nil {
+		raw := *f.Raw
+		if f.Raw.Fields != nil {
+			raw.Fields = make(map[string]json.RawMessage, len(f.Raw.Fields))
+			for k, v := range f.Raw.Fields {
+				raw.Fields[k] = v
+			}
+		}
+		c.Raw = &raw
+	}
+	return &c
+}
+
+// Equal 逐个字段比较两个文件信息。fields 为空时比较除 Raw 和展示语言之外的所有字段，否则只比较指定的字段
+func (f *FileEntity) Equal(other *FileEntity, fields ...FileField) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	if len(fields) == 0 {
+		if !f.CreatedTime.Equal(other.CreatedTime) || !f.UpdatedTime.Equal(other.UpdatedTime) {
+			return false
+		}
+		// time.Time 已经比较过，不能直接使用 == 比较；lang 只影响展示，不参与比较
+		a, b := *f, *other
+		a.Raw, b.Raw = nil, nil
+		a.lang, b.lang = "", ""
+		a.CreatedTime, b.CreatedTime = time.Time{}, time.Time{}
+		a.UpdatedTime, b.UpdatedTime = time.Time{}, time.Time{}
+		return a == b
+	}
+	for _, field := range fields {
+		if !f.equalField(other, field) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *FileEntity) equalField(other *FileEntity, field FileField) bool {
+	switch field {
+	case FileFieldDriveId:
+		return f.DriveId == other.DriveId
+	case FileFieldFileId:
+		return f.FileId == other.FileId
+	case FileFieldFileName:
+		return f.FileName == other.FileName
+	case FileFieldFileSize:
+		return f.FileSize == other.FileSize
+	case FileFieldFileType:
+		return f.FileType == other.FileType
+	case FileFieldParentFileId:
+		return f.ParentFileId == other.ParentFileId
+	case FileFieldPath:
+		return f.Path == other.Path
+	case FileFieldHash:
+		return f.ContentHash == other.ContentHash && f.Crc64Hash == other.Crc64Hash
+	case FileFieldCreatedAt:
+		return f.CreatedAt == other.CreatedAt && f.CreatedTime.Equal(other.CreatedTime)
+	case FileFieldUpdatedAt:
+		return f.UpdatedAt == other.UpdatedAt && f.UpdatedTime.Equal(other.UpdatedTime)
+	}
+	return false
+}
+
+// Clone 深拷贝文件列表
+func (fl FileList) Clone() FileList {
+	if fl == nil {
+		return nil
+	}
+	c := make(FileList, len(fl))
+	for k := range fl {
+		c[k] = fl[k].Clone()
+	}
+	return c
+}
+
+// Equal 按顺序逐个比较两个文件列表，fields 的含义和 FileEntity.Equal 相同
+func (fl FileList) Equal(other FileList, fields ...FileField) bool {
+	if len(fl) != len(other) {
+		return false
+	}
+	for k := range fl {
+		if !fl[k].Equal(other[k], fields...) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatJSONTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func parseJSONTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// MarshalJSON 实现 json.Marshaler，输出稳定的JSON格式，不包含 Raw
+func (f FileEntity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&fileEntityJSON{
+		fileEntityAlias: (*fileEntityAlias)(&f),
+		CreatedTime:     formatJSONTime(f.CreatedTime),
+		UpdatedTime:     formatJSONTime(f.UpdatedTime),
+	})
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler，解析 MarshalJSON 输出的格式
+func (f *FileEntity) UnmarshalJSON(data []byte) error {
+	v := &fileEntityJSON{fileEntityAlias: (*fileEntityAlias)(f)}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	var err error
+	if f.CreatedTime, err = parseJSONTime(v.CreatedTime); err != nil {
+		return err
+	}
+	if f.UpdatedTime, err = parseJSONTime(v.UpdatedTime); err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarshalText 实现 encoding.TextMarshaler，输出单行的紧凑格式，适合日志使用，例如：
+// file id=xxx name="a.txt" size=3 path="/a.txt" updated=2021-07-29T23:18:07+08:00
+func (f FileEntity) MarshalText() ([]byte, error) {
+	return []byte(f.Compact()), nil
+}
+
+// Compact 单行的紧凑格式，和 MarshalText 相同。String 返回适合展示给用户的多行格式
+func (f *FileEntity) Compact() string {
+	if f == nil {
+		return ""
+	}
+	builder := &strings.Builder{}
+	fileType := f.FileType
+	if fileType == "" {
+		fileType = "unknown"
+	}
+	builder.WriteString(fileType)
+	builder.WriteString(" id=" + f.FileId)
+	builder.WriteString(" name=" + strconv.Quote(f.FileName))
+	if !f.IsFolder() {
+		builder.WriteString(" size=" + strconv.FormatInt(f.FileSize, 10))
+	}
+	builder.WriteString(" path=" + strconv.Quote(f.Path))
+	if !f.UpdatedTime.IsZero() {
+		builder.WriteString(" updated=" + formatJSONTime(f.UpdatedTime))
+	}
+	return builder.String()
+}
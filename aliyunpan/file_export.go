@@ -0,0 +1,183 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"io"
+	"strconv"
+)
+
+type (
+	// ExportFormat 文件列表导出格式
+	ExportFormat string
+
+	// exportRecord 导出的文件记录
+	exportRecord struct {
+		Path        string `json:"path"`
+		Type        string `json:"type"`
+		FileId      string `json:"fileId"`
+		Size        int64  `json:"size"`
+		ContentHash string `json:"contentHash"`
+		Crc64Hash   string `json:"crc64Hash"`
+		UpdatedAt   string `json:"updatedAt"`
+	}
+
+	// FileListExporter 流式导出文件列表
+	FileListExporter struct {
+		w      io.Writer
+		format ExportFormat
+		csvw   *csv.Writer
+		count  int
+	}
+)
+
+const (
+	// ExportFormatCsv CSV格式，第一行为表头
+	ExportFormatCsv ExportFormat = "csv"
+	// ExportFormatJson JSON数组
+	ExportFormatJson ExportFormat = "json"
+	// ExportFormatNdjson 每行一个JSON对象
+	ExportFormatNdjson ExportFormat = "ndjson"
+)
+
+var (
+	exportCsvHeader = []string{"path", "type", "fileId", "size", "contentHash", "crc64Hash", "updatedAt"}
+)
+
+// NewFileListExporter 创建流式导出器，写入完成后必须调用 Close
+func NewFileListExporter(w io.Writer, format ExportFormat) (*FileListExporter, error) {
+	e := &FileListExporter{
+		w:      w,
+		format: format,
+	}
+	switch format {
+	case ExportFormatCsv:
+		e.csvw = csv.NewWriter(w)
+		if err := e.csvw.Write(exportCsvHeader); err != nil {
+			return nil, err
+		}
+	case ExportFormatJson:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return nil, err
+		}
+	case ExportFormatNdjson:
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+	return e, nil
+}
+
+// Write 写入一个文件记录
+func (e *FileListExporter) Write(f *FileEntity) error {
+	if f == nil {
+		return nil
+	}
+	r := &exportRecord{
+		Path:        f.Path,
+		Type:        f.FileType,
+		FileId:      f.FileId,
+		Size:        f.FileSize,
+		ContentHash: f.ContentHash,
+		Crc64Hash:   f.Crc64Hash,
+		UpdatedAt:   f.UpdatedAt,
+	}
+
+	switch e.format {
+	case ExportFormatCsv:
+		if err := e.csvw.Write([]string{r.Path, r.Type, r.FileId, strconv.FormatInt(r.Size, 10), r.ContentHash, r.Crc64Hash, r.UpdatedAt}); err != nil {
+			return err
+		}
+	case ExportFormatJson, ExportFormatNdjson:
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		prefix, suffix := "", "\n"
+		if e.format == ExportFormatJson {
+			suffix = ""
+			if e.count > 0 {
+				prefix = ","
+			}
+		}
+		if _, err = io.WriteString(e.w, prefix+string(data)+suffix); err != nil {
+			return err
+		}
+	}
+	e.count++
+	return nil
+}
+
+// Close 完成导出
+func (e *FileListExporter) Close() error {
+	switch e.format {
+	case ExportFormatCsv:
+		e.csvw.Flush()
+		return e.csvw.Error()
+	case ExportFormatJson:
+		_, err := io.WriteString(e.w, "]\n")
+		return err
+	}
+	return nil
+}
+
+// Export 导出文件列表，包含路径、大小、哈希值、修改时间等信息，可用于审计或外部去重
+func (fl FileList) Export(w io.Writer, format ExportFormat) error {
+	e, err := NewFileListExporter(w, format)
+	if err != nil {
+		return err
+	}
+	for _, f := range fl {
+		if err = e.Write(f); err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
+
+// ExportTree 递归导出网盘目录下的所有文件和文件夹，边遍历边写入
+func (p *PanClient) ExportTree(driveId, pathStr string, w io.Writer, format ExportFormat) *apierror.ApiError {
+	e, err := NewFileListExporter(w, format)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+
+	var walkErr *apierror.ApiError
+	p.FilesDirectoriesRecurseList(driveId, pathStr, func(depth int, fdPath string, fd *FileEntity, apierr *apierror.ApiError) bool {
+		if apierr != nil {
+			walkErr = apierr
+			return false
+		}
+		if depth == 0 && fd.IsFolder() {
+			// 不导出根目录本身
+			return true
+		}
+		if err := e.Write(fd); err != nil {
+			walkErr = apierror.NewApiErrorWithError(err)
+			return false
+		}
+		return true
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	if err = e.Close(); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	return nil
+}
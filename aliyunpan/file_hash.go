@@ -0,0 +1,80 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// HashType 哈希类型，名称和rclone保持一致
+	HashType string
+
+	// FileHashes 文件哈希值集合
+	FileHashes map[HashType]string
+)
+
+const (
+	// HashTypeSha1 SHA1，小写十六进制
+	HashTypeSha1 HashType = "sha1"
+	// HashTypeCrc64 CRC64，十进制字符串
+	HashTypeCrc64 HashType = "crc64"
+)
+
+// Hashes 获取网盘文件的哈希值，格式和rclone一致。文件夹或者没有哈希值的文件返回空集合
+func Hashes(f *FileEntity) FileHashes {
+	r := FileHashes{}
+	if f == nil || f.IsFolder() {
+		return r
+	}
+	if f.ContentHash != "" && (f.ContentHashName == "" || f.ContentHashName == "sha1") {
+		r[HashTypeSha1] = apiutil.NormalizeSha1(f.ContentHash)
+	}
+	if f.Crc64Hash != "" {
+		r[HashTypeCrc64] = f.Crc64Hash
+	}
+	return r
+}
+
+// Hashes 获取文件的哈希值
+func (f *FileEntity) Hashes() FileHashes {
+	return Hashes(f)
+}
+
+// LocalFileHashes 计算本地文件的哈希值，格式和 Hashes 一致，可以直接比较
+func LocalFileHashes(localPath string) (FileHashes, error) {
+	sha1Str, crc64Str, err := apiutil.ComputeFileHashes(localPath)
+	if err != nil {
+		return nil, err
+	}
+	return FileHashes{
+		HashTypeSha1:  sha1Str,
+		HashTypeCrc64: crc64Str,
+	}, nil
+}
+
+// Equal 比较两个哈希集合，只比较双方都存在的哈希类型，没有共同的哈希类型返回false
+func (h FileHashes) Equal(other FileHashes) bool {
+	matched := false
+	for t, v := range h {
+		if ov, ok := other[t]; ok && ov != "" && v != "" {
+			if ov != v {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+)
+
+const (
+	// fileInfoBatchSize 每次批量获取的文件数量
+	fileInfoBatchSize = 100
+)
+
+type (
+	// PathInfoResult 批量获取路径对应的文件信息的结果
+	PathInfoResult struct {
+		// Path 请求的路径
+		Path string
+		// FileInfo 文件信息，出错时为nil
+		FileInfo *FileEntity
+		// Err 路径不存在等错误
+		Err *apierror.ApiError
+	}
+
+	// pathResolver 批量解析路径，同一个文件夹只获取一次文件列表
+	pathResolver struct {
+		list   func(parentFileId string) (FileList, *apierror.ApiError)
+		cached func(pathStr string) (*FileEntity, bool)
+		// children 文件夹FileId -> 文件夹下的文件
+		children map[string]FileList
+		// resolved 已经解析过的路径
+		resolved map[string]*PathInfoResult
+	}
+)
+
+// FileInfoByPaths 批量获取多个绝对路径的文件信息，结果和 paths 一一对应。
+// 路径之间共享上级文件夹的文件列表和元数据缓存，比逐个调用 FileInfoByPath 请求更少
+func (p *PanClient) FileInfoByPaths(driveId string, paths []string) []*PathInfoResult {
+	r := &pathResolver{
+		list: func(parentFileId string) (FileList, *apierror.ApiError) {
+			return p.FileListGetAll(&FileListParam{DriveId: driveId, ParentFileId: parentFileId})
+		},
+		cached: func(pathStr string) (*FileEntity, bool) {
+			return p.cachedFileInfoByPath(driveId, pathStr)
+		},
+		children: map[string]FileList{},
+		resolved: map[string]*PathInfoResult{},
+	}
+	results := make([]*PathInfoResult, 0, len(paths))
+	for _, pathStr := range paths {
+		res := r.resolve(pathStr)
+		results = append(results, &PathInfoResult{Path: pathStr, FileInfo: res.FileInfo, Err: res.Err})
+	}
+	return results
+}
+
+func (r *pathResolver) resolve(pathStr string) *PathInfoResult {
+	if pathStr == "" {
+		pathStr = "/"
+	}
+	if !path.IsAbs(pathStr) {
+		return &PathInfoResult{Path: pathStr, Err: apierror.NewFailedApiError("pathStr必须是绝对路径")}
+	}
+	pathStr = path.Clean(pathStr)
+	if res, ok := r.resolved[pathStr]; ok {
+		return res
+	}
+	res := &PathInfoResult{Path: pathStr}
+	r.resolved[pathStr] = res
+
+	if pathStr == "/" {
+		res.FileInfo = NewFileEntityForRootDir()
+		return res
+	}
+	if fe, ok := r.cached(pathStr); ok {
+		res.FileInfo = fe
+		return res
+	}
+	dir, name := apiutil.SplitDrivePath(pathStr)
+	parent := r.resolve(dir)
+	if parent.Err != nil {
+		res.Err = parent.Err
+		return res
+	}
+	if !parent.FileInfo.IsFolder() {
+		res.Err = apierror.NewApiError(apierror.ApiCodeFileNotFoundCode, "文件不存在")
+		return res
+	}
+	children, ok := r.children[parent.FileInfo.FileId]
+	if !ok {
+		var err *apierror.ApiError
+		if children, err = r.list(parent.FileInfo.FileId); err != nil {
+			// 获取失败不缓存，其他路径可以重试
+			delete(r.resolved, pathStr)
+			res.Err = err
+			return res
+		}
+		r.children[parent.FileInfo.FileId] = children
+	}
+	for _, fe := range children {
+		if fe.FileName == name {
+			fe.Path = pathStr
+			res.FileInfo = fe
+			return res
+		}
+	}
+	res.Err = apierror.NewApiError(apierror.ApiCodeFileNotFoundCode, "文件不存在")
+	return res
+}
+
+// FileInfosById 批量获取文件信息，使用批量接口每次请求最多获取 100 个文件，文件ID为空代表根目录。
+// 返回值和 fileIds 一一对应，不存在或者获取失败的文件对应的结果为nil
+func (p *PanClient) FileInfosById(driveId string, fileIds []string) (FileList, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/get"); o != nil {
+		return p.openFileInfosById(o, driveId, fileIds)
+	}
+	result := make(FileList, len(fileIds))
+	// 文件ID -> 在结果中的位置，同一个ID可能出现多次
+	pending := map[string][]int{}
+	requests := BatchRequestList{}
+	for i, fileId := range fileIds {
+		if fileId == "" {
+			fileId = DefaultRootParentFileId
+		}
+		if fe, ok := p.cachedFileInfo(driveId, fileId); ok {
+			result[i] = fe
+			continue
+		}
+		if _, ok := pending[fileId]; !ok {
+			requests = append(requests, &BatchRequest{
+				Id:     fileId,
+				Method: "POST",
+				Url:    "/file/get",
+				Headers: map[string]string{
+					"Content-Type": "application/json",
+				},
+				Body: map[string]interface{}{
+					"drive_id": driveId,
+					"file_id":  fileId,
+				},
+			})
+		}
+		pending[fileId] = append(pending[fileId], i)
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/batch", API_URL)
+	for start := 0; start < len(requests); start += fileInfoBatchSize {
+		end := start + fileInfoBatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+		r, err := p.BatchTask(fullUrl.String(), &BatchRequestParam{
+			Requests: requests[start:end],
+			Resource: "file",
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range r.Responses {
+			if item.Status != 200 || item.Body == nil {
+				continue
+			}
+			fe, e := p.batchFileEntity(item.Body)
+			if e != nil {
+				logger.Verboseln("parse batch file info error ", e)
+				continue
+			}
+			p.storeFileInfo(fe)
+			for _, i := range pending[item.Id] {
+				result[i] = fe
+			}
+		}
+	}
+	return result, nil
+}
+
+// openFileInfosById 开放平台没有批量接口，逐个获取文件信息
+func (p *PanClient) openFileInfosById(o *OpenPanClient, driveId string, fileIds []string) (FileList, *apierror.ApiError) {
+	result := make(FileList, len(fileIds))
+	fetched := map[string]*FileEntity{}
+	for i, fileId := range fileIds {
+		if fileId == "" {
+			fileId = DefaultRootParentFileId
+		}
+		fe, ok := fetched[fileId]
+		if !ok {
+			var err *apierror.ApiError
+			fe, err = p.openFileInfoById(o, driveId, fileId)
+			if err != nil && err.Code != apierror.ApiCodeFileNotFoundCode {
+				return nil, err
+			}
+			fetched[fileId] = fe
+		}
+		result[i] = fe
+	}
+	return result, nil
+}
+
+// batchFileEntity 批量响应中的文件信息转换为 FileEntity
+func (p *PanClient) batchFileEntity(body map[string]interface{}) (*FileEntity, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	raw := &FileEntityRaw{}
+	if err = json.Unmarshal(data, raw); err != nil {
+		return nil, err
+	}
+	return p.newFileEntity(raw), nil
+}
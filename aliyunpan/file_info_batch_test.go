@@ -0,0 +1,130 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestPathResolver(t *testing.T) {
+	tree := map[string]FileList{
+		DefaultRootParentFileId: {{FileId: "a", FileName: "a", FileType: "folder"}, {FileId: "f", FileName: "f.txt", FileType: "file"}},
+		"a":                     {{FileId: "b", FileName: "b.txt", FileType: "file"}, {FileId: "c", FileName: "c.txt", FileType: "file"}},
+	}
+	listed := map[string]int{}
+	r := &pathResolver{
+		list: func(parentFileId string) (FileList, *apierror.ApiError) {
+			listed[parentFileId]++
+			return tree[parentFileId], nil
+		},
+		cached: func(pathStr string) (*FileEntity, bool) {
+			return nil, false
+		},
+		children: map[string]FileList{},
+		resolved: map[string]*PathInfoResult{},
+	}
+	expected := map[string]string{
+		"/a/b.txt":     "b",
+		"/a/c.txt":     "c",
+		"/f.txt":       "f",
+		"/a/":          "a",
+		"/":            DefaultRootParentFileId,
+		"/a/x.txt":     "",
+		"/f.txt/x.txt": "",
+		"/x/y.txt":     "",
+	}
+	for p, id := range expected {
+		res := r.resolve(p)
+		if id == "" {
+			if res.Err == nil || res.Err.Code != apierror.ApiCodeFileNotFoundCode {
+				t.Fatalf("%s: expected not found, got %+v", p, res)
+			}
+			continue
+		}
+		if res.Err != nil || res.FileInfo.FileId != id {
+			t.Fatalf("%s: unexpected result %+v", p, res)
+		}
+	}
+	if listed[DefaultRootParentFileId] != 1 || listed["a"] != 1 || len(listed) != 2 {
+		t.Fatalf("each folder should be listed once: %v", listed)
+	}
+}
+
+func TestFileInfosById(t *testing.T) {
+	var requested []string
+	SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			param := &BatchRequestParam{}
+			data, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(data, param)
+			responses := []string{}
+			for _, req := range param.Requests {
+				requested = append(requested, req.Id)
+				if req.Id == "missing" {
+					responses = append(responses, `{"id":"missing","status":404,"body":{"code":"NotFound.File"}}`)
+					continue
+				}
+				responses = append(responses, `{"id":"`+req.Id+`","status":200,"body":{"drive_id":"d1","file_id":"`+req.Id+`","name":"`+req.Id+`","type":"folder"}}`)
+			}
+			body := `{"responses":[` + strings.Join(responses, ",") + `]}`
+			return &http.Response{StatusCode: 200, Status: "200 OK", Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+		})
+	})
+	defer SetTransportWrapper(nil)
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	r, err := p.FileInfosById("d1", []string{"f1", "", "missing", "f1"})
+	if err != nil || len(r) != 4 {
+		t.Fatalf("unexpected result %v %v", r, err)
+	}
+	// 空文件ID代表根目录，同一个文件只请求一次
+	if r[0].FileId != "f1" || r[1].FileId != DefaultRootParentFileId || r[2] != nil || r[3] != r[0] {
+		t.Fatalf("unexpected result %v", r)
+	}
+	if strings.Join(requested, ",") != "f1,root,missing" {
+		t.Fatalf("unexpected requests %v", requested)
+	}
+}
+
+func TestFileInfosByIdWithOpenToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&param)
+		if r.URL.Path != "/adrive/v1.0/openFile/get" || param["file_id"] == "missing" {
+			w.WriteHeader(404)
+			w.Write([]byte(`{"code":"NotFound.File","message":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"drive_id":"d1","file_id":"` + param["file_id"] + `","name":"a.txt","type":"file"}`))
+	}))
+	defer server.Close()
+
+	p := NewPanClientWithOpenToken(OpenToken{TokenType: "Bearer", AccessToken: "oat"})
+	p.OpenClient().apiUrl = server.URL
+	r, err := p.FileInfosById("d1", []string{"f1", "", "missing"})
+	if err != nil || len(r) != 3 {
+		t.Fatalf("unexpected result %v %v", r, err)
+	}
+	if r[0].FileId != "f1" || r[1].FileId != DefaultRootParentFileId || r[2] != nil {
+		t.Fatalf("unexpected result %v", r)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// FindLargeFiles 查找网盘中最大的 topN 个文件(大小不小于 minSize 字节)，按大小倒序排列，并补全文件的完整路径。
+// 用于空间管理工具查找占用空间最多的文件
+func (p *PanClient) FindLargeFiles(driveId string, minSize int64, topN int) (FileList, *apierror.ApiError) {
+	if topN <= 0 {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "topN must be greater than 0")
+	}
+	param := &FileSearchParam{
+		DriveId: driveId,
+		Query:   `type = "file" and size >= ` + strconv.FormatInt(minSize, 10),
+		OrderBy: "size DESC",
+	}
+	fileList := FileList{}
+	pg := NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		param.Marker = marker
+		result, err := p.FileSearch(param)
+		if err != nil {
+			return "", err
+		}
+		fileList = append(fileList, result.FileList...)
+		if len(fileList) >= topN {
+			return "", nil
+		}
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
+	}
+
+	// 服务器排序只作参考，客户端再排序一次
+	sort.SliceStable(fileList, func(i, j int) bool {
+		return fileList[i].FileSize > fileList[j].FileSize
+	})
+	if len(fileList) > topN {
+		fileList = fileList[:topN]
+	}
+
+	folders := map[string]string{DefaultRootParentFileId: "/"}
+	for _, f := range fileList {
+		dir, err := p.folderPathById(driveId, f.ParentFileId, folders)
+		if err != nil {
+			return nil, err
+		}
+		f.Path = path.Join(dir, f.FileName)
+	}
+	return fileList, nil
+}
+
+// folderPathById 获取文件夹的完整路径，folders 缓存已经查询过的文件夹路径
+func (p *PanClient) folderPathById(driveId, folderId string, folders map[string]string) (string, *apierror.ApiError) {
+	if folderId == "" {
+		return "/", nil
+	}
+	if fp, ok := folders[folderId]; ok {
+		return fp, nil
+	}
+	fe, err := p.FileInfoById(driveId, folderId)
+	if err != nil {
+		return "", err
+	}
+	parent, err := p.folderPathById(driveId, fe.ParentFileId, folders)
+	if err != nil {
+		return "", err
+	}
+	fp := path.Join(parent, fe.FileName)
+	folders[folderId] = fp
+	return fp, nil
+}
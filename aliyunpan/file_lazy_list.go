@@ -0,0 +1,201 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// DirPage 递归获取时一个文件夹的一页文件
+	DirPage struct {
+		// Folder 文件所在的文件夹
+		Folder *FileEntity
+		// Depth 文件的深度，根目录下的文件为1
+		Depth int
+		// PageIndex 页序号，从0开始
+		PageIndex int
+		// Files 该页的文件，Path 已经设置为完整路径
+		Files FileList
+		// Err 获取该文件夹出错，出错的文件夹不再继续获取，其他文件夹不受影响
+		Err *apierror.ApiError
+	}
+
+	// lazyLister 并发获取文件夹，获取到一页就输出一页
+	lazyLister struct {
+		ctx     context.Context
+		driveId string
+		fetch   crawlPageFetcher
+		out     chan *DirPage
+
+		mu    sync.Mutex
+		cond  *sync.Cond
+		queue []*lazyFolder
+		// active 正在获取的文件夹数量
+		active int
+	}
+
+	lazyFolder struct {
+		folder *FileEntity
+		depth  int
+	}
+)
+
+const (
+	// DefaultLazyListConcurrency RecurseListLazy 默认同时获取的文件夹数量
+	DefaultLazyListConcurrency = 4
+)
+
+// RecurseListLazy 递归获取目录，按广度优先的顺序并发获取多个同级文件夹，获取到一页文件就立即从返回的通道输出，
+// 调用方不需要等待整个目录获取完成就可以开始处理。所有文件夹获取完成或者 ctx 取消后通道关闭。
+// concurrency 为同时获取的文件夹数量，小于等于0时使用 DefaultLazyListConcurrency
+func (p *PanClient) RecurseListLazy(ctx context.Context, driveId, pathStr string, concurrency int) (<-chan *DirPage, *apierror.ApiError) {
+	c := p.WithContext(ctx).WithRequestClass(RequestClassBulk)
+	root, err := c.FileInfoByPath(driveId, pathStr)
+	if err != nil {
+		return nil, err
+	}
+	if !root.IsFolder() {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "pathStr必须是文件夹")
+	}
+	return newLazyLister(ctx, driveId, c.FileList).start(root, concurrency), nil
+}
+
+func newLazyLister(ctx context.Context, driveId string, fetch crawlPageFetcher) *lazyLister {
+	l := &lazyLister{
+		ctx:     ctx,
+		driveId: driveId,
+		fetch:   fetch,
+		out:     make(chan *DirPage),
+	}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *lazyLister) start(root *FileEntity, concurrency int) <-chan *DirPage {
+	if concurrency <= 0 {
+		concurrency = DefaultLazyListConcurrency
+	}
+	l.queue = []*lazyFolder{{folder: root, depth: 1}}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.work()
+		}()
+	}
+	finished := make(chan struct{})
+	// ctx 取消时唤醒等待任务的协程
+	go func() {
+		select {
+		case <-l.ctx.Done():
+			l.mu.Lock()
+			l.cond.Broadcast()
+			l.mu.Unlock()
+		case <-finished:
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(finished)
+		close(l.out)
+	}()
+	return l.out
+}
+
+// next 获取下一个要获取的文件夹，没有文件夹并且其他协程都已经完成时返回nil
+func (l *lazyLister) next() *lazyFolder {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.queue) == 0 && l.active > 0 && l.ctx.Err() == nil {
+		l.cond.Wait()
+	}
+	if len(l.queue) == 0 || l.ctx.Err() != nil {
+		// 全部完成，唤醒其他等待的协程退出
+		l.cond.Broadcast()
+		return nil
+	}
+	f := l.queue[0]
+	l.queue = l.queue[1:]
+	l.active++
+	return f
+}
+
+func (l *lazyLister) done(subFolders []*lazyFolder) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queue = append(l.queue, subFolders...)
+	l.active--
+	l.cond.Broadcast()
+}
+
+func (l *lazyLister) work() {
+	for {
+		f := l.next()
+		if f == nil {
+			return
+		}
+		l.done(l.listFolder(f))
+	}
+}
+
+// listFolder 获取文件夹下的所有页，返回子文件夹
+func (l *lazyLister) listFolder(f *lazyFolder) []*lazyFolder {
+	subFolders := []*lazyFolder{}
+	pageIndex := 0
+	pg := NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		r, err := l.fetch(&FileListParam{
+			DriveId:      l.driveId,
+			ParentFileId: f.folder.FileId,
+			Marker:       marker,
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, fi := range r.FileList {
+			fi.Path = apiutil.JoinDrivePath(f.folder.Path, fi.FileName)
+			if fi.IsFolder() {
+				subFolders = append(subFolders, &lazyFolder{folder: fi, depth: f.depth + 1})
+			}
+		}
+		if !l.emit(&DirPage{Folder: f.folder, Depth: f.depth, PageIndex: pageIndex, Files: r.FileList}) {
+			return "", apierror.NewApiErrorWithError(l.ctx.Err())
+		}
+		pageIndex++
+		return r.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		if l.ctx.Err() == nil {
+			l.emit(&DirPage{Folder: f.folder, Depth: f.depth, PageIndex: pageIndex, Err: err})
+		}
+		return nil
+	}
+	return subFolders
+}
+
+// emit 输出一页，ctx 取消时返回false
+func (l *lazyLister) emit(page *DirPage) bool {
+	select {
+	case l.out <- page:
+		return true
+	case <-l.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestLazyLister(t *testing.T) {
+	folder := func(id, name string) *FileEntity {
+		return &FileEntity{FileId: id, FileName: name, FileType: "folder"}
+	}
+	file := func(id, name string) *FileEntity {
+		return &FileEntity{FileId: id, FileName: name, FileType: "file"}
+	}
+	pages := map[string]*FileListResult{
+		"root": {FileList: FileList{folder("a", "a"), folder("x", "x"), file("b", "b.txt")}},
+		"a":    {FileList: FileList{file("c", "c.txt")}, NextMarker: "m1"},
+		"a/m1": {FileList: FileList{folder("d", "d")}},
+		"d":    {FileList: FileList{file("e", "e.txt")}},
+	}
+	fetch := func(param *FileListParam) (*FileListResult, *apierror.ApiError) {
+		key := param.ParentFileId
+		if param.Marker != "" {
+			key += "/" + param.Marker
+		}
+		r, ok := pages[key]
+		if !ok {
+			return nil, apierror.NewFailedApiError("not found")
+		}
+		fl := FileList{}
+		for _, f := range r.FileList {
+			c := *f
+			fl = append(fl, &c)
+		}
+		return &FileListResult{FileList: fl, NextMarker: r.NextMarker}, nil
+	}
+
+	root := &FileEntity{FileId: "root", Path: "/", FileType: "folder"}
+	var paths []string
+	errCount := 0
+	for page := range newLazyLister(context.Background(), "d1", fetch).start(root, 2) {
+		if page.Err != nil {
+			errCount++
+			if page.Folder.FileId != "x" {
+				t.Errorf("unexpected error folder %s", page.Folder.FileId)
+			}
+			continue
+		}
+		for _, f := range page.Files {
+			paths = append(paths, f.Path)
+		}
+	}
+	sort.Strings(paths)
+	want := []string{"/a", "/a/c.txt", "/a/d", "/a/d/e.txt", "/b.txt", "/x"}
+	if len(paths) != len(want) {
+		t.Fatalf("got %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("got %v, want %v", paths, want)
+		}
+	}
+	if errCount != 1 {
+		t.Errorf("got %d errors, want 1", errCount)
+	}
+
+	// 取消后通道关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := newLazyLister(ctx, "d1", fetch).start(root, 2)
+	<-ch
+	cancel()
+	for range ch {
+	}
+}
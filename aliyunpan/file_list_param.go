@@ -0,0 +1,118 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// FileListParamError 文件列表参数校验错误
+	FileListParamError struct {
+		// Field 出错的参数
+		Field string
+		// Reason 错误原因
+		Reason string
+	}
+
+	// FileListParamBuilder 文件列表参数构造器
+	FileListParamBuilder struct {
+		param FileListParam
+	}
+)
+
+const (
+	// DefaultFileListLimit 文件列表默认每页数量
+	DefaultFileListLimit = 100
+	// MaxFileListLimit 文件列表每页最大数量
+	MaxFileListLimit = 200
+)
+
+func (e *FileListParamError) Error() string {
+	return fmt.Sprintf("文件列表参数错误 %s: %s", e.Field, e.Reason)
+}
+
+// Validate 校验文件列表参数，在发起请求前发现错误。返回的错误为 *FileListParamError，
+// FileList 等接口返回的 ApiError 可以通过 errors.As 获取
+func (param *FileListParam) Validate() error {
+	if param == nil {
+		return &FileListParamError{Field: "param", Reason: "参数不能为空"}
+	}
+	if param.DriveId == "" {
+		return &FileListParamError{Field: "drive_id", Reason: "网盘ID不能为空"}
+	}
+	if strings.Contains(param.ParentFileId, PathSeparator) {
+		return &FileListParamError{Field: "parent_file_id", Reason: "需要文件ID而不是文件路径：" + param.ParentFileId}
+	}
+	if param.Limit < 0 || param.Limit > MaxFileListLimit {
+		return &FileListParamError{Field: "limit", Reason: fmt.Sprintf("每页数量必须在0到%d之间：%d", MaxFileListLimit, param.Limit)}
+	}
+	switch param.OrderBy {
+	case "", FileOrderByName, FileOrderByCreatedAt, FileOrderByUpdatedAt, FileOrderBySize:
+	default:
+		return &FileListParamError{Field: "order_by", Reason: "不支持的排序字段：" + string(param.OrderBy)}
+	}
+	switch param.OrderDirection {
+	case "", FileOrderDirectionAsc, FileOrderDirectionDesc:
+	default:
+		return &FileListParamError{Field: "order_direction", Reason: "不支持的排序方向：" + string(param.OrderDirection)}
+	}
+	return nil
+}
+
+// NewFileListParamBuilder 创建文件列表参数构造器，默认列出网盘根目录
+func NewFileListParamBuilder(driveId string) *FileListParamBuilder {
+	return &FileListParamBuilder{
+		param: FileListParam{
+			DriveId:      driveId,
+			ParentFileId: DefaultRootParentFileId,
+		},
+	}
+}
+
+// ParentFileId 设置要列出的文件夹ID
+func (b *FileListParamBuilder) ParentFileId(parentFileId string) *FileListParamBuilder {
+	b.param.ParentFileId = parentFileId
+	return b
+}
+
+// Limit 设置每页数量
+func (b *FileListParamBuilder) Limit(limit int) *FileListParamBuilder {
+	b.param.Limit = limit
+	return b
+}
+
+// OrderBy 设置排序字段和排序方向
+func (b *FileListParamBuilder) OrderBy(orderBy FileOrderBy, direction FileOrderDirection) *FileListParamBuilder {
+	b.param.OrderBy = orderBy
+	b.param.OrderDirection = direction
+	return b
+}
+
+// Marker 设置下一页参数
+func (b *FileListParamBuilder) Marker(marker string) *FileListParamBuilder {
+	b.param.Marker = marker
+	return b
+}
+
+// Build 校验并返回文件列表参数
+func (b *FileListParamBuilder) Build() (*FileListParam, error) {
+	param := b.param
+	if err := param.Validate(); err != nil {
+		return nil, err
+	}
+	return &param, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFileListParamValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		param *FileListParam
+		field string
+	}{
+		{"nil", nil, "param"},
+		{"ok", &FileListParam{DriveId: "d1"}, ""},
+		{"full", &FileListParam{DriveId: "d1", ParentFileId: "f1", Limit: MaxFileListLimit, OrderBy: FileOrderBySize, OrderDirection: FileOrderDirectionDesc}, ""},
+		{"no drive", &FileListParam{ParentFileId: "f1"}, "drive_id"},
+		{"path", &FileListParam{DriveId: "d1", ParentFileId: "/a/b"}, "parent_file_id"},
+		{"negative limit", &FileListParam{DriveId: "d1", Limit: -1}, "limit"},
+		{"large limit", &FileListParam{DriveId: "d1", Limit: MaxFileListLimit + 1}, "limit"},
+		{"order by", &FileListParam{DriveId: "d1", OrderBy: "type"}, "order_by"},
+		{"order direction", &FileListParam{DriveId: "d1", OrderDirection: "down"}, "order_direction"},
+	}
+	for _, c := range cases {
+		err := c.param.Validate()
+		if c.field == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+		pe, ok := err.(*FileListParamError)
+		if !ok || pe.Field != c.field {
+			t.Fatalf("%s: expected %s error, got %v", c.name, c.field, err)
+		}
+	}
+}
+
+func TestFileListParamBuilder(t *testing.T) {
+	cases := []struct {
+		name  string
+		build func() (*FileListParam, error)
+		want  FileListParam
+		field string
+	}{
+		{
+			name:  "default",
+			build: NewFileListParamBuilder("d1").Build,
+			want:  FileListParam{DriveId: "d1", ParentFileId: DefaultRootParentFileId},
+		},
+		{
+			name: "all",
+			build: NewFileListParamBuilder("d1").ParentFileId("f1").Limit(50).
+				OrderBy(FileOrderByUpdatedAt, FileOrderDirectionAsc).Marker("m1").Build,
+			want: FileListParam{DriveId: "d1", ParentFileId: "f1", Limit: 50, OrderBy: FileOrderByUpdatedAt, OrderDirection: FileOrderDirectionAsc, Marker: "m1"},
+		},
+		{
+			name:  "no drive",
+			build: NewFileListParamBuilder("").Build,
+			field: "drive_id",
+		},
+		{
+			name:  "bad limit",
+			build: NewFileListParamBuilder("d1").Limit(1000).Build,
+			field: "limit",
+		},
+	}
+	for _, c := range cases {
+		param, err := c.build()
+		if c.field != "" {
+			pe, ok := err.(*FileListParamError)
+			if param != nil || !ok || pe.Field != c.field {
+				t.Fatalf("%s: expected %s error, got %+v %v", c.name, c.field, param, err)
+			}
+			continue
+		}
+		if err != nil || *param != c.want {
+			t.Fatalf("%s: unexpected param %+v %v", c.name, param, err)
+		}
+	}
+}
+
+func TestFileListParamErrorCause(t *testing.T) {
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	_, err := p.FileList(&FileListParam{DriveId: "d1", Limit: -1})
+	var pe *FileListParamError
+	if err == nil || !errors.As(err, &pe) || pe.Field != "limit" {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
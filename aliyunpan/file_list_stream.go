@@ -0,0 +1,132 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// FileEachFunc 逐个处理文件，返回false停止获取
+	FileEachFunc func(fe *FileEntity) bool
+)
+
+// FileListEach 逐个返回文件夹下的所有文件，用于文件数量很多的文件夹。
+// 和 FileListGetAll 不同，不会保存完整的文件列表，每页的响应按元素流式解析并复用同一个解析对象，
+// 返回的 FileEntity 不包含 Raw，也不会写入元数据缓存
+func (p *PanClient) FileListEach(param *FileListParam, fn FileEachFunc) *apierror.ApiError {
+	if err := param.Validate(); err != nil {
+		return apierror.NewApiErrorWithCause(apierror.ApiCodeBadRequest, err)
+	}
+	pageParam := *param
+	pageParam.Limit = p.pageSize(pageParam.Limit)
+	raw := &FileEntityRaw{}
+	stopped := false
+	pg := NewPaginator(param.Marker, func(marker string) (string, *apierror.ApiError) {
+		pageParam.Marker = marker
+		body, err := p.fileListBody(&pageParam)
+		if err != nil {
+			return "", err
+		}
+		next, ok, e := decodeFileListStream(body, raw, func(r *FileEntityRaw) bool {
+			fe := p.newFileEntity(r)
+			// raw 会被下一个文件复用
+			fe.Raw = nil
+			return fn(fe)
+		})
+		if e != nil {
+			logger.Verboseln("parse file list result json error ", e)
+			return "", apierror.NewFailedApiError(e.Error())
+		}
+		if !ok {
+			stopped = true
+			return "", nil
+		}
+		return next, nil
+	})
+	for pg.HasNext() && !stopped {
+		if err := pg.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeFileListStream 流式解析文件列表响应，每个文件都解析到 raw 中再调用 fn，
+// 返回下一页的 marker，fn 返回false时 ok 为false
+func decodeFileListStream(data []byte, raw *FileEntityRaw, fn func(r *FileEntityRaw) bool) (nextMarker string, ok bool, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err = expectDelim(dec, '{'); err != nil {
+		return "", false, err
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return "", false, err
+		}
+		switch t {
+		case "items":
+			start, err := dec.Token()
+			if err != nil {
+				return "", false, err
+			}
+			if start == nil {
+				// "items": null
+				continue
+			}
+			if d, isDelim := start.(json.Delim); !isDelim || d != '[' {
+				return "", false, fmt.Errorf("expected [, got %v", start)
+			}
+			for dec.More() {
+				*raw = FileEntityRaw{}
+				if err = dec.Decode(raw); err != nil {
+					return "", false, err
+				}
+				if !fn(raw) {
+					return "", false, nil
+				}
+			}
+			if err = expectDelim(dec, ']'); err != nil {
+				return "", false, err
+			}
+		case "next_marker":
+			if err = dec.Decode(&nextMarker); err != nil {
+				return "", false, err
+			}
+		default:
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return "", false, err
+			}
+		}
+	}
+	return nextMarker, true, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %v, got %v", delim, t)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import "testing"
+
+func TestDecodeFileListStream(t *testing.T) {
+	data := []byte(`{"items":[{"file_id":"a","name":"a.txt","type":"file","size":3},{"file_id":"b","name":"b","type":"folder"}],"punished_file_count":0,"next_marker":"m1"}`)
+	raw := &FileEntityRaw{}
+	names := []string{}
+	next, ok, err := decodeFileListStream(data, raw, func(r *FileEntityRaw) bool {
+		names = append(names, r.Name)
+		return true
+	})
+	if err != nil || !ok || next != "m1" || len(names) != 2 || names[1] != "b" || raw.Size != 0 {
+		t.Fatalf("unexpected result %v %v %v %v %+v", next, ok, err, names, raw)
+	}
+
+	count := 0
+	_, ok, err = decodeFileListStream(data, raw, func(r *FileEntityRaw) bool {
+		count++
+		return false
+	})
+	if err != nil || ok || count != 1 {
+		t.Fatalf("expected to stop after first item, got %v %v %d", ok, err, count)
+	}
+
+	if next, ok, err = decodeFileListStream([]byte(`{"items":null,"next_marker":""}`), raw, nil); err != nil || !ok || next != "" {
+		t.Fatalf("unexpected empty result %v %v %v", next, ok, err)
+	}
+	if _, _, err = decodeFileListStream([]byte(`[]`), raw, nil); err == nil {
+		t.Fatal("expected error for invalid response")
+	}
+}
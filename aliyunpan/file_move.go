@@ -39,11 +39,16 @@ type(
 		FileId string
 		// 是否成功
 		Success bool
+		// AsyncTaskId 文件夹较大时服务器以异步任务处理，任务ID不为空
+		AsyncTaskId string
 	}
 )
 
 // FileMove 移动文件
 func (p *PanClient) FileMove(param []*FileMoveParam) ([]*FileMoveResult, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/move"); o != nil {
+		return p.openFileMove(o, param)
+	}
 	// url
 	fullUrl := &strings.Builder{}
 	fmt.Fprintf(fullUrl, "%s/v3/batch", API_URL)
@@ -71,9 +76,18 @@ func (p *PanClient) FileMove(param []*FileMoveParam) ([]*FileMoveResult, *apierr
 	for _,item := range result.Responses{
 		r = append(r, &FileMoveResult{
 			FileId: item.Id,
-			Success:     item.Status == 200,
+			Success:     item.Status == 200 || item.Status == 202,
+			AsyncTaskId: batchAsyncTaskId(item),
 		})
 	}
+	for _, mp := range param {
+		p.fileChanged(mp.DriveId, mp.FileId)
+		toDriveId := mp.ToDriveId
+		if toDriveId == "" {
+			toDriveId = mp.DriveId
+		}
+		p.childrenChanged(toDriveId, mp.ToParentFileId)
+	}
 	return r, nil
 }
 
@@ -0,0 +1,124 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+)
+
+type (
+	// QuickAccessItem 快速访问中固定的文件夹
+	QuickAccessItem struct {
+		DriveId string `json:"drive_id"`
+		FileId  string `json:"file_id"`
+		// Name 文件夹名称
+		Name string `json:"name"`
+		// Type 类型，folder / file
+		Type      string `json:"type"`
+		CreatedAt string `json:"created_at"`
+	}
+
+	quickAccessListResult struct {
+		Items []*QuickAccessItem `json:"items"`
+	}
+)
+
+// QuickAccessList 获取快速访问列表，和官方客户端左侧的快速访问一致
+func (p *PanClient) QuickAccessList() ([]*QuickAccessItem, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v1/quick_access/list", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	postData := map[string]interface{}{}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get quick access list error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &quickAccessListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse quick access list result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	items := []*QuickAccessItem{}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		item.CreatedAt = apiutil.UtcTime2LocalFormat(item.CreatedAt)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// QuickAccessAdd 将文件夹固定到快速访问
+func (p *PanClient) QuickAccessAdd(driveId, fileId string) (bool, *apierror.ApiError) {
+	return p.quickAccessUpdate("add", driveId, fileId)
+}
+
+// QuickAccessRemove 将文件夹从快速访问中移除
+func (p *PanClient) QuickAccessRemove(driveId, fileId string) (bool, *apierror.ApiError) {
+	return p.quickAccessUpdate("remove", driveId, fileId)
+}
+
+func (p *PanClient) quickAccessUpdate(action, driveId, fileId string) (bool, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v1/quick_access/%s", API_URL, action)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if driveId == "" || fileId == "" {
+		return false, apierror.NewApiError(apierror.ApiCodeBadRequest, "drive id and file id cannot be empty")
+	}
+
+	postData := map[string]interface{}{
+		"drive_id": driveId,
+		"file_id":  fileId,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("quick access "+action+" error ", err)
+		return false, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return false, err1
+	}
+	return true, nil
+}
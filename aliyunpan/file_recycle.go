@@ -29,7 +29,7 @@ func (p *PanClient) RecycleBinFileList(param *RecycleBinFileListParam) (*FileLis
 				continue
 			}
 
-			result.FileList = append(result.FileList, createFileEntity(flr.Items[k]))
+			result.FileList = append(result.FileList, p.newFileEntity(flr.Items[k]))
 		}
 		result.NextMarker = flr.NextMarker
 	}
@@ -43,33 +43,27 @@ func (p *PanClient) RecycleBinFileListGetAll(param *RecycleBinFileListParam) (Fi
 		Limit:   param.Limit,
 		Marker:  param.Marker,
 	}
-	if internalParam.Limit <= 0 {
-		internalParam.Limit = 100
-	}
+	internalParam.Limit = p.pageSize(internalParam.Limit)
 
 	fileList := FileList{}
-	result, err := p.RecycleBinFileList(internalParam)
-	if err != nil || result == nil {
-		return nil, err
-	}
-	fileList = append(fileList, result.FileList...)
-
-	// more page?
-	for len(result.NextMarker) > 0 {
-		internalParam.Marker = result.NextMarker
-		result, err = p.RecycleBinFileList(internalParam)
-		if err == nil && result != nil {
-			fileList = append(fileList, result.FileList...)
-		} else {
-			break
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.RecycleBinFileList(internalParam)
+		if err != nil {
+			return "", err
 		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
 	}
 	return fileList, nil
 }
 
 func (p *PanClient) recycleBinFileListReq(param *RecycleBinFileListParam) (*fileListResult, *apierror.ApiError) {
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 		"referer": "https://www.aliyundrive.com/",
 		"origin": "https://www.aliyundrive.com",
 	}
@@ -78,15 +72,13 @@ func (p *PanClient) recycleBinFileListReq(param *RecycleBinFileListParam) (*file
 	fmt.Fprintf(fullUrl, "%s/v2/recyclebin/list", API_URL)
 	logger.Verboseln("do request url: " + fullUrl.String())
 
-	limit := param.Limit
-	if limit <= 0 {
-		limit = 100
-	}
+	defaults := p.RequestDefaults()
+	limit := p.pageSize(param.Limit)
 	postData := map[string]interface{} {
 		"drive_id": param.DriveId,
 		"limit": limit,
-		"image_thumbnail_process": "image/resize,w_400/format,jpeg",
-		"video_thumbnail_process": "video/snapshot,t_0,f_jpg,ar_auto,w_800",
+		"image_thumbnail_process": defaults.ImageThumbnailProcess,
+		"video_thumbnail_process": defaults.VideoThumbnailProcess,
 		"order_by": "name",
 		"order_direction": "DESC",
 	}
@@ -95,7 +87,7 @@ func (p *PanClient) recycleBinFileListReq(param *RecycleBinFileListParam) (*file
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get recycle bin file list error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
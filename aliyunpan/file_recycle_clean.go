@@ -0,0 +1,127 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"path"
+	"time"
+)
+
+type (
+	// RecycleBinCleanParam 回收站清理参数，满足任一条件的文件会被彻底删除
+	RecycleBinCleanParam struct {
+		DriveId string
+		// OlderThanDays 移入回收站超过指定天数的文件，<=0 代表不按时间清理
+		OlderThanDays int
+		// NamePatterns 文件名匹配规则，使用 path.Match 语法，例如：*.tmp
+		NamePatterns []string
+		// DryRun 只返回匹配的文件，不执行删除
+		DryRun bool
+	}
+
+	// RecycleBinCleanResult 回收站清理结果
+	RecycleBinCleanResult struct {
+		// Matched 满足清理条件的文件
+		Matched FileList
+		// Deleted 已经彻底删除的文件
+		Deleted FileList
+	}
+)
+
+const (
+	// recycleBinCleanBatchSize 每次批量删除的文件数量
+	recycleBinCleanBatchSize = 100
+)
+
+// RecycleBinClean 按保留策略清理回收站，彻底删除超过指定天数或者匹配文件名规则的文件。适合由备份工具定时执行
+func (p *PanClient) RecycleBinClean(param *RecycleBinCleanParam) (*RecycleBinCleanResult, *apierror.ApiError) {
+	if param == nil || (param.OlderThanDays <= 0 && len(param.NamePatterns) == 0) {
+		return nil, apierror.NewFailedApiError("必须指定保留天数或者文件名匹配规则")
+	}
+	for _, pattern := range param.NamePatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, apierror.NewFailedApiError("文件名匹配规则错误：" + pattern)
+		}
+	}
+
+	fileList, err := p.RecycleBinFileListGetAll(&RecycleBinFileListParam{
+		DriveId: param.DriveId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RecycleBinCleanResult{
+		Matched: FileList{},
+		Deleted: FileList{},
+	}
+	deadline := time.Now().AddDate(0, 0, -param.OlderThanDays)
+	for _, f := range fileList {
+		if f == nil {
+			continue
+		}
+		if recycleBinFileMatched(f, param, deadline) {
+			result.Matched = append(result.Matched, f)
+		}
+	}
+	if param.DryRun {
+		return result, nil
+	}
+
+	for start := 0; start < len(result.Matched); start += recycleBinCleanBatchSize {
+		end := start + recycleBinCleanBatchSize
+		if end > len(result.Matched) {
+			end = len(result.Matched)
+		}
+		batchParam := []*FileBatchActionParam{}
+		fileMap := map[string]*FileEntity{}
+		for _, f := range result.Matched[start:end] {
+			batchParam = append(batchParam, &FileBatchActionParam{DriveId: param.DriveId, FileId: f.FileId})
+			fileMap[f.FileId] = f
+		}
+		r, err := p.RecycleBinFileDelete(batchParam)
+		if err != nil {
+			logger.Verboseln("clean recycle bin error ", err)
+			return result, err
+		}
+		for _, item := range r {
+			if item.Success && fileMap[item.FileId] != nil {
+				result.Deleted = append(result.Deleted, fileMap[item.FileId])
+			}
+		}
+	}
+	return result, nil
+}
+
+func recycleBinFileMatched(f *FileEntity, param *RecycleBinCleanParam, deadline time.Time) bool {
+	if param.OlderThanDays > 0 {
+		// 没有移入回收站时间则使用最后修改时间
+		timeStr := f.TrashedAt
+		if timeStr == "" {
+			timeStr = f.UpdatedAt
+		}
+		if t, err := time.ParseInLocation("2006-01-02 15:04:05", timeStr, time.Local); err == nil && t.Before(deadline) {
+			return true
+		}
+	}
+	for _, pattern := range param.NamePatterns {
+		if ok, _ := path.Match(pattern, f.FileName); ok {
+			return true
+		}
+	}
+	return false
+}
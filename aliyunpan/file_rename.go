@@ -25,12 +25,15 @@ import (
 
 // FileRename 重命名文件
 func (p *PanClient) FileRename(driveId, renameFileId, newName string) (bool, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/update"); o != nil {
+		return p.openFileRename(o, driveId, renameFileId, newName)
+	}
 	if renameFileId == "" {
 		return false, apierror.NewFailedApiError("请指定命名的文件")
 	}
 	// header
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -42,12 +45,12 @@ func (p *PanClient) FileRename(driveId, renameFileId, newName string) (bool, *ap
 	postData := map[string]interface{} {
 		"drive_id": driveId,
 		"file_id": renameFileId,
-		"name": newName,
+		"name": p.encodeFileName(newName),
 		"check_name_mode": "refuse",
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get rename error ", err)
 		return false, apierror.NewFailedApiError(err.Error())
@@ -64,5 +67,6 @@ func (p *PanClient) FileRename(driveId, renameFileId, newName string) (bool, *ap
 		logger.Verboseln("parse rename result json error ", err2)
 		return false, apierror.NewFailedApiError(err2.Error())
 	}
+	p.fileChanged(driveId, renameFileId)
 	return true, nil
 }
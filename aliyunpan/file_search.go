@@ -0,0 +1,134 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreatedAfterQuery 生成搜索指定时间之后创建的文件的条件
+func CreatedAfterQuery(t time.Time) string {
+	return "created_at > " + strconv.Quote(t.UTC().Format("2006-01-02T15:04:05"))
+}
+
+// FileSearch 搜索文件
+func (p *PanClient) FileSearch(param *FileSearchParam) (*FileListResult, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/search"); o != nil {
+		return p.openFileSearch(o, param)
+	}
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v3/file/search", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	if param.DriveId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, (&FileListParamError{Field: "drive_id", Reason: "网盘ID不能为空"}).Error())
+	}
+
+	defaults := p.RequestDefaults()
+	postData := map[string]interface{}{
+		"drive_id":                param.DriveId,
+		"limit":                   p.pageSize(param.Limit),
+		"image_thumbnail_process": defaults.ImageThumbnailProcess,
+		"image_url_process":       defaults.ImageUrlProcess,
+		"video_thumbnail_process": defaults.VideoThumbnailProcess,
+	}
+	if param.Query != "" {
+		postData["query"] = param.Query
+	}
+	if param.OrderBy != "" {
+		postData["order_by"] = param.OrderBy
+	}
+	if param.Marker != "" {
+		postData["marker"] = param.Marker
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("file search error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &fileListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse file search result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	result := &FileListResult{
+		FileList:   FileList{},
+		NextMarker: r.NextMarker,
+	}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		result.FileList = append(result.FileList, p.newFileEntity(item))
+	}
+	return result, nil
+}
+
+// FileSearchGetAll 获取所有搜索结果
+func (p *PanClient) FileSearchGetAll(param *FileSearchParam) (FileList, *apierror.ApiError) {
+	internalParam := *param
+	fileList := FileList{}
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.FileSearch(&internalParam)
+		if err != nil {
+			return "", err
+		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
+	}
+	return fileList, nil
+}
+
+// RecentUploadedFiles 获取最近 within 时间内新上传的文件，按创建时间倒序排列。例如查看备份任务刚刚上传了哪些文件
+func (p *PanClient) RecentUploadedFiles(driveId string, within time.Duration) (FileList, *apierror.ApiError) {
+	return p.FileSearchGetAll(recentUploadedSearchParam(driveId, within))
+}
+
+// RecentUploadedFiles 获取最近 within 时间内新上传的文件，按创建时间倒序排列
+func (p *OpenPanClient) RecentUploadedFiles(driveId string, within time.Duration) (FileList, *apierror.ApiError) {
+	return p.FileSearchGetAll(recentUploadedSearchParam(driveId, within))
+}
+
+func recentUploadedSearchParam(driveId string, within time.Duration) *FileSearchParam {
+	return &FileSearchParam{
+		DriveId: driveId,
+		Query:   CreatedAfterQuery(time.Now().Add(-within)) + ` and type = "file"`,
+		OrderBy: "created_at DESC",
+	}
+}
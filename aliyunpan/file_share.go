@@ -52,7 +52,7 @@ type(
 		Status        string    `json:"status"`
 		UpdatedAt     string `json:"updated_at"`
 
-		FirstFile     *fileEntityResult `json:"first_file"`
+		FirstFile     *FileEntityRaw `json:"first_file"`
 	}
 
 	shareListResult struct {
@@ -78,10 +78,12 @@ type(
 	}
 )
 
-func createShareEntity(item *shareEntityResult) *ShareEntity {
+// newShareEntity 创建分享信息，分享的第一个文件还原编码的文件名
+func (p *PanClient) newShareEntity(item *shareEntityResult) *ShareEntity {
 	if item == nil {
 		return nil
 	}
+	tf := p.TimeFormat()
 	return &ShareEntity{
 		Creator: item.Creator,
 		DriveId: item.DriveId,
@@ -92,21 +94,27 @@ func createShareEntity(item *shareEntityResult) *ShareEntity {
 		FileIdList: item.FileIdList,
 		SaveCount: item.SaveCount,
 		Status: item.Status,
-		Expiration: apiutil.UtcTime2LocalFormat(item.Expiration),
-		UpdatedAt: apiutil.UtcTime2LocalFormat(item.UpdatedAt),
-		CreatedAt: apiutil.UtcTime2LocalFormat(item.CreatedAt),
-		FirstFile: createFileEntity(item.FirstFile),
+		Expiration: tf.FormatUtc(item.Expiration),
+		UpdatedAt: tf.FormatUtc(item.UpdatedAt),
+		CreatedAt: tf.FormatUtc(item.CreatedAt),
+		FirstFile: p.newFileEntity(item.FirstFile),
 	}
 }
 
 // ShareList 获取分享链接列表
 func (p *PanClient) ShareLinkList(userId string) ([]*ShareEntity, *apierror.ApiError) {
 	resultList := []*ShareEntity{}
-	if r,e := p.getShareLinkListReq(userId); e == nil {
-		for _,item := range r.Items {
-			resultList = append(resultList, createShareEntity(item))
+	pg := NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		r, e := p.getShareLinkListReq(userId, marker)
+		if e != nil {
+			return "", e
 		}
-	} else {
+		for _, item := range r.Items {
+			resultList = append(resultList, p.newShareEntity(item))
+		}
+		return r.NextMarker, nil
+	})
+	if e := pg.All(); e != nil {
 		return nil, e
 	}
 	return resultList, nil
@@ -162,7 +170,7 @@ func (p *PanClient) ShareLinkCancel(shareIdList []string) ([]*ShareCancelResult,
 func (p *PanClient) ShareLinkCreate(param ShareCreateParam) (*ShareEntity, *apierror.ApiError) {
 	// header
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -184,7 +192,7 @@ func (p *PanClient) ShareLinkCreate(param ShareCreateParam) (*ShareEntity, *apie
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("create share list error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -202,13 +210,13 @@ func (p *PanClient) ShareLinkCreate(param ShareCreateParam) (*ShareEntity, *apie
 		logger.Verboseln("parse share create result json error ", err2)
 		return nil, apierror.NewFailedApiError(err2.Error())
 	}
-	return createShareEntity(r), nil
+	return p.newShareEntity(r), nil
 }
 
-func (p *PanClient) getShareLinkListReq(userId string) (*shareListResult, *apierror.ApiError) {
+func (p *PanClient) getShareLinkListReq(userId, marker string) (*shareListResult, *apierror.ApiError) {
 	// header
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -223,9 +231,12 @@ func (p *PanClient) getShareLinkListReq(userId string) (*shareListResult, *apier
 		"order_by": "created_at",
 		"order_direction": "DESC",
 	}
+	if marker != "" {
+		postData["marker"] = marker
+	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get share list error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -0,0 +1,166 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+type (
+	// FileQuery 文件搜索条件，可以用 And / Or 组合，最终转换成搜索接口的 query 语句执行
+	FileQuery struct {
+		// expr 单个条件语句，组合条件时为空
+		expr string
+		// op 组合方式，and 或者 or
+		op       string
+		children []*FileQuery
+	}
+)
+
+const (
+	queryOpAnd = "and"
+	queryOpOr  = "or"
+)
+
+// UpdatedAfterQuery 生成搜索指定时间之后修改的文件的条件
+func UpdatedAfterQuery(t time.Time) string {
+	return "updated_at > " + strconv.Quote(t.UTC().Format("2006-01-02T15:04:05"))
+}
+
+// QueryExpr 使用原始的 query 语句作为条件
+func QueryExpr(expr string) *FileQuery {
+	return &FileQuery{expr: expr}
+}
+
+// QueryStarred 已收藏的文件
+func QueryStarred() *FileQuery {
+	return QueryExpr("starred = true")
+}
+
+// QueryRecent 最近 within 时间内修改过的文件
+func QueryRecent(within time.Duration) *FileQuery {
+	return QueryExpr(UpdatedAfterQuery(time.Now().Add(-within)))
+}
+
+// QueryName 文件名包含关键字的文件
+func QueryName(keyword string) *FileQuery {
+	return QueryExpr("name match " + strconv.Quote(keyword))
+}
+
+// QueryFileType 指定类型的文件，fileType 为 file 或者 folder
+func QueryFileType(fileType string) *FileQuery {
+	return QueryExpr("type = " + strconv.Quote(fileType))
+}
+
+// QueryCategory 指定分类的文件，例如 image, video, doc, audio
+func QueryCategory(category string) *FileQuery {
+	return QueryExpr("category = " + strconv.Quote(category))
+}
+
+// QueryAnd 同时满足所有条件
+func QueryAnd(queries ...*FileQuery) *FileQuery {
+	return newFileQuery(queryOpAnd, queries)
+}
+
+// QueryOr 满足任意一个条件
+func QueryOr(queries ...*FileQuery) *FileQuery {
+	return newFileQuery(queryOpOr, queries)
+}
+
+func newFileQuery(op string, queries []*FileQuery) *FileQuery {
+	q := &FileQuery{op: op}
+	for _, c := range queries {
+		if c.IsEmpty() {
+			continue
+		}
+		if c.op == op {
+			// 相同的组合方式直接展开，减少括号
+			q.children = append(q.children, c.children...)
+		} else {
+			q.children = append(q.children, c)
+		}
+	}
+	if len(q.children) == 1 {
+		return q.children[0]
+	}
+	return q
+}
+
+// And 同时满足当前条件和 queries 中的所有条件
+func (q *FileQuery) And(queries ...*FileQuery) *FileQuery {
+	return QueryAnd(append([]*FileQuery{q}, queries...)...)
+}
+
+// Or 满足当前条件或者 queries 中的任意一个条件
+func (q *FileQuery) Or(queries ...*FileQuery) *FileQuery {
+	return QueryOr(append([]*FileQuery{q}, queries...)...)
+}
+
+// IsEmpty 是否没有任何条件
+func (q *FileQuery) IsEmpty() bool {
+	return q == nil || (q.expr == "" && len(q.children) == 0)
+}
+
+// String 转换成搜索接口的 query 语句
+func (q *FileQuery) String() string {
+	if q.IsEmpty() {
+		return ""
+	}
+	if q.expr != "" {
+		return q.expr
+	}
+	parts := make([]string, 0, len(q.children))
+	for _, c := range q.children {
+		s := c.String()
+		if c.expr == "" && len(c.children) > 1 {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " "+q.op+" ")
+}
+
+// SmartQuery 使用组合条件搜索文件，返回所有结果。orderBy 为空时使用接口默认排序，例如 "updated_at DESC"
+func (p *PanClient) SmartQuery(driveId string, query *FileQuery, orderBy string) (FileList, *apierror.ApiError) {
+	param, err := smartQuerySearchParam(driveId, query, orderBy)
+	if err != nil {
+		return nil, err
+	}
+	return p.FileSearchGetAll(param)
+}
+
+// SmartQuery 使用组合条件搜索文件，返回所有结果
+func (p *OpenPanClient) SmartQuery(driveId string, query *FileQuery, orderBy string) (FileList, *apierror.ApiError) {
+	param, err := smartQuerySearchParam(driveId, query, orderBy)
+	if err != nil {
+		return nil, err
+	}
+	return p.FileSearchGetAll(param)
+}
+
+func smartQuerySearchParam(driveId string, query *FileQuery, orderBy string) (*FileSearchParam, *apierror.ApiError) {
+	if query.IsEmpty() {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "搜索条件不能为空")
+	}
+	return &FileSearchParam{
+		DriveId: driveId,
+		Query:   query.String(),
+		OrderBy: orderBy,
+	}, nil
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import "testing"
+
+func TestFileQuery(t *testing.T) {
+	q := QueryStarred().Or(QueryName("报告")).And(QueryCategory("doc"), QueryFileType("file"))
+	want := `(starred = true or name match "报告") and category = "doc" and type = "file"`
+	if q.String() != want {
+		t.Errorf("got %s, want %s", q.String(), want)
+	}
+
+	// 单个条件和空条件不加括号
+	q = QueryAnd(QueryOr(QueryStarred()), nil, QueryAnd())
+	if q.String() != "starred = true" {
+		t.Errorf("got %s", q.String())
+	}
+	if !QueryOr().IsEmpty() {
+		t.Error("empty query expected")
+	}
+	if _, err := smartQuerySearchParam("d1", QueryAnd(), ""); err == nil {
+		t.Error("empty query should fail")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// SnapshotEntry 快照中的文件记录，只保留比较变更需要的字段
+	SnapshotEntry struct {
+		Path         string `json:"p"`
+		FileId       string `json:"i"`
+		ParentFileId string `json:"pi,omitempty"`
+		// IsFolder 是否是文件夹
+		IsFolder    bool   `json:"d,omitempty"`
+		FileSize    int64  `json:"s,omitempty"`
+		ContentHash string `json:"h,omitempty"`
+		UpdatedAt   string `json:"u,omitempty"`
+	}
+
+	// TreeSnapshot 网盘目录树快照，可以保存到文件，之后再加载和新的快照比较
+	TreeSnapshot struct {
+		// Version 快照格式版本
+		Version int    `json:"version"`
+		DriveId string `json:"driveId"`
+		// ShareId 分享链接快照的分享ID，网盘目录快照为空
+		ShareId  string `json:"shareId,omitempty"`
+		RootPath string `json:"rootPath"`
+		// CreatedAt 快照创建时间
+		CreatedAt string `json:"createdAt"`
+		// Entries 按路径排序的文件记录，不包含根目录本身
+		Entries []*SnapshotEntry `json:"entries"`
+	}
+
+	// SnapshotMove 移动或者重命名的文件
+	SnapshotMove struct {
+		From *SnapshotEntry
+		To   *SnapshotEntry
+	}
+
+	// SnapshotDiff 两个快照的差异
+	SnapshotDiff struct {
+		// Added 新增的文件
+		Added []*SnapshotEntry
+		// Removed 删除的文件
+		Removed []*SnapshotEntry
+		// Modified 内容或者修改时间改变的文件，记录的是新的文件信息
+		Modified []*SnapshotEntry
+		// Moved 移动或者重命名的文件，通过 FileId 判断
+		Moved []*SnapshotMove
+	}
+)
+
+const (
+	// TreeSnapshotVersion 当前快照格式版本
+	TreeSnapshotVersion = 1
+)
+
+func newSnapshotEntry(f *FileEntity) *SnapshotEntry {
+	return &SnapshotEntry{
+		Path:         f.Path,
+		FileId:       f.FileId,
+		ParentFileId: f.ParentFileId,
+		IsFolder:     f.IsFolder(),
+		FileSize:     f.FileSize,
+		ContentHash:  strings.ToUpper(f.ContentHash),
+		UpdatedAt:    f.UpdatedAt,
+	}
+}
+
+// TreeSnapshot 递归获取网盘目录，生成目录树快照
+func (p *PanClient) TreeSnapshot(driveId, pathStr string) (*TreeSnapshot, *apierror.ApiError) {
+	snapshot := &TreeSnapshot{
+		Version:   TreeSnapshotVersion,
+		DriveId:   driveId,
+		RootPath:  pathStr,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Entries:   []*SnapshotEntry{},
+	}
+
+	var walkErr *apierror.ApiError
+	p.FilesDirectoriesRecurseList(driveId, pathStr, func(depth int, fdPath string, fd *FileEntity, apierr *apierror.ApiError) bool {
+		if apierr != nil {
+			walkErr = apierr
+			return false
+		}
+		if depth == 0 && fd.IsFolder() {
+			return true
+		}
+		snapshot.Entries = append(snapshot.Entries, newSnapshotEntry(fd))
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+	sort.Slice(snapshot.Entries, func(i, j int) bool {
+		return snapshot.Entries[i].Path < snapshot.Entries[j].Path
+	})
+	return snapshot, nil
+}
+
+// Save 保存快照，使用gzip压缩的JSON格式
+func (s *TreeSnapshot) Save(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	if err := json.NewEncoder(gw).Encode(s); err != nil {
+		gw.Close()
+		return err
+	}
+	return gw.Close()
+}
+
+// SaveFile 保存快照到文件，先写临时文件再重命名，避免写入中断导致文件损坏
+func (s *TreeSnapshot) SaveFile(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	tmpPath := filePath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err = s.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// LoadTreeSnapshot 加载 Save 保存的快照
+func LoadTreeSnapshot(r io.Reader) (*TreeSnapshot, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	s := &TreeSnapshot{}
+	if err = json.NewDecoder(gr).Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Version > TreeSnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version: %d", s.Version)
+	}
+	return s, nil
+}
+
+// LoadTreeSnapshotFile 从文件加载快照
+func LoadTreeSnapshotFile(filePath string) (*TreeSnapshot, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadTreeSnapshot(f)
+}
+
+// IsEmpty 两个快照是否没有差异
+func (d *SnapshotDiff) IsEmpty() bool {
+	return d == nil || (len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.Moved) == 0)
+}
+
+// Diff 比较旧快照和新快照的差异，s 为旧快照
+func (s *TreeSnapshot) Diff(newer *TreeSnapshot) *SnapshotDiff {
+	result := &SnapshotDiff{
+		Added:    []*SnapshotEntry{},
+		Removed:  []*SnapshotEntry{},
+		Modified: []*SnapshotEntry{},
+		Moved:    []*SnapshotMove{},
+	}
+	oldById := map[string]*SnapshotEntry{}
+	if s != nil {
+		for _, e := range s.Entries {
+			oldById[e.FileId] = e
+		}
+	}
+	newById := map[string]bool{}
+	if newer != nil {
+		for _, e := range newer.Entries {
+			newById[e.FileId] = true
+			old, ok := oldById[e.FileId]
+			if !ok {
+				result.Added = append(result.Added, e)
+				continue
+			}
+			if old.Path != e.Path {
+				result.Moved = append(result.Moved, &SnapshotMove{From: old, To: e})
+			}
+			if !e.IsFolder && (old.FileSize != e.FileSize || old.ContentHash != e.ContentHash || old.UpdatedAt != e.UpdatedAt) {
+				result.Modified = append(result.Modified, e)
+			}
+		}
+	}
+	if s != nil {
+		for _, e := range s.Entries {
+			if !newById[e.FileId] {
+				result.Removed = append(result.Removed, e)
+			}
+		}
+	}
+	return result
+}
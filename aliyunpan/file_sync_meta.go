@@ -0,0 +1,89 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"strings"
+)
+
+type (
+	// SyncMetaInfo 同步盘文件夹的同步信息，从 FileEntity.SyncMeta 解析得到
+	SyncMetaInfo struct {
+		// DeviceId 同步设备ID
+		DeviceId string `json:"deviceId"`
+		// DeviceName 同步设备名称
+		DeviceName string `json:"deviceName"`
+		// Platform 同步设备平台，例如：windows / mac
+		Platform string `json:"platform"`
+		// SourceFolder 同步设备上的本地文件夹路径
+		SourceFolder string `json:"sourceFolder"`
+	}
+
+	syncMetaRaw struct {
+		DeviceId     string `json:"device_id"`
+		DeviceName   string `json:"device_name"`
+		Platform     string `json:"platform"`
+		SourceFolder string `json:"folder_path"`
+		// LocalPath 旧版本客户端使用的字段名
+		LocalPath string `json:"local_path"`
+	}
+)
+
+var (
+	// ErrSyncMetaEmpty 文件没有同步信息
+	ErrSyncMetaEmpty = errors.New("sync meta is empty")
+)
+
+// ParseSyncMeta 解析 FileEntity.SyncMeta 字符串
+func ParseSyncMeta(syncMeta string) (*SyncMetaInfo, error) {
+	syncMeta = strings.TrimSpace(syncMeta)
+	if syncMeta == "" {
+		return nil, ErrSyncMetaEmpty
+	}
+	raw := &syncMetaRaw{}
+	if err := json.Unmarshal([]byte(syncMeta), raw); err != nil {
+		return nil, err
+	}
+	info := &SyncMetaInfo{
+		DeviceId:     raw.DeviceId,
+		DeviceName:   raw.DeviceName,
+		Platform:     raw.Platform,
+		SourceFolder: raw.SourceFolder,
+	}
+	if info.SourceFolder == "" {
+		info.SourceFolder = raw.LocalPath
+	}
+	return info, nil
+}
+
+// SyncMetaInfo 解析文件的同步信息，不是同步盘的文件返回 ErrSyncMetaEmpty
+func (f *FileEntity) SyncMetaInfo() (*SyncMetaInfo, error) {
+	if f == nil || !f.SyncFlag {
+		return nil, ErrSyncMetaEmpty
+	}
+	return ParseSyncMeta(f.SyncMeta)
+}
+
+// SyncFolderList 获取网盘中所有同步盘的文件夹
+func (p *PanClient) SyncFolderList(driveId string) (FileList, *apierror.ApiError) {
+	return p.FileSearchGetAll(&FileSearchParam{
+		DriveId: driveId,
+		Query:   `type = "folder" and sync_flag = true`,
+		OrderBy: "updated_at DESC",
+	})
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"unicode/utf8"
+)
+
+type (
+	// FileTextContent 文本文件内容
+	FileTextContent struct {
+		FileId string
+		// Text 转换为UTF-8的文本内容
+		Text string
+		// Charset 检测到的原始编码，UTF-8 / UTF-16LE / UTF-16BE / GB18030
+		Charset string
+		// Size 文件实际大小
+		Size int64
+		// Truncated 文件大于 maxBytes，只读取了前 maxBytes 字节
+		Truncated bool
+	}
+)
+
+const (
+	// DefaultTextContentMaxBytes 默认最多读取的文本大小，1MB
+	DefaultTextContentMaxBytes int64 = 1024 * 1024
+)
+
+// FileGetTextContent 下载较小的文本文件到内存中，用于预览或者读取配置文件。
+// 文件大于 maxBytes 时只读取前 maxBytes 字节并设置 Truncated，maxBytes 小于等于0时使用 DefaultTextContentMaxBytes。
+// 自动检测 UTF-8 / UTF-16 / GB18030 编码并转换为UTF-8
+func (p *PanClient) FileGetTextContent(driveId, fileId string, maxBytes int64) (*FileTextContent, *apierror.ApiError) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultTextContentMaxBytes
+	}
+	fe, apierr := p.FileInfoById(driveId, fileId)
+	if apierr != nil {
+		return nil, apierr
+	}
+	if fe.IsFolder() {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, "不能读取文件夹的内容："+fe.FileName)
+	}
+	result := &FileTextContent{
+		FileId:    fileId,
+		Size:      fe.FileSize,
+		Truncated: fe.FileSize > maxBytes,
+	}
+	if fe.FileSize == 0 {
+		result.Charset = "UTF-8"
+		return result, nil
+	}
+
+	urlResult, apierr := p.GetFileDownloadUrl(&GetFileDownloadUrlParam{DriveId: driveId, FileId: fileId})
+	if apierr != nil {
+		return nil, apierr
+	}
+	readRange := FileDownloadRange{}
+	if result.Truncated {
+		readRange.End = maxBytes - 1
+	}
+
+	var data []byte
+	var readErr error
+	client := NewHTTPClient()
+	apierr = p.DownloadFileData(urlResult.Url, readRange, func(httpMethod, fullUrl string, headers map[string]string) (*http.Response, error) {
+		resp, err := client.Req(httpMethod, fullUrl, nil, headers)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 && resp.StatusCode != 206 {
+			return resp, fmt.Errorf("unexpected http status code, %d, %s", resp.StatusCode, resp.Status)
+		}
+		data, readErr = ioutil.ReadAll(io.LimitReader(resp.Body, maxBytes))
+		return resp, readErr
+	})
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	result.Text, result.Charset = decodeText(data, result.Truncated)
+	return result, nil
+}
+
+// decodeText 检测文本编码并转换为UTF-8。truncated 为true时忽略末尾被截断的不完整字符
+func decodeText(data []byte, truncated bool) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), "UTF-8"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		if truncated && len(data)%2 == 1 {
+			data = data[:len(data)-1]
+		}
+		text, _ := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		return string(text), "UTF-16LE"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		if truncated && len(data)%2 == 1 {
+			data = data[:len(data)-1]
+		}
+		text, _ := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		return string(text), "UTF-16BE"
+	}
+
+	check := data
+	if truncated {
+		// 截断的位置可能在多字节字符中间
+		for i := 0; i < utf8.UTFMax-1 && len(check) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(check); r != utf8.RuneError {
+				break
+			}
+			check = check[:len(check)-1]
+		}
+	}
+	if utf8.Valid(check) {
+		return string(check), "UTF-8"
+	}
+	text, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data), "UTF-8"
+	}
+	return string(text), "GB18030"
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("配置文件")
+	cases := []struct {
+		data      []byte
+		truncated bool
+		text      string
+		charset   string
+	}{
+		{[]byte("hello 世界"), false, "hello 世界", "UTF-8"},
+		{append([]byte{0xEF, 0xBB, 0xBF}, "bom"...), false, "bom", "UTF-8"},
+		{[]byte{0xFF, 0xFE, 'h', 0, 'i', 0}, false, "hi", "UTF-16LE"},
+		{[]byte{0xFE, 0xFF, 0, 'h', 0, 'i', 0}, true, "hi", "UTF-16BE"},
+		// 截断在"界"的中间
+		{[]byte("hello 世界")[:11], true, "hello 世", "UTF-8"},
+		{[]byte(gbk), false, "配置文件", "GB18030"},
+	}
+	for _, c := range cases {
+		text, charset := decodeText(c.data, c.truncated)
+		if text != c.text || charset != c.charset {
+			t.Errorf("decodeText(%v) = %q %s, want %q %s", c.data, text, charset, c.text, c.charset)
+		}
+	}
+}
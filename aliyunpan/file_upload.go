@@ -9,7 +9,6 @@ import (
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
 	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
 	"github.com/tickstep/library-go/logger"
-	"github.com/tickstep/library-go/requester"
 	"github.com/tickstep/library-go/requester/rio"
 	"io"
 	"math"
@@ -46,6 +45,9 @@ type (
 		ProofCode    string `json:"proof_code"`
 		ProofVersion string `json:"proof_version"`
 
+		// ParallelUpload 是否允许多个分片同时上传，否则分片必须按顺序上传
+		ParallelUpload bool `json:"parallel_upload,omitempty"`
+
 		// 分片大小
 		// 不进行json序列化
 		BlockSize int64 `json:"-"`
@@ -247,7 +249,7 @@ func CalcProofCode(accessToken string, reader rio.ReaderAtLen64, fileSize int64)
 func (p *PanClient) CreateUploadFile(param *CreateFileUploadParam) (*CreateFileUploadResult, *apierror.ApiError) {
 	// header
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -257,6 +259,11 @@ func (p *PanClient) CreateUploadFile(param *CreateFileUploadParam) (*CreateFileU
 
 	// data
 	postData := param
+	if p.isNameEncoding() {
+		encodedParam := *param
+		encodedParam.Name = apiutil.EncodeFileName(param.Name)
+		postData = &encodedParam
+	}
 
 	if len(postData.PartInfoList) == 0 {
 		blockSize := DefaultChunkSize
@@ -280,7 +287,7 @@ func (p *PanClient) CreateUploadFile(param *CreateFileUploadParam) (*CreateFileU
 	postData.Type = "file"
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("create upload file error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -297,6 +304,8 @@ func (p *PanClient) CreateUploadFile(param *CreateFileUploadParam) (*CreateFileU
 		logger.Verboseln("parse create upload file result json error ", err2)
 		return nil, apierror.NewFailedApiError(err2.Error())
 	}
+	r.FileName = p.decodeFileName(r.FileName)
+	p.childrenChanged(param.DriveId, param.ParentFileId)
 	return r, nil
 }
 
@@ -306,7 +315,7 @@ func (p *PanClient) CreateUploadFile(param *CreateFileUploadParam) (*CreateFileU
 func (p *PanClient) GetUploadUrl(param *GetUploadUrlParam) (*GetUploadUrlResult, *apierror.ApiError) {
 	// header
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -318,7 +327,7 @@ func (p *PanClient) GetUploadUrl(param *GetUploadUrlParam) (*GetUploadUrlResult,
 	postData := param
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get upload url error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -364,7 +373,7 @@ func (p *PanClient) UploadFileData(uploadUrl string, uploadFunc UploadFunc) *api
 
 // UploadDataChunk 上传数据。该方法是同步阻塞的
 func (p *PanClient) UploadDataChunk(url string, data *FileUploadChunkData) *apierror.ApiError {
-	var client = requester.NewHTTPClient()
+	var client = NewHTTPClient()
 
 	// header
 	header := map[string]string{
@@ -381,11 +390,17 @@ func (p *PanClient) UploadDataChunk(url string, data *FileUploadChunkData) *apie
 		return apierror.NewFailedApiError("数据块错误")
 	}
 	// request
+	release, err := p.acquire(RequestClassBulk)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	defer release()
 	resp, err := client.Req("PUT", fullUrl.String(), data, header)
 	if err != nil || resp.StatusCode != 200 {
 		logger.Verboseln("upload file data chunk error ", err)
 		return apierror.NewFailedApiError(err.Error())
 	}
+	p.stats.upload(data.Len())
 	return nil
 }
 
@@ -393,7 +408,7 @@ func (p *PanClient) UploadDataChunk(url string, data *FileUploadChunkData) *apie
 func (p *PanClient) CompleteUploadFile(param *CompleteUploadFileParam) (*CompleteUploadFileResult, *apierror.ApiError) {
 	// header
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	// url
@@ -410,7 +425,7 @@ func (p *PanClient) CompleteUploadFile(param *CompleteUploadFileParam) (*Complet
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("complete upload file error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -428,11 +443,13 @@ func (p *PanClient) CompleteUploadFile(param *CompleteUploadFileParam) (*Complet
 		return nil, apierror.NewFailedApiError(err2.Error())
 	}
 
+	p.fileChanged(r.DriveId, r.FileId)
+	p.childrenChanged(r.DriveId, r.ParentFileId)
 	return &CompleteUploadFileResult{
 		DriveId:         r.DriveId,
 		DomainId:        r.DomainId,
 		FileId:          r.FileId,
-		Name:            r.Name,
+		Name:            p.decodeFileName(r.Name),
 		Type:            r.Type,
 		Size:            r.Size,
 		UploadId:        r.UploadId,
@@ -440,6 +457,6 @@ func (p *PanClient) CompleteUploadFile(param *CompleteUploadFileParam) (*Complet
 		Crc64Hash:       r.Crc64Hash,
 		ContentHash:     r.ContentHash,
 		ContentHashName: r.ContentHashName,
-		CreatedAt:       apiutil.UtcTime2LocalFormat(r.CreatedAt),
+		CreatedAt:       p.TimeFormat().FormatUtc(r.CreatedAt),
 	}, nil
 }
@@ -0,0 +1,161 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// WalkEvent 递归遍历目录时每个文件或者出错时产生的事件，实现了 json.Marshaler，
+	// 流式导出时可以直接逐行输出为 ndjson
+	WalkEvent struct {
+		// Depth 深度，根目录为0
+		Depth int
+		// Path 文件的完整路径
+		Path string
+		// Entry 文件信息，出错时为nil
+		Entry *FileEntity
+		// Parent 上级文件夹，根目录为nil。获取文件列表出错时为出错的文件夹
+		Parent *FileEntity
+		// PageIndex 文件所在的上级文件夹列表页序号，从0开始
+		PageIndex int
+		// Marker 获取该页使用的 marker，第一页为空
+		Marker string
+		// Err 获取文件或者文件列表出错
+		Err *apierror.ApiError
+	}
+
+	// WalkFunc 处理遍历事件，返回false停止遍历
+	WalkFunc func(event *WalkEvent) bool
+
+	// walkEventJSON WalkEvent 的JSON格式，上级文件夹只输出ID
+	walkEventJSON struct {
+		Depth        int         `json:"depth"`
+		Path         string      `json:"path"`
+		Entry        *FileEntity `json:"entry,omitempty"`
+		ParentFileId string      `json:"parentFileId,omitempty"`
+		PageIndex    int         `json:"pageIndex"`
+		Marker       string      `json:"marker,omitempty"`
+		Error        string      `json:"error,omitempty"`
+	}
+
+	walker struct {
+		ctx     context.Context
+		driveId string
+		fetch   crawlPageFetcher
+		fn      WalkFunc
+		stopped bool
+	}
+)
+
+// MarshalJSON 实现 json.Marshaler，输出稳定的单行JSON
+func (e *WalkEvent) MarshalJSON() ([]byte, error) {
+	v := &walkEventJSON{
+		Depth:     e.Depth,
+		Path:      e.Path,
+		Entry:     e.Entry,
+		PageIndex: e.PageIndex,
+		Marker:    e.Marker,
+	}
+	if e.Parent != nil {
+		v.ParentFileId = e.Parent.FileId
+	}
+	if e.Err != nil {
+		v.Error = e.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// FilesDirectoriesWalk 递归遍历目录，和 FilesDirectoriesRecurseListWithContext 的顺序相同，
+// 但是逐页获取、不保存文件列表，并且回调收到包含上级文件夹和分页位置的 WalkEvent。
+// fn 返回false或者 ctx 取消时停止遍历
+func (p *PanClient) FilesDirectoriesWalk(ctx context.Context, driveId, pathStr string, fn WalkFunc) *apierror.ApiError {
+	c := p.WithContext(ctx).WithRequestClass(RequestClassBulk)
+	root, err := c.FileInfoByPath(driveId, pathStr)
+	if err != nil {
+		fn(&WalkEvent{Path: pathStr, Err: err})
+		return err
+	}
+	w := &walker{ctx: ctx, driveId: driveId, fetch: c.FileList, fn: fn}
+	return w.walk(root)
+}
+
+func (w *walker) emit(e *WalkEvent) {
+	if !w.fn(e) {
+		w.stopped = true
+	}
+}
+
+func (w *walker) walk(root *FileEntity) *apierror.ApiError {
+	w.emit(&WalkEvent{Path: root.Path, Entry: root})
+	if w.stopped || !root.IsFolder() {
+		return nil
+	}
+	return w.walkFolder(root, 1)
+}
+
+func (w *walker) walkFolder(folder *FileEntity, depth int) *apierror.ApiError {
+	pageIndex := 0
+	pg := NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		var r *FileListResult
+		var err *apierror.ApiError
+		if w.ctx.Err() != nil {
+			err = apierror.NewApiErrorWithError(w.ctx.Err())
+		} else {
+			r, err = w.fetch(&FileListParam{
+				DriveId:      w.driveId,
+				ParentFileId: folder.FileId,
+				Marker:       marker,
+			})
+		}
+		if err != nil {
+			w.emit(&WalkEvent{Depth: depth, Path: folder.Path, Parent: folder, PageIndex: pageIndex, Marker: marker, Err: err})
+			return "", err
+		}
+		for _, fi := range r.FileList {
+			fi.Path = apiutil.JoinDrivePath(folder.Path, fi.FileName)
+			w.emit(&WalkEvent{
+				Depth:     depth,
+				Path:      fi.Path,
+				Entry:     fi,
+				Parent:    folder,
+				PageIndex: pageIndex,
+				Marker:    marker,
+			})
+			if !w.stopped && fi.IsFolder() {
+				if err = w.walkFolder(fi, depth+1); err != nil {
+					return "", err
+				}
+			}
+			if w.stopped {
+				return "", nil
+			}
+		}
+		pageIndex++
+		return r.NextMarker, nil
+	})
+	for pg.HasNext() && !w.stopped {
+		// 出错时已经在获取的位置产生了错误事件，上级文件夹直接返回
+		if err := pg.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
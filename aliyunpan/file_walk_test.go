@@ -0,0 +1,70 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestFilesDirectoriesWalk(t *testing.T) {
+	pages := map[string]*FileListResult{
+		"root":    {FileList: FileList{{FileId: "a", FileName: "a", FileType: "folder"}}, NextMarker: "m1"},
+		"root/m1": {FileList: FileList{{FileId: "b", FileName: "b.txt", FileType: "file"}}},
+	}
+	fetch := func(param *FileListParam) (*FileListResult, *apierror.ApiError) {
+		key := param.ParentFileId
+		if param.Marker != "" {
+			key += "/" + param.Marker
+		}
+		if r, ok := pages[key]; ok {
+			return r, nil
+		}
+		return nil, apierror.NewFailedApiError("list error")
+	}
+
+	lines := []string{}
+	w := &walker{ctx: context.Background(), driveId: "d1", fetch: fetch, fn: func(e *WalkEvent) bool {
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(data))
+		return true
+	}}
+	root := &FileEntity{FileId: "root", FileName: "/", FileType: "folder", Path: "/"}
+
+	// a 的列表获取出错，停止遍历
+	err := w.walk(root)
+	if err == nil || len(lines) != 3 {
+		t.Fatalf("unexpected walk result %v %v", err, lines)
+	}
+	if !strings.Contains(lines[2], `"error":"list error"`) || !strings.Contains(lines[2], `"parentFileId":"a"`) {
+		t.Fatalf("unexpected error event %s", lines[2])
+	}
+
+	pages["a"] = &FileListResult{FileList: FileList{}}
+	lines = lines[:0]
+	if err = w.walk(root); err != nil || len(lines) != 3 {
+		t.Fatalf("unexpected walk result %v %v", err, lines)
+	}
+	if !strings.Contains(lines[2], `"path":"/b.txt"`) || !strings.Contains(lines[2], `"pageIndex":1`) || !strings.Contains(lines[2], `"marker":"m1"`) {
+		t.Fatalf("unexpected page event %s", lines[2])
+	}
+}
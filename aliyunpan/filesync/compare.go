@@ -0,0 +1,115 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+)
+
+// CompareFile 按 compareMode 比较本地文件和网盘文件的内容，返回空字符串代表一致。
+// 差异比较、同步和镜像都使用该规则：大小不同一定不一致，大小相同时再按比较方式比较SHA1或者修改时间，
+// 网盘没有记录SHA1时认为一致
+func CompareFile(l *LocalFileInfo, r *aliyunpan.FileEntity, compareMode CompareMode) (DiffReason, error) {
+	if l.Size != r.FileSize {
+		return DiffReasonSize, nil
+	}
+	switch compareMode {
+	case CompareModeSize:
+		return "", nil
+	case CompareModeMtime:
+		if absDuration(l.ModTime.Sub(remoteModTime(r))) <= mtimeTolerance {
+			return "", nil
+		}
+		return DiffReasonMtime, nil
+	default:
+		if r.ContentHash == "" {
+			// 网盘没有记录SHA1，只能认为一致
+			return "", nil
+		}
+		sha1Str, err := l.Sha1()
+		if err != nil {
+			return "", err
+		}
+		if strings.EqualFold(sha1Str, r.ContentHash) {
+			return "", nil
+		}
+		return DiffReasonSha1, nil
+	}
+}
+
+// NeedsUpload 本地文件是否需要上传：网盘不存在，或者内容不一致。
+// 本地是文件夹，或者一端是文件另一端是文件夹时返回false，类型冲突需要调用方另外处理
+func NeedsUpload(l *LocalFileInfo, r *aliyunpan.FileEntity, compareMode CompareMode) (bool, error) {
+	if l == nil || l.IsDir {
+		return false, nil
+	}
+	if r == nil {
+		return true, nil
+	}
+	if r.IsFolder() {
+		return false, nil
+	}
+	reason, err := CompareFile(l, r, compareMode)
+	return reason != "", err
+}
+
+// NeedsDownload 网盘文件是否需要下载：本地不存在，或者内容不一致。规则和 NeedsUpload 相同
+func NeedsDownload(l *LocalFileInfo, r *aliyunpan.FileEntity, compareMode CompareMode) (bool, error) {
+	if r == nil || r.IsFolder() {
+		return false, nil
+	}
+	if l == nil {
+		return true, nil
+	}
+	if l.IsDir {
+		return false, nil
+	}
+	reason, err := CompareFile(l, r, compareMode)
+	return reason != "", err
+}
+
+// ContentEqual 本地文件和网盘文件的内容是否一致，比较大小和SHA1
+func ContentEqual(localPath string, r *aliyunpan.FileEntity) (bool, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return false, err
+	}
+	if info.IsDir() || r == nil || r.IsFolder() {
+		return false, nil
+	}
+	l := &LocalFileInfo{Path: localPath, Size: info.Size(), ModTime: info.ModTime()}
+	reason, err := CompareFile(l, r, CompareModeSha1)
+	return reason == "", err
+}
+
+// remoteModTime 获取网盘文件的修改时间
+func remoteModTime(fe *aliyunpan.FileEntity) time.Time {
+	if !fe.UpdatedTime.IsZero() {
+		return fe.UpdatedTime
+	}
+	t, _ := time.ParseInLocation("2006-01-02 15:04:05", fe.UpdatedAt, time.Local)
+	return t
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"path"
+	"strings"
+)
+
+type (
+	// ConflictResolution 冲突处理方式
+	ConflictResolution string
+
+	// ConflictResolver 双向同步时，本地和网盘文件都存在但内容不一致，由 ConflictResolver 决定如何处理。
+	// 应用可以实现该接口，例如弹窗询问用户
+	ConflictResolver interface {
+		// Resolve 返回冲突的处理方式，conflict 为 DiffResult.Modified 中的条目
+		Resolve(conflict *DiffEntry) ConflictResolution
+	}
+
+	// ConflictResolverFunc 函数形式的 ConflictResolver，用于回调询问
+	ConflictResolverFunc func(conflict *DiffEntry) ConflictResolution
+
+	newerWinsResolver  struct{}
+	largerWinsResolver struct{}
+	keepBothResolver   struct{}
+)
+
+const (
+	// ConflictUseLocal 使用本地文件覆盖网盘文件
+	ConflictUseLocal ConflictResolution = "use_local"
+	// ConflictUseRemote 使用网盘文件覆盖本地文件
+	ConflictUseRemote ConflictResolution = "use_remote"
+	// ConflictKeepBoth 保留两份文件，网盘文件重命名为带后缀的文件名后下载到本地，本地文件上传到原文件名
+	ConflictKeepBoth ConflictResolution = "keep_both"
+	// ConflictSkip 跳过，不做任何处理
+	ConflictSkip ConflictResolution = "skip"
+
+	// DefaultConflictSuffix 保留两份文件时，网盘文件重命名使用的默认后缀
+	DefaultConflictSuffix = ".conflict"
+)
+
+var (
+	// NewerWins 修改时间较新的一方覆盖另一方，默认策略
+	NewerWins ConflictResolver = newerWinsResolver{}
+	// LargerWins 文件较大的一方覆盖另一方，大小相同则修改时间较新的一方覆盖另一方
+	LargerWins ConflictResolver = largerWinsResolver{}
+	// KeepBoth 保留两份文件
+	KeepBoth ConflictResolver = keepBothResolver{}
+)
+
+// Resolve 实现 ConflictResolver 接口
+func (f ConflictResolverFunc) Resolve(conflict *DiffEntry) ConflictResolution {
+	return f(conflict)
+}
+
+func (newerWinsResolver) Resolve(conflict *DiffEntry) ConflictResolution {
+	if conflict.LocalNewer() {
+		return ConflictUseLocal
+	}
+	return ConflictUseRemote
+}
+
+func (largerWinsResolver) Resolve(conflict *DiffEntry) ConflictResolution {
+	if conflict.Local != nil && conflict.Remote != nil && conflict.Local.Size != conflict.Remote.FileSize {
+		if conflict.Local.Size > conflict.Remote.FileSize {
+			return ConflictUseLocal
+		}
+		return ConflictUseRemote
+	}
+	return NewerWins.Resolve(conflict)
+}
+
+func (keepBothResolver) Resolve(conflict *DiffEntry) ConflictResolution {
+	return ConflictKeepBoth
+}
+
+// conflictRelPath 生成带后缀的文件路径，后缀加在扩展名之前，例如：dir/a.conflict.txt
+func conflictRelPath(relPath, suffix string) string {
+	if suffix == "" {
+		suffix = DefaultConflictSuffix
+	}
+	dir, name := path.Split(relPath)
+	ext := path.Ext(name)
+	if ext == name {
+		// 以"."开头的文件，例如：.bashrc
+		ext = ""
+	}
+	return dir + strings.TrimSuffix(name, ext) + suffix + ext
+}
+
+// resolveConflict 根据冲突处理方式生成同步动作
+func resolveConflict(d *DiffEntry, resolution ConflictResolution, suffix string) []*Action {
+	reason := diffReasonText[d.Reason]
+	switch resolution {
+	case ConflictUseLocal:
+		return []*Action{{Type: ActionUpload, RelPath: d.RelPath, Local: d.Local, Remote: d.Remote, Reason: reason}}
+	case ConflictUseRemote:
+		return []*Action{{Type: ActionDownload, RelPath: d.RelPath, Local: d.Local, Remote: d.Remote, Reason: reason}}
+	case ConflictKeepBoth:
+		newRelPath := conflictRelPath(d.RelPath, suffix)
+		return []*Action{
+			{Type: ActionRenameRemote, RelPath: d.RelPath, NewRelPath: newRelPath, Remote: d.Remote, Reason: "保留两份文件"},
+			{Type: ActionUpload, RelPath: d.RelPath, Local: d.Local, Reason: reason},
+			{Type: ActionDownload, RelPath: newRelPath, Remote: d.Remote, Reason: "保留两份文件"},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+type (
+	// DiffReason 文件不一致的原因
+	DiffReason string
+
+	// DiffEntry 差异条目
+	DiffEntry struct {
+		// RelPath 相对根目录的路径，使用"/"分隔
+		RelPath string
+		IsDir   bool
+		// Local 本地文件信息，只存在于网盘时为nil
+		Local *LocalFileInfo
+		// Remote 网盘文件信息，只存在于本地时为nil
+		Remote *aliyunpan.FileEntity
+		// Reason 修改原因，只有 Modified 条目才有值
+		Reason DiffReason
+	}
+
+	// DiffResult 本地和网盘的差异结果，以本地为参照
+	DiffResult struct {
+		// Added 只存在于本地的文件
+		Added []*DiffEntry
+		// Removed 只存在于网盘的文件
+		Removed []*DiffEntry
+		// Modified 两端都存在但内容不一致的文件
+		Modified []*DiffEntry
+		// TypeConflicts 一端是文件，另一端是文件夹
+		TypeConflicts []*DiffEntry
+	}
+)
+
+const (
+	// DiffReasonSize 文件大小不一致
+	DiffReasonSize DiffReason = "size"
+	// DiffReasonSha1 SHA1不一致
+	DiffReasonSha1 DiffReason = "sha1"
+	// DiffReasonMtime 修改时间不一致
+	DiffReasonMtime DiffReason = "mtime"
+)
+
+// LocalNewer 本地文件的修改时间是否比网盘文件新
+func (d *DiffEntry) LocalNewer() bool {
+	if d.Local == nil || d.Remote == nil {
+		return false
+	}
+	return d.Local.ModTime.After(remoteModTime(d.Remote))
+}
+
+// IsEmpty 本地和网盘是否完全一致
+func (d *DiffResult) IsEmpty() bool {
+	return d == nil || (len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0 && len(d.TypeConflicts) == 0)
+}
+
+// Diff 比较本地目录和网盘目录的差异，不做任何修改。remotePath 为网盘绝对路径
+func Diff(panClient *aliyunpan.PanClient, localRoot, driveId, remotePath string, compareMode CompareMode) (*DiffResult, *apierror.ApiError) {
+	localFiles, err := ScanLocal(filepath.Clean(localRoot))
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	remoteFiles, apierr := ScanRemote(panClient, driveId, path.Clean("/"+remotePath))
+	if apierr != nil {
+		return nil, apierr
+	}
+	r, err := DiffFiles(localFiles, remoteFiles, compareMode)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	return r, nil
+}
+
+// DiffFiles 比较已经扫描好的本地和网盘文件列表。各个列表按路径排序
+func DiffFiles(localFiles LocalFileMap, remoteFiles RemoteFileMap, compareMode CompareMode) (*DiffResult, error) {
+	if compareMode == "" {
+		compareMode = CompareModeSha1
+	}
+	keys := make([]string, 0, len(localFiles)+len(remoteFiles))
+	for k := range localFiles {
+		keys = append(keys, k)
+	}
+	for k := range remoteFiles {
+		if _, ok := localFiles[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	result := &DiffResult{
+		Added:         []*DiffEntry{},
+		Removed:       []*DiffEntry{},
+		Modified:      []*DiffEntry{},
+		TypeConflicts: []*DiffEntry{},
+	}
+	for _, rel := range keys {
+		l, r := localFiles[rel], remoteFiles[rel]
+		switch {
+		case l != nil && r == nil:
+			result.Added = append(result.Added, &DiffEntry{RelPath: rel, IsDir: l.IsDir, Local: l})
+		case l == nil && r != nil:
+			result.Removed = append(result.Removed, &DiffEntry{RelPath: rel, IsDir: r.IsFolder(), Remote: r})
+		case l != nil && r != nil:
+			if l.IsDir != r.IsFolder() {
+				result.TypeConflicts = append(result.TypeConflicts, &DiffEntry{RelPath: rel, Local: l, Remote: r})
+				continue
+			}
+			if l.IsDir {
+				continue
+			}
+			reason, err := CompareFile(l, r, compareMode)
+			if err != nil {
+				return nil, err
+			}
+			if reason != "" {
+				result.Modified = append(result.Modified, &DiffEntry{RelPath: rel, Local: l, Remote: r, Reason: reason})
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// LocalFileInfo 本地文件信息
+	LocalFileInfo struct {
+		// RelPath 相对同步根目录的路径，使用"/"分隔
+		RelPath string
+		// Path 本地完整路径
+		Path    string
+		Size    int64
+		ModTime time.Time
+		IsDir   bool
+		// LinkTarget 软链接的目标路径，只有 SpecialFileStoreAsText 策略下的软链接才有值
+		LinkTarget string
+
+		sha1 string
+	}
+
+	// LocalFileMap 本地文件列表，key为相对路径
+	LocalFileMap map[string]*LocalFileInfo
+
+	// SpecialFilePolicy 软链接等特殊文件的处理策略
+	SpecialFilePolicy string
+
+	// ScanOptions 扫描本地目录的选项
+	ScanOptions struct {
+		// SpecialFiles 软链接的处理策略，默认为 SpecialFileSkip。套接字、设备文件等总是跳过
+		SpecialFiles SpecialFilePolicy
+		// NormalizeNames 是否把相对路径统一为 Unicode NFC 形式。macOS 的文件名是 NFD 形式，
+		// 开启后和网盘上 NFC 形式的同名文件可以对应上。Path 仍然是本地真实路径
+		NormalizeNames bool
+	}
+
+	// SkippedEntry 扫描时跳过的文件
+	SkippedEntry struct {
+		RelPath string
+		Path    string
+		Mode    os.FileMode
+		// Reason 跳过的原因
+		Reason string
+	}
+
+	// ScanReport 扫描报告，记录所有被跳过的文件，用于审计
+	ScanReport struct {
+		Skipped []*SkippedEntry
+	}
+)
+
+const (
+	// SpecialFileSkip 跳过软链接
+	SpecialFileSkip SpecialFilePolicy = "skip"
+	// SpecialFileFollow 跟随软链接，按链接目标的文件或者文件夹处理，循环链接和目标不存在的链接会被跳过
+	SpecialFileFollow SpecialFilePolicy = "follow"
+	// SpecialFileStoreAsText 把软链接的目标路径保存为网盘上带 SymlinkTextSuffix 后缀的文本文件，下载时还原为软链接
+	SpecialFileStoreAsText SpecialFilePolicy = "store_as_text"
+
+	// SymlinkTextSuffix 保存软链接目标的网盘文件后缀
+	SymlinkTextSuffix = ".symlink"
+)
+
+// Sha1 计算文件的SHA1值(大写)，计算结果会被缓存
+func (l *LocalFileInfo) Sha1() (string, error) {
+	if l.IsDir {
+		return "", nil
+	}
+	if l.sha1 != "" {
+		return l.sha1, nil
+	}
+	if l.LinkTarget != "" {
+		sum := sha1.Sum([]byte(l.LinkTarget))
+		l.sha1 = strings.ToUpper(hex.EncodeToString(sum[:]))
+		return l.sha1, nil
+	}
+	f, err := os.Open(l.Path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	l.sha1 = strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	return l.sha1, nil
+}
+
+// ScanLocal 递归扫描本地目录，返回目录下所有文件和文件夹的信息。不包含根目录本身，跳过软链接和设备文件等特殊文件
+func ScanLocal(localRoot string) (LocalFileMap, error) {
+	result, _, err := ScanLocalWithOptions(localRoot, ScanOptions{})
+	return result, err
+}
+
+// ScanLocalWithOptions 按 opts 的策略递归扫描本地目录，同时返回被跳过的特殊文件报告
+func ScanLocalWithOptions(localRoot string, opts ScanOptions) (LocalFileMap, *ScanReport, error) {
+	result := LocalFileMap{}
+	report := &ScanReport{Skipped: []*SkippedEntry{}}
+	localRoot = filepath.Clean(localRoot)
+	if _, err := os.Stat(localRoot); err != nil {
+		if os.IsNotExist(err) {
+			// 本地目录不存在，当作空目录处理
+			return result, report, nil
+		}
+		return nil, nil, err
+	}
+	if opts.SpecialFiles == "" {
+		opts.SpecialFiles = SpecialFileSkip
+	}
+
+	sc := &localScanner{
+		opts:    opts,
+		result:  result,
+		report:  report,
+		visited: map[string]bool{},
+	}
+	if real, err := filepath.EvalSymlinks(localRoot); err == nil {
+		sc.visited[real] = true
+	}
+	if err := sc.scanDir(localRoot, ""); err != nil {
+		return nil, nil, err
+	}
+	return result, report, nil
+}
+
+type localScanner struct {
+	opts   ScanOptions
+	result LocalFileMap
+	report *ScanReport
+	// visited 跟随软链接时已经扫描过的文件夹真实路径，避免循环链接
+	visited map[string]bool
+}
+
+func (sc *localScanner) scanDir(dir, relDir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range entries {
+		p := filepath.Join(dir, info.Name())
+		rel := info.Name()
+		if sc.opts.NormalizeNames {
+			rel = norm.NFC.String(rel)
+		}
+		if relDir != "" {
+			rel = relDir + "/" + rel
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err = sc.scanSymlink(p, rel, info); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			sc.skip(p, rel, info.Mode(), "不支持的文件类型")
+			continue
+		}
+		sc.add(p, rel, info)
+		if info.IsDir() {
+			if err = sc.scanDir(p, rel); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (sc *localScanner) scanSymlink(p, rel string, info os.FileInfo) error {
+	switch sc.opts.SpecialFiles {
+	case SpecialFileStoreAsText:
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		rel += SymlinkTextSuffix
+		sc.result[rel] = &LocalFileInfo{
+			RelPath:    rel,
+			Path:       p,
+			Size:       int64(len(target)),
+			ModTime:    info.ModTime(),
+			LinkTarget: target,
+		}
+		return nil
+	case SpecialFileFollow:
+		targetInfo, err := os.Stat(p)
+		if err != nil {
+			sc.skip(p, rel, info.Mode(), "软链接目标不存在")
+			return nil
+		}
+		if !targetInfo.IsDir() && !targetInfo.Mode().IsRegular() {
+			sc.skip(p, rel, targetInfo.Mode(), "不支持的文件类型")
+			return nil
+		}
+		if !targetInfo.IsDir() {
+			sc.add(p, rel, targetInfo)
+			return nil
+		}
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return err
+		}
+		if sc.visited[real] {
+			sc.skip(p, rel, info.Mode(), "循环的软链接")
+			return nil
+		}
+		sc.visited[real] = true
+		sc.add(p, rel, targetInfo)
+		return sc.scanDir(p, rel)
+	default:
+		sc.skip(p, rel, info.Mode(), "软链接")
+		return nil
+	}
+}
+
+func (sc *localScanner) add(p, rel string, info os.FileInfo) {
+	if _, ok := sc.result[rel]; ok {
+		sc.skip(p, rel, info.Mode(), "文件名规范化后重复")
+		return
+	}
+	sc.result[rel] = &LocalFileInfo{
+		RelPath: rel,
+		Path:    p,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		IsDir:   info.IsDir(),
+	}
+}
+
+func (sc *localScanner) skip(p, rel string, mode os.FileMode, reason string) {
+	sc.report.Skipped = append(sc.report.Skipped, &SkippedEntry{
+		RelPath: rel,
+		Path:    p,
+		Mode:    mode,
+		Reason:  reason,
+	})
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+	"sort"
+)
+
+type (
+	// MirrorOption 镜像选项
+	MirrorOption struct {
+		// CompareMode 文件比较方式，默认为比较大小和SHA1
+		CompareMode CompareMode
+		// DryRun 只生成镜像计划，不做任何修改
+		DryRun bool
+		// Manager 传输任务管理器，不为nil时上传下载加入任务队列异步执行
+		Manager *transfer.Manager
+		// Callback 镜像动作执行完成回调
+		Callback ActionCallback
+		// SpecialFiles 本地软链接的处理策略，默认为 SpecialFileSkip
+		SpecialFiles SpecialFilePolicy
+	}
+)
+
+// MirrorToRemote 镜像本地目录到网盘目录，使网盘目录和本地目录完全一致，网盘多余的文件会被删除到回收站。
+// 返回执行的镜像计划，DryRun 时只返回计划
+func MirrorToRemote(panClient *aliyunpan.PanClient, localRoot, driveId, remoteRoot string, option *MirrorOption) (*Plan, *apierror.ApiError) {
+	return mirror(NewSyncer(panClient, driveId, localRoot, remoteRoot, Policy{Mode: SyncModeUpload, DeleteExtra: true}), option)
+}
+
+// MirrorToLocal 镜像网盘目录到本地目录，使本地目录和网盘目录完全一致，本地多余的文件会被删除。
+// 返回执行的镜像计划，DryRun 时只返回计划
+func MirrorToLocal(panClient *aliyunpan.PanClient, driveId, remoteRoot, localRoot string, option *MirrorOption) (*Plan, *apierror.ApiError) {
+	return mirror(NewSyncer(panClient, driveId, localRoot, remoteRoot, Policy{Mode: SyncModeDownload, DeleteExtra: true}), option)
+}
+
+func mirror(s *Syncer, option *MirrorOption) (*Plan, *apierror.ApiError) {
+	if option == nil {
+		option = &MirrorOption{}
+	}
+	if option.CompareMode != "" {
+		s.policy.CompareMode = option.CompareMode
+	}
+	if option.SpecialFiles != "" {
+		s.policy.SpecialFiles = option.SpecialFiles
+	}
+	s.SetTransferManager(option.Manager)
+
+	localFiles, remoteFiles, apierr := s.scan()
+	if apierr != nil {
+		return nil, apierr
+	}
+	diff, err := DiffFiles(localFiles, remoteFiles, s.policy.CompareMode)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	plan := s.newPlan(buildMirrorActions(diff, s.policy.Mode == SyncModeUpload))
+	plan.Skipped = s.skipped
+	if option.DryRun {
+		return plan, nil
+	}
+	return plan, s.Apply(plan, option.Callback)
+}
+
+// buildMirrorActions 根据差异生成镜像动作，toRemote 为true代表以本地为源
+func buildMirrorActions(diff *DiffResult, toRemote bool) []*Action {
+	deleteType, mkdirType, transferType := ActionDeleteLocal, ActionMkdirLocal, ActionDownload
+	if toRemote {
+		deleteType, mkdirType, transferType = ActionDeleteRemote, ActionMkdirRemote, ActionUpload
+	}
+	// 源端和目标端的条目
+	pick := func(d *DiffEntry) (src bool, srcIsDir bool) {
+		if toRemote {
+			return d.Local != nil, d.Local != nil && d.Local.IsDir
+		}
+		return d.Remote != nil, d.Remote != nil && d.Remote.IsFolder()
+	}
+
+	actions := []*Action{}
+	deletedDirs := map[string]bool{}
+	addDelete := func(d *DiffEntry, isDir bool, reason string) {
+		if hasAncestor(d.RelPath, deletedDirs) {
+			return
+		}
+		actions = append(actions, &Action{Type: deleteType, RelPath: d.RelPath, Local: d.Local, Remote: d.Remote, Reason: reason})
+		if isDir {
+			deletedDirs[d.RelPath] = true
+		}
+	}
+	addCreate := func(d *DiffEntry, isDir bool, reason string) {
+		if isDir {
+			actions = append(actions, &Action{Type: mkdirType, RelPath: d.RelPath, Local: d.Local, Remote: d.Remote})
+		} else {
+			actions = append(actions, &Action{Type: transferType, RelPath: d.RelPath, Local: d.Local, Remote: d.Remote, Reason: reason})
+		}
+	}
+
+	// 类型冲突：先删除目标端，再按源端重新创建
+	for _, d := range diff.TypeConflicts {
+		_, srcIsDir := pick(d)
+		addDelete(d, !srcIsDir, "文件类型不一致")
+		addCreate(d, srcIsDir, "文件类型不一致")
+	}
+	for _, list := range [][]*DiffEntry{diff.Added, diff.Removed} {
+		for _, d := range list {
+			if src, srcIsDir := pick(d); src {
+				addCreate(d, srcIsDir, "目标端不存在")
+			} else {
+				addDelete(d, d.IsDir, "源端不存在")
+			}
+		}
+	}
+	for _, d := range diff.Modified {
+		actions = append(actions, &Action{Type: transferType, RelPath: d.RelPath, Local: d.Local, Remote: d.Remote, Reason: diffReasonText[d.Reason]})
+	}
+	sortMirrorActions(actions)
+	return actions
+}
+
+// sortMirrorActions 镜像的执行顺序：先删除，保证类型冲突的路径可以重新创建，再创建目录，最后传输文件
+func sortMirrorActions(actions []*Action) {
+	order := func(t ActionType) int {
+		switch t {
+		case ActionDeleteRemote, ActionDeleteLocal:
+			return 0
+		case ActionMkdirRemote, ActionMkdirLocal:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(actions, func(i, j int) bool {
+		oi, oj := order(actions[i].Type), order(actions[j].Type)
+		if oi != oj {
+			return oi < oj
+		}
+		if oi == 0 {
+			return actions[i].RelPath > actions[j].RelPath
+		}
+		return actions[i].RelPath < actions[j].RelPath
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"sort"
+	"strings"
+)
+
+type (
+	// ActionType 同步动作类型
+	ActionType string
+
+	// Action 同步动作
+	Action struct {
+		Type ActionType
+		// RelPath 相对同步根目录的路径
+		RelPath string
+		// NewRelPath 重命名后的路径，只有 ActionRenameRemote 才有值
+		NewRelPath string
+		Local      *LocalFileInfo
+		Remote     *aliyunpan.FileEntity
+		// Reason 产生该动作的原因
+		Reason string
+	}
+
+	// Plan 同步计划，可以先展示给用户确认后再执行
+	Plan struct {
+		LocalRoot  string
+		DriveId    string
+		RemoteRoot string
+		Actions    []*Action
+		// Skipped 扫描本地目录时跳过的软链接、设备文件等，用于审计
+		Skipped []*SkippedEntry
+	}
+)
+
+const (
+	// ActionUpload 上传本地文件到网盘
+	ActionUpload ActionType = "upload"
+	// ActionDownload 下载网盘文件到本地
+	ActionDownload ActionType = "download"
+	// ActionMkdirRemote 创建网盘文件夹
+	ActionMkdirRemote ActionType = "mkdir_remote"
+	// ActionMkdirLocal 创建本地文件夹
+	ActionMkdirLocal ActionType = "mkdir_local"
+	// ActionDeleteRemote 删除网盘文件到回收站
+	ActionDeleteRemote ActionType = "delete_remote"
+	// ActionDeleteLocal 删除本地文件
+	ActionDeleteLocal ActionType = "delete_local"
+	// ActionRenameRemote 重命名网盘文件，用于冲突时保留两份文件
+	ActionRenameRemote ActionType = "rename_remote"
+)
+
+// actionOrder 执行顺序：先创建目录，再传输文件，最后删除
+var actionOrder = map[ActionType]int{
+	ActionRenameRemote: 0,
+	ActionMkdirRemote:  0,
+	ActionMkdirLocal:   0,
+	ActionUpload:       1,
+	ActionDownload:     1,
+	ActionDeleteRemote: 2,
+	ActionDeleteLocal:  2,
+}
+
+func (a *Action) String() string {
+	target := a.RelPath
+	if a.NewRelPath != "" {
+		target += " -> " + a.NewRelPath
+	}
+	if a.Reason == "" {
+		return fmt.Sprintf("%s %s", a.Type, target)
+	}
+	return fmt.Sprintf("%s %s (%s)", a.Type, target, a.Reason)
+}
+
+// IsEmpty 是否没有任何需要执行的动作
+func (p *Plan) IsEmpty() bool {
+	return p == nil || len(p.Actions) == 0
+}
+
+// Count 统计各个动作的数量
+func (p *Plan) Count() map[ActionType]int {
+	r := map[ActionType]int{}
+	if p == nil {
+		return r
+	}
+	for _, a := range p.Actions {
+		r[a.Type]++
+	}
+	return r
+}
+
+// String 同步计划展示信息
+func (p *Plan) String() string {
+	builder := &strings.Builder{}
+	if p == nil {
+		return ""
+	}
+	for _, a := range p.Actions {
+		builder.WriteString(a.String() + "\n")
+	}
+	return builder.String()
+}
+
+// sortActions 按执行顺序排序。删除动作按路径倒序，保证先删除子文件
+func sortActions(actions []*Action) {
+	sort.SliceStable(actions, func(i, j int) bool {
+		oi, oj := actionOrder[actions[i].Type], actionOrder[actions[j].Type]
+		if oi != oj {
+			return oi < oj
+		}
+		if oi == actionOrder[ActionDeleteRemote] {
+			return actions[i].RelPath > actions[j].RelPath
+		}
+		return actions[i].RelPath < actions[j].RelPath
+	})
+}
+
+// hasAncestor 路径的上级目录是否在集合中
+func hasAncestor(relPath string, dirs map[string]bool) bool {
+	for {
+		idx := strings.LastIndex(relPath, "/")
+		if idx < 0 {
+			return false
+		}
+		relPath = relPath[:idx]
+		if dirs[relPath] {
+			return true
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"path"
+
+	"github.com/tickstep/library-go/logger"
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// RemoteFileMap 网盘文件列表，key为相对路径
+	RemoteFileMap map[string]*aliyunpan.FileEntity
+)
+
+// ScanRemote 递归获取网盘目录下的所有文件和文件夹。不包含根目录本身，目录不存在则返回空列表
+func ScanRemote(panClient *aliyunpan.PanClient, driveId, remoteRoot string) (RemoteFileMap, *apierror.ApiError) {
+	result := RemoteFileMap{}
+	remoteRoot = path.Clean("/" + remoteRoot)
+
+	rootInfo, err := panClient.FileInfoByPath(driveId, remoteRoot)
+	if err != nil {
+		if err.Code == apierror.ApiCodeFileNotFoundCode {
+			return result, nil
+		}
+		return nil, err
+	}
+	if !rootInfo.IsFolder() {
+		return nil, apierror.NewFailedApiError("网盘路径不是文件夹：" + remoteRoot)
+	}
+
+	var walkErr *apierror.ApiError
+	fileList := panClient.FilesDirectoriesRecurseList(driveId, remoteRoot, func(depth int, fdPath string, fd *aliyunpan.FileEntity, apierr *apierror.ApiError) bool {
+		if apierr != nil {
+			walkErr = apierr
+			return false
+		}
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	for _, fe := range fileList {
+		if fe == nil {
+			continue
+		}
+		rel := remoteRelPath(remoteRoot, fe.Path)
+		if rel == "" {
+			continue
+		}
+		result[rel] = fe
+	}
+	return result, nil
+}
+
+// remoteRelPath 获取网盘文件相对于根目录的路径
+func remoteRelPath(remoteRoot, fullPath string) string {
+	rel, _ := apiutil.RelDrivePath(remoteRoot, fullPath)
+	return rel
+}
+
+// NormalizeNames 返回相对路径统一为 Unicode NFC 形式的文件列表。
+// 只有文件名的 Unicode 形式不同的多个文件只保留一个
+func (m RemoteFileMap) NormalizeNames() RemoteFileMap {
+	result := make(RemoteFileMap, len(m))
+	for rel, fe := range m {
+		nfc := norm.NFC.String(rel)
+		if _, ok := result[nfc]; ok {
+			logger.Verboseln("duplicate remote file after normalization: " + rel)
+			continue
+		}
+		result[nfc] = fe
+	}
+	return result
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"sync"
+	"time"
+)
+
+type (
+	// RemoteWatchConfig 网盘目录轮询监听配置，回调为nil代表不关心该类变化
+	RemoteWatchConfig struct {
+		// Interval 轮询间隔，默认为1分钟
+		Interval time.Duration
+		// OnCreated 新增文件或者文件夹
+		OnCreated func(entry *aliyunpan.SnapshotEntry)
+		// OnModified 文件内容或者修改时间改变
+		OnModified func(entry *aliyunpan.SnapshotEntry)
+		// OnDeleted 文件或者文件夹被删除
+		OnDeleted func(entry *aliyunpan.SnapshotEntry)
+		// OnMoved 文件被移动或者重命名
+		OnMoved func(move *aliyunpan.SnapshotMove)
+		// OnError 获取网盘目录出错，下次轮询会继续重试
+		OnError func(err *apierror.ApiError)
+	}
+
+	// RemoteWatch 定时获取网盘目录快照并和上一次比较，通过回调通知变化。
+	// 适用于不能使用文件变更接口的网页端客户端，根目录为"/"时监听整个网盘
+	RemoteWatch struct {
+		panClient  aliyunpan.PanAPI
+		driveId    string
+		remotePath string
+		config     RemoteWatchConfig
+
+		mu       sync.Mutex
+		snapshot *aliyunpan.TreeSnapshot
+		stop     chan struct{}
+		done     chan struct{}
+		// unregister 取消在客户端注册的关闭回调
+		unregister func()
+		// callbacks 轮询协程正在执行的回调数量
+		callbacks int
+	}
+)
+
+// NewRemoteWatch 创建网盘目录轮询监听，需要先调用 Start 开始监听
+func NewRemoteWatch(panClient aliyunpan.PanAPI, driveId, remotePath string, config RemoteWatchConfig) *RemoteWatch {
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	return &RemoteWatch{
+		panClient:  panClient,
+		driveId:    driveId,
+		remotePath: remotePath,
+		config:     config,
+	}
+}
+
+// Start 获取初始快照并开始轮询，初始快照中已有的文件不会触发回调
+func (w *RemoteWatch) Start() *apierror.ApiError {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return nil
+	}
+	if w.snapshot == nil {
+		snapshot, err := w.panClient.TreeSnapshot(w.driveId, w.remotePath)
+		if err != nil {
+			return err
+		}
+		w.snapshot = snapshot
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	if r, ok := w.panClient.(aliyunpan.CloserRegistry); ok {
+		w.unregister = r.RegisterCloser(w)
+	}
+	go w.run(w.stop, w.done)
+	return nil
+}
+
+// Stop 停止轮询，等待正在进行的轮询结束，返回后不会再触发新的回调。
+// 可以在回调中调用：回调正在执行时只通知轮询停止，不等待当前回调返回，避免在回调中调用时死锁
+func (w *RemoteWatch) Stop() {
+	w.mu.Lock()
+	stop, done, unregister := w.stop, w.done, w.unregister
+	w.stop = nil
+	w.unregister = nil
+	inCallback := w.callbacks > 0
+	w.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	if unregister != nil {
+		unregister()
+	}
+	close(stop)
+	if inCallback {
+		return
+	}
+	<-done
+}
+
+// Close 实现 aliyunpan.Closer，客户端关闭时停止轮询
+func (w *RemoteWatch) Close(ctx context.Context) error {
+	w.Stop()
+	return nil
+}
+
+// Snapshot 最近一次获取的快照
+func (w *RemoteWatch) Snapshot() *aliyunpan.TreeSnapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.snapshot
+}
+
+func (w *RemoteWatch) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			w.poll(stop)
+		}
+	}
+}
+
+// Poll 立即获取一次快照，和上一次比较并触发回调，返回本次的差异。第一次调用只记录快照
+func (w *RemoteWatch) Poll() (*aliyunpan.SnapshotDiff, *apierror.ApiError) {
+	return w.poll(nil)
+}
+
+// poll 获取快照并触发回调，stop 不为nil时为轮询协程调用，stop 关闭后不再触发回调
+func (w *RemoteWatch) poll(stop chan struct{}) (*aliyunpan.SnapshotDiff, *apierror.ApiError) {
+	snapshot, err := w.panClient.TreeSnapshot(w.driveId, w.remotePath)
+	if err != nil {
+		logger.Verboseln("remote watch snapshot error ", err)
+		if w.config.OnError != nil {
+			w.callback(stop, func() {
+				w.config.OnError(err)
+			})
+		}
+		return nil, err
+	}
+
+	w.mu.Lock()
+	previous := w.snapshot
+	w.snapshot = snapshot
+	w.mu.Unlock()
+	if previous == nil {
+		return &aliyunpan.SnapshotDiff{}, nil
+	}
+
+	diff := previous.Diff(snapshot)
+	w.notify(stop, diff)
+	return diff, nil
+}
+
+func (w *RemoteWatch) notify(stop chan struct{}, diff *aliyunpan.SnapshotDiff) {
+	if w.config.OnDeleted != nil {
+		for _, e := range diff.Removed {
+			e := e
+			w.callback(stop, func() {
+				w.config.OnDeleted(e)
+			})
+		}
+	}
+	if w.config.OnMoved != nil {
+		for _, m := range diff.Moved {
+			m := m
+			w.callback(stop, func() {
+				w.config.OnMoved(m)
+			})
+		}
+	}
+	if w.config.OnCreated != nil {
+		for _, e := range diff.Added {
+			e := e
+			w.callback(stop, func() {
+				w.config.OnCreated(e)
+			})
+		}
+	}
+	if w.config.OnModified != nil {
+		for _, e := range diff.Modified {
+			e := e
+			w.callback(stop, func() {
+				w.config.OnModified(e)
+			})
+		}
+	}
+}
+
+// callback 调用回调，轮询协程调用时记录正在执行的回调，已经停止时不再调用
+func (w *RemoteWatch) callback(stop chan struct{}, f func()) {
+	if stop == nil {
+		f()
+		return
+	}
+	w.mu.Lock()
+	select {
+	case <-stop:
+		w.mu.Unlock()
+		return
+	default:
+	}
+	w.callbacks++
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.callbacks--
+		w.mu.Unlock()
+	}()
+	f()
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/panmock"
+	"testing"
+	"time"
+)
+
+func TestRemoteWatchPoll(t *testing.T) {
+	snapshots := []*aliyunpan.TreeSnapshot{
+		{Entries: []*aliyunpan.SnapshotEntry{
+			{Path: "/a.txt", FileId: "a", FileSize: 1},
+			{Path: "/b.txt", FileId: "b", FileSize: 2},
+		}},
+		{Entries: []*aliyunpan.SnapshotEntry{
+			{Path: "/a.txt", FileId: "a", FileSize: 10},
+			{Path: "/c.txt", FileId: "c", FileSize: 3},
+		}},
+	}
+	mock := &panmock.PanClient{}
+	mock.TreeSnapshotFunc = func(driveId, pathStr string) (*aliyunpan.TreeSnapshot, *apierror.ApiError) {
+		s := snapshots[0]
+		if len(snapshots) > 1 {
+			snapshots = snapshots[1:]
+		}
+		return s, nil
+	}
+
+	created, modified, deleted := []string{}, []string{}, []string{}
+	w := NewRemoteWatch(mock, "1", "/", RemoteWatchConfig{
+		OnCreated:  func(e *aliyunpan.SnapshotEntry) { created = append(created, e.Path) },
+		OnModified: func(e *aliyunpan.SnapshotEntry) { modified = append(modified, e.Path) },
+		OnDeleted:  func(e *aliyunpan.SnapshotEntry) { deleted = append(deleted, e.Path) },
+	})
+	_, err := w.Poll()
+	assert.Nil(t, err)
+	assert.Empty(t, created)
+
+	diff, err := w.Poll()
+	assert.Nil(t, err)
+	assert.False(t, diff.IsEmpty())
+	assert.Equal(t, []string{"/c.txt"}, created)
+	assert.Equal(t, []string{"/a.txt"}, modified)
+	assert.Equal(t, []string{"/b.txt"}, deleted)
+	assert.Equal(t, 2, mock.Calls("TreeSnapshot"))
+}
+
+func TestRemoteWatchStopInCallback(t *testing.T) {
+	polls := 0
+	mock := &panmock.PanClient{}
+	mock.TreeSnapshotFunc = func(driveId, pathStr string) (*aliyunpan.TreeSnapshot, *apierror.ApiError) {
+		polls++
+		s := &aliyunpan.TreeSnapshot{}
+		if polls > 1 {
+			s.Entries = []*aliyunpan.SnapshotEntry{{Path: "/a.txt", FileId: "a"}, {Path: "/b.txt", FileId: "b"}}
+		}
+		return s, nil
+	}
+
+	created := make(chan string, 2)
+	var w *RemoteWatch
+	w = NewRemoteWatch(mock, "1", "/", RemoteWatchConfig{
+		Interval: time.Millisecond,
+		OnCreated: func(e *aliyunpan.SnapshotEntry) {
+			created <- e.Path
+			// 在回调中停止不会死锁，之后的回调不再触发
+			w.Stop()
+		},
+	})
+	assert.Nil(t, w.Start())
+	select {
+	case <-created:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected created callback")
+	}
+	select {
+	case <-w.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop in callback deadlocked")
+	}
+	assert.Equal(t, 0, len(created))
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+)
+
+const (
+	// maxSymlinkTextSize 保存软链接目标的文件最大长度，超过的文件不会被当作软链接
+	maxSymlinkTextSize = 4096
+)
+
+// isSymlinkText 网盘文件是否是 SpecialFileStoreAsText 策略保存的软链接
+func (s *Syncer) isSymlinkText(relPath string) bool {
+	return s.policy.SpecialFiles == SpecialFileStoreAsText && strings.HasSuffix(relPath, SymlinkTextSuffix)
+}
+
+// localPath 相对路径对应的本地路径，保存为文本的软链接去掉后缀
+func (s *Syncer) localPath(relPath string) string {
+	if s.isSymlinkText(relPath) {
+		relPath = strings.TrimSuffix(relPath, SymlinkTextSuffix)
+	}
+	return filepath.Join(s.localRoot, filepath.FromSlash(relPath))
+}
+
+// uploadSymlink 把软链接的目标路径上传为文本文件
+func (s *Syncer) uploadSymlink(action *Action) *apierror.ApiError {
+	parentId, name, err := s.uploadTarget(action)
+	if err != nil {
+		return err
+	}
+	_, err = transfer.UploadData(context.Background(), s.panClient, s.driveId, parentId, []byte(action.Local.LinkTarget), name, nil)
+	return err
+}
+
+// downloadSymlink 读取网盘文本文件中保存的目标路径，在本地还原为软链接
+func (s *Syncer) downloadSymlink(fe *aliyunpan.FileEntity, localPath string) *apierror.ApiError {
+	if fe.FileSize > maxSymlinkTextSize {
+		return apierror.NewFailedApiError("软链接文件过大：" + fe.FileName)
+	}
+	content, err := s.panClient.FileGetTextContent(s.driveId, fe.FileId, maxSymlinkTextSize)
+	if err != nil {
+		return err
+	}
+	if content.Text == "" {
+		return apierror.NewFailedApiError("软链接目标为空：" + fe.FileName)
+	}
+	if e := os.MkdirAll(filepath.Dir(localPath), 0755); e != nil {
+		return apierror.NewApiErrorWithError(e)
+	}
+	if e := os.RemoveAll(localPath); e != nil {
+		return apierror.NewApiErrorWithError(e)
+	}
+	if e := os.Symlink(content.Text, localPath); e != nil {
+		return apierror.NewApiErrorWithError(e)
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package filesync 本地文件夹和网盘文件夹同步
+package filesync
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+	"github.com/tickstep/library-go/logger"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// SyncMode 同步模式
+	SyncMode string
+
+	// CompareMode 文件比较方式
+	CompareMode string
+
+	// Policy 同步策略
+	Policy struct {
+		// Mode 同步模式，默认为双向同步
+		Mode SyncMode
+		// CompareMode 文件比较方式，默认为比较大小和SHA1
+		CompareMode CompareMode
+		// DeleteExtra 单向同步时，是否删除目标端多余的文件。双向同步没有历史状态，不会删除任何文件
+		DeleteExtra bool
+		// ConflictResolver 双向同步时，本地和网盘文件内容不一致的处理策略，默认为 NewerWins
+		ConflictResolver ConflictResolver
+		// ConflictSuffix 保留两份文件时使用的文件名后缀，默认为 DefaultConflictSuffix
+		ConflictSuffix string
+		// SpecialFiles 本地软链接的处理策略，默认为 SpecialFileSkip
+		SpecialFiles SpecialFilePolicy
+		// NormalizeNames 比较和上传时把文件名统一为 Unicode NFC 形式，
+		// 避免 macOS 上 NFD 形式的文件名和网盘文件对应不上，产生多余的上传下载
+		NormalizeNames bool
+	}
+
+	// ActionCallback 同步动作执行完成回调，err为nil代表执行成功
+	ActionCallback func(action *Action, err *apierror.ApiError)
+
+	// Syncer 同步器
+	Syncer struct {
+		panClient  *aliyunpan.PanClient
+		driveId    string
+		localRoot  string
+		remoteRoot string
+		policy     Policy
+		// manager 传输任务管理器，为nil时同步执行上传下载
+		manager *transfer.Manager
+
+		// remoteDirIds 网盘文件夹相对路径 -> FileId
+		remoteDirIds map[string]string
+		// skipped 最近一次扫描本地目录时跳过的特殊文件
+		skipped []*SkippedEntry
+	}
+)
+
+const (
+	// SyncModeUpload 单向同步：本地 -> 网盘
+	SyncModeUpload SyncMode = "upload"
+	// SyncModeDownload 单向同步：网盘 -> 本地
+	SyncModeDownload SyncMode = "download"
+	// SyncModeTwoWay 双向同步，内容不一致的文件由 Policy.ConflictResolver 决定如何处理
+	SyncModeTwoWay SyncMode = "two_way"
+
+	// CompareModeSha1 比较大小，大小一致再比较SHA1
+	CompareModeSha1 CompareMode = "sha1"
+	// CompareModeSize 只比较大小
+	CompareModeSize CompareMode = "size"
+	// CompareModeMtime 比较大小和修改时间
+	CompareModeMtime CompareMode = "mtime"
+
+	// mtimeTolerance 修改时间比较的误差，网盘时间只精确到秒
+	mtimeTolerance = 2 * time.Second
+)
+
+var diffReasonText = map[DiffReason]string{
+	DiffReasonSize:  "文件大小不一致",
+	DiffReasonSha1:  "SHA1不一致",
+	DiffReasonMtime: "修改时间不一致",
+}
+
+// NewSyncer 创建同步器。remoteRoot 为网盘绝对路径
+func NewSyncer(panClient *aliyunpan.PanClient, driveId, localRoot, remoteRoot string, policy Policy) *Syncer {
+	if policy.Mode == "" {
+		policy.Mode = SyncModeTwoWay
+	}
+	if policy.CompareMode == "" {
+		policy.CompareMode = CompareModeSha1
+	}
+	if policy.ConflictResolver == nil {
+		policy.ConflictResolver = NewerWins
+	}
+	if policy.SpecialFiles == "" {
+		policy.SpecialFiles = SpecialFileSkip
+	}
+	return &Syncer{
+		panClient:  panClient,
+		driveId:    driveId,
+		localRoot:  filepath.Clean(localRoot),
+		remoteRoot: path.Clean("/" + remoteRoot),
+		policy:     policy,
+	}
+}
+
+// Plan 扫描本地和网盘文件，生成同步计划，不会做任何修改
+func (s *Syncer) Plan() (*Plan, *apierror.ApiError) {
+	localFiles, remoteFiles, apierr := s.scan()
+	if apierr != nil {
+		return nil, apierr
+	}
+	actions, err := buildActions(localFiles, remoteFiles, s.policy)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	plan := s.newPlan(actions)
+	plan.Skipped = s.skipped
+	return plan, nil
+}
+
+// SetTransferManager 设置传输任务管理器，设置后上传下载动作只会加入任务队列，由管理器异步执行
+func (s *Syncer) SetTransferManager(manager *transfer.Manager) {
+	s.manager = manager
+}
+
+// scan 扫描本地和网盘文件，并记录已存在的网盘文件夹，上传时无需再查询
+func (s *Syncer) scan() (LocalFileMap, RemoteFileMap, *apierror.ApiError) {
+	localFiles, report, err := ScanLocalWithOptions(s.localRoot, ScanOptions{
+		SpecialFiles:   s.policy.SpecialFiles,
+		NormalizeNames: s.policy.NormalizeNames,
+	})
+	if err != nil {
+		return nil, nil, apierror.NewApiErrorWithError(err)
+	}
+	s.skipped = report.Skipped
+	remoteFiles, apierr := ScanRemote(s.panClient, s.driveId, s.remoteRoot)
+	if apierr != nil {
+		return nil, nil, apierr
+	}
+	if s.policy.NormalizeNames {
+		remoteFiles = remoteFiles.NormalizeNames()
+	}
+
+	s.remoteDirIds = map[string]string{}
+	for rel, fe := range remoteFiles {
+		if fe.IsFolder() {
+			s.remoteDirIds[rel] = fe.FileId
+		}
+	}
+	return localFiles, remoteFiles, nil
+}
+
+func (s *Syncer) newPlan(actions []*Action) *Plan {
+	return &Plan{
+		LocalRoot:  s.localRoot,
+		DriveId:    s.driveId,
+		RemoteRoot: s.remoteRoot,
+		Actions:    actions,
+	}
+}
+
+// Apply 执行同步计划。单个动作失败不会中断执行，返回最后一个错误
+func (s *Syncer) Apply(plan *Plan, callback ActionCallback) *apierror.ApiError {
+	if plan == nil {
+		return apierror.NewFailedApiError("同步计划不能为空")
+	}
+	if s.remoteDirIds == nil {
+		s.remoteDirIds = map[string]string{}
+	}
+
+	var lastErr *apierror.ApiError
+	for _, action := range plan.Actions {
+		err := s.applyAction(action)
+		if err != nil {
+			logger.Verboseln("sync action error ", action, err)
+			lastErr = err
+		}
+		if callback != nil {
+			callback(action, err)
+		}
+	}
+	return lastErr
+}
+
+// Sync 生成同步计划并立即执行
+func (s *Syncer) Sync(callback ActionCallback) (*Plan, *apierror.ApiError) {
+	plan, err := s.Plan()
+	if err != nil {
+		return nil, err
+	}
+	return plan, s.Apply(plan, callback)
+}
+
+func (s *Syncer) applyAction(action *Action) *apierror.ApiError {
+	localPath := s.actionLocalPath(action)
+	switch action.Type {
+	case ActionMkdirLocal:
+		if err := os.MkdirAll(localPath, 0755); err != nil {
+			return apierror.NewApiErrorWithError(err)
+		}
+	case ActionDeleteLocal:
+		if err := os.RemoveAll(localPath); err != nil {
+			return apierror.NewApiErrorWithError(err)
+		}
+	case ActionDownload:
+		if s.isSymlinkText(action.RelPath) {
+			return s.downloadSymlink(action.Remote, localPath)
+		}
+		if s.manager != nil {
+			if _, err := s.manager.AddDownload(s.driveId, path.Join(s.remoteRoot, action.RelPath), localPath); err != nil {
+				return apierror.NewApiErrorWithError(err)
+			}
+			return nil
+		}
+		return DownloadFile(s.panClient, action.Remote, localPath)
+	case ActionMkdirRemote:
+		_, err := s.remoteDirId(action.RelPath)
+		return err
+	case ActionUpload:
+		if action.Local != nil && action.Local.LinkTarget != "" {
+			return s.uploadSymlink(action)
+		}
+		if s.manager != nil {
+			if _, err := s.manager.AddUpload(s.driveId, localPath, path.Join(s.remoteRoot, action.RelPath)); err != nil {
+				return apierror.NewApiErrorWithError(err)
+			}
+			return nil
+		}
+		parentId, name, err := s.uploadTarget(action)
+		if err != nil {
+			return err
+		}
+		_, err = UploadFile(s.panClient, s.driveId, parentId, localPath, name)
+		return err
+	case ActionDeleteRemote:
+		r, err := s.panClient.FileDelete([]*aliyunpan.FileBatchActionParam{
+			{DriveId: s.driveId, FileId: action.Remote.FileId},
+		})
+		if err != nil {
+			return err
+		}
+		if len(r) == 0 || !r[0].Success {
+			return apierror.NewFailedApiError("删除网盘文件失败：" + action.RelPath)
+		}
+		s.forgetRemoteDir(action.RelPath)
+	case ActionRenameRemote:
+		if _, err := s.panClient.FileRename(s.driveId, action.Remote.FileId, path.Base(action.NewRelPath)); err != nil {
+			return err
+		}
+		s.forgetRemoteDir(action.RelPath)
+	}
+	return nil
+}
+
+// actionLocalPath 动作对应的本地路径，本地文件已存在时使用扫描到的真实路径，文件名规范化后也能找到原文件
+func (s *Syncer) actionLocalPath(action *Action) string {
+	if action.Local != nil && action.Local.Path != "" {
+		return action.Local.Path
+	}
+	return s.localPath(action.RelPath)
+}
+
+// uploadTarget 上传的网盘文件夹FileId和文件名。网盘已有对应的文件时沿用网盘的文件名，
+// 避免产生只有 Unicode 形式不同的重复文件
+func (s *Syncer) uploadTarget(action *Action) (string, string, *apierror.ApiError) {
+	relPath := action.RelPath
+	if s.policy.NormalizeNames {
+		relPath = norm.NFC.String(relPath)
+	}
+	dir, name := path.Split(relPath)
+	if action.Remote != nil && action.Remote.FileName != "" {
+		name = action.Remote.FileName
+	}
+	parentId, err := s.remoteDirId(strings.TrimSuffix(dir, "/"))
+	return parentId, name, err
+}
+
+// remoteDirId 获取网盘文件夹的FileId，不存在则创建
+func (s *Syncer) remoteDirId(relDir string) (string, *apierror.ApiError) {
+	if id, ok := s.remoteDirIds[relDir]; ok {
+		return id, nil
+	}
+	fullPath := path.Join(s.remoteRoot, relDir)
+	if fullPath == "/" {
+		s.remoteDirIds[relDir] = aliyunpan.DefaultRootParentFileId
+		return aliyunpan.DefaultRootParentFileId, nil
+	}
+	r, err := s.panClient.MkdirByFullPath(s.driveId, fullPath)
+	if err != nil {
+		return "", err
+	}
+	s.remoteDirIds[relDir] = r.FileId
+	return r.FileId, nil
+}
+
+// forgetRemoteDir 网盘文件夹被删除或者重命名后，移除文件夹以及子文件夹记录的FileId
+func (s *Syncer) forgetRemoteDir(relDir string) {
+	for rel := range s.remoteDirIds {
+		if rel == relDir || strings.HasPrefix(rel, relDir+"/") {
+			delete(s.remoteDirIds, rel)
+		}
+	}
+}
+
+// buildActions 比较本地和网盘文件，生成同步动作
+func buildActions(localFiles LocalFileMap, remoteFiles RemoteFileMap, policy Policy) ([]*Action, error) {
+	diff, err := DiffFiles(localFiles, remoteFiles, policy.CompareMode)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []*Action{}
+	deletedDirs := map[string]bool{}
+	for _, d := range diff.Added {
+		if policy.Mode == SyncModeDownload {
+			if policy.DeleteExtra && !hasAncestor(d.RelPath, deletedDirs) {
+				actions = append(actions, &Action{Type: ActionDeleteLocal, RelPath: d.RelPath, Local: d.Local, Reason: "网盘不存在"})
+				if d.IsDir {
+					deletedDirs[d.RelPath] = true
+				}
+			}
+		} else if d.IsDir {
+			actions = append(actions, &Action{Type: ActionMkdirRemote, RelPath: d.RelPath, Local: d.Local})
+		} else {
+			actions = append(actions, &Action{Type: ActionUpload, RelPath: d.RelPath, Local: d.Local, Reason: "网盘不存在"})
+		}
+	}
+
+	for _, d := range diff.Removed {
+		if policy.Mode == SyncModeUpload {
+			if policy.DeleteExtra && !hasAncestor(d.RelPath, deletedDirs) {
+				actions = append(actions, &Action{Type: ActionDeleteRemote, RelPath: d.RelPath, Remote: d.Remote, Reason: "本地不存在"})
+				if d.IsDir {
+					deletedDirs[d.RelPath] = true
+				}
+			}
+		} else if d.IsDir {
+			actions = append(actions, &Action{Type: ActionMkdirLocal, RelPath: d.RelPath, Remote: d.Remote})
+		} else {
+			actions = append(actions, &Action{Type: ActionDownload, RelPath: d.RelPath, Remote: d.Remote, Reason: "本地不存在"})
+		}
+	}
+
+	for _, d := range diff.Modified {
+		a := &Action{RelPath: d.RelPath, Local: d.Local, Remote: d.Remote, Reason: diffReasonText[d.Reason]}
+		switch policy.Mode {
+		case SyncModeUpload:
+			if d.Reason == DiffReasonMtime && !d.LocalNewer() {
+				// 网盘文件更新，无需上传
+				continue
+			}
+			a.Type = ActionUpload
+		case SyncModeDownload:
+			if d.Reason == DiffReasonMtime && d.LocalNewer() {
+				continue
+			}
+			a.Type = ActionDownload
+		default:
+			resolver := policy.ConflictResolver
+			if resolver == nil {
+				resolver = NewerWins
+			}
+			actions = append(actions, resolveConflict(d, resolver.Resolve(d), policy.ConflictSuffix)...)
+			continue
+		}
+		actions = append(actions, a)
+	}
+
+	for _, d := range diff.TypeConflicts {
+		logger.Verboseln("sync skip file type conflict: " + d.RelPath)
+	}
+	sortActions(actions)
+	return actions, nil
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testFiles() (LocalFileMap, RemoteFileMap) {
+	now := time.Now().Truncate(time.Second)
+	local := LocalFileMap{
+		"a.txt":     {RelPath: "a.txt", Size: 10, ModTime: now},
+		"dir":       {RelPath: "dir", IsDir: true},
+		"dir/b.txt": {RelPath: "dir/b.txt", Size: 20, ModTime: now.Add(time.Hour)},
+	}
+	remote := RemoteFileMap{
+		"dir":       {FileId: "dir", FileType: "folder"},
+		"dir/b.txt": {FileId: "b", FileType: "file", FileSize: 30, UpdatedAt: now.Format("2006-01-02 15:04:05")},
+		"old":       {FileId: "old", FileType: "folder"},
+		"old/c.txt": {FileId: "c", FileType: "file", FileSize: 5},
+	}
+	return local, remote
+}
+
+func TestBuildActionsTwoWay(t *testing.T) {
+	local, remote := testFiles()
+	actions, err := buildActions(local, remote, Policy{Mode: SyncModeTwoWay, CompareMode: CompareModeSha1})
+	assert.Nil(t, err)
+
+	r := map[string]ActionType{}
+	for _, a := range actions {
+		r[a.RelPath] = a.Type
+	}
+	assert.Equal(t, map[string]ActionType{
+		"a.txt":     ActionUpload,
+		"dir/b.txt": ActionUpload,
+		"old":       ActionMkdirLocal,
+		"old/c.txt": ActionDownload,
+	}, r)
+	assert.Equal(t, ActionMkdirLocal, actions[0].Type)
+}
+
+func TestBuildActionsUploadDeleteExtra(t *testing.T) {
+	local, remote := testFiles()
+	actions, err := buildActions(local, remote, Policy{Mode: SyncModeUpload, CompareMode: CompareModeSize, DeleteExtra: true})
+	assert.Nil(t, err)
+
+	// old/c.txt 随上级目录一起删除
+	assert.Equal(t, 3, len(actions))
+	assert.Equal(t, ActionDeleteRemote, actions[2].Type)
+	assert.Equal(t, "old", actions[2].RelPath)
+}
+
+func TestBuildActionsSameFile(t *testing.T) {
+	local := LocalFileMap{"a.txt": {RelPath: "a.txt", Size: 10}}
+	remote := RemoteFileMap{"a.txt": &aliyunpan.FileEntity{FileType: "file", FileSize: 10}}
+	actions, err := buildActions(local, remote, Policy{Mode: SyncModeTwoWay, CompareMode: CompareModeSha1})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(actions))
+}
+
+func TestBuildMirrorActionsToRemote(t *testing.T) {
+	local, remote := testFiles()
+	local["conflict"] = &LocalFileInfo{RelPath: "conflict", Size: 1}
+	remote["conflict"] = &aliyunpan.FileEntity{FileId: "conflict", FileType: "folder"}
+	remote["conflict/d.txt"] = &aliyunpan.FileEntity{FileId: "d", FileType: "file", FileSize: 1}
+	diff, err := DiffFiles(local, remote, CompareModeSize)
+	assert.Nil(t, err)
+
+	actions := buildMirrorActions(diff, true)
+	r := []string{}
+	for _, a := range actions {
+		r = append(r, string(a.Type)+" "+a.RelPath)
+	}
+	assert.Equal(t, []string{
+		"delete_remote old",
+		"delete_remote conflict",
+		"upload a.txt",
+		"upload conflict",
+		"upload dir/b.txt",
+	}, r)
+}
+
+func TestBuildActionsKeepBoth(t *testing.T) {
+	local, remote := testFiles()
+	actions, err := buildActions(local, remote, Policy{Mode: SyncModeTwoWay, CompareMode: CompareModeSize, ConflictResolver: KeepBoth})
+	assert.Nil(t, err)
+
+	r := []string{}
+	for _, a := range actions {
+		if a.RelPath == "dir/b.txt" || a.RelPath == "dir/b.conflict.txt" {
+			r = append(r, a.String())
+		}
+	}
+	assert.Equal(t, []string{
+		"rename_remote dir/b.txt -> dir/b.conflict.txt (保留两份文件)",
+		"download dir/b.conflict.txt (保留两份文件)",
+		"upload dir/b.txt (文件大小不一致)",
+	}, r)
+	assert.Equal(t, ".bashrc.conflict", conflictRelPath(".bashrc", ""))
+}
+
+func TestBuildActionsConflictCallback(t *testing.T) {
+	local, remote := testFiles()
+	asked := 0
+	actions, err := buildActions(local, remote, Policy{Mode: SyncModeTwoWay, CompareMode: CompareModeSize, ConflictResolver: ConflictResolverFunc(func(conflict *DiffEntry) ConflictResolution {
+		asked++
+		return ConflictSkip
+	})})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, asked)
+	for _, a := range actions {
+		assert.NotEqual(t, "dir/b.txt", a.RelPath)
+	}
+}
+
+func TestVerifyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesync")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("123456789"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "b.txt"), []byte("123456789"), 0644))
+
+	local, err := ScanLocal(dir)
+	assert.Nil(t, err)
+	remote := RemoteFileMap{
+		"a.txt": {FileType: "file", FileSize: 9, ContentHash: "F7C3BC1D808E04732ADF679965CCC34CA7AE3441", Crc64Hash: "11051210869376104954"},
+		"b.txt": {FileType: "file", FileSize: 9, Crc64Hash: "1"},
+		"c.txt": {FileType: "file", FileSize: 1},
+	}
+	r := verifyFiles(local, remote)
+	assert.Equal(t, 1, r.Verified)
+	assert.Equal(t, 2, len(r.Mismatches))
+	assert.Equal(t, VerifyCrc64Mismatch, r.Mismatches[0].Problem)
+	assert.Equal(t, VerifyMissingLocal, r.Mismatches[1].Problem)
+}
+
+func TestScanLocalSpecialFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesync")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("123"), 0644))
+	assert.Nil(t, os.Symlink("sub/a.txt", filepath.Join(dir, "link.txt")))
+	assert.Nil(t, os.Symlink("sub", filepath.Join(dir, "linkdir")))
+	// 指向上级目录的循环链接
+	assert.Nil(t, os.Symlink("..", filepath.Join(dir, "sub", "loop")))
+
+	local, report, err := ScanLocalWithOptions(dir, ScanOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(local))
+	assert.Equal(t, 3, len(report.Skipped))
+
+	local, report, err = ScanLocalWithOptions(dir, ScanOptions{SpecialFiles: SpecialFileFollow})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), local["link.txt"].Size)
+	assert.True(t, local["linkdir"].IsDir)
+	assert.NotNil(t, local["linkdir/a.txt"])
+	// linkdir/loop 和 sub/loop 都指向根目录，是循环链接
+	assert.Equal(t, 2, len(report.Skipped))
+
+	local, report, err = ScanLocalWithOptions(dir, ScanOptions{SpecialFiles: SpecialFileStoreAsText})
+	assert.Nil(t, err)
+	l := local["link.txt"+SymlinkTextSuffix]
+	assert.Equal(t, "sub/a.txt", l.LinkTarget)
+	sha1, err := l.Sha1()
+	assert.Nil(t, err)
+	assert.Equal(t, 40, len(sha1))
+	assert.Equal(t, 0, len(report.Skipped))
+}
+
+func TestNormalizeNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesync")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	// NFD 形式的文件名，é 由 e 和组合重音符组成
+	nfd := "cafe\u0301.txt"
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, nfd), []byte("1"), 0644))
+
+	local, _, err := ScanLocalWithOptions(dir, ScanOptions{NormalizeNames: true})
+	assert.Nil(t, err)
+	l := local["caf\u00e9.txt"]
+	assert.NotNil(t, l)
+	assert.Equal(t, filepath.Join(dir, nfd), l.Path)
+
+	remote := RemoteFileMap{"cafe\u0301.txt": {FileType: "file", FileSize: 1}}.NormalizeNames()
+	assert.NotNil(t, remote["caf\u00e9.txt"])
+}
+
+func TestNeedsUpload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesync")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	p := filepath.Join(dir, "a.txt")
+	assert.Nil(t, ioutil.WriteFile(p, []byte("123456789"), 0644))
+	now := time.Now()
+	l := &LocalFileInfo{RelPath: "a.txt", Path: p, Size: 9, ModTime: now}
+	same := &aliyunpan.FileEntity{FileType: "file", FileSize: 9, ContentHash: "F7C3BC1D808E04732ADF679965CCC34CA7AE3441", UpdatedTime: now.Add(-time.Second)}
+	changed := &aliyunpan.FileEntity{FileType: "file", FileSize: 9, ContentHash: "0", UpdatedTime: now.Add(-time.Hour)}
+
+	need, err := NeedsUpload(l, nil, CompareModeSha1)
+	assert.Nil(t, err)
+	assert.True(t, need)
+	need, _ = NeedsUpload(l, same, CompareModeSha1)
+	assert.False(t, need)
+	need, _ = NeedsUpload(l, changed, CompareModeSha1)
+	assert.True(t, need)
+	need, _ = NeedsUpload(l, changed, CompareModeSize)
+	assert.False(t, need)
+	need, _ = NeedsUpload(l, changed, CompareModeMtime)
+	assert.True(t, need)
+	need, _ = NeedsUpload(l, &aliyunpan.FileEntity{FileType: "folder"}, CompareModeSha1)
+	assert.False(t, need)
+	need, _ = NeedsDownload(nil, same, CompareModeSha1)
+	assert.True(t, need)
+
+	equal, err := ContentEqual(p, same)
+	assert.Nil(t, err)
+	assert.True(t, equal)
+	equal, _ = ContentEqual(p, changed)
+	assert.False(t, equal)
+}
+
+func TestFindRenamed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesync")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("hello"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "other.txt"), []byte("world"), 0644))
+
+	now := time.Now().Truncate(time.Second)
+	present := map[string]*LocalFileInfo{
+		"new.txt":   {RelPath: "new.txt", Path: filepath.Join(dir, "new.txt"), Size: 5, ModTime: now},
+		"other.txt": {RelPath: "other.txt", Path: filepath.Join(dir, "other.txt"), Size: 5, ModTime: now},
+	}
+	changes := map[string]fsnotify.Op{"old.txt": fsnotify.Remove, "new.txt": fsnotify.Create, "other.txt": fsnotify.Create}
+
+	// SHA1一致才是重命名
+	fe := &aliyunpan.FileEntity{FileType: "file", FileSize: 5, ContentHash: "AAF4C61DDCC5E8A2DABEDE0F3B482CD9AEA9434D"}
+	assert.Equal(t, "new.txt", findRenamed("old.txt", fe, present, changes, nil))
+	fe.ContentHash = "0000000000000000000000000000000000000000"
+	assert.Equal(t, "", findRenamed("old.txt", fe, present, changes, nil))
+
+	// 没有SHA1时比较修改时间
+	delete(present, "other.txt")
+	fe = &aliyunpan.FileEntity{FileType: "file", FileSize: 5, UpdatedAt: now.Add(time.Hour).Format("2006-01-02 15:04:05")}
+	assert.Equal(t, "", findRenamed("old.txt", fe, present, changes, nil))
+	fe.UpdatedAt = now.Format("2006-01-02 15:04:05")
+	assert.Equal(t, "new.txt", findRenamed("old.txt", fe, present, changes, nil))
+}
+
+func TestFindRenamedFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesync")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "new"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "new", "a.txt"), []byte("hello"), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "new", "b.txt"), []byte("world"), 0644))
+
+	present := map[string]*LocalFileInfo{
+		"new": {RelPath: "new", Path: filepath.Join(dir, "new"), IsDir: true},
+	}
+	changes := map[string]fsnotify.Op{"old": fsnotify.Remove, "new": fsnotify.Create}
+	fe := &aliyunpan.FileEntity{FileId: "old", FileType: "folder"}
+	remoteNames := []string{}
+	remoteChildNames := func(fe *aliyunpan.FileEntity) ([]string, error) {
+		return remoteNames, nil
+	}
+
+	// 下级文件名一致才是重命名
+	remoteNames = []string{"b.txt", "a.txt"}
+	assert.Equal(t, "new", findRenamed("old", fe, present, changes, remoteChildNames))
+	remoteNames = []string{"a.txt", "c.txt"}
+	assert.Equal(t, "", findRenamed("old", fe, present, changes, remoteChildNames))
+	remoteNames = []string{"a.txt"}
+	assert.Equal(t, "", findRenamed("old", fe, present, changes, remoteChildNames))
+
+	// 获取网盘文件列表失败时不当作重命名
+	assert.Equal(t, "", findRenamed("old", fe, present, changes, func(fe *aliyunpan.FileEntity) ([]string, error) {
+		return nil, os.ErrPermission
+	}))
+}
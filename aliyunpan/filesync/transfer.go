@@ -0,0 +1,32 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+)
+
+// UploadFile 上传本地文件到网盘指定文件夹，同名文件会被覆盖。支持秒传
+func UploadFile(panClient *aliyunpan.PanClient, driveId, parentFileId, localPath, fileName string) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError) {
+	return transfer.UploadFile(context.Background(), panClient, driveId, parentFileId, localPath, fileName, nil)
+}
+
+// DownloadFile 下载网盘文件到本地指定路径，并把修改时间设置为网盘文件的修改时间
+func DownloadFile(panClient *aliyunpan.PanClient, fe *aliyunpan.FileEntity, localPath string) *apierror.ApiError {
+	return transfer.DownloadFile(context.Background(), panClient, fe, localPath, nil)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type (
+	// VerifyProblem 校验不通过的原因
+	VerifyProblem string
+
+	// VerifyMismatch 校验不通过的文件
+	VerifyMismatch struct {
+		RelPath string
+		Problem VerifyProblem
+		Local   *LocalFileInfo
+		Remote  *aliyunpan.FileEntity
+		// Detail 详细信息，例如不一致的哈希值
+		Detail string
+	}
+
+	// VerifyResult 校验结果
+	VerifyResult struct {
+		// Verified 校验通过的文件数量
+		Verified int
+		// Mismatches 校验不通过的文件，按路径排序
+		Mismatches []*VerifyMismatch
+	}
+)
+
+const (
+	// VerifyMissingLocal 本地文件不存在
+	VerifyMissingLocal VerifyProblem = "missing_local"
+	// VerifyMissingRemote 网盘文件不存在
+	VerifyMissingRemote VerifyProblem = "missing_remote"
+	// VerifyTypeMismatch 一端是文件，另一端是文件夹
+	VerifyTypeMismatch VerifyProblem = "type_mismatch"
+	// VerifySizeMismatch 文件大小不一致
+	VerifySizeMismatch VerifyProblem = "size_mismatch"
+	// VerifySha1Mismatch SHA1不一致
+	VerifySha1Mismatch VerifyProblem = "sha1_mismatch"
+	// VerifyCrc64Mismatch CRC64不一致
+	VerifyCrc64Mismatch VerifyProblem = "crc64_mismatch"
+	// VerifyReadError 读取本地文件失败
+	VerifyReadError VerifyProblem = "read_error"
+)
+
+// IsOk 是否全部校验通过
+func (r *VerifyResult) IsOk() bool {
+	return r != nil && len(r.Mismatches) == 0
+}
+
+func (m *VerifyMismatch) String() string {
+	if m.Detail == "" {
+		return fmt.Sprintf("%s %s", m.Problem, m.RelPath)
+	}
+	return fmt.Sprintf("%s %s (%s)", m.Problem, m.RelPath, m.Detail)
+}
+
+// VerifyTree 校验本地备份和网盘文件是否一致，不会下载任何文件。比较文件大小，再计算本地文件的SHA1和CRC64，
+// 和网盘记录的哈希值比较。remotePath 为网盘绝对路径
+func VerifyTree(panClient *aliyunpan.PanClient, driveId, remotePath, localPath string) (*VerifyResult, *apierror.ApiError) {
+	localFiles, err := ScanLocal(filepath.Clean(localPath))
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	remoteFiles, apierr := ScanRemote(panClient, driveId, path.Clean("/"+remotePath))
+	if apierr != nil {
+		return nil, apierr
+	}
+	return verifyFiles(localFiles, remoteFiles), nil
+}
+
+func verifyFiles(localFiles LocalFileMap, remoteFiles RemoteFileMap) *VerifyResult {
+	result := &VerifyResult{
+		Mismatches: []*VerifyMismatch{},
+	}
+	addMismatch := func(rel string, problem VerifyProblem, l *LocalFileInfo, r *aliyunpan.FileEntity, detail string) {
+		result.Mismatches = append(result.Mismatches, &VerifyMismatch{RelPath: rel, Problem: problem, Local: l, Remote: r, Detail: detail})
+	}
+
+	for rel, r := range remoteFiles {
+		l := localFiles[rel]
+		if l == nil {
+			addMismatch(rel, VerifyMissingLocal, nil, r, "")
+			continue
+		}
+		if l.IsDir != r.IsFolder() {
+			addMismatch(rel, VerifyTypeMismatch, l, r, "")
+			continue
+		}
+		if l.IsDir {
+			continue
+		}
+		if l.Size != r.FileSize {
+			addMismatch(rel, VerifySizeMismatch, l, r, fmt.Sprintf("local %d, remote %d", l.Size, r.FileSize))
+			continue
+		}
+		sha1Str, crc64Str, err := apiutil.ComputeFileHashes(l.Path)
+		if err != nil {
+			addMismatch(rel, VerifyReadError, l, r, err.Error())
+			continue
+		}
+		if r.ContentHash != "" && !strings.EqualFold(sha1Str, r.ContentHash) {
+			addMismatch(rel, VerifySha1Mismatch, l, r, fmt.Sprintf("local %s, remote %s", sha1Str, apiutil.NormalizeSha1(r.ContentHash)))
+			continue
+		}
+		if r.Crc64Hash != "" && crc64Str != r.Crc64Hash {
+			addMismatch(rel, VerifyCrc64Mismatch, l, r, fmt.Sprintf("local %s, remote %s", crc64Str, r.Crc64Hash))
+			continue
+		}
+		result.Verified++
+	}
+	for rel, l := range localFiles {
+		if remoteFiles[rel] == nil {
+			addMismatch(rel, VerifyMissingRemote, l, nil, "")
+		}
+	}
+	sort.Slice(result.Mismatches, func(i, j int) bool {
+		return result.Mismatches[i].RelPath < result.Mismatches[j].RelPath
+	})
+	return result
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesync
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+	"github.com/tickstep/library-go/logger"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// WatchConfig 本地目录监听配置
+	WatchConfig struct {
+		// Debounce 文件变化后等待的时间，期间的变化会合并处理，默认为2秒
+		Debounce time.Duration
+		// Manager 传输任务管理器，不为nil时上传加入任务队列异步执行
+		Manager *transfer.Manager
+		// Callback 每个同步动作执行完成回调
+		Callback ActionCallback
+	}
+
+	// LocalWatch 监听本地目录的变化，自动把新建、修改、重命名、删除同步到网盘，即"自动备份文件夹"
+	LocalWatch struct {
+		syncer  *Syncer
+		config  WatchConfig
+		watcher *fsnotify.Watcher
+
+		mu      sync.Mutex
+		pending map[string]fsnotify.Op
+		stop    chan struct{}
+		done    chan struct{}
+		// unregister 取消在客户端注册的关闭回调
+		unregister func()
+	}
+)
+
+// NewLocalWatch 创建本地目录监听。remoteRoot 为网盘绝对路径，需要先调用 Start 开始监听
+func NewLocalWatch(panClient *aliyunpan.PanClient, localRoot, driveId, remoteRoot string, config WatchConfig) *LocalWatch {
+	if config.Debounce <= 0 {
+		config.Debounce = 2 * time.Second
+	}
+	s := NewSyncer(panClient, driveId, localRoot, remoteRoot, Policy{Mode: SyncModeUpload})
+	s.SetTransferManager(config.Manager)
+	s.remoteDirIds = map[string]string{}
+	return &LocalWatch{
+		syncer:  s,
+		config:  config,
+		pending: map[string]fsnotify.Op{},
+	}
+}
+
+// Start 开始监听，启动前的本地变化不会被同步，可以先调用 Syncer 或者 MirrorToRemote 做一次全量同步
+func (w *LocalWatch) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.watcher != nil {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err = addWatchRecursive(watcher, w.syncer.localRoot); err != nil {
+		watcher.Close()
+		return err
+	}
+	w.watcher = watcher
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	w.unregister = w.syncer.panClient.RegisterCloser(w)
+	go w.run(watcher, w.stop, w.done)
+	return nil
+}
+
+// Stop 停止监听，还未处理的变化会被立即处理
+func (w *LocalWatch) Stop() error {
+	w.mu.Lock()
+	watcher, stop, done, unregister := w.watcher, w.stop, w.done, w.unregister
+	w.watcher = nil
+	w.unregister = nil
+	w.mu.Unlock()
+	if watcher == nil {
+		return nil
+	}
+	unregister()
+	close(stop)
+	<-done
+	w.flush()
+	return watcher.Close()
+}
+
+// Close 实现 aliyunpan.Closer，客户端关闭时停止监听
+func (w *LocalWatch) Close(ctx context.Context) error {
+	return w.Stop()
+}
+
+// addWatchRecursive fsnotify 不支持递归监听，需要监听每一个子目录
+func addWatchRecursive(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+func (w *LocalWatch) run(watcher *fsnotify.Watcher, stop, done chan struct{}) {
+	defer close(done)
+	timer := time.NewTimer(w.config.Debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			w.record(watcher, ev)
+			timer.Reset(w.config.Debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Verboseln("watch local dir error ", err)
+		case <-timer.C:
+			w.flush()
+		}
+	}
+}
+
+// record 记录文件变化，新建的目录需要添加监听
+func (w *LocalWatch) record(watcher *fsnotify.Watcher, ev fsnotify.Event) {
+	if strings.HasSuffix(ev.Name, transfer.DownloadTmpSuffix) || strings.HasSuffix(ev.Name, transfer.DownloadMetaSuffix) {
+		return
+	}
+	rel, err := filepath.Rel(w.syncer.localRoot, ev.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	if ev.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			if err = addWatchRecursive(watcher, ev.Name); err != nil {
+				logger.Verboseln("watch new dir error ", err)
+			}
+		}
+	}
+	w.mu.Lock()
+	w.pending[filepath.ToSlash(rel)] |= ev.Op
+	w.mu.Unlock()
+}
+
+// flush 处理所有记录的变化
+func (w *LocalWatch) flush() {
+	w.mu.Lock()
+	changes := w.pending
+	w.pending = map[string]fsnotify.Op{}
+	w.mu.Unlock()
+	if len(changes) == 0 {
+		return
+	}
+
+	plan := w.syncer.newPlan(w.buildWatchActions(changes))
+	w.syncer.Apply(plan, w.config.Callback)
+}
+
+// buildWatchActions 根据变化的文件生成同步动作
+func (w *LocalWatch) buildWatchActions(changes map[string]fsnotify.Op) []*Action {
+	present := map[string]*LocalFileInfo{}
+	removed := []string{}
+	for rel := range changes {
+		p := filepath.Join(w.syncer.localRoot, filepath.FromSlash(rel))
+		info, err := os.Stat(p)
+		if err != nil {
+			removed = append(removed, rel)
+			continue
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			continue
+		}
+		present[rel] = &LocalFileInfo{RelPath: rel, Path: p, Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}
+	}
+	sort.Strings(removed)
+
+	actions := []*Action{}
+	deletedDirs := map[string]bool{}
+	for _, rel := range removed {
+		if hasAncestor(rel, deletedDirs) {
+			continue
+		}
+		fe, err := w.syncer.panClient.FileInfoByPath(w.syncer.driveId, path.Join(w.syncer.remoteRoot, rel))
+		if err != nil {
+			// 网盘不存在，无需处理
+			continue
+		}
+		// 同一目录下新出现的内容一致的文件，当作重命名处理
+		if renamed := findRenamed(rel, fe, present, changes, w.remoteChildNames); renamed != "" {
+			actions = append(actions, &Action{Type: ActionRenameRemote, RelPath: rel, NewRelPath: renamed, Remote: fe, Reason: "本地重命名"})
+			delete(present, renamed)
+			continue
+		}
+		actions = append(actions, &Action{Type: ActionDeleteRemote, RelPath: rel, Remote: fe, Reason: "本地已删除"})
+		if fe.IsFolder() {
+			deletedDirs[rel] = true
+		}
+	}
+
+	for rel, l := range present {
+		if !l.IsDir {
+			actions = append(actions, &Action{Type: ActionUpload, RelPath: rel, Local: l, Reason: "本地已修改"})
+			continue
+		}
+		actions = append(actions, &Action{Type: ActionMkdirRemote, RelPath: rel, Local: l})
+		if changes[rel]&fsnotify.Create == 0 {
+			continue
+		}
+		// 新建或者移入的目录，上传目录下的所有文件
+		children, err := ScanLocal(l.Path)
+		if err != nil {
+			logger.Verboseln("scan new dir error ", err)
+			continue
+		}
+		for childRel, child := range children {
+			childRel = rel + "/" + childRel
+			if _, ok := present[childRel]; ok {
+				continue
+			}
+			child.RelPath = childRel
+			if child.IsDir {
+				actions = append(actions, &Action{Type: ActionMkdirRemote, RelPath: childRel, Local: child})
+			} else {
+				actions = append(actions, &Action{Type: ActionUpload, RelPath: childRel, Local: child, Reason: "本地新建"})
+			}
+		}
+	}
+	sortActions(actions)
+	return actions
+}
+
+// childNamesFunc 获取网盘文件夹下的文件名
+type childNamesFunc func(fe *aliyunpan.FileEntity) ([]string, error)
+
+// remoteChildNames 获取网盘文件夹下的文件名
+func (w *LocalWatch) remoteChildNames(fe *aliyunpan.FileEntity) ([]string, error) {
+	fileList, apierr := w.syncer.panClient.FileListGetAll(&aliyunpan.FileListParam{
+		DriveId:      w.syncer.driveId,
+		ParentFileId: fe.FileId,
+	})
+	if apierr != nil {
+		return nil, apierr
+	}
+	names := make([]string, 0, len(fileList))
+	for _, child := range fileList {
+		names = append(names, child.FileName)
+	}
+	return names, nil
+}
+
+// findRenamed 查找重命名后的文件。文件需要内容一致：网盘记录了SHA1时比较SHA1，否则比较修改时间；
+// 文件夹需要下级的文件名与网盘一致。无法确认时不当作重命名，按删除和上传处理
+func findRenamed(rel string, fe *aliyunpan.FileEntity, present map[string]*LocalFileInfo, changes map[string]fsnotify.Op, remoteChildNames childNamesFunc) string {
+	dir := path.Dir(rel)
+	for candidate, l := range present {
+		if changes[candidate]&fsnotify.Create == 0 || path.Dir(candidate) != dir || l.IsDir != fe.IsFolder() {
+			continue
+		}
+		if l.IsDir {
+			if !isSameFolder(l, fe, remoteChildNames) {
+				continue
+			}
+		} else if !isSameContent(l, fe) {
+			continue
+		}
+		return candidate
+	}
+	return ""
+}
+
+// isSameFolder 本地文件夹和网盘文件夹下的文件名是否完全一致
+func isSameFolder(l *LocalFileInfo, fe *aliyunpan.FileEntity, remoteChildNames childNamesFunc) bool {
+	infos, err := ioutil.ReadDir(l.Path)
+	if err != nil {
+		logger.Verboseln("read local dir error ", err)
+		return false
+	}
+	names, err := remoteChildNames(fe)
+	if err != nil {
+		logger.Verboseln("list remote dir error ", err)
+		return false
+	}
+	if len(infos) != len(names) {
+		return false
+	}
+	localNames := map[string]bool{}
+	for _, info := range infos {
+		localNames[info.Name()] = true
+	}
+	for _, name := range names {
+		if !localNames[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// isSameContent 本地文件和网盘文件的内容是否确定一致
+func isSameContent(l *LocalFileInfo, fe *aliyunpan.FileEntity) bool {
+	if l.Size != fe.FileSize {
+		return false
+	}
+	if fe.ContentHash == "" {
+		// 重命名不会改变修改时间
+		mtime := remoteModTime(fe)
+		return !mtime.IsZero() && absDuration(l.ModTime.Sub(mtime)) <= mtimeTolerance
+	}
+	sha1Str, err := l.Sha1()
+	if err != nil {
+		logger.Verboseln("compute sha1 error ", err)
+		return false
+	}
+	return strings.EqualFold(sha1Str, fe.ContentHash)
+}
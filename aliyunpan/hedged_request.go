@@ -0,0 +1,103 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/library-go/logger"
+	"time"
+)
+
+type (
+	hedgedFetchResult struct {
+		body []byte
+		err  error
+	}
+)
+
+// EnableHedgedRequest 开启对冲请求。
+// 对于 file/get, file/list 这类小的幂等请求，如果在 delay 时间内没有返回，则再发起一个相同的请求，使用最先返回的结果，
+// 用于降低交互式客户端的长尾延迟。delay <= 0 代表关闭对冲请求
+func (p *PanClient) EnableHedgedRequest(delay time.Duration) {
+	if delay < 0 {
+		delay = 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hedgeDelay = delay
+}
+
+// DisableHedgedRequest 关闭对冲请求
+func (p *PanClient) DisableHedgedRequest() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hedgeDelay = 0
+}
+
+// hedgedFetch 发起幂等请求，如果开启了对冲请求，则超时后再发起一个相同的请求，返回最先成功的结果
+func (p *PanClient) hedgedFetch(method string, urlStr string, post interface{}, header map[string]string) ([]byte, error) {
+	p.mu.RLock()
+	delay := p.hedgeDelay
+	p.mu.RUnlock()
+	if delay <= 0 {
+		return p.fetch(method, urlStr, post, header)
+	}
+
+	// 缓冲为2，保证落后的请求返回时不会阻塞
+	resultChan := make(chan *hedgedFetchResult, 2)
+	doFetch := func() {
+		body, err := p.doFetch(method, urlStr, post, header)
+		resultChan <- &hedgedFetchResult{body: body, err: err}
+	}
+
+	go doFetch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var done <-chan struct{}
+	if p.ctx != nil {
+		done = p.ctx.Done()
+	}
+	inFlight := 1
+	hedged := false
+	var lastErr error
+	for {
+		select {
+		case r := <-resultChan:
+			inFlight--
+			if r.err == nil {
+				return r.body, nil
+			}
+			lastErr = r.err
+			if !hedged {
+				// 首个请求已经失败，立即发起对冲请求
+				hedged = true
+				timer.Stop()
+				inFlight++
+				go doFetch()
+			} else if inFlight == 0 {
+				return nil, lastErr
+			}
+		case <-done:
+			return nil, p.ctx.Err()
+		case <-timer.C:
+			if !hedged {
+				logger.Verboseln("hedged request fired: " + urlStr)
+				hedged = true
+				inFlight++
+				go doFetch()
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/tickstep/library-go/requester"
+)
+
+type (
+	// TransportWrapper 包装http请求底层的 RoundTripper，可用于注入日志、监控或者模拟网络故障（参见 chaos 包）
+	TransportWrapper func(base http.RoundTripper) http.RoundTripper
+)
+
+var (
+	transportMu      sync.RWMutex
+	transportWrapper TransportWrapper
+	// sharedTransports 包级共享客户端原始的 Transport，用于重新设置或者取消包装
+	sharedTransports map[*requester.HTTPClient]http.RoundTripper
+)
+
+// SetTransportWrapper 设置全局的 TransportWrapper，之后SDK发起的http请求（包括文件数据的上传下载）都会经过包装后的 Transport。
+// 传入nil恢复默认。该方法应该在发起请求之前调用
+func SetTransportWrapper(wrapper TransportWrapper) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	if sharedTransports == nil {
+		sharedTransports = map[*requester.HTTPClient]http.RoundTripper{}
+		for _, c := range []*requester.HTTPClient{client, appClient} {
+			// 先初始化 transport，避免之后 lazyInit 覆盖包装后的 Transport
+			c.SetKeepAlive(true)
+			sharedTransports[c] = c.Client.Transport
+		}
+	}
+	transportWrapper = wrapper
+	for c, base := range sharedTransports {
+		c.Client.Transport = wrapTransport(base, wrapper)
+	}
+}
+
+// NewHTTPClient 创建http客户端，设置了 TransportWrapper 时使用包装后的 Transport。
+// 调用 DownloadFileData 等需要自行发起请求的接口时，应该使用该方法创建客户端
+func NewHTTPClient() *requester.HTTPClient {
+	c := requester.NewHTTPClient()
+	transportMu.RLock()
+	wrapper := transportWrapper
+	transportMu.RUnlock()
+	if wrapper != nil {
+		c.SetKeepAlive(true)
+		c.Client.Transport = wrapper(c.Client.Transport)
+	}
+	return c
+}
+
+func wrapTransport(base http.RoundTripper, wrapper TransportWrapper) http.RoundTripper {
+	if wrapper == nil {
+		return base
+	}
+	return wrapper(base)
+}
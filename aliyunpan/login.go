@@ -59,6 +59,11 @@ var (
 	client = requester.NewHTTPClient()
 )
 
+func init() {
+	// 提前初始化 transport，避免多个 goroutine 同时发起第一个请求时 lazyInit 产生数据竞争
+	client.SetKeepAlive(true)
+}
+
 func (w *WebLoginToken) GetAuthorizationStr() string {
 	return w.AccessTokenType + " " + w.AccessToken
 }
@@ -72,7 +77,7 @@ func (w *WebLoginToken) IsAccessTokenExpired() bool {
 }
 
 func GetAccessTokenFromRefreshToken(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
-	client := requester.NewHTTPClient()
+	client := NewHTTPClient()
 
 	header := map[string]string {}
 
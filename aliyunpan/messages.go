@@ -0,0 +1,104 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import "sync"
+
+type (
+	// Language 实体展示信息使用的语言
+	Language string
+)
+
+const (
+	// LanguageZh 简体中文，默认语言
+	LanguageZh Language = "zh"
+	// LanguageEn 英文
+	LanguageEn Language = "en"
+
+	// MessageFileId 等为消息目录中的标签，RegisterMessages 注册其他语言时使用
+	MessageFileId     = "file.id"
+	MessageFileName   = "file.name"
+	MessageFileType   = "file.type"
+	MessageFilePath   = "file.path"
+	MessageTypeFolder = "file.type.folder"
+	MessageTypeFile   = "file.type.file"
+)
+
+var (
+	messagesMu sync.RWMutex
+	// messageCatalog 各语言的标签，缺少的标签使用中文
+	messageCatalog = map[Language]map[string]string{
+		LanguageZh: {
+			MessageFileId:     "文件ID",
+			MessageFileName:   "文件名",
+			MessageFileType:   "文件类型",
+			MessageFilePath:   "文件路径",
+			MessageTypeFolder: "目录",
+			MessageTypeFile:   "文件",
+		},
+		LanguageEn: {
+			MessageFileId:     "File ID",
+			MessageFileName:   "Name",
+			MessageFileType:   "Type",
+			MessageFilePath:   "Path",
+			MessageTypeFolder: "Folder",
+			MessageTypeFile:   "File",
+		},
+	}
+)
+
+// RegisterMessages 注册或者覆盖一种语言的标签，用于展示中英文以外的界面
+func RegisterMessages(lang Language, messages map[string]string) {
+	messagesMu.Lock()
+	defer messagesMu.Unlock()
+	m := messageCatalog[lang]
+	if m == nil {
+		m = map[string]string{}
+		messageCatalog[lang] = m
+	}
+	for k, v := range messages {
+		m[k] = v
+	}
+}
+
+// Message 获取标签在该语言中的文本，语言或者标签不存在时使用中文
+func (lang Language) Message(key string) string {
+	messagesMu.RLock()
+	defer messagesMu.RUnlock()
+	if msg, ok := messageCatalog[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := messageCatalog[LanguageZh][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// SetLanguage 设置获取到的实体 String() 使用的语言，默认为 LanguageZh
+func (pc *PanClient) SetLanguage(lang Language) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.lang = lang
+}
+
+// Language 实体展示信息使用的语言
+func (pc *PanClient) Language() Language {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if pc.lang == "" {
+		return LanguageZh
+	}
+	return pc.lang
+}
@@ -0,0 +1,529 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// MetaStore 文件元数据缓存。设置到 PanClient 后，获取文件信息和文件列表时先查询缓存，
+	// 重命名、移动、删除、创建文件夹、上传等修改操作会同步让缓存失效，需要最新数据时使用 WithCacheBypass。
+	// 可以使用内置的 FileMetaStore，也可以基于 bbolt、SQLite 等实现该接口
+	MetaStore interface {
+		// Get 通过文件ID获取文件信息
+		Get(driveId, fileId string) (*FileEntity, bool)
+		// Children 获取文件夹下完整的文件列表
+		Children(driveId, parentFileId string) (FileList, bool)
+		// Put 保存文件信息
+		Put(fe *FileEntity)
+		// PutChildren 保存文件夹下完整的文件列表
+		PutChildren(driveId, parentFileId string, children FileList)
+		// Invalidate 删除文件的缓存，以及所在文件夹的文件列表缓存。如果是文件夹，同时删除文件夹下所有文件的缓存
+		Invalidate(driveId, fileId string)
+		// InvalidateChildren 删除文件夹的文件列表缓存
+		InvalidateChildren(driveId, parentFileId string)
+		// PathById 通过文件ID获取文件的完整路径
+		PathById(driveId, fileId string) (string, bool)
+		// IdByPath 通过文件的完整路径获取文件ID
+		IdByPath(driveId, pathStr string) (string, bool)
+	}
+
+	// FileMetaStore 保存到本地文件的元数据缓存，数据在内存中，调用 Flush 或者 Close 时写入文件，
+	// 下次启动时加载，GUI客户端冷启动时可以立即显示文件列表
+	FileMetaStore struct {
+		mu       sync.RWMutex
+		filePath string
+		dirty    bool
+		data     *metaStoreData
+	}
+
+	metaStoreData struct {
+		Version int `json:"version"`
+		// Entries key为 driveId/fileId
+		Entries map[string]*FileEntity `json:"entries"`
+		// Children key为 driveId/parentFileId，value为子文件ID
+		Children map[string][]string `json:"children"`
+		// Paths 文件ID到完整路径的索引，key为 driveId/fileId
+		Paths map[string]string `json:"paths"`
+		// Ids 完整路径到文件ID的索引，key为 driveId:path
+		Ids map[string]string `json:"ids"`
+	}
+)
+
+const (
+	// MetaStoreVersion 元数据缓存文件格式版本
+	MetaStoreVersion = 1
+)
+
+func metaKey(driveId, fileId string) string {
+	return driveId + "/" + fileId
+}
+
+func metaPathKey(driveId, pathStr string) string {
+	return driveId + ":" + pathStr
+}
+
+// NewMemoryMetaStore 创建只保存在内存中的元数据缓存
+func NewMemoryMetaStore() *FileMetaStore {
+	return &FileMetaStore{data: newMetaStoreData()}
+}
+
+// OpenFileMetaStore 打开保存在 filePath 的元数据缓存，文件不存在或者版本不兼容时创建空的缓存
+func OpenFileMetaStore(filePath string) (*FileMetaStore, error) {
+	s := &FileMetaStore{filePath: filePath, data: newMetaStoreData()}
+	f, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	data := newMetaStoreData()
+	if err = json.NewDecoder(gr).Decode(data); err != nil {
+		return nil, err
+	}
+	if data.Version == MetaStoreVersion {
+		if data.Paths == nil || data.Ids == nil {
+			data.Paths, data.Ids = map[string]string{}, map[string]string{}
+		}
+		s.data = data
+	}
+	return s, nil
+}
+
+func newMetaStoreData() *metaStoreData {
+	return &metaStoreData{
+		Version:  MetaStoreVersion,
+		Entries:  map[string]*FileEntity{},
+		Children: map[string][]string{},
+		Paths:    map[string]string{},
+		Ids:      map[string]string{},
+	}
+}
+
+// Get 通过文件ID获取文件信息，返回的是副本
+func (s *FileMetaStore) Get(driveId, fileId string) (*FileEntity, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	fe, ok := s.data.Entries[metaKey(driveId, fileId)]
+	if !ok {
+		return nil, false
+	}
+	return fe.Clone(), true
+}
+
+// Children 获取文件夹下完整的文件列表，返回的是副本
+func (s *FileMetaStore) Children(driveId, parentFileId string) (FileList, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids, ok := s.data.Children[metaKey(driveId, parentFileId)]
+	if !ok {
+		return nil, false
+	}
+	fl := FileList{}
+	for _, id := range ids {
+		fe, ok := s.data.Entries[metaKey(driveId, id)]
+		if !ok {
+			// 部分文件已经失效，列表不完整
+			return nil, false
+		}
+		fl = append(fl, fe.Clone())
+	}
+	return fl, true
+}
+
+// Put 保存文件信息
+func (s *FileMetaStore) Put(fe *FileEntity) {
+	if fe == nil || fe.FileId == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(fe)
+}
+
+func (s *FileMetaStore) put(fe *FileEntity) {
+	c := fe.Clone()
+	c.Raw = nil
+	key := metaKey(fe.DriveId, fe.FileId)
+	s.data.Entries[key] = c
+	s.dirty = true
+
+	// 所在文件夹的路径已知时更新路径索引
+	parentPath, ok := s.pathById(fe.DriveId, fe.ParentFileId)
+	if !ok {
+		return
+	}
+	p := path.Join(parentPath, fe.FileName)
+	if old, ok := s.data.Paths[key]; ok && old != p {
+		// 文件被重命名或者移动，原路径下的子文件路径都已失效
+		s.unindexTree(fe.DriveId, fe.FileId)
+	}
+	s.data.Paths[key] = p
+	s.data.Ids[metaPathKey(fe.DriveId, p)] = fe.FileId
+}
+
+func (s *FileMetaStore) pathById(driveId, fileId string) (string, bool) {
+	if fileId == DefaultRootParentFileId {
+		return "/", true
+	}
+	p, ok := s.data.Paths[metaKey(driveId, fileId)]
+	return p, ok
+}
+
+// unindexTree 删除文件及其子文件的路径索引
+func (s *FileMetaStore) unindexTree(driveId, fileId string) {
+	key := metaKey(driveId, fileId)
+	if p, ok := s.data.Paths[key]; ok {
+		delete(s.data.Paths, key)
+		if s.data.Ids[metaPathKey(driveId, p)] == fileId {
+			delete(s.data.Ids, metaPathKey(driveId, p))
+		}
+	}
+	for _, id := range s.data.Children[key] {
+		s.unindexTree(driveId, id)
+	}
+}
+
+// PathById 通过文件ID获取文件的完整路径
+func (s *FileMetaStore) PathById(driveId, fileId string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pathById(driveId, fileId)
+}
+
+// IdByPath 通过文件的完整路径获取文件ID
+func (s *FileMetaStore) IdByPath(driveId, pathStr string) (string, bool) {
+	if pathStr == "/" {
+		return DefaultRootParentFileId, true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.data.Ids[metaPathKey(driveId, pathStr)]
+	return id, ok
+}
+
+// PutChildren 保存文件夹下完整的文件列表
+func (s *FileMetaStore) PutChildren(driveId, parentFileId string, children FileList) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 已经不在文件夹中的文件都失效
+	present := map[string]bool{}
+	for _, fe := range children {
+		if fe != nil {
+			present[fe.FileId] = true
+		}
+	}
+	for _, id := range s.data.Children[metaKey(driveId, parentFileId)] {
+		if !present[id] {
+			s.invalidateTree(driveId, id)
+		}
+	}
+	ids := make([]string, 0, len(children))
+	for _, fe := range children {
+		if fe == nil || fe.FileId == "" {
+			continue
+		}
+		if fe.DriveId == "" {
+			fe = fe.Clone()
+			fe.DriveId = driveId
+		}
+		s.put(fe)
+		ids = append(ids, fe.FileId)
+	}
+	s.data.Children[metaKey(driveId, parentFileId)] = ids
+	s.dirty = true
+}
+
+// Invalidate 删除文件的缓存，以及所在文件夹的文件列表缓存。如果是文件夹，同时删除文件夹下所有文件的缓存
+func (s *FileMetaStore) Invalidate(driveId, fileId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := metaKey(driveId, fileId)
+	if fe, ok := s.data.Entries[key]; ok {
+		delete(s.data.Children, metaKey(driveId, fe.ParentFileId))
+	}
+	s.invalidateTree(driveId, fileId)
+	s.dirty = true
+}
+
+func (s *FileMetaStore) invalidateTree(driveId, fileId string) {
+	key := metaKey(driveId, fileId)
+	if p, ok := s.data.Paths[key]; ok {
+		delete(s.data.Paths, key)
+		if s.data.Ids[metaPathKey(driveId, p)] == fileId {
+			delete(s.data.Ids, metaPathKey(driveId, p))
+		}
+	}
+	delete(s.data.Entries, key)
+	ids, ok := s.data.Children[key]
+	if !ok {
+		return
+	}
+	delete(s.data.Children, key)
+	for _, id := range ids {
+		s.invalidateTree(driveId, id)
+	}
+}
+
+// InvalidateChildren 删除文件夹的文件列表缓存
+func (s *FileMetaStore) InvalidateChildren(driveId, parentFileId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data.Children, metaKey(driveId, parentFileId))
+	s.dirty = true
+}
+
+// Flush 把缓存写入文件，先写临时文件再重命名。内存缓存不做任何操作
+func (s *FileMetaStore) Flush() error {
+	if s.filePath == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.filePath), 0755); err != nil {
+		return err
+	}
+	tmpPath := s.filePath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	gw := gzip.NewWriter(f)
+	if err = json.NewEncoder(gw).Encode(s.data); err != nil {
+		gw.Close()
+		f.Close()
+		return err
+	}
+	if err = gw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, s.filePath); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close 写入文件
+func (s *FileMetaStore) Close() error {
+	return s.Flush()
+}
+
+// SetMetaStore 设置文件元数据缓存，为nil代表不使用缓存。派生的客户端共享缓存
+func (pc *PanClient) SetMetaStore(store MetaStore) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.metaStore = store
+}
+
+// MetaStore 返回设置的文件元数据缓存
+func (pc *PanClient) MetaStore() MetaStore {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.metaStore
+}
+
+// CachedFileList 从缓存中获取文件夹下的文件列表，没有缓存返回false。用于冷启动时先显示上一次的列表
+func (pc *PanClient) CachedFileList(driveId, parentFileId string) (FileList, bool) {
+	store := pc.MetaStore()
+	if store == nil {
+		return nil, false
+	}
+	if parentFileId == "" {
+		parentFileId = DefaultRootParentFileId
+	}
+	fl, ok := store.Children(driveId, parentFileId)
+	pc.stats.cache(ok)
+	return fl, ok
+}
+
+// readMetaStore 读取缓存时使用的元数据缓存，没有设置或者跳过缓存时返回nil
+func (pc *PanClient) readMetaStore() MetaStore {
+	if pc.bypassCache {
+		return nil
+	}
+	return pc.MetaStore()
+}
+
+// WithCacheBypass 派生一个读取时跳过元数据缓存的客户端，获取到的数据仍然会写入缓存。用于需要最新数据的调用
+func (pc *PanClient) WithCacheBypass() *PanClient {
+	c := pc.clone()
+	c.bypassCache = true
+	return c
+}
+
+// cachedChildren 从缓存中获取完整的文件列表，只有第一页开始、默认排序的请求才使用缓存
+func (pc *PanClient) cachedChildren(param *FileListParam) (FileList, bool) {
+	if param.Marker != "" || param.OrderBy != "" || param.OrderDirection != "" {
+		return nil, false
+	}
+	store := pc.readMetaStore()
+	if store == nil {
+		return nil, false
+	}
+	parentFileId := param.ParentFileId
+	if parentFileId == "" {
+		parentFileId = DefaultRootParentFileId
+	}
+	fl, ok := store.Children(param.DriveId, parentFileId)
+	pc.stats.cache(ok)
+	return fl, ok
+}
+
+func (pc *PanClient) cachedFileInfo(driveId, fileId string) (*FileEntity, bool) {
+	store := pc.readMetaStore()
+	if store == nil {
+		return nil, false
+	}
+	fe, ok := store.Get(driveId, fileId)
+	pc.stats.cache(ok)
+	return fe, ok
+}
+
+func (pc *PanClient) storeFileInfo(fe *FileEntity) {
+	if store := pc.MetaStore(); store != nil && fe != nil {
+		store.Put(fe)
+	}
+}
+
+func (pc *PanClient) storeChildren(driveId, parentFileId string, children FileList) {
+	if store := pc.MetaStore(); store != nil {
+		if parentFileId == "" {
+			parentFileId = DefaultRootParentFileId
+		}
+		store.PutChildren(driveId, parentFileId, children)
+	}
+}
+
+// fileChanged 在修改文件的接口成功后调用，使缓存失效并记录写入时间
+func (pc *PanClient) fileChanged(driveId, fileId string) {
+	pc.writes.record()
+	if store := pc.MetaStore(); store != nil {
+		store.Invalidate(driveId, fileId)
+	}
+}
+
+// childrenChanged 在文件夹下新增或移入文件后调用，使缓存失效并记录写入时间
+func (pc *PanClient) childrenChanged(driveId, parentFileId string) {
+	pc.writes.record()
+	if store := pc.MetaStore(); store != nil {
+		if parentFileId == "" {
+			parentFileId = DefaultRootParentFileId
+		}
+		store.InvalidateChildren(driveId, parentFileId)
+	}
+}
+
+// FilePathById 获取文件的完整路径。设置了元数据缓存时优先使用路径索引，否则逐级查询上级文件夹
+func (pc *PanClient) FilePathById(driveId, fileId string) (string, *apierror.ApiError) {
+	if fileId == "" || fileId == DefaultRootParentFileId {
+		return "/", nil
+	}
+	if store := pc.readMetaStore(); store != nil {
+		p, ok := store.PathById(driveId, fileId)
+		pc.stats.cache(ok)
+		if ok {
+			return p, nil
+		}
+	}
+	fe, err := pc.FileInfoById(driveId, fileId)
+	if err != nil {
+		return "", err
+	}
+	parent, err := pc.folderPathById(driveId, fe.ParentFileId, map[string]string{DefaultRootParentFileId: "/"})
+	if err != nil {
+		return "", err
+	}
+	return path.Join(parent, fe.FileName), nil
+}
+
+// cachedFileInfoByPath 通过路径索引获取文件信息
+func (pc *PanClient) cachedFileInfoByPath(driveId, pathStr string) (*FileEntity, bool) {
+	store := pc.readMetaStore()
+	if store == nil {
+		return nil, false
+	}
+	id, ok := store.IdByPath(driveId, pathStr)
+	if !ok {
+		pc.stats.cache(false)
+		return nil, false
+	}
+	fe, ok := store.Get(driveId, id)
+	pc.stats.cache(ok)
+	if ok {
+		fe.Path = pathStr
+	}
+	return fe, ok
+}
+
+// RefreshMetaStore 重新递归获取 rootPath 下的所有文件，修复元数据缓存和路径索引中过期的数据
+func (pc *PanClient) RefreshMetaStore(ctx context.Context, driveId, rootPath string) *apierror.ApiError {
+	if pc.MetaStore() == nil {
+		return nil
+	}
+	// 重新获取前先让根文件夹的缓存失效，避免读到缓存
+	if id, ok := pc.MetaStore().IdByPath(driveId, rootPath); ok && id != DefaultRootParentFileId {
+		pc.MetaStore().Invalidate(driveId, id)
+	}
+	_, err := pc.WithCacheBypass().WithRequestClass(RequestClassBulk).FilesDirectoriesRecurseListWithContext(ctx, driveId, rootPath, nil)
+	return err
+}
+
+// StartMetaStoreRefresh 在后台每隔 interval 调用一次 RefreshMetaStore，ctx 取消或者客户端关闭后停止
+func (pc *PanClient) StartMetaStoreRefresh(ctx context.Context, driveId, rootPath string, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pc.Done():
+				return
+			case <-ticker.C:
+				if err := pc.RefreshMetaStore(ctx, driveId, rootPath); err != nil {
+					logger.Verboseln("refresh meta store error ", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestFileMetaStore(t *testing.T) {
+	dir := t.TempDir()
+	storePath := filepath.Join(dir, "meta.gz")
+	s, err := OpenFileMetaStore(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.PutChildren("1", "root", FileList{
+		{DriveId: "1", FileId: "d", FileName: "docs", FileType: "folder", ParentFileId: "root"},
+		{DriveId: "1", FileId: "a", FileName: "a.txt", FileType: "file", ParentFileId: "root", FileSize: 3},
+	})
+	s.PutChildren("1", "d", FileList{
+		{DriveId: "1", FileId: "b", FileName: "b.txt", FileType: "file", ParentFileId: "d"},
+	})
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = OpenFileMetaStore(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl, ok := s.Children("1", "root")
+	if !ok || len(fl) != 2 || fl[1].FileSize != 3 {
+		t.Fatalf("unexpected cached children %v", fl)
+	}
+
+	// 删除文件夹后，文件夹下的文件和所在文件夹的列表都失效
+	s.Invalidate("1", "d")
+	if _, ok = s.Children("1", "root"); ok {
+		t.Fatal("parent listing should be invalidated")
+	}
+	if _, ok = s.Get("1", "b"); ok {
+		t.Fatal("child of removed folder should be invalidated")
+	}
+	if fe, ok := s.Get("1", "a"); !ok || fe.FileName != "a.txt" {
+		t.Fatal("sibling should stay cached")
+	}
+
+	p := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	p.SetMetaStore(s)
+	if fe, err := p.FileInfoById("1", "a"); err != nil || fe.FileName != "a.txt" {
+		t.Fatalf("expected cached file info, got %v %v", fe, err)
+	}
+	if st := p.Stats(); st.CacheHits != 1 {
+		t.Fatalf("expected one cache hit, got %+v", st)
+	}
+}
+
+func TestFileMetaStorePathIndex(t *testing.T) {
+	s := NewMemoryMetaStore()
+	s.PutChildren("1", "root", FileList{
+		{DriveId: "1", FileId: "d", FileName: "docs", FileType: "folder", ParentFileId: "root"},
+	})
+	s.PutChildren("1", "d", FileList{
+		{DriveId: "1", FileId: "b", FileName: "b.txt", FileType: "file", ParentFileId: "d"},
+	})
+	if p, ok := s.PathById("1", "b"); !ok || p != "/docs/b.txt" {
+		t.Fatalf("unexpected path %s", p)
+	}
+	if id, ok := s.IdByPath("1", "/docs/b.txt"); !ok || id != "b" {
+		t.Fatalf("unexpected id %s", id)
+	}
+
+	// 重命名文件夹后，原路径和子文件的路径都失效
+	s.Put(&FileEntity{DriveId: "1", FileId: "d", FileName: "papers", FileType: "folder", ParentFileId: "root"})
+	if _, ok := s.IdByPath("1", "/docs/b.txt"); ok {
+		t.Fatal("old child path should be removed")
+	}
+	if id, ok := s.IdByPath("1", "/papers"); !ok || id != "d" {
+		t.Fatal("new folder path should be indexed")
+	}
+
+	// 重新获取列表时，已经不存在的文件被移除
+	s.PutChildren("1", "root", FileList{})
+	if _, ok := s.Get("1", "d"); ok {
+		t.Fatal("removed folder should be invalidated")
+	}
+
+	p := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	p.SetMetaStore(s)
+	s.PutChildren("1", "root", FileList{{DriveId: "1", FileId: "a", FileName: "a.txt", FileType: "file", ParentFileId: "root"}})
+	if fe, err := p.FileInfoByPath("1", "/a.txt"); err != nil || fe.FileId != "a" || fe.Path != "/a.txt" {
+		t.Fatalf("expected indexed file info, got %v %v", fe, err)
+	}
+	if fp, err := p.FilePathById("1", "a"); err != nil || fp != "/a.txt" {
+		t.Fatalf("expected indexed path, got %s %v", fp, err)
+	}
+}
+
+func TestTTLMetaStore(t *testing.T) {
+	now := time.Date(2021, 7, 29, 12, 0, 0, 0, time.UTC)
+	s := NewTTLMetaStore(time.Minute, 2)
+	s.now = func() time.Time { return now }
+
+	s.PutChildren("1", "root", FileList{
+		{DriveId: "1", FileId: "a", FileName: "a.txt", FileType: "file", ParentFileId: "root"},
+		{DriveId: "1", FileId: "b", FileName: "b.txt", FileType: "file", ParentFileId: "root"},
+	})
+	p := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	p.SetMetaStore(s)
+	if fl, err := p.FileListGetAll(&FileListParam{DriveId: "1"}); err != nil || len(fl) != 2 {
+		t.Fatalf("expected cached listing, got %v %v", fl, err)
+	}
+	if _, ok := p.WithCacheBypass().cachedFileInfo("1", "a"); ok {
+		t.Fatal("bypass client should not read the cache")
+	}
+
+	// 超过数量上限淘汰最久没有使用的 b
+	s.Get("1", "a")
+	s.Put(&FileEntity{DriveId: "1", FileId: "c", FileName: "c.txt", ParentFileId: "root"})
+	if _, ok := s.Get("1", "b"); ok || s.Len() != 2 {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if _, ok := s.Children("1", "root"); ok {
+		t.Fatal("listing with evicted entry should be incomplete")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := s.Get("1", "a"); ok {
+		t.Fatal("entry should expire")
+	}
+	if id, ok := s.IdByPath("1", "/c.txt"); ok {
+		t.Fatalf("expired path index should not be used, got %s", id)
+	}
+}
+
+func TestMetaStoreTrashRestore(t *testing.T) {
+	var trashed, lists int32
+	SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			data, _ := ioutil.ReadAll(r.Body)
+			body := "{}"
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/file/list"):
+				atomic.AddInt32(&lists, 1)
+				body = `{"items":[{"drive_id":"d1","file_id":"f1","name":"a.txt","type":"file","parent_file_id":"root"}`
+				if atomic.LoadInt32(&trashed) == 0 {
+					body += `,{"drive_id":"d1","file_id":"f2","name":"b.txt","type":"file","parent_file_id":"root"}`
+				}
+				body += `]}`
+			case strings.HasSuffix(r.URL.Path, "/file/get"):
+				body = `{"drive_id":"d1","file_id":"f2","name":"b.txt","type":"file","parent_file_id":"root"}`
+			case strings.HasSuffix(r.URL.Path, "/batch"):
+				if strings.Contains(string(data), "/recyclebin/trash") {
+					atomic.StoreInt32(&trashed, 1)
+				} else {
+					atomic.StoreInt32(&trashed, 0)
+				}
+				body = `{"responses":[{"id":"f2","status":204}]}`
+			}
+			return &http.Response{StatusCode: 200, Status: "200 OK", Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+		})
+	})
+	defer SetTransportWrapper(nil)
+
+	pc := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1"}, AppLoginToken{})
+	pc.SetMetaStore(NewMemoryMetaStore())
+	list := func() FileList {
+		fl, err := pc.FileListGetAll(&FileListParam{DriveId: "d1", ParentFileId: DefaultRootParentFileId})
+		if err != nil {
+			t.Fatalf("list error: %s", err)
+		}
+		return fl
+	}
+	if fl := list(); len(fl) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(fl))
+	}
+
+	param := []*FileBatchActionParam{{DriveId: "d1", FileId: "f2"}}
+	if _, err := pc.FileDelete(param); err != nil {
+		t.Fatalf("trash error: %s", err)
+	}
+	if fl := list(); len(fl) != 1 {
+		t.Fatalf("expected 1 file after trash, got %d", len(fl))
+	}
+	if _, err := pc.RecycleBinFileRestore(param); err != nil {
+		t.Fatalf("restore error: %s", err)
+	}
+	// 还原后原文件夹的文件列表缓存失效，重新获取
+	if fl := list(); len(fl) != 2 {
+		t.Fatalf("expected 2 files after restore, got %d", len(fl))
+	}
+	if n := atomic.LoadInt32(&lists); n != 3 {
+		t.Fatalf("expected 3 list requests, got %d", n)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type (
+	// TTLMetaStore 带过期时间和数量上限的内存元数据缓存。缓存的文件信息和文件列表超过 ttl 后失效，
+	// 文件数量超过上限时淘汰最久没有使用的文件。适合需要缓存但不能长时间读到旧数据的服务端使用
+	TTLMetaStore struct {
+		mu         sync.Mutex
+		inner      *FileMetaStore
+		ttl        time.Duration
+		maxEntries int
+		// lru 按最近使用排序的文件，最前面的是最近使用的
+		lru     *list.List
+		entries map[string]*list.Element
+		// children 文件列表的过期时间，key为 driveId/parentFileId
+		children map[string]time.Time
+		now      func() time.Time
+	}
+
+	ttlMetaEntry struct {
+		driveId   string
+		fileId    string
+		expiresAt time.Time
+	}
+)
+
+// NewTTLMetaStore 创建带过期时间的内存元数据缓存，maxEntries 小于等于0代表不限制文件数量
+func NewTTLMetaStore(ttl time.Duration, maxEntries int) *TTLMetaStore {
+	return &TTLMetaStore{
+		inner:      NewMemoryMetaStore(),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+		children:   map[string]time.Time{},
+		now:        time.Now,
+	}
+}
+
+// Len 缓存的文件数量
+func (s *TTLMetaStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// alive 文件是否在缓存中并且没有过期，过期的文件会被删除
+func (s *TTLMetaStore) alive(driveId, fileId string) bool {
+	elem, ok := s.entries[metaKey(driveId, fileId)]
+	if !ok {
+		return false
+	}
+	if s.now().After(elem.Value.(*ttlMetaEntry).expiresAt) {
+		s.remove(elem)
+		s.inner.Invalidate(driveId, fileId)
+		return false
+	}
+	s.lru.MoveToFront(elem)
+	return true
+}
+
+func (s *TTLMetaStore) remove(elem *list.Element) {
+	e := elem.Value.(*ttlMetaEntry)
+	s.lru.Remove(elem)
+	delete(s.entries, metaKey(e.driveId, e.fileId))
+}
+
+// touch 记录文件的过期时间，超过数量上限时淘汰最久没有使用的文件
+func (s *TTLMetaStore) touch(driveId, fileId string, expiresAt time.Time) {
+	key := metaKey(driveId, fileId)
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*ttlMetaEntry).expiresAt = expiresAt
+		s.lru.MoveToFront(elem)
+	} else {
+		s.entries[key] = s.lru.PushFront(&ttlMetaEntry{driveId: driveId, fileId: fileId, expiresAt: expiresAt})
+	}
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		e := oldest.Value.(*ttlMetaEntry)
+		s.remove(oldest)
+		s.inner.Invalidate(e.driveId, e.fileId)
+	}
+}
+
+// Get 通过文件ID获取文件信息，过期返回false
+func (s *TTLMetaStore) Get(driveId, fileId string) (*FileEntity, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.alive(driveId, fileId) {
+		return nil, false
+	}
+	return s.inner.Get(driveId, fileId)
+}
+
+// Children 获取文件夹下完整的文件列表，过期返回false
+func (s *TTLMetaStore) Children(driveId, parentFileId string) (FileList, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := metaKey(driveId, parentFileId)
+	expiresAt, ok := s.children[key]
+	if !ok {
+		return nil, false
+	}
+	if s.now().After(expiresAt) {
+		delete(s.children, key)
+		s.inner.InvalidateChildren(driveId, parentFileId)
+		return nil, false
+	}
+	return s.inner.Children(driveId, parentFileId)
+}
+
+// Put 保存文件信息
+func (s *TTLMetaStore) Put(fe *FileEntity) {
+	if fe == nil || fe.FileId == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inner.Put(fe)
+	s.touch(fe.DriveId, fe.FileId, s.now().Add(s.ttl))
+}
+
+// PutChildren 保存文件夹下完整的文件列表
+func (s *TTLMetaStore) PutChildren(driveId, parentFileId string, children FileList) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxEntries > 0 && len(children) > s.maxEntries {
+		// 超过上限的列表无法完整缓存
+		return
+	}
+	s.inner.PutChildren(driveId, parentFileId, children)
+	expiresAt := s.now().Add(s.ttl)
+	for _, fe := range children {
+		if fe != nil {
+			s.touch(driveId, fe.FileId, expiresAt)
+		}
+	}
+	s.children[metaKey(driveId, parentFileId)] = expiresAt
+}
+
+// Invalidate 删除文件的缓存，以及所在文件夹的文件列表缓存
+func (s *TTLMetaStore) Invalidate(driveId, fileId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[metaKey(driveId, fileId)]; ok {
+		s.remove(elem)
+	}
+	s.inner.Invalidate(driveId, fileId)
+}
+
+// InvalidateChildren 删除文件夹的文件列表缓存
+func (s *TTLMetaStore) InvalidateChildren(driveId, parentFileId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.children, metaKey(driveId, parentFileId))
+	s.inner.InvalidateChildren(driveId, parentFileId)
+}
+
+// PathById 通过文件ID获取文件的完整路径，文件过期返回false
+func (s *TTLMetaStore) PathById(driveId, fileId string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fileId != DefaultRootParentFileId && !s.alive(driveId, fileId) {
+		return "", false
+	}
+	return s.inner.PathById(driveId, fileId)
+}
+
+// IdByPath 通过文件的完整路径获取文件ID，文件过期返回false
+func (s *TTLMetaStore) IdByPath(driveId, pathStr string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.inner.IdByPath(driveId, pathStr)
+	if !ok || (id != DefaultRootParentFileId && !s.alive(driveId, id)) {
+		return "", false
+	}
+	return id, true
+}
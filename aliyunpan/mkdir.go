@@ -37,12 +37,15 @@ type (
 
 // Mkdir 创建文件夹
 func (p *PanClient) Mkdir(driveId, parentFileId, dirName string) (*MkdirResult, *apierror.ApiError) {
+	if o := p.openRoute("/adrive/v1.0/openFile/create"); o != nil {
+		return p.openMkdir(o, driveId, parentFileId, dirName)
+	}
 	if parentFileId == "" {
 		// 默认根目录
 		parentFileId = DefaultRootParentFileId
 	}
 	header := map[string]string {
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -52,13 +55,13 @@ func (p *PanClient) Mkdir(driveId, parentFileId, dirName string) (*MkdirResult,
 	postData := map[string]interface{} {
 		"drive_id": driveId,
 		"parent_file_id": parentFileId,
-		"name": dirName,
+		"name": p.encodeFileName(dirName),
 		"check_name_mode": "refuse",
 		"type": "folder",
 	}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get file info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -75,6 +78,8 @@ func (p *PanClient) Mkdir(driveId, parentFileId, dirName string) (*MkdirResult,
 		logger.Verboseln("parse file info result json error ", err2)
 		return nil, apierror.NewFailedApiError(err2.Error())
 	}
+	r.FileName = p.decodeFileName(r.FileName)
+	p.childrenChanged(driveId, parentFileId)
 	return r, nil
 }
 
@@ -122,7 +127,7 @@ func (p *PanClient) MkdirRecursive(driveId, parentFileId string, fullPath string
 
 	// not existed, mkdir dir
 	name := pathSlice[index]
-	if !apiutil.CheckFileNameValid(name) {
+	if !p.isNameEncoding() && !apiutil.CheckFileNameValid(name) {
 		r.FileId = ""
 		return r, apierror.NewFailedApiError("文件夹名不能包含特殊字符：" + apiutil.FileNameSpecialChars)
 	}
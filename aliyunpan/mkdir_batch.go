@@ -0,0 +1,113 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+)
+
+const (
+	// mkdirBatchSize 每次批量创建的文件夹数量
+	mkdirBatchSize = 100
+)
+
+type (
+	// MkdirParam 批量创建文件夹参数
+	MkdirParam struct {
+		// ParentFileId 上级文件夹ID，为空代表根目录
+		ParentFileId string
+		// Name 文件夹名称
+		Name string
+	}
+)
+
+// MkdirBatch 使用批量接口创建多个文件夹，每次请求最多创建 100 个。同名文件夹已经存在时返回已有的文件夹。
+// 返回值和 params 一一对应，创建失败的文件夹对应的结果为nil
+func (p *PanClient) MkdirBatch(driveId string, params []*MkdirParam) ([]*MkdirResult, *apierror.ApiError) {
+	result := make([]*MkdirResult, len(params))
+	requests := BatchRequestList{}
+	for i, param := range params {
+		if !p.isNameEncoding() && !apiutil.CheckFileNameValid(param.Name) {
+			return nil, apierror.NewFailedApiError("文件夹名不能包含特殊字符：" + apiutil.FileNameSpecialChars)
+		}
+		parentFileId := param.ParentFileId
+		if parentFileId == "" {
+			parentFileId = DefaultRootParentFileId
+		}
+		requests = append(requests, &BatchRequest{
+			Id:     fmt.Sprint(i),
+			Method: "POST",
+			Url:    "/file/create",
+			Headers: map[string]string{
+				"Content-Type": "application/json",
+			},
+			Body: map[string]interface{}{
+				"drive_id":        driveId,
+				"parent_file_id":  parentFileId,
+				"name":            p.encodeFileName(param.Name),
+				"check_name_mode": "refuse",
+				"type":            "folder",
+			},
+		})
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/batch", API_URL)
+	for start := 0; start < len(requests); start += mkdirBatchSize {
+		end := start + mkdirBatchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+		r, err := p.BatchTask(fullUrl.String(), &BatchRequestParam{
+			Requests: requests[start:end],
+			Resource: "file",
+		})
+		// 部分文件夹可能已经创建成功
+		for _, param := range params[start:end] {
+			p.childrenChanged(driveId, param.ParentFileId)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range r.Responses {
+			i := 0
+			if _, e := fmt.Sscan(item.Id, &i); e != nil || i < start || i >= end {
+				continue
+			}
+			if item.Status < 200 || item.Status >= 300 || item.Body == nil {
+				logger.Verboseln("batch mkdir error ", params[i].Name, item.Status)
+				continue
+			}
+			data, e := json.Marshal(item.Body)
+			if e != nil {
+				continue
+			}
+			mr := &MkdirResult{}
+			if e = json.Unmarshal(data, mr); e != nil || mr.FileId == "" {
+				logger.Verboseln("parse batch mkdir result error ", e)
+				continue
+			}
+			mr.FileName = p.decodeFileName(mr.FileName)
+			result[i] = mr
+		}
+	}
+	return result, nil
+}
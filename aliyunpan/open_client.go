@@ -0,0 +1,283 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"strings"
+	"sync"
+)
+
+type (
+	// OpenToken 开放平台授权token
+	OpenToken struct {
+		TokenType    string `json:"tokenType"`
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+		ExpiresIn    int    `json:"expiresIn"`
+		// ExpireTime 过期时间，本地时间格式：2006-01-02 15:04:05
+		ExpireTime string `json:"expireTime"`
+		// Scopes 授权范围，可以使用 ParseOpenScopes 解析授权接口返回的scope。为空代表不检查
+		Scopes []string `json:"scopes"`
+	}
+
+	// OpenPanClient 开放平台(adrive/v1.0)网盘客户端，和 PanClient 使用相同的文件模型。
+	// 开放平台接口是第三方应用官方支持的接口，比网页版接口稳定。可以在多个 goroutine 中并发使用
+	OpenPanClient struct {
+		mu    sync.RWMutex
+		token OpenToken
+
+		// apiUrl 接口地址，默认为 OPENAPI_URL
+		apiUrl string
+		// quota 接口调用次数统计，为nil代表不统计
+		quota *OpenQuotaTracker
+
+		// refresh token 自动刷新，为nil代表不自动刷新
+		refresh     OpenTokenRefreshFunc
+		onRefreshed func(token OpenToken)
+		// refreshMu 保证同时只有一个请求刷新 token
+		refreshMu sync.Mutex
+
+		// base 绑定了请求函数的客户端对应的原始客户端，token 等状态都保存在原始客户端中
+		base *OpenPanClient
+		// fetch 发起http请求，为nil代表直接请求
+		fetch fetchFunc
+	}
+
+	// OpenTokenRefreshFunc 使用 refresh token 获取新的开放平台token，通常使用 OpenAuth.RefreshToken
+	OpenTokenRefreshFunc func(refreshToken string) (*OpenToken, *apierror.ApiError)
+
+	// fetchFunc 发起http请求
+	fetchFunc func(method string, urlStr string, post interface{}, header map[string]string) ([]byte, error)
+
+	// OpenDriveInfo 开放平台用户网盘信息
+	OpenDriveInfo struct {
+		UserId          string `json:"user_id"`
+		Name            string `json:"name"`
+		Avatar          string `json:"avatar"`
+		DefaultDriveId  string `json:"default_drive_id"`
+		ResourceDriveId string `json:"resource_drive_id"`
+		BackupDriveId   string `json:"backup_drive_id"`
+	}
+
+	// OpenSpaceInfo 开放平台网盘空间信息
+	OpenSpaceInfo struct {
+		// TotalSize 网盘空间总大小
+		TotalSize uint64 `json:"total_size"`
+		// UsedSize 网盘已使用空间大小
+		UsedSize uint64 `json:"used_size"`
+	}
+
+	openSpaceInfoResult struct {
+		PersonalSpaceInfo OpenSpaceInfo `json:"personal_space_info"`
+	}
+)
+
+// GetAuthorizationStr 请求头使用的授权信息
+func (t *OpenToken) GetAuthorizationStr() string {
+	tokenType := t.TokenType
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// NewOpenPanClient 创建开放平台网盘客户端
+func NewOpenPanClient(token OpenToken) *OpenPanClient {
+	return &OpenPanClient{
+		token:  token,
+		apiUrl: OPENAPI_URL,
+	}
+}
+
+// withFetch 派生一个使用 fetch 发起请求的客户端，和原客户端共享token、调用次数统计等状态
+func (p *OpenPanClient) withFetch(fetch fetchFunc) *OpenPanClient {
+	root := p.root()
+	return &OpenPanClient{
+		apiUrl: root.apiUrl,
+		base:   root,
+		fetch:  fetch,
+	}
+}
+
+// root 保存状态的原始客户端
+func (p *OpenPanClient) root() *OpenPanClient {
+	if p.base != nil {
+		return p.base
+	}
+	return p
+}
+
+// UpdateToken 更新token
+func (p *OpenPanClient) UpdateToken(token OpenToken) {
+	r := p.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.token = token
+}
+
+// Token 获取当前的token
+func (p *OpenPanClient) Token() OpenToken {
+	r := p.root()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.token
+}
+
+// GetAccessToken 获取当前的 access token
+func (p *OpenPanClient) GetAccessToken() string {
+	return p.Token().AccessToken
+}
+
+func (p *OpenPanClient) authorizationStr() string {
+	token := p.Token()
+	return token.GetAuthorizationStr()
+}
+
+// SetTokenRefreshFunc 开启 token 自动刷新。token 即将过期或者请求返回 token 无效时，使用 refresh token 获取新的 token 并重试原请求。
+// onRefreshed 可以为nil，不为nil时每次刷新成功后调用，用于保存新的 token。refresh 为nil代表关闭
+func (p *OpenPanClient) SetTokenRefreshFunc(refresh OpenTokenRefreshFunc, onRefreshed func(token OpenToken)) {
+	r := p.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refresh = refresh
+	r.onRefreshed = onRefreshed
+}
+
+// refreshToken 刷新 token，usedAuthorization 为失效的授权信息。其他请求已经刷新过时直接返回新的授权信息，
+// 没有开启自动刷新或者刷新失败返回false
+func (p *OpenPanClient) refreshToken(usedAuthorization string) (string, bool) {
+	r := p.root()
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	r.mu.RLock()
+	token := r.token
+	refresh := r.refresh
+	onRefreshed := r.onRefreshed
+	r.mu.RUnlock()
+	if refresh == nil {
+		return "", false
+	}
+	if token.GetAuthorizationStr() != usedAuthorization {
+		return token.GetAuthorizationStr(), true
+	}
+	newToken, err := refresh(token.RefreshToken)
+	if err != nil || newToken == nil {
+		logger.Verboseln("refresh open token error ", err)
+		return "", false
+	}
+	r.UpdateToken(*newToken)
+	if onRefreshed != nil {
+		onRefreshed(*newToken)
+	}
+	return newToken.GetAuthorizationStr(), true
+}
+
+// currentAuthorization 请求使用的授权信息，开启了自动刷新并且 token 即将过期时先刷新
+func (p *OpenPanClient) currentAuthorization() string {
+	token := p.Token()
+	authorization := token.GetAuthorizationStr()
+	if token.ExpireTime == "" || !token.IsAccessTokenExpired() {
+		return authorization
+	}
+	if newAuthorization, ok := p.refreshToken(authorization); ok {
+		return newAuthorization
+	}
+	return authorization
+}
+
+// doRequest 发起开放平台POST请求，解析返回的JSON到 result，result 为nil则不解析
+func (p *OpenPanClient) doRequest(path string, postData interface{}, result interface{}) *apierror.ApiError {
+	if err := p.checkScope(path); err != nil {
+		return err
+	}
+	if quota := p.QuotaTracker(); quota != nil {
+		if err := quota.Acquire(path); err != nil {
+			return err
+		}
+	}
+
+	header := map[string]string{
+		"authorization": p.currentAuthorization(),
+		"content-type":  "application/json;charset=UTF-8",
+		"accept":        "application/json",
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s%s", p.apiUrl, path)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	// request
+	fetch := p.fetch
+	if fetch == nil {
+		fetch = client.Fetch
+	}
+	body, err := fetch("POST", fullUrl.String(), postData, header)
+	if err == nil && isTokenInvalidBody(body) {
+		// token 失效，刷新后重试一次
+		if authorization, ok := p.refreshToken(header["authorization"]); ok {
+			header["authorization"] = authorization
+			body, err = fetch("POST", fullUrl.String(), postData, header)
+		}
+	}
+	if err != nil {
+		logger.Verboseln("open api request error ", err)
+		return apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return err1
+	}
+
+	// parse result
+	if result == nil || len(body) == 0 {
+		return nil
+	}
+	if err2 := json.Unmarshal(body, result); err2 != nil {
+		logger.Verboseln("parse open api result json error ", err2)
+		return apierror.NewFailedApiError(err2.Error())
+	}
+	return nil
+}
+
+// GetDriveInfo 获取用户信息和网盘ID
+func (p *OpenPanClient) GetDriveInfo() (*OpenDriveInfo, *apierror.ApiError) {
+	r := &OpenDriveInfo{}
+	if err := p.doRequest("/adrive/v1.0/user/getDriveInfo", map[string]interface{}{}, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetSpaceInfo 获取网盘空间使用情况
+func (p *OpenPanClient) GetSpaceInfo() (*OpenSpaceInfo, *apierror.ApiError) {
+	r := &openSpaceInfoResult{}
+	if err := p.doRequest("/adrive/v1.0/user/getSpaceInfo", map[string]interface{}{}, r); err != nil {
+		return nil, err
+	}
+	return &r.PersonalSpaceInfo, nil
+}
+
+// FreeSize 网盘剩余空间大小
+func (s *OpenSpaceInfo) FreeSize() uint64 {
+	if s == nil || s.UsedSize >= s.TotalSize {
+		return 0
+	}
+	return s.TotalSize - s.UsedSize
+}
@@ -0,0 +1,447 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenPanClientFileList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"code":"AccessTokenExpired","message":"expired"}`))
+			return
+		}
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/adrive/v1.0/openFile/list":
+			if req["marker"] == nil {
+				w.Write([]byte(`{"items":[{"drive_id":"1","file_id":"a","name":"a.txt","type":"file","size":3}],"next_marker":"m1"}`))
+			} else {
+				w.Write([]byte(`{"items":[{"drive_id":"1","file_id":"b","name":"b","type":"folder"}],"next_marker":""}`))
+			}
+		case "/adrive/v1.0/openFile/getVideoPreviewPlayInfo":
+			w.Write([]byte(`{"drive_id":"1","file_id":"v","video_preview_play_info":{"category":"live_transcoding","meta":{"duration":61.5},"live_transcoding_task_list":[{"template_id":"SD","template_height":540,"status":"finished","url":"sd"},{"template_id":"FHD","template_height":1080,"status":"finished","url":"fhd"},{"template_id":"QHD","template_height":1440,"status":"running"}],"live_transcoding_subtitle_task_list":[{"language":"chi","status":"finished","url":"chi.vtt"},{"language":"eng","status":"running"}]}}`))
+		case "/adrive/v1.0/openFile/video/updateRecord":
+			w.Write([]byte(`{"drive_id":"1","file_id":"v","play_cursor":"` + req["play_cursor"].(string) + `"}`))
+		case "/adrive/v1.0/user/getDriveInfo":
+			w.Write([]byte(`{"user_id":"u1","name":"tick","default_drive_id":"1","resource_drive_id":"2"}`))
+		case "/adrive/v1.0/user/getSpaceInfo":
+			w.Write([]byte(`{"personal_space_info":{"used_size":40,"total_size":100}}`))
+		case "/adrive/v1.0/openFile/starredList", "/adrive/v1.0/openFile/recentList":
+			w.Write([]byte(`{"items":[{"drive_id":"1","file_id":"s","name":"s.txt","type":"file","starred":true}],"next_marker":""}`))
+		case "/adrive/v1.0/openFile/search":
+			w.Write([]byte(`{"items":[{"drive_id":"1","file_id":"r","name":"报告.doc","type":"file"}],"next_marker":""}`))
+		case "/adrive/v1.0/openFile/copy":
+			w.Write([]byte(`{"drive_id":"1","file_id":"a2"}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	p := NewOpenPanClient(OpenToken{AccessToken: "token"})
+	p.apiUrl = server.URL
+	fl, err := p.FileListGetAll(&FileListParam{DriveId: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fl) != 2 || fl[0].FileName != "a.txt" || fl[0].FileSize != 3 || !fl[1].IsFolder() {
+		t.Fatalf("unexpected file list %v", fl)
+	}
+
+	var api DriveAPI = NewOpenDriveAPI(p)
+	ui, err := api.DriveUserInfo()
+	if err != nil || ui.UserId != "u1" || ui.FileDriveId != "1" || ui.ResourceDriveId != "2" {
+		t.Fatalf("unexpected drive user info %+v %v", ui, err)
+	}
+	si, err := p.GetSpaceInfo()
+	if err != nil || si.TotalSize != 100 || si.FreeSize() != 60 {
+		t.Fatalf("unexpected space info %+v %v", si, err)
+	}
+
+	fl, err = p.FileSearchGetAll(&FileSearchParam{DriveId: "1", Query: NameMatchQuery("报告")})
+	if err != nil || len(fl) != 1 || fl[0].FileId != "r" {
+		t.Fatalf("unexpected search result %v %v", fl, err)
+	}
+	fl, err = p.StarredFileListGetAll(&StarredFileListParam{DriveId: "1"})
+	if err != nil || len(fl) != 1 || fl[0].FileId != "s" {
+		t.Fatalf("unexpected starred list %v %v", fl, err)
+	}
+	fl, err = p.RecentFileList(0)
+	if err != nil || len(fl) != 1 {
+		t.Fatalf("unexpected recent list %v %v", fl, err)
+	}
+	cr, err := p.FileCopy([]*FileCopyParam{{DriveId: "1", FileId: "a", ToParentFileId: "root"}})
+	if err != nil || !cr[0].Success || cr[0].NewFileId != "a2" {
+		t.Fatalf("unexpected copy result %v %v", cr, err)
+	}
+
+	info, err := p.GetVideoPreviewPlayInfo(&VideoPreviewPlayInfoParam{DriveId: "1", FileId: "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.FileId != "v" || info.Meta.Duration != 61.5 || info.FinishedTask("").Url != "fhd" || info.FinishedTask("SD").Url != "sd" || info.FinishedTask("QHD") != nil {
+		t.Fatalf("unexpected play info %+v", info)
+	}
+	if defs := info.Definitions(); len(defs) != 2 || defs[0] != VideoDefinitionSD || defs[1] != VideoDefinitionFHD {
+		t.Fatalf("unexpected definitions %v", defs)
+	}
+	if subs := info.Subtitles(); len(subs) != 1 || subs[0].Language != "chi" || subs[0].Url != "chi.vtt" {
+		t.Fatalf("unexpected subtitles %v", subs)
+	}
+	if info.TranscodeStatus(VideoDefinitionQHD) != VideoTranscodeStatusRunning {
+		t.Fatal("expected QHD to be running")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	_, err = p.WaitVideoTranscode(ctx, &VideoPreviewPlayInfoParam{DriveId: "1", FileId: "v", TemplateId: "QHD"}, time.Millisecond)
+	cancel()
+	if err == nil {
+		t.Fatal("expected QHD transcode wait to time out")
+	}
+	record, err := p.UpdateVideoRecord("1", "v", 30.5, 61.5)
+	if err != nil || record.PlayCursor != 30.5 {
+		t.Fatalf("unexpected play record %+v %v", record, err)
+	}
+
+	p.UpdateToken(OpenToken{AccessToken: "expired"})
+	if _, err = p.FileList(&FileListParam{DriveId: "1"}); err == nil || err.Code != apierror.ApiCodeTokenExpiredCode {
+		t.Fatalf("expected token expired error, got %v", err)
+	}
+}
+
+func TestOpenPanClientScope(t *testing.T) {
+	if s := ParseOpenScopes("user:base, file:all:write"); len(s) != 2 || s[1] != OpenScopeFileWrite {
+		t.Fatalf("unexpected scopes %v", s)
+	}
+	p := NewOpenPanClient(OpenToken{AccessToken: "token", Scopes: []string{OpenScopeUserBase, OpenScopeFileWrite}})
+	if !p.HasScope(OpenScopeFileRead) || p.HasScope(OpenScopeAlbumRead) {
+		t.Fatal("unexpected scope check")
+	}
+	p.UpdateToken(OpenToken{AccessToken: "token", Scopes: []string{OpenScopeFileRead}})
+	// 请求前就会被拒绝，不会发起网络请求
+	if _, err := p.Mkdir("1", "root", "a"); err == nil || err.Code != apierror.ApiCodeScopeNotGranted {
+		t.Fatalf("expected scope error, got %v", err)
+	}
+}
+
+func TestOpenAuthQrCodeLogin(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth/authorize/qrcode":
+			w.Write([]byte(`{"qrCodeUrl":"https://qr","sid":"s1"}`))
+		case "/oauth/qrcode/s1/status":
+			polls++
+			if polls < 2 {
+				w.Write([]byte(`{"status":"ScanSuccess"}`))
+			} else {
+				w.Write([]byte(`{"status":"LoginSuccess","authCode":"code"}`))
+			}
+		case "/oauth/access_token":
+			req := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req["code"] != "code" {
+				w.WriteHeader(400)
+				return
+			}
+			w.Write([]byte(`{"token_type":"Bearer","access_token":"at","refresh_token":"rt","expires_in":7200,"scope":"user:base,file:all:read"}`))
+		}
+	}))
+	defer server.Close()
+
+	a := NewOpenAuth("id", "secret", []string{OpenScopeUserBase, OpenScopeFileRead, OpenScopeFileWrite})
+	a.apiUrl = server.URL
+	qr, err := a.GetQrCode(0, 0)
+	if err != nil || qr.Sid != "s1" {
+		t.Fatalf("unexpected qr code %v %v", qr, err)
+	}
+	token, err := a.WaitQrCodeLogin(context.Background(), qr.Sid, time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at" || token.IsAccessTokenExpired() || !token.HasScope(OpenScopeFileRead) || token.HasScope(OpenScopeFileWrite) {
+		t.Fatalf("unexpected token %+v", token)
+	}
+}
+
+func TestOpenAuthAuthorizeAndRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["grant_type"] != "refresh_token" || req["refresh_token"] != "rt" {
+			w.WriteHeader(400)
+			return
+		}
+		w.Write([]byte(`{"token_type":"Bearer","access_token":"at2","refresh_token":"rt2","expires_in":7200}`))
+	}))
+	defer server.Close()
+
+	a := NewOpenAuth("id", "secret", []string{OpenScopeUserBase, OpenScopeFileRead})
+	a.apiUrl = server.URL
+	authorizeUrl := a.AuthorizeUrl("https://app.example/callback", "xyz")
+	if authorizeUrl != server.URL+"/oauth/authorize?client_id=id&redirect_uri=https%3A%2F%2Fapp.example%2Fcallback&response_type=code&scope=user%3Abase%2Cfile%3Aall%3Aread&state=xyz" {
+		t.Fatalf("unexpected authorize url %s", authorizeUrl)
+	}
+
+	code, err := ParseOpenAuthorizeCallback("https://app.example/callback?code=c1&state=xyz", "xyz")
+	if err != nil || code != "c1" {
+		t.Fatalf("unexpected code %s %v", code, err)
+	}
+	if _, err = ParseOpenAuthorizeCallback("https://app.example/callback?code=c1&state=abc", "xyz"); err == nil {
+		t.Fatal("expected state mismatch error")
+	}
+	if _, err = ParseOpenAuthorizeCallback("https://app.example/callback?error=access_denied&state=xyz", "xyz"); err == nil {
+		t.Fatal("expected access denied error")
+	}
+
+	token, err := a.RefreshToken("rt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at2" || token.RefreshToken != "rt2" || len(token.Scopes) != 0 || !token.HasScope(OpenScopeFileRead) {
+		t.Fatalf("unexpected token %+v", token)
+	}
+}
+
+func TestPanClientOpenRoute(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer oat" {
+			w.WriteHeader(401)
+			return
+		}
+		switch r.URL.Path {
+		case "/adrive/v1.0/openFile/get":
+			w.Write([]byte(`{"drive_id":"d1","file_id":"f1","name":"a.txt","type":"file"}`))
+		case "/adrive/v1.0/openFile/update":
+			w.Write([]byte(`{"drive_id":"d1","file_id":"f1","name":"b.txt","type":"file"}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	if p.OpenClient() != nil {
+		t.Fatal("expected no open client")
+	}
+	p.SetOpenToken(OpenToken{TokenType: "Bearer", AccessToken: "oat"})
+	p.OpenClient().apiUrl = server.URL
+
+	fe, err := p.FileInfoById("d1", "f1")
+	if err != nil || fe.FileName != "a.txt" {
+		t.Fatalf("unexpected file %+v %v", fe, err)
+	}
+	if ok, err := p.FileRename("d1", "f1", "b.txt"); err != nil || !ok {
+		t.Fatalf("unexpected rename result %v %v", ok, err)
+	}
+	if c := p.CloneWithToken(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web2"}); c.OpenClient() != nil {
+		t.Fatal("expected cloned client without open token")
+	}
+
+	p.SetOpenToken(OpenToken{})
+	if p.OpenClient() != nil {
+		t.Fatal("expected open token removed")
+	}
+}
+
+func TestPanClientOpenRouteClientBehaviours(t *testing.T) {
+	var renamed string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer oat2" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"code":"AccessTokenInvalid","message":"AccessToken is invalid"}`))
+			return
+		}
+		req := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/adrive/v1.0/openFile/get":
+			w.Write([]byte(`{"drive_id":"d1","file_id":"f1","name":"a：b.txt","type":"file"}`))
+		case "/adrive/v1.0/openFile/update":
+			renamed, _ = req["name"].(string)
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	p.EnableNameEncoding(true)
+	p.SetOpenToken(OpenToken{TokenType: "Bearer", AccessToken: "oat", RefreshToken: "ort"})
+	p.OpenClient().apiUrl = server.URL
+	refreshed := 0
+	p.OpenClient().SetTokenRefreshFunc(func(refreshToken string) (*OpenToken, *apierror.ApiError) {
+		refreshed++
+		return &OpenToken{TokenType: "Bearer", AccessToken: "oat2", RefreshToken: refreshToken + "+"}, nil
+	}, nil)
+
+	// token 失效后刷新开放平台token并重试，文件名还原
+	fe, err := p.FileInfoById("d1", "f1")
+	if err != nil || fe.FileName != "a:b.txt" || refreshed != 1 || p.OpenClient().Token().RefreshToken != "ort+" {
+		t.Fatalf("unexpected file %+v %v, refreshed %d", fe, err, refreshed)
+	}
+	if ok, err := p.FileRename("d1", "f1", "c:d.txt"); err != nil || !ok || renamed != "c：d.txt" {
+		t.Fatalf("unexpected rename result %v %v %s", ok, err, renamed)
+	}
+	if n := p.Stats().Requests["/adrive/v1.0/openFile/get"]; n != 2 {
+		t.Fatalf("expected open requests counted, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.WithContext(ctx).FileInfoById("d1", "f1"); err == nil {
+		t.Fatal("expected error with canceled context")
+	}
+	p.Close(context.Background())
+	if _, err := p.FileInfoById("d1", "f1"); err == nil || err.Error() != ErrClientClosed.Error() {
+		t.Fatalf("expected client closed error, got %v", err)
+	}
+}
+
+func TestPanClientWithOpenTokenOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/adrive/v1.0/user/getDriveInfo":
+			w.Write([]byte(`{"user_id":"u1","name":"nick","default_drive_id":"d1"}`))
+		case "/adrive/v1.0/user/getSpaceInfo":
+			w.Write([]byte(`{"personal_space_info":{"total_size":100,"used_size":10}}`))
+		case "/adrive/v1.0/openFile/get":
+			w.Write([]byte(`{"drive_id":"d1","file_id":"f1","name":"a.txt","type":"file"}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer server.Close()
+
+	p := NewPanClientWithOpenToken(OpenToken{TokenType: "Bearer", AccessToken: "oat"})
+	p.OpenClient().apiUrl = server.URL
+	if p.HasWebToken() {
+		t.Fatal("expected no web token")
+	}
+
+	ui, err := p.GetUserInfo()
+	if err != nil || ui.FileDriveId != "d1" || ui.UserId != "u1" || ui.TotalSize != 100 {
+		t.Fatalf("unexpected user info %+v %v", ui, err)
+	}
+	if fe, err := p.FileInfoById("d1", "f1"); err != nil || fe.FileName != "a.txt" {
+		t.Fatalf("unexpected file %+v %v", fe, err)
+	}
+	// 只有网页版接口的功能直接返回错误，不会发出没有token的请求
+	if _, err := p.ShareLinkList("u1"); err == nil || err.Error() != ErrWebTokenRequired.Error() {
+		t.Fatalf("expected web token required error, got %v", err)
+	}
+}
+
+func TestPanClientOpenRouteScopeFallback(t *testing.T) {
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	p.SetOpenToken(OpenToken{TokenType: "Bearer", AccessToken: "oat", Scopes: []string{OpenScopeFileRead}})
+	if p.openRoute("/adrive/v1.0/openFile/get") == nil {
+		t.Fatal("expected read api routed to open platform")
+	}
+	// 缺少写入授权，改用网页版接口
+	if p.openRoute("/adrive/v1.0/openFile/update") != nil {
+		t.Fatal("expected write api routed to web")
+	}
+
+	// 没有网页版token时仍然使用开放平台接口，由授权范围检查返回错误
+	o := NewPanClientWithOpenToken(OpenToken{TokenType: "Bearer", AccessToken: "oat", Scopes: []string{OpenScopeFileRead}})
+	if o.openRoute("/adrive/v1.0/openFile/update") == nil {
+		t.Fatal("expected open client without web token")
+	}
+	if _, err := o.FileRename("d1", "f1", "b.txt"); err == nil || err.Code != apierror.ApiCodeScopeNotGranted {
+		t.Fatalf("expected scope not granted error, got %v", err)
+	}
+}
+
+func TestOpenQuotaTracker(t *testing.T) {
+	now := time.Date(2021, 7, 29, 23, 59, 59, 0, time.Local)
+	slept := time.Duration(0)
+	q := NewOpenQuotaTracker(false)
+	q.now = func() time.Time { return now }
+	q.sleep = func(d time.Duration) {
+		slept += d
+		now = now.Add(d)
+	}
+	path := "/adrive/v1.0/openFile/list"
+	q.SetLimit(path, OpenQuotaLimit{PerSecond: 2, PerDay: 3})
+	for i := 0; i < 3; i++ {
+		if err := q.Acquire(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if slept != time.Second {
+		t.Fatalf("expected to wait one second, waited %v", slept)
+	}
+	// 第三次调用等待后已是新的一天，重新计数
+	if u := q.Usage(path); u.UsedToday != 1 || u.RemainingToday != 2 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	now = now.Add(time.Hour)
+	q.SetLimit(path, OpenQuotaLimit{PerDay: 1})
+	q.Acquire(path)
+	if err := q.Acquire(path); err == nil || err.Code != apierror.ApiCodeQuotaExceeded {
+		t.Fatalf("expected quota error, got %v", err)
+	}
+	if u := q.Usage(path); u.UsedToday != 1 || u.RemainingToday != 0 {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	q = NewOpenQuotaTracker(true)
+	q.SetDefaultLimit(OpenQuotaLimit{PerSecond: 1})
+	q.Acquire(path)
+	if err := q.Acquire(path); err == nil {
+		t.Fatal("expected reject")
+	}
+}
+
+func TestOpenPanClientWaitAsyncTask(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/adrive/v1.0/openFile/move":
+			w.Write([]byte(`{"drive_id":"1","file_id":"d","async_task_id":"t1"}`))
+		case "/adrive/v1.0/openFile/async_task/get":
+			polls++
+			if polls < 3 {
+				w.Write([]byte(`{"state":"Running","async_task_id":"t1"}`))
+			} else {
+				w.Write([]byte(`{"state":"Succeed","async_task_id":"t1"}`))
+			}
+		}
+	}))
+	defer server.Close()
+
+	p := NewOpenPanClient(OpenToken{AccessToken: "token"})
+	p.apiUrl = server.URL
+	mr, err := p.FileMove([]*FileMoveParam{{DriveId: "1", FileId: "d", ToParentFileId: "root"}})
+	if err != nil || !mr[0].Success || mr[0].AsyncTaskId != "t1" {
+		t.Fatalf("unexpected move result %+v %v", mr[0], err)
+	}
+	task, err := p.WaitAsyncTask(context.Background(), mr[0].AsyncTaskId, time.Millisecond)
+	if err != nil || !task.IsSucceed() || polls != 3 {
+		t.Fatalf("unexpected task %+v %v, polls %d", task, err, polls)
+	}
+}
@@ -0,0 +1,441 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type (
+	// FileSearchParam 文件搜索参数
+	FileSearchParam struct {
+		DriveId string `json:"drive_id"`
+		// Query 搜索条件，例如：name match "报告" and category = "doc"，为空则搜索所有文件
+		Query string `json:"query"`
+		// OrderBy 排序，例如：updated_at DESC
+		OrderBy string `json:"order_by"`
+		Limit   int    `json:"limit"`
+		// Marker 下一页参数
+		Marker string `json:"marker"`
+	}
+
+	// StarredFileListParam 收藏文件列表参数
+	StarredFileListParam struct {
+		DriveId string `json:"drive_id"`
+		// OrderBy 排序字段，例如：updated_at
+		OrderBy string `json:"order_by"`
+		// OrderDirection 排序方向，ASC / DESC
+		OrderDirection string `json:"order_direction"`
+		Limit          int    `json:"limit"`
+		// Marker 下一页参数
+		Marker string `json:"marker"`
+	}
+
+	// FileCopyParam 复制文件参数
+	FileCopyParam struct {
+		// 源网盘ID
+		DriveId string `json:"drive_id"`
+		// 源文件ID
+		FileId string `json:"file_id"`
+		// 目标网盘ID，为空代表同一个网盘
+		ToDriveId string `json:"to_drive_id"`
+		// 目标文件夹ID
+		ToParentFileId string `json:"to_parent_file_id"`
+		// AutoRename 目标文件夹存在同名文件时是否自动重命名
+		AutoRename bool `json:"auto_rename"`
+	}
+
+	// FileCopyResult 复制文件结果
+	FileCopyResult struct {
+		// 源文件ID
+		FileId string
+		// NewFileId 复制后的文件ID
+		NewFileId string
+		// AsyncTaskId 复制文件夹时为异步任务，任务ID不为空
+		AsyncTaskId string
+		// 是否成功
+		Success bool
+	}
+
+	fileCopyResult struct {
+		DriveId     string `json:"drive_id"`
+		FileId      string `json:"file_id"`
+		AsyncTaskId string `json:"async_task_id"`
+	}
+)
+
+// NameMatchQuery 生成按文件名模糊搜索的条件
+func NameMatchQuery(name string) string {
+	return "name match " + strconv.Quote(name)
+}
+
+// openFileListResult 转换文件列表接口的返回结果
+func openFileListResult(flr *fileListResult) *FileListResult {
+	result := &FileListResult{
+		FileList:   FileList{},
+		NextMarker: flr.NextMarker,
+	}
+	for k := range flr.Items {
+		if flr.Items[k] == nil {
+			continue
+		}
+		result.FileList = append(result.FileList, createFileEntity(flr.Items[k]))
+	}
+	return result
+}
+
+// FileList 获取文件列表
+func (p *OpenPanClient) FileList(param *FileListParam) (*FileListResult, *apierror.ApiError) {
+	if err := param.Validate(); err != nil {
+		return nil, apierror.NewApiErrorWithCause(apierror.ApiCodeBadRequest, err)
+	}
+	pFileId := param.ParentFileId
+	if pFileId == "" {
+		pFileId = DefaultRootParentFileId
+	}
+	limit := param.Limit
+	if limit <= 0 {
+		limit = DefaultFileListLimit
+	}
+	postData := map[string]interface{}{
+		"drive_id":       param.DriveId,
+		"parent_file_id": pFileId,
+		"limit":          limit,
+	}
+	if param.OrderBy != "" {
+		postData["order_by"] = param.OrderBy
+	}
+	if param.OrderDirection != "" {
+		postData["order_direction"] = param.OrderDirection
+	}
+	if len(param.Marker) > 0 {
+		postData["marker"] = param.Marker
+	}
+
+	flr := &fileListResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/list", postData, flr); err != nil {
+		return nil, err
+	}
+	return openFileListResult(flr), nil
+}
+
+// FileListGetAll 获取指定目录下的所有文件列表
+func (p *OpenPanClient) FileListGetAll(param *FileListParam) (FileList, *apierror.ApiError) {
+	internalParam := *param
+	fileList := FileList{}
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.FileList(&internalParam)
+		if err != nil {
+			return "", err
+		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		if param.ReturnPartialOnError {
+			return fileList, err
+		}
+		return nil, err
+	}
+	return fileList, nil
+}
+
+// FileInfoById 通过FileId获取文件信息
+func (p *OpenPanClient) FileInfoById(driveId, fileId string) (*FileEntity, *apierror.ApiError) {
+	r := &FileEntityRaw{}
+	postData := map[string]interface{}{
+		"drive_id": driveId,
+		"file_id":  fileId,
+	}
+	if err := p.doRequest("/adrive/v1.0/openFile/get", postData, r); err != nil {
+		return nil, err
+	}
+	return createFileEntity(r), nil
+}
+
+// FileInfoByPath 通过路径获取文件信息
+func (p *OpenPanClient) FileInfoByPath(driveId string, pathStr string) (*FileEntity, *apierror.ApiError) {
+	pathStr = path.Clean("/" + strings.ReplaceAll(pathStr, "\\", "/"))
+	if pathStr == "/" {
+		return NewFileEntityForRootDir(), nil
+	}
+	r := &FileEntityRaw{}
+	postData := map[string]interface{}{
+		"drive_id":  driveId,
+		"file_path": pathStr,
+	}
+	if err := p.doRequest("/adrive/v1.0/openFile/get_by_path", postData, r); err != nil {
+		return nil, err
+	}
+	fe := createFileEntity(r)
+	fe.Path = pathStr
+	return fe, nil
+}
+
+// GetFileDownloadUrl 获取文件下载链接
+func (p *OpenPanClient) GetFileDownloadUrl(param *GetFileDownloadUrlParam) (*GetFileDownloadUrlResult, *apierror.ApiError) {
+	expireSec := param.ExpireSec
+	if expireSec <= 0 {
+		expireSec = 900
+	}
+	postData := map[string]interface{}{
+		"drive_id":   param.DriveId,
+		"file_id":    param.FileId,
+		"expire_sec": expireSec,
+	}
+	r := &GetFileDownloadUrlResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/getDownloadUrl", postData, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Mkdir 创建文件夹，同名文件夹已存在时返回已存在的文件夹
+func (p *OpenPanClient) Mkdir(driveId, parentFileId, dirName string) (*MkdirResult, *apierror.ApiError) {
+	if parentFileId == "" {
+		parentFileId = DefaultRootParentFileId
+	}
+	postData := map[string]interface{}{
+		"drive_id":        driveId,
+		"parent_file_id":  parentFileId,
+		"name":            dirName,
+		"type":            "folder",
+		"check_name_mode": "refuse",
+	}
+	r := &MkdirResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/create", postData, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FileRename 重命名文件，同名文件已存在时返回错误
+func (p *OpenPanClient) FileRename(driveId, renameFileId, newName string) (bool, *apierror.ApiError) {
+	postData := map[string]interface{}{
+		"drive_id":        driveId,
+		"file_id":         renameFileId,
+		"name":            newName,
+		"check_name_mode": "refuse",
+	}
+	if err := p.doRequest("/adrive/v1.0/openFile/update", postData, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FileMove 移动文件。开放平台不支持批量接口，逐个移动，单个文件失败不影响其他文件
+func (p *OpenPanClient) FileMove(param []*FileMoveParam) ([]*FileMoveResult, *apierror.ApiError) {
+	r := []*FileMoveResult{}
+	for _, mp := range param {
+		postData := map[string]interface{}{
+			"drive_id":          mp.DriveId,
+			"file_id":           mp.FileId,
+			"to_parent_file_id": mp.ToParentFileId,
+			"check_name_mode":   "refuse",
+		}
+		if mp.ToDriveId != "" {
+			postData["to_drive_id"] = mp.ToDriveId
+		}
+		ar := &openAsyncTaskResult{}
+		err := p.doRequest("/adrive/v1.0/openFile/move", postData, ar)
+		r = append(r, &FileMoveResult{
+			FileId:      mp.FileId,
+			Success:     err == nil,
+			AsyncTaskId: ar.AsyncTaskId,
+		})
+	}
+	return r, nil
+}
+
+// FileDelete 删除文件到回收站。开放平台不支持批量接口，逐个删除，单个文件失败不影响其他文件
+func (p *OpenPanClient) FileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError) {
+	r := []*FileBatchActionResult{}
+	for _, dp := range param {
+		postData := map[string]interface{}{
+			"drive_id": dp.DriveId,
+			"file_id":  dp.FileId,
+		}
+		ar := &openAsyncTaskResult{}
+		err := p.doRequest("/adrive/v1.0/openFile/recyclebin/trash", postData, ar)
+		r = append(r, &FileBatchActionResult{
+			FileId:      dp.FileId,
+			Success:     err == nil,
+			AsyncTaskId: ar.AsyncTaskId,
+		})
+	}
+	return r, nil
+}
+
+// FileSearch 搜索文件
+func (p *OpenPanClient) FileSearch(param *FileSearchParam) (*FileListResult, *apierror.ApiError) {
+	if param.DriveId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, (&FileListParamError{Field: "drive_id", Reason: "网盘ID不能为空"}).Error())
+	}
+	limit := param.Limit
+	if limit <= 0 {
+		limit = DefaultFileListLimit
+	}
+	postData := map[string]interface{}{
+		"drive_id": param.DriveId,
+		"limit":    limit,
+	}
+	if param.Query != "" {
+		postData["query"] = param.Query
+	}
+	if param.OrderBy != "" {
+		postData["order_by"] = param.OrderBy
+	}
+	if len(param.Marker) > 0 {
+		postData["marker"] = param.Marker
+	}
+
+	flr := &fileListResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/search", postData, flr); err != nil {
+		return nil, err
+	}
+	return openFileListResult(flr), nil
+}
+
+// FileSearchGetAll 获取所有搜索结果
+func (p *OpenPanClient) FileSearchGetAll(param *FileSearchParam) (FileList, *apierror.ApiError) {
+	internalParam := *param
+	fileList := FileList{}
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.FileSearch(&internalParam)
+		if err != nil {
+			return "", err
+		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
+	}
+	return fileList, nil
+}
+
+// FileCopy 复制文件。开放平台不支持批量接口，逐个复制，单个文件失败不影响其他文件。
+// 复制文件夹为异步任务，返回结果中的 AsyncTaskId 不为空
+func (p *OpenPanClient) FileCopy(param []*FileCopyParam) ([]*FileCopyResult, *apierror.ApiError) {
+	r := []*FileCopyResult{}
+	for _, cp := range param {
+		postData := map[string]interface{}{
+			"drive_id":          cp.DriveId,
+			"file_id":           cp.FileId,
+			"to_parent_file_id": cp.ToParentFileId,
+			"auto_rename":       cp.AutoRename,
+		}
+		if cp.ToDriveId != "" {
+			postData["to_drive_id"] = cp.ToDriveId
+		}
+		cr := &fileCopyResult{}
+		err := p.doRequest("/adrive/v1.0/openFile/copy", postData, cr)
+		r = append(r, &FileCopyResult{
+			FileId:      cp.FileId,
+			NewFileId:   cr.FileId,
+			AsyncTaskId: cr.AsyncTaskId,
+			Success:     err == nil,
+		})
+	}
+	return r, nil
+}
+
+// RecycleBinFileDelete 彻底删除文件，不会进入回收站，无法恢复
+func (p *OpenPanClient) RecycleBinFileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError) {
+	r := []*FileBatchActionResult{}
+	for _, dp := range param {
+		postData := map[string]interface{}{
+			"drive_id": dp.DriveId,
+			"file_id":  dp.FileId,
+		}
+		ar := &openAsyncTaskResult{}
+		err := p.doRequest("/adrive/v1.0/openFile/delete", postData, ar)
+		r = append(r, &FileBatchActionResult{
+			FileId:      dp.FileId,
+			Success:     err == nil,
+			AsyncTaskId: ar.AsyncTaskId,
+		})
+	}
+	return r, nil
+}
+
+// StarredFileList 获取收藏文件列表
+func (p *OpenPanClient) StarredFileList(param *StarredFileListParam) (*FileListResult, *apierror.ApiError) {
+	if param.DriveId == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeBadRequest, (&FileListParamError{Field: "drive_id", Reason: "网盘ID不能为空"}).Error())
+	}
+	limit := param.Limit
+	if limit <= 0 {
+		limit = DefaultFileListLimit
+	}
+	postData := map[string]interface{}{
+		"drive_id": param.DriveId,
+		"limit":    limit,
+	}
+	if param.OrderBy != "" {
+		postData["order_by"] = param.OrderBy
+	}
+	if param.OrderDirection != "" {
+		postData["order_direction"] = param.OrderDirection
+	}
+	if len(param.Marker) > 0 {
+		postData["marker"] = param.Marker
+	}
+
+	flr := &fileListResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/starredList", postData, flr); err != nil {
+		return nil, err
+	}
+	return openFileListResult(flr), nil
+}
+
+// StarredFileListGetAll 获取所有收藏文件
+func (p *OpenPanClient) StarredFileListGetAll(param *StarredFileListParam) (FileList, *apierror.ApiError) {
+	internalParam := *param
+	fileList := FileList{}
+	pg := NewPaginator(internalParam.Marker, func(marker string) (string, *apierror.ApiError) {
+		internalParam.Marker = marker
+		result, err := p.StarredFileList(&internalParam)
+		if err != nil {
+			return "", err
+		}
+		fileList = append(fileList, result.FileList...)
+		return result.NextMarker, nil
+	})
+	if err := pg.All(); err != nil {
+		return nil, err
+	}
+	return fileList, nil
+}
+
+// RecentFileList 获取最近使用的文件列表，limit 小于等于0时使用默认值
+func (p *OpenPanClient) RecentFileList(limit int) (FileList, *apierror.ApiError) {
+	if limit <= 0 {
+		limit = DefaultFileListLimit
+	}
+	postData := map[string]interface{}{
+		"limit": limit,
+	}
+	flr := &fileListResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/recentList", postData, flr); err != nil {
+		return nil, err
+	}
+	return openFileListResult(flr).FileList, nil
+}
@@ -0,0 +1,262 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type (
+	// OpenAuth 开放平台授权，用于第三方应用获取用户的 OpenToken
+	OpenAuth struct {
+		clientId     string
+		clientSecret string
+		scopes       []string
+
+		// apiUrl 接口地址，默认为 OPENAPI_URL
+		apiUrl string
+	}
+
+	// OpenQrCode 授权二维码
+	OpenQrCode struct {
+		// QrCodeUrl 二维码图片地址，展示给用户使用阿里云盘App扫码
+		QrCodeUrl string `json:"qrCodeUrl"`
+		// Sid 二维码ID，用于查询扫码状态
+		Sid string `json:"sid"`
+	}
+
+	// OpenQrCodeStatus 二维码扫码状态
+	OpenQrCodeStatus string
+
+	// OpenQrCodeStatusResult 二维码扫码状态查询结果
+	OpenQrCodeStatusResult struct {
+		Status OpenQrCodeStatus `json:"status"`
+		// AuthCode 授权码，状态为 LoginSuccess 时才有，用于获取token
+		AuthCode string `json:"authCode"`
+	}
+
+	openTokenResult struct {
+		TokenType    string `json:"token_type"`
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+		// Scope 实际授予的授权范围，可能比申请的少
+		Scope string `json:"scope"`
+	}
+)
+
+const (
+	// OpenQrCodeStatusWaitLogin 等待扫码
+	OpenQrCodeStatusWaitLogin OpenQrCodeStatus = "WaitLogin"
+	// OpenQrCodeStatusScanSuccess 已扫码，等待用户确认
+	OpenQrCodeStatusScanSuccess OpenQrCodeStatus = "ScanSuccess"
+	// OpenQrCodeStatusLoginSuccess 用户已确认授权
+	OpenQrCodeStatusLoginSuccess OpenQrCodeStatus = "LoginSuccess"
+	// OpenQrCodeStatusExpired 二维码已过期
+	OpenQrCodeStatusExpired OpenQrCodeStatus = "QRCodeExpired"
+)
+
+// NewOpenAuth 创建开放平台授权，clientId 和 clientSecret 在开放平台创建应用后获得，scopes 为需要用户授权的范围
+func NewOpenAuth(clientId, clientSecret string, scopes []string) *OpenAuth {
+	return &OpenAuth{
+		clientId:     clientId,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		apiUrl:       OPENAPI_URL,
+	}
+}
+
+// IsAccessTokenExpired access token 是否已经过期，距离过期不足60秒也视为过期
+func (t *OpenToken) IsAccessTokenExpired() bool {
+	expireTime, err := time.ParseInLocation("2006-01-02 15:04:05", t.ExpireTime, time.Local)
+	if err != nil {
+		return true
+	}
+	return time.Until(expireTime) < 60*time.Second
+}
+
+func (a *OpenAuth) doRequest(method, path string, postData interface{}, result interface{}) *apierror.ApiError {
+	header := map[string]string{
+		"content-type": "application/json;charset=UTF-8",
+		"accept":       "application/json",
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s%s", a.apiUrl, path)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	// request
+	body, err := client.Fetch(method, fullUrl.String(), postData, header)
+	if err != nil {
+		logger.Verboseln("open auth request error ", err)
+		return apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return err1
+	}
+
+	// parse result
+	if err2 := json.Unmarshal(body, result); err2 != nil {
+		logger.Verboseln("parse open auth result json error ", err2)
+		return apierror.NewFailedApiError(err2.Error())
+	}
+	return nil
+}
+
+// GetQrCode 获取授权二维码，width 和 height 为二维码图片大小，为0使用默认大小
+func (a *OpenAuth) GetQrCode(width, height int) (*OpenQrCode, *apierror.ApiError) {
+	postData := map[string]interface{}{
+		"client_id":     a.clientId,
+		"client_secret": a.clientSecret,
+		"scopes":        a.scopes,
+	}
+	if width > 0 {
+		postData["width"] = width
+	}
+	if height > 0 {
+		postData["height"] = height
+	}
+	r := &OpenQrCode{}
+	if err := a.doRequest("POST", "/oauth/authorize/qrcode", postData, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// QrCodeStatus 查询二维码扫码状态
+func (a *OpenAuth) QrCodeStatus(sid string) (*OpenQrCodeStatusResult, *apierror.ApiError) {
+	r := &OpenQrCodeStatusResult{}
+	if err := a.doRequest("GET", "/oauth/qrcode/"+sid+"/status", nil, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// AuthorizeUrl 网页授权地址，用户在浏览器中打开并同意授权后，跳转到 redirectUri 并带上授权码 code 和 state 参数。
+// redirectUri 需要和开放平台应用配置的回调地址一致，state 用于防止跨站请求伪造，回调时使用 ParseOpenAuthorizeCallback 校验
+func (a *OpenAuth) AuthorizeUrl(redirectUri, state string) string {
+	params := url.Values{}
+	params.Set("client_id", a.clientId)
+	params.Set("redirect_uri", redirectUri)
+	params.Set("scope", strings.Join(a.scopes, ","))
+	params.Set("response_type", "code")
+	if state != "" {
+		params.Set("state", state)
+	}
+	return a.apiUrl + "/oauth/authorize?" + params.Encode()
+}
+
+// ParseOpenAuthorizeCallback 解析网页授权回调地址，校验 state 后返回授权码。用户拒绝授权时返回错误
+func ParseOpenAuthorizeCallback(callbackUrl, state string) (string, *apierror.ApiError) {
+	u, err := url.Parse(callbackUrl)
+	if err != nil {
+		return "", apierror.NewApiError(apierror.ApiCodeBadRequest, "回调地址错误："+err.Error())
+	}
+	query := u.Query()
+	if e := query.Get("error"); e != "" {
+		return "", apierror.NewFailedApiError("用户未授权：" + e)
+	}
+	if query.Get("state") != state {
+		return "", apierror.NewApiError(apierror.ApiCodeBadRequest, "回调地址state不匹配")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", apierror.NewApiError(apierror.ApiCodeBadRequest, "回调地址缺少授权码")
+	}
+	return code, nil
+}
+
+// GetAccessToken 使用授权码获取token
+func (a *OpenAuth) GetAccessToken(authCode string) (*OpenToken, *apierror.ApiError) {
+	postData := map[string]interface{}{
+		"client_id":     a.clientId,
+		"client_secret": a.clientSecret,
+		"grant_type":    "authorization_code",
+		"code":          authCode,
+	}
+	return a.tokenReq(postData)
+}
+
+// RefreshToken 使用 refresh token 获取新的token。refresh token 只能使用一次，刷新后需要保存新的token
+func (a *OpenAuth) RefreshToken(refreshToken string) (*OpenToken, *apierror.ApiError) {
+	if refreshToken == "" {
+		return nil, apierror.NewApiError(apierror.ApiCodeRefreshTokenExpiredCode, "refresh token为空")
+	}
+	postData := map[string]interface{}{
+		"client_id":     a.clientId,
+		"client_secret": a.clientSecret,
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken,
+	}
+	return a.tokenReq(postData)
+}
+
+func (a *OpenAuth) tokenReq(postData map[string]interface{}) (*OpenToken, *apierror.ApiError) {
+	r := &openTokenResult{}
+	if err := a.doRequest("POST", "/oauth/access_token", postData, r); err != nil {
+		return nil, err
+	}
+	token := &OpenToken{
+		TokenType:    r.TokenType,
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+		ExpiresIn:    r.ExpiresIn,
+		ExpireTime:   time.Now().Add(time.Duration(r.ExpiresIn) * time.Second).Format("2006-01-02 15:04:05"),
+	}
+	// 用户可能只同意了部分授权范围，使用接口返回的授权范围；没有返回时不记录，不做检查
+	if r.Scope != "" {
+		token.Scopes = ParseOpenScopes(r.Scope)
+	}
+	return token, nil
+}
+
+// WaitQrCodeLogin 轮询二维码扫码状态，用户确认授权后获取token。interval 为轮询间隔，默认为2秒。
+// onStatus 不为nil时每次查询到状态都会回调，可以用于提示用户；二维码过期或者 ctx 取消时返回错误
+func (a *OpenAuth) WaitQrCodeLogin(ctx context.Context, sid string, interval time.Duration, onStatus func(status OpenQrCodeStatus)) (*OpenToken, *apierror.ApiError) {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r, err := a.QrCodeStatus(sid)
+		if err != nil {
+			return nil, err
+		}
+		if onStatus != nil {
+			onStatus(r.Status)
+		}
+		switch r.Status {
+		case OpenQrCodeStatusLoginSuccess:
+			return a.GetAccessToken(r.AuthCode)
+		case OpenQrCodeStatusExpired:
+			return nil, apierror.NewFailedApiError("二维码已过期")
+		}
+		select {
+		case <-ctx.Done():
+			return nil, apierror.NewApiErrorWithError(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
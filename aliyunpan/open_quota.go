@@ -0,0 +1,176 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"sync"
+	"time"
+)
+
+type (
+	// OpenQuotaLimit 开放平台接口调用限制，0代表不限制
+	OpenQuotaLimit struct {
+		// PerSecond 每秒最多调用次数
+		PerSecond int
+		// PerDay 每天最多调用次数
+		PerDay int
+	}
+
+	// OpenQuotaUsage 接口调用统计
+	OpenQuotaUsage struct {
+		// Path 接口路径
+		Path string
+		// UsedLastSecond 最近一秒的调用次数
+		UsedLastSecond int
+		// UsedToday 今天的调用次数
+		UsedToday int
+		// RemainingToday 今天剩余的调用次数，-1代表不限制
+		RemainingToday int
+	}
+
+	// OpenQuotaTracker 开放平台接口调用次数统计，在达到服务器限制前延迟或者拒绝请求
+	OpenQuotaTracker struct {
+		mu           sync.Mutex
+		limits       map[string]OpenQuotaLimit
+		defaultLimit OpenQuotaLimit
+		// reject 超过每秒限制时是否直接拒绝，false则等待到可以调用为止。超过每日限制总是拒绝
+		reject   bool
+		counters map[string]*quotaCounter
+
+		now   func() time.Time
+		sleep func(time.Duration)
+	}
+
+	quotaCounter struct {
+		day    string
+		today  int
+		recent []time.Time
+	}
+)
+
+// NewOpenQuotaTracker 创建接口调用次数统计。reject 为true时超过每秒限制直接返回错误，否则等待
+func NewOpenQuotaTracker(reject bool) *OpenQuotaTracker {
+	return &OpenQuotaTracker{
+		limits:   map[string]OpenQuotaLimit{},
+		reject:   reject,
+		counters: map[string]*quotaCounter{},
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// SetLimit 设置指定接口的调用限制，path 例如：/adrive/v1.0/openFile/getDownloadUrl
+func (q *OpenQuotaTracker) SetLimit(path string, limit OpenQuotaLimit) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits[path] = limit
+}
+
+// SetDefaultLimit 设置没有单独设置限制的接口的调用限制
+func (q *OpenQuotaTracker) SetDefaultLimit(limit OpenQuotaLimit) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.defaultLimit = limit
+}
+
+func (q *OpenQuotaTracker) limit(path string) OpenQuotaLimit {
+	if l, ok := q.limits[path]; ok {
+		return l
+	}
+	return q.defaultLimit
+}
+
+// counter 获取接口的计数器，并清理过期的数据
+func (q *OpenQuotaTracker) counter(path string, now time.Time) *quotaCounter {
+	c, ok := q.counters[path]
+	if !ok {
+		c = &quotaCounter{}
+		q.counters[path] = c
+	}
+	if day := now.Format("2006-01-02"); c.day != day {
+		c.day = day
+		c.today = 0
+	}
+	i := 0
+	for i < len(c.recent) && now.Sub(c.recent[i]) >= time.Second {
+		i++
+	}
+	c.recent = c.recent[i:]
+	return c
+}
+
+// Usage 获取指定接口的调用统计
+func (q *OpenQuotaTracker) Usage(path string) OpenQuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c := q.counter(path, q.now())
+	u := OpenQuotaUsage{
+		Path:           path,
+		UsedLastSecond: len(c.recent),
+		UsedToday:      c.today,
+		RemainingToday: -1,
+	}
+	if l := q.limit(path); l.PerDay > 0 {
+		u.RemainingToday = l.PerDay - c.today
+		if u.RemainingToday < 0 {
+			u.RemainingToday = 0
+		}
+	}
+	return u
+}
+
+// Acquire 调用接口前申请一次调用次数，超过限制时等待或者返回 ApiCodeQuotaExceeded 错误
+func (q *OpenQuotaTracker) Acquire(path string) *apierror.ApiError {
+	for {
+		q.mu.Lock()
+		now := q.now()
+		c := q.counter(path, now)
+		l := q.limit(path)
+		if l.PerDay > 0 && c.today >= l.PerDay {
+			q.mu.Unlock()
+			return apierror.NewApiError(apierror.ApiCodeQuotaExceeded, fmt.Sprintf("接口今日调用次数已达上限%d：%s", l.PerDay, path))
+		}
+		if l.PerSecond <= 0 || len(c.recent) < l.PerSecond {
+			c.today++
+			c.recent = append(c.recent, now)
+			q.mu.Unlock()
+			return nil
+		}
+		wait := c.recent[0].Add(time.Second).Sub(now)
+		q.mu.Unlock()
+		if q.reject {
+			return apierror.NewApiError(apierror.ApiCodeQuotaExceeded, fmt.Sprintf("接口调用频率超过每秒%d次：%s", l.PerSecond, path))
+		}
+		q.sleep(wait)
+	}
+}
+
+// SetQuotaTracker 设置接口调用次数统计，为nil代表不统计
+func (p *OpenPanClient) SetQuotaTracker(q *OpenQuotaTracker) {
+	r := p.root()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quota = q
+}
+
+// QuotaTracker 获取接口调用次数统计
+func (p *OpenPanClient) QuotaTracker() *OpenQuotaTracker {
+	r := p.root()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.quota
+}
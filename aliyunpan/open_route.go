@@ -0,0 +1,241 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"errors"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+var (
+	// ErrWebTokenRequired 只设置了开放平台token的客户端调用只有网页版接口的功能
+	ErrWebTokenRequired = errors.New("该接口只支持网页版token")
+)
+
+// NewPanClientWithOpenToken 创建只使用开放平台token的客户端。开放平台支持的接口通过开放平台请求，
+// 用户信息使用开放平台的网盘信息填充，只有网页版接口的功能返回 ErrWebTokenRequired 错误。
+// 之后可以通过 UpdateToken 补充网页版token，逐步迁移
+func NewPanClientWithOpenToken(token OpenToken) *PanClient {
+	pc := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	pc.SetOpenToken(token)
+	return pc
+}
+
+// SetOpenToken 设置开放平台token。设置后文件列表、文件详情、搜索、下载链接、新建文件夹、重命名、移动和删除
+// 通过开放平台接口请求，其他接口仍然使用网页版token。token的授权范围不包含某个接口并且设置了网页版token时，
+// 该接口使用网页版请求。开放平台请求和网页版请求一样检查客户端是否关闭、使用绑定的上下文、请求调度器和限速器，
+// 计入流量统计并处理文件名编码；不经过元数据缓存，也不使用设备会话签名和风控验证。
+// 开放平台token的自动刷新通过 OpenClient().SetTokenRefreshFunc 设置。传入空token取消
+func (pc *PanClient) SetOpenToken(token OpenToken) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if token.AccessToken == "" {
+		pc.open = nil
+		return
+	}
+	if pc.open == nil {
+		pc.open = NewOpenPanClient(token)
+		return
+	}
+	pc.open.UpdateToken(token)
+}
+
+// OpenClient 返回设置开放平台token后使用的客户端，没有设置返回nil
+func (pc *PanClient) OpenClient() *OpenPanClient {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.open
+}
+
+// HasWebToken 是否设置了网页版token
+func (pc *PanClient) HasWebToken() bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.webToken.AccessToken != ""
+}
+
+// openRoute 开放平台接口 path 对应的功能需要通过开放平台请求时返回开放平台客户端，否则返回nil。
+// 开放平台token缺少接口需要的授权范围时，如果有网页版token则改用网页版接口。
+// 返回的客户端通过 openFetch 发起请求
+func (pc *PanClient) openRoute(path string) *OpenPanClient {
+	pc.mu.RLock()
+	o := pc.open
+	hasWebToken := pc.webToken.AccessToken != ""
+	pc.mu.RUnlock()
+	if o == nil {
+		return nil
+	}
+	if scope, ok := openApiScopes[path]; ok && hasWebToken && !o.HasScope(scope) {
+		return nil
+	}
+	return o.withFetch(pc.openFetch)
+}
+
+// openFetch 发起开放平台请求，绑定的上下文取消后立即返回
+func (pc *PanClient) openFetch(method string, urlStr string, post interface{}, header map[string]string) ([]byte, error) {
+	return pc.fetchWithContext(pc.doOpenFetch, method, urlStr, post, header)
+}
+
+// doOpenFetch 发起一次开放平台请求并记录统计，设置了请求调度器时先申请名额。
+// 网页版token的刷新、设备会话签名和风控验证不适用于开放平台请求
+func (pc *PanClient) doOpenFetch(method string, urlStr string, post interface{}, header map[string]string) ([]byte, error) {
+	if pc.isClosed() {
+		return nil, ErrClientClosed
+	}
+	release, err := pc.acquire(pc.requestClass)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	body, err := client.Fetch(method, urlStr, post, header)
+	pc.stats.request(urlStr, len(body), err != nil)
+	return body, err
+}
+
+// openFileEntity 开放平台返回的文件信息，还原编码的文件名并设置展示语言和时间格式
+func (pc *PanClient) openFileEntity(fe *FileEntity) *FileEntity {
+	if fe == nil {
+		return nil
+	}
+	if pc.isNameEncoding() {
+		fe.FileName = apiutil.DecodeFileName(fe.FileName)
+		fe.Path = apiutil.DecodePath(fe.Path)
+	}
+	fe.lang = pc.Language()
+	pc.applyTimeFormat(fe)
+	return fe
+}
+
+func (pc *PanClient) openFileList(list FileList) FileList {
+	for _, fe := range list {
+		pc.openFileEntity(fe)
+	}
+	return list
+}
+
+func (pc *PanClient) openFileListPage(o *OpenPanClient, param *FileListParam) (*FileListResult, *apierror.ApiError) {
+	r, err := o.FileList(param)
+	if err != nil {
+		return nil, err
+	}
+	pc.openFileList(r.FileList)
+	return r, nil
+}
+
+func (pc *PanClient) openFileListGetAll(o *OpenPanClient, param *FileListParam) (FileList, *apierror.ApiError) {
+	r, err := o.FileListGetAll(param)
+	return pc.openFileList(r), err
+}
+
+func (pc *PanClient) openFileInfoById(o *OpenPanClient, driveId, fileId string) (*FileEntity, *apierror.ApiError) {
+	fe, err := o.FileInfoById(driveId, fileId)
+	if err != nil {
+		return nil, err
+	}
+	return pc.openFileEntity(fe), nil
+}
+
+func (pc *PanClient) openFileInfoByPath(o *OpenPanClient, driveId, pathStr string) (*FileEntity, *apierror.ApiError) {
+	if pc.isNameEncoding() {
+		pathStr = apiutil.EncodePath(pathStr)
+	}
+	fe, err := o.FileInfoByPath(driveId, pathStr)
+	if err != nil {
+		return nil, err
+	}
+	return pc.openFileEntity(fe), nil
+}
+
+func (pc *PanClient) openFileSearch(o *OpenPanClient, param *FileSearchParam) (*FileListResult, *apierror.ApiError) {
+	r, err := o.FileSearch(param)
+	if err != nil {
+		return nil, err
+	}
+	pc.openFileList(r.FileList)
+	return r, nil
+}
+
+// webTokenMissing 只设置了开放平台token，网页版接口无法请求
+func (pc *PanClient) webTokenMissing() bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.webToken.AccessToken == "" && pc.open != nil
+}
+
+// openUserInfo 使用开放平台的网盘信息和空间信息填充用户信息
+func (pc *PanClient) openUserInfo(o *OpenPanClient) (*UserInfo, *apierror.ApiError) {
+	di, err := o.GetDriveInfo()
+	if err != nil {
+		return nil, err
+	}
+	si, err := o.GetSpaceInfo()
+	if err != nil {
+		return nil, err
+	}
+	return &UserInfo{
+		FileDriveId: di.DefaultDriveId,
+		UserId:      di.UserId,
+		Nickname:    di.Name,
+		TotalSize:   si.TotalSize,
+		UsedSize:    si.UsedSize,
+	}, nil
+}
+
+func (pc *PanClient) openMkdir(o *OpenPanClient, driveId, parentFileId, dirName string) (*MkdirResult, *apierror.ApiError) {
+	r, err := o.Mkdir(driveId, parentFileId, pc.encodeFileName(dirName))
+	if err != nil {
+		return nil, err
+	}
+	pc.childrenChanged(driveId, parentFileId)
+	return r, nil
+}
+
+func (pc *PanClient) openFileRename(o *OpenPanClient, driveId, renameFileId, newName string) (bool, *apierror.ApiError) {
+	ok, err := o.FileRename(driveId, renameFileId, pc.encodeFileName(newName))
+	if err != nil {
+		return false, err
+	}
+	pc.fileChanged(driveId, renameFileId)
+	return ok, nil
+}
+
+func (pc *PanClient) openFileMove(o *OpenPanClient, param []*FileMoveParam) ([]*FileMoveResult, *apierror.ApiError) {
+	r, err := o.FileMove(param)
+	if err != nil {
+		return nil, err
+	}
+	for _, mp := range param {
+		pc.fileChanged(mp.DriveId, mp.FileId)
+		toDriveId := mp.ToDriveId
+		if toDriveId == "" {
+			toDriveId = mp.DriveId
+		}
+		pc.childrenChanged(toDriveId, mp.ToParentFileId)
+	}
+	return r, nil
+}
+
+func (pc *PanClient) openFileDelete(o *OpenPanClient, param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError) {
+	r, err := o.FileDelete(param)
+	if err != nil {
+		return nil, err
+	}
+	for _, dp := range param {
+		pc.fileChanged(dp.DriveId, dp.FileId)
+	}
+	return r, nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"strings"
+)
+
+const (
+	// OpenScopeUserBase 获取用户基本信息
+	OpenScopeUserBase = "user:base"
+	// OpenScopeFileRead 读取所有文件
+	OpenScopeFileRead = "file:all:read"
+	// OpenScopeFileWrite 写入所有文件
+	OpenScopeFileWrite = "file:all:write"
+	// OpenScopeAlbumRead 读取共享相册
+	OpenScopeAlbumRead = "album:shared:read"
+	// OpenScopeShareWrite 创建分享
+	OpenScopeShareWrite = "file:share:write"
+)
+
+var (
+	// openApiScopes 开放平台接口需要的授权范围，没有列出的接口不做检查
+	openApiScopes = map[string]string{
+		"/adrive/v1.0/user/getDriveInfo":                OpenScopeUserBase,
+		"/adrive/v1.0/user/getSpaceInfo":                OpenScopeUserBase,
+		"/adrive/v1.0/openFile/list":                    OpenScopeFileRead,
+		"/adrive/v1.0/openFile/get":                     OpenScopeFileRead,
+		"/adrive/v1.0/openFile/get_by_path":             OpenScopeFileRead,
+		"/adrive/v1.0/openFile/search":                  OpenScopeFileRead,
+		"/adrive/v1.0/openFile/starredList":             OpenScopeFileRead,
+		"/adrive/v1.0/openFile/recentList":              OpenScopeFileRead,
+		"/adrive/v1.0/openFile/getDownloadUrl":          OpenScopeFileRead,
+		"/adrive/v1.0/openFile/getVideoPreviewPlayInfo": OpenScopeFileRead,
+		"/adrive/v1.0/openFile/async_task/get":          OpenScopeFileRead,
+		"/adrive/v1.0/openFile/create":                  OpenScopeFileWrite,
+		"/adrive/v1.0/openFile/update":                  OpenScopeFileWrite,
+		"/adrive/v1.0/openFile/move":                    OpenScopeFileWrite,
+		"/adrive/v1.0/openFile/copy":                    OpenScopeFileWrite,
+		"/adrive/v1.0/openFile/recyclebin/trash":        OpenScopeFileWrite,
+		"/adrive/v1.0/openFile/delete":                  OpenScopeFileWrite,
+		"/adrive/v1.0/openFile/video/updateRecord":      OpenScopeFileWrite,
+	}
+)
+
+// ParseOpenScopes 解析授权接口返回的scope字符串，支持逗号或者空格分隔
+func ParseOpenScopes(scope string) []string {
+	fields := strings.FieldsFunc(scope, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	scopes := []string{}
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			scopes = append(scopes, f)
+		}
+	}
+	return scopes
+}
+
+// HasScope token是否包含指定的授权范围。没有记录授权范围的token视为包含所有授权范围。
+// 拥有写入权限时同时视为拥有读取权限
+func (t *OpenToken) HasScope(scope string) bool {
+	if len(t.Scopes) == 0 {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope || (scope == OpenScopeFileRead && s == OpenScopeFileWrite) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 当前token是否包含指定的授权范围
+func (p *OpenPanClient) HasScope(scope string) bool {
+	token := p.Token()
+	return token.HasScope(scope)
+}
+
+// checkScope 请求前检查授权范围，不包含时返回 ApiCodeScopeNotGranted 错误，避免请求后得到难以理解的403错误
+func (p *OpenPanClient) checkScope(path string) *apierror.ApiError {
+	scope, ok := openApiScopes[path]
+	if !ok || p.HasScope(scope) {
+		return nil
+	}
+	return apierror.NewApiError(apierror.ApiCodeScopeNotGranted, "授权范围不包含 "+scope+"，无法调用接口："+path)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"time"
+)
+
+type (
+	// AsyncTaskState 异步任务状态
+	AsyncTaskState string
+
+	// AsyncTask 异步任务。复制、移动、删除较大的文件夹时服务器以异步任务处理
+	AsyncTask struct {
+		// TaskId 任务ID
+		TaskId string `json:"async_task_id"`
+		// State 任务状态
+		State AsyncTaskState `json:"state"`
+	}
+
+	openAsyncTaskResult struct {
+		AsyncTaskId string `json:"async_task_id"`
+	}
+)
+
+const (
+	// AsyncTaskStateRunning 任务执行中
+	AsyncTaskStateRunning AsyncTaskState = "Running"
+	// AsyncTaskStateSucceed 任务执行成功
+	AsyncTaskStateSucceed AsyncTaskState = "Succeed"
+	// AsyncTaskStateFailed 任务执行失败
+	AsyncTaskStateFailed AsyncTaskState = "Failed"
+)
+
+// IsFinished 任务是否已经结束，成功或者失败
+func (t *AsyncTask) IsFinished() bool {
+	if t == nil {
+		return false
+	}
+	return t.State == AsyncTaskStateSucceed || t.State == AsyncTaskStateFailed
+}
+
+// IsSucceed 任务是否执行成功
+func (t *AsyncTask) IsSucceed() bool {
+	return t != nil && t.State == AsyncTaskStateSucceed
+}
+
+// GetAsyncTask 查询异步任务状态
+func (p *OpenPanClient) GetAsyncTask(taskId string) (*AsyncTask, *apierror.ApiError) {
+	postData := map[string]interface{}{
+		"async_task_id": taskId,
+	}
+	r := &AsyncTask{}
+	if err := p.doRequest("/adrive/v1.0/openFile/async_task/get", postData, r); err != nil {
+		return nil, err
+	}
+	if r.TaskId == "" {
+		r.TaskId = taskId
+	}
+	return r, nil
+}
+
+// WaitAsyncTask 每隔 interval 查询一次异步任务状态，直到任务结束或者 ctx 取消。任务执行失败时返回错误
+func (p *OpenPanClient) WaitAsyncTask(ctx context.Context, taskId string, interval time.Duration) (*AsyncTask, *apierror.ApiError) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r, err := p.GetAsyncTask(taskId)
+		if err != nil {
+			return nil, err
+		}
+		switch r.State {
+		case AsyncTaskStateSucceed:
+			return r, nil
+		case AsyncTaskStateFailed:
+			return r, apierror.NewFailedApiError("异步任务执行失败：" + taskId)
+		}
+		select {
+		case <-ctx.Done():
+			return r, apierror.NewApiErrorWithError(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type (
+	// VideoDefinition 视频转码清晰度
+	VideoDefinition string
+
+	// VideoPreviewPlayInfoParam 获取视频转码播放信息参数
+	VideoPreviewPlayInfoParam struct {
+		DriveId string `json:"drive_id"`
+		FileId  string `json:"file_id"`
+		// TemplateId 只获取指定清晰度，例如：LD/SD/HD/FHD/QHD，为空获取所有清晰度
+		TemplateId string `json:"template_id"`
+		// GetSubtitleInfo 是否获取字幕
+		GetSubtitleInfo bool `json:"get_subtitle_info"`
+		// UrlExpireSec 播放链接有效期，单位秒，默认为900秒，最长14400秒
+		UrlExpireSec int `json:"url_expire_sec"`
+	}
+
+	// VideoTranscodingTask 视频转码清晰度
+	VideoTranscodingTask struct {
+		// TemplateId 清晰度，例如：LD/SD/HD/FHD/QHD
+		TemplateId     string `json:"template_id"`
+		TemplateName   string `json:"template_name"`
+		TemplateWidth  int    `json:"template_width"`
+		TemplateHeight int    `json:"template_height"`
+		// Status 转码状态，finished 代表可以播放，running 代表正在转码
+		Status string `json:"status"`
+		Stage  string `json:"stage"`
+		// Url m3u8播放地址
+		Url string `json:"url"`
+	}
+
+	// VideoSubtitleTask 视频字幕
+	VideoSubtitleTask struct {
+		Language string `json:"language"`
+		Status   string `json:"status"`
+		Url      string `json:"url"`
+	}
+
+	// SubtitleTrack 可以直接加载的字幕轨道
+	SubtitleTrack struct {
+		// Language 语言，例如：chi / eng
+		Language string `json:"language"`
+		// Url 字幕文件地址，WebVTT格式
+		Url string `json:"url"`
+	}
+
+	// VideoPreviewPlayInfo 视频转码播放信息
+	VideoPreviewPlayInfo struct {
+		DriveId  string `json:"drive_id"`
+		FileId   string `json:"file_id"`
+		Category string `json:"category"`
+		Meta     struct {
+			// Duration 视频时长，单位秒
+			Duration float64 `json:"duration"`
+			Width    int     `json:"width"`
+			Height   int     `json:"height"`
+		} `json:"meta"`
+		TranscodingTaskList []*VideoTranscodingTask `json:"live_transcoding_task_list"`
+		SubtitleTaskList    []*VideoSubtitleTask    `json:"live_transcoding_subtitle_task_list"`
+	}
+
+	videoPreviewPlayInfoResult struct {
+		DriveId  string                `json:"drive_id"`
+		FileId   string                `json:"file_id"`
+		PlayInfo *VideoPreviewPlayInfo `json:"video_preview_play_info"`
+	}
+
+	// VideoPlayRecord 视频播放进度
+	VideoPlayRecord struct {
+		DriveId string `json:"drive_id"`
+		FileId  string `json:"file_id"`
+		// PlayCursor 播放进度，单位秒
+		PlayCursor float64
+		// Duration 视频时长，单位秒
+		Duration float64
+	}
+
+	videoPlayRecordResult struct {
+		DriveId    string `json:"drive_id"`
+		FileId     string `json:"file_id"`
+		PlayCursor string `json:"play_cursor"`
+		Duration   string `json:"duration"`
+	}
+)
+
+const (
+	// VideoDefinitionLD 流畅
+	VideoDefinitionLD VideoDefinition = "LD"
+	// VideoDefinitionSD 标清 540P
+	VideoDefinitionSD VideoDefinition = "SD"
+	// VideoDefinitionHD 高清 720P
+	VideoDefinitionHD VideoDefinition = "HD"
+	// VideoDefinitionFHD 超清 1080P
+	VideoDefinitionFHD VideoDefinition = "FHD"
+	// VideoDefinitionQHD 2K
+	VideoDefinitionQHD VideoDefinition = "QHD"
+
+	// VideoTranscodeStatusFinished 转码完成
+	VideoTranscodeStatusFinished = "finished"
+	// VideoTranscodeStatusRunning 正在转码
+	VideoTranscodeStatusRunning = "running"
+	// VideoTranscodeStatusFailed 转码失败
+	VideoTranscodeStatusFailed = "failed"
+)
+
+var videoDefinitionOrder = map[VideoDefinition]int{
+	VideoDefinitionLD:  1,
+	VideoDefinitionSD:  2,
+	VideoDefinitionHD:  3,
+	VideoDefinitionFHD: 4,
+	VideoDefinitionQHD: 5,
+}
+
+// Definitions 返回已经转码完成可以播放的清晰度，按清晰度从低到高排序
+func (v *VideoPreviewPlayInfo) Definitions() []VideoDefinition {
+	defs := []VideoDefinition{}
+	if v == nil {
+		return defs
+	}
+	for _, task := range v.TranscodingTaskList {
+		if task == nil || task.Status != VideoTranscodeStatusFinished || task.Url == "" {
+			continue
+		}
+		defs = append(defs, VideoDefinition(task.TemplateId))
+	}
+	sort.SliceStable(defs, func(i, j int) bool {
+		return videoDefinitionOrder[defs[i]] < videoDefinitionOrder[defs[j]]
+	})
+	return defs
+}
+
+// TranscodeStatus 返回指定清晰度的转码状态，没有该清晰度返回空字符串
+func (v *VideoPreviewPlayInfo) TranscodeStatus(def VideoDefinition) string {
+	if v == nil {
+		return ""
+	}
+	for _, task := range v.TranscodingTaskList {
+		if task != nil && task.TemplateId == string(def) {
+			return task.Status
+		}
+	}
+	return ""
+}
+
+// Subtitles 返回已经提取完成的字幕轨道，获取播放信息时需要设置 GetSubtitleInfo 为true
+func (v *VideoPreviewPlayInfo) Subtitles() []*SubtitleTrack {
+	tracks := []*SubtitleTrack{}
+	if v == nil {
+		return tracks
+	}
+	for _, task := range v.SubtitleTaskList {
+		if task == nil || task.Status != VideoTranscodeStatusFinished || task.Url == "" {
+			continue
+		}
+		tracks = append(tracks, &SubtitleTrack{
+			Language: task.Language,
+			Url:      task.Url,
+		})
+	}
+	return tracks
+}
+
+// FinishedTask 返回指定清晰度已经转码完成的任务，templateId 为空时返回清晰度最高的任务，没有则返回nil
+func (v *VideoPreviewPlayInfo) FinishedTask(templateId string) *VideoTranscodingTask {
+	if v == nil {
+		return nil
+	}
+	var best *VideoTranscodingTask
+	for _, task := range v.TranscodingTaskList {
+		if task == nil || task.Status != VideoTranscodeStatusFinished || task.Url == "" {
+			continue
+		}
+		if templateId != "" {
+			if task.TemplateId == templateId {
+				return task
+			}
+			continue
+		}
+		if best == nil || task.TemplateHeight > best.TemplateHeight {
+			best = task
+		}
+	}
+	return best
+}
+
+// GetVideoPreviewPlayInfo 获取视频转码后的播放信息，可以直接播放m3u8链接，无需下载原文件
+func (p *OpenPanClient) GetVideoPreviewPlayInfo(param *VideoPreviewPlayInfoParam) (*VideoPreviewPlayInfo, *apierror.ApiError) {
+	postData := map[string]interface{}{
+		"drive_id":          param.DriveId,
+		"file_id":           param.FileId,
+		"category":          "live_transcoding",
+		"get_subtitle_info": param.GetSubtitleInfo,
+	}
+	if param.TemplateId != "" {
+		postData["template_id"] = param.TemplateId
+	}
+	if param.UrlExpireSec > 0 {
+		postData["url_expire_sec"] = param.UrlExpireSec
+	}
+	r := &videoPreviewPlayInfoResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/getVideoPreviewPlayInfo", postData, r); err != nil {
+		return nil, err
+	}
+	if r.PlayInfo == nil {
+		return nil, apierror.NewFailedApiError("视频播放信息为空")
+	}
+	r.PlayInfo.DriveId = r.DriveId
+	r.PlayInfo.FileId = r.FileId
+	return r.PlayInfo, nil
+}
+
+// UpdateVideoRecord 更新视频播放进度，用于多设备同步观看进度。playCursor 和 duration 单位为秒
+func (p *OpenPanClient) UpdateVideoRecord(driveId, fileId string, playCursor, duration float64) (*VideoPlayRecord, *apierror.ApiError) {
+	postData := map[string]interface{}{
+		"drive_id":    driveId,
+		"file_id":     fileId,
+		"play_cursor": strconv.FormatFloat(playCursor, 'f', 3, 64),
+	}
+	if duration > 0 {
+		postData["duration"] = strconv.FormatFloat(duration, 'f', 3, 64)
+	}
+	r := &videoPlayRecordResult{}
+	if err := p.doRequest("/adrive/v1.0/openFile/video/updateRecord", postData, r); err != nil {
+		return nil, err
+	}
+	record := &VideoPlayRecord{
+		DriveId: r.DriveId,
+		FileId:  r.FileId,
+	}
+	record.PlayCursor, _ = strconv.ParseFloat(r.PlayCursor, 64)
+	record.Duration, _ = strconv.ParseFloat(r.Duration, 64)
+	return record, nil
+}
+
+// WaitVideoTranscode 请求视频转码并等待转码完成。获取播放信息时服务器会自动开始转码，
+// 每隔 interval 查询一次，直到 param.TemplateId 指定的清晰度(为空则任意清晰度)转码完成、转码失败或者 ctx 取消
+func (p *OpenPanClient) WaitVideoTranscode(ctx context.Context, param *VideoPreviewPlayInfoParam, interval time.Duration) (*VideoPreviewPlayInfo, *apierror.ApiError) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		info, err := p.GetVideoPreviewPlayInfo(param)
+		if err != nil {
+			return nil, err
+		}
+		if info.FinishedTask(param.TemplateId) != nil {
+			return info, nil
+		}
+		if param.TemplateId != "" && info.TranscodeStatus(VideoDefinition(param.TemplateId)) == VideoTranscodeStatusFailed {
+			return info, apierror.NewFailedApiError("视频转码失败：" + param.TemplateId)
+		}
+		select {
+		case <-ctx.Done():
+			return info, apierror.NewApiErrorWithError(ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+type (
+	// PageFetcher 获取marker对应的一页数据，返回下一页的marker，为空代表没有下一页。
+	// 获取到的数据由调用方在函数中自行保存
+	PageFetcher func(marker string) (nextMarker string, err *apierror.ApiError)
+
+	// Paginator 分页工具，所有使用 marker 翻页的接口共用，保证获取全部数据的行为一致：
+	// 任意一页出错都返回错误，服务器返回重复的 marker 时停止翻页，避免死循环
+	Paginator struct {
+		fetch  PageFetcher
+		marker string
+		seen   map[string]bool
+		done   bool
+	}
+)
+
+// NewPaginator 创建分页工具，marker 为起始页参数，为空代表从第一页开始
+func NewPaginator(marker string, fetch PageFetcher) *Paginator {
+	return &Paginator{
+		fetch:  fetch,
+		marker: marker,
+		seen:   map[string]bool{},
+	}
+}
+
+// HasNext 是否还有下一页
+func (pg *Paginator) HasNext() bool {
+	return !pg.done
+}
+
+// Marker 下一页的marker
+func (pg *Paginator) Marker() string {
+	return pg.marker
+}
+
+// Next 获取下一页
+func (pg *Paginator) Next() *apierror.ApiError {
+	if pg.done {
+		return nil
+	}
+	pg.seen[pg.marker] = true
+	next, err := pg.fetch(pg.marker)
+	if err != nil {
+		return err
+	}
+	if next == "" || pg.seen[next] {
+		pg.done = true
+	}
+	pg.marker = next
+	return nil
+}
+
+// All 获取剩余的所有页
+func (pg *Paginator) All() *apierror.ApiError {
+	for pg.HasNext() {
+		if err := pg.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"testing"
+)
+
+func TestPaginator(t *testing.T) {
+	pages := map[string]string{"": "m1", "m1": "m2", "m2": ""}
+	fetched := []string{}
+	pg := NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		fetched = append(fetched, marker)
+		return pages[marker], nil
+	})
+	if err := pg.All(); err != nil {
+		t.Fatal(err)
+	}
+	if len(fetched) != 3 || pg.HasNext() {
+		t.Fatalf("unexpected pages %v", fetched)
+	}
+
+	// 重复的marker不会死循环
+	count := 0
+	pg = NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		count++
+		return "same", nil
+	})
+	if err := pg.All(); err != nil || count != 2 {
+		t.Fatalf("unexpected count %d %v", count, err)
+	}
+
+	// 出错时返回错误
+	pg = NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+		if marker == "m1" {
+			return "", apierror.NewFailedApiError("failed")
+		}
+		return "m1", nil
+	})
+	if err := pg.All(); err == nil {
+		t.Fatal("expected error")
+	}
+}
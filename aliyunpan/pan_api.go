@@ -0,0 +1,93 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"io"
+)
+
+type (
+	// PanAPI 网盘接口，由 *PanClient 实现。
+	// 下游项目可以依赖该接口而不是 *PanClient，单元测试时使用 panmock 包中的模拟实现，无需访问网络，也可以实现其他的存储后端
+	PanAPI interface {
+		// 用户
+		GetUserInfo() (*UserInfo, *apierror.ApiError)
+
+		// 文件列表和文件信息
+		FileList(param *FileListParam) (*FileListResult, *apierror.ApiError)
+		FileListGetAll(param *FileListParam) (FileList, *apierror.ApiError)
+		FileInfoById(driveId, fileId string) (*FileEntity, *apierror.ApiError)
+		FileInfoByPath(driveId string, pathStr string) (*FileEntity, *apierror.ApiError)
+		FilesDirectoriesRecurseList(driveId string, path string, handleFileDirectoryFunc HandleFileDirectoryFunc) FileList
+		FilesDirectoriesRecurseListWithContext(ctx context.Context, driveId string, path string, handleFileDirectoryFunc HandleFileDirectoryFunc) (FileList, *apierror.ApiError)
+		FileGetLastCursor(driveId string) (string, *apierror.ApiError)
+		FileListDelta(param *FileListDeltaParam) (*FileListDeltaResult, *apierror.ApiError)
+		FileListDeltaGetAll(param *FileListDeltaParam) ([]*FileChangeEvent, string, *apierror.ApiError)
+		TreeSnapshot(driveId, pathStr string) (*TreeSnapshot, *apierror.ApiError)
+		ExportTree(driveId, pathStr string, w io.Writer, format ExportFormat) *apierror.ApiError
+		DedupeScan(param *DedupeScanParam) (*DedupeScanResult, *apierror.ApiError)
+
+		// 文件操作
+		Mkdir(driveId, parentFileId, dirName string) (*MkdirResult, *apierror.ApiError)
+		MkdirByFullPath(driveId, fullPath string) (*MkdirResult, *apierror.ApiError)
+		FileRename(driveId, renameFileId, newName string) (bool, *apierror.ApiError)
+		FileMove(param []*FileMoveParam) ([]*FileMoveResult, *apierror.ApiError)
+		FileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+		FileStarred(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+		FileUnstarred(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+
+		// 回收站
+		RecycleBinFileList(param *RecycleBinFileListParam) (*FileListResult, *apierror.ApiError)
+		RecycleBinFileListGetAll(param *RecycleBinFileListParam) (FileList, *apierror.ApiError)
+		RecycleBinFileDelete(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+		RecycleBinFileRestore(param []*FileBatchActionParam) ([]*FileBatchActionResult, *apierror.ApiError)
+		RecycleBinClean(param *RecycleBinCleanParam) (*RecycleBinCleanResult, *apierror.ApiError)
+
+		// 上传
+		CreateUploadFile(param *CreateFileUploadParam) (*CreateFileUploadResult, *apierror.ApiError)
+		GetUploadUrl(param *GetUploadUrlParam) (*GetUploadUrlResult, *apierror.ApiError)
+		UploadFileData(uploadUrl string, uploadFunc UploadFunc) *apierror.ApiError
+		UploadDataChunk(url string, data *FileUploadChunkData) *apierror.ApiError
+		CompleteUploadFile(param *CompleteUploadFileParam) (*CompleteUploadFileResult, *apierror.ApiError)
+
+		// 下载
+		GetFileDownloadUrl(param *GetFileDownloadUrlParam) (*GetFileDownloadUrlResult, *apierror.ApiError)
+		DownloadFileData(downloadFileUrl string, fileRange FileDownloadRange, downloadFunc DownloadFuncCallback) *apierror.ApiError
+		DownloadFileDataAndSave(downloadFileUrl string, fileRange FileDownloadRange, writerAt io.WriterAt) *apierror.ApiError
+
+		// 分享
+		ShareLinkList(userId string) ([]*ShareEntity, *apierror.ApiError)
+		ShareLinkCreate(param ShareCreateParam) (*ShareEntity, *apierror.ApiError)
+		ShareLinkCancel(shareIdList []string) ([]*ShareCancelResult, *apierror.ApiError)
+
+		// 相册
+		AlbumList(param *AlbumListParam) (*AlbumListResult, *apierror.ApiError)
+		AlbumListGetAll(param *AlbumListParam) (AlbumList, *apierror.ApiError)
+		AlbumCreate(param *AlbumCreateParam) (*AlbumEntity, *apierror.ApiError)
+		AlbumEdit(param *AlbumEditParam) (*AlbumEntity, *apierror.ApiError)
+		AlbumDelete(param *AlbumDeleteParam) (bool, *apierror.ApiError)
+		AlbumGet(param *AlbumGetParam) (*AlbumEntity, *apierror.ApiError)
+		AlbumShareCreate(param *AlbumShareCreateParam) (*AlbumShareCreateResult, *apierror.ApiError)
+		AlbumListFile(param *AlbumListFileParam) (*FileListResult, *apierror.ApiError)
+		AlbumListFileGetAll(param *AlbumListFileParam) (FileList, *apierror.ApiError)
+		AlbumAddFile(param *AlbumAddFileParam) (*FileList, *apierror.ApiError)
+		AlbumDeleteFile(param *AlbumDeleteFileParam) (bool, *apierror.ApiError)
+	}
+)
+
+// 编译期检查 *PanClient 实现了 PanAPI
+var _ PanAPI = (*PanClient)(nil)
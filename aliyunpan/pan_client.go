@@ -15,7 +15,11 @@
 package aliyunpan
 
 import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
 	"github.com/tickstep/library-go/requester"
+	"sync"
+	"time"
 )
 
 const (
@@ -27,28 +31,276 @@ var (
 )
 
 type (
+	// PanClient 网盘客户端，可以在多个 goroutine 中并发使用。
+	// 运行中更新token、开启或关闭对冲请求和文件名编码都是并发安全的，正在进行的请求使用调用时的配置
 	PanClient struct {
 		client     *requester.HTTPClient // http 客户端
+
+		// mu 保护下面的可变状态
+		mu sync.RWMutex
 		webToken WebLoginToken
 		appToken AppLoginToken
+
+		// hedgeDelay 对冲请求延迟，为0代表不开启
+		hedgeDelay time.Duration
+
+		// nameEncoding 是否开启文件名编码
+		nameEncoding bool
+		// lang 实体展示信息使用的语言
+		lang Language
+		// timeFormat 文件信息中时间字符串使用的时区和格式
+		timeFormat apiutil.TimeFormat
+
+		// defaults 列表类请求的默认参数
+		defaults RequestDefaults
+
+		// ctx 绑定的上下文，为nil代表没有绑定
+		ctx context.Context
+
+		// stats 流量和请求统计
+		stats *statsRecorder
+
+		// scheduler 请求调度器，为nil代表不限制并发
+		scheduler *RequestScheduler
+		// requestClass 发起请求使用的类别
+		requestClass RequestClass
+		// limiter 请求限速器，为nil代表不限速
+		limiter *RateLimiter
+
+		// metaStore 文件元数据缓存，为nil代表不使用缓存
+		metaStore MetaStore
+		// bypassCache 读取时跳过元数据缓存
+		bypassCache bool
+
+		// writes 最近一次写操作的时间，克隆出的客户端共享
+		writes *writeTracker
+		// consistency 写后读重试配置，MaxAttempts为0代表不重试
+		consistency ConsistencyRetry
+
+		// refresher token 自动刷新，为nil代表不自动刷新
+		refresher *tokenRefresher
+		// tokenHook token 刷新回调
+		tokenHook *tokenHook
+		// tokenStore token 存储，为nil代表不保存
+		tokenStore TokenStore
+		// refreshSkew 提前刷新 token 的时间，为0代表不提前刷新
+		refreshSkew time.Duration
+		// lifecycle 生命周期，Close 时关闭注册的子系统
+		lifecycle *lifecycle
+		// ownsLifecycle 是否拥有生命周期，只有拥有生命周期的客户端调用 Close 才会关闭
+		ownsLifecycle bool
+		// open 开放平台客户端，设置了开放平台token时部分文件接口通过它请求，为nil代表不使用
+		open *OpenPanClient
+		// device 设备会话，为nil代表请求不签名
+		device *DeviceSession
+		// appMode 使用手机客户端 token，请求使用手机客户端的接口地址和请求头
+		appMode bool
+		// challenge 风控验证回调
+		challenge *challengeHook
+		// share 匿名浏览分享链接的会话，为nil代表不是匿名分享客户端
+		share *shareSession
 	}
 )
 
 
 func NewPanClient(webToken WebLoginToken, appToken AppLoginToken) *PanClient {
-	client := requester.NewHTTPClient()
+	client := NewHTTPClient()
 
 	return &PanClient{
 		client: client,
 		webToken: webToken,
 		appToken: appToken,
+		defaults: DefaultRequestDefaults(),
+		stats: newStatsRecorder(),
+		writes: &writeTracker{},
+		tokenHook: &tokenHook{},
+		refreshSkew: DefaultTokenRefreshSkew,
+		lifecycle: newLifecycle(),
+		ownsLifecycle: true,
+		challenge: &challengeHook{},
 	}
 }
 
 func (pc *PanClient) UpdateToken(webToken WebLoginToken)  {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
 	pc.webToken = webToken
 }
 
 func (pc *PanClient) GetAccessToken() string {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
 	return pc.webToken.AccessToken
+}
+
+// CloneWithToken 派生一个使用指定token的客户端，共享http连接，并复制对冲请求、文件名编码等配置和绑定的上下文。
+// 用于同时代理多个用户请求的服务。派生的客户端不会自动刷新token，也不使用开放平台token和设备会话。风控验证回调继续使用，验证状态单独记录。
+// 派生的客户端有单独的生命周期，可以单独关闭，父客户端关闭时一起关闭
+func (pc *PanClient) CloneWithToken(webToken WebLoginToken) *PanClient {
+	c := pc.clone()
+	pc.forkLifecycle(c)
+	c.webToken = webToken
+	c.refresher = nil
+	c.tokenHook = &tokenHook{}
+	c.tokenStore = nil
+	c.open = nil
+	c.device = nil
+	c.challenge = pc.challenge.fork()
+	return c
+}
+
+// WithContext 派生一个绑定了 ctx 的客户端，共享http连接和配置。
+// ctx 取消后接口请求立即返回错误，已经发出的请求在后台完成后结果被丢弃。派生的客户端不能单独关闭
+func (pc *PanClient) WithContext(ctx context.Context) *PanClient {
+	c := pc.clone()
+	c.ctx = ctx
+	return c
+}
+
+// Context 返回绑定的上下文，没有绑定则返回 context.Background()
+func (pc *PanClient) Context() context.Context {
+	if pc.ctx == nil {
+		return context.Background()
+	}
+	return pc.ctx
+}
+
+func (pc *PanClient) clone() *PanClient {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return &PanClient{
+		client:       pc.client,
+		webToken:     pc.webToken,
+		appToken:     pc.appToken,
+		hedgeDelay:   pc.hedgeDelay,
+		nameEncoding: pc.nameEncoding,
+		lang:         pc.lang,
+		defaults:     pc.defaults,
+		ctx:          pc.ctx,
+		stats:        pc.stats,
+		scheduler:    pc.scheduler,
+		requestClass: pc.requestClass,
+		limiter:      pc.limiter,
+		metaStore:    pc.metaStore,
+		bypassCache:  pc.bypassCache,
+		writes:       pc.writes,
+		consistency:  pc.consistency,
+		refresher:    pc.refresher,
+		tokenHook:    pc.tokenHook,
+		tokenStore:   pc.tokenStore,
+		refreshSkew:  pc.refreshSkew,
+		lifecycle:    pc.lifecycle,
+		open:         pc.open,
+		device:       pc.device,
+		appMode:      pc.appMode,
+		challenge:    pc.challenge,
+		share:        pc.share,
+	}
+}
+
+// fetch 发起请求，绑定的上下文取消后立即返回
+func (pc *PanClient) fetch(method string, urlStr string, post interface{}, header map[string]string) ([]byte, error) {
+	return pc.fetchWithContext(pc.doFetch, method, urlStr, post, header)
+}
+
+// fetchWithContext 使用 do 发起请求，绑定的上下文取消后立即返回
+func (pc *PanClient) fetchWithContext(do fetchFunc, method string, urlStr string, post interface{}, header map[string]string) ([]byte, error) {
+	if pc.ctx == nil {
+		return do(method, urlStr, post, header)
+	}
+	if err := pc.ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 缓冲为1，上下文取消后请求返回时不会阻塞
+	resultChan := make(chan *hedgedFetchResult, 1)
+	go func() {
+		body, err := do(method, urlStr, post, header)
+		resultChan <- &hedgedFetchResult{body: body, err: err}
+	}()
+	select {
+	case r := <-resultChan:
+		return r.body, r.err
+	case <-pc.ctx.Done():
+		return nil, pc.ctx.Err()
+	}
+}
+
+// authorizationStr 请求头使用的授权信息
+func (pc *PanClient) authorizationStr() string {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.webToken.GetAuthorizationStr()
+}
+
+// EnableNameEncoding 开启文件名编码。开启后上传、创建文件夹、重命名时，文件名中网盘不允许的字符会被替换为全角字符，
+// 获取文件列表时再还原，保证包含特殊字符的文件可以正确上传下载
+func (pc *PanClient) EnableNameEncoding(enabled bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.nameEncoding = enabled
+}
+
+// isNameEncoding 是否开启了文件名编码
+func (pc *PanClient) isNameEncoding() bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.nameEncoding
+}
+
+// encodeFileName 如果开启了文件名编码，则编码文件名
+func (pc *PanClient) encodeFileName(name string) string {
+	if !pc.isNameEncoding() {
+		return name
+	}
+	return apiutil.EncodeFileName(name)
+}
+
+// decodeFileName 如果开启了文件名编码，则还原文件名
+func (pc *PanClient) decodeFileName(name string) string {
+	if !pc.isNameEncoding() {
+		return name
+	}
+	return apiutil.DecodeFileName(name)
+}
+
+// SetTimeFormat 设置文件信息中时间的时区和格式，零值使用进程本地时区和 apiutil.DefaultTimeLayout。
+// 运行在UTC时区的服务可以指定用户所在的时区，CreatedTime、UpdatedTime 也会转换到该时区
+func (pc *PanClient) SetTimeFormat(tf apiutil.TimeFormat) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.timeFormat = tf
+}
+
+// TimeFormat 文件信息中时间使用的时区和格式
+func (pc *PanClient) TimeFormat() apiutil.TimeFormat {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.timeFormat
+}
+
+// applyTimeFormat 按设置的时区和格式重新转换服务器返回的时间
+func (pc *PanClient) applyTimeFormat(fe *FileEntity) {
+	tf := pc.TimeFormat()
+	if fe.Raw == nil || tf == (apiutil.TimeFormat{}) {
+		return
+	}
+	fe.CreatedAt = tf.FormatUtc(fe.Raw.CreatedAt)
+	fe.UpdatedAt = tf.FormatUtc(fe.Raw.UpdatedAt)
+	fe.TrashedAt = tf.FormatUtc(fe.Raw.TrashedAt)
+	fe.CreatedTime = tf.Time(fe.Raw.CreatedAt)
+	fe.UpdatedTime = tf.Time(fe.Raw.UpdatedAt)
+}
+
+// newFileEntity 创建文件信息，并还原编码的文件名
+func (pc *PanClient) newFileEntity(f *FileEntityRaw) *FileEntity {
+	fe := createFileEntity(f)
+	if fe != nil && pc.isNameEncoding() {
+		fe.FileName = apiutil.DecodeFileName(fe.FileName)
+		fe.Path = fe.FileName
+	}
+	if fe != nil {
+		fe.lang = pc.Language()
+		pc.applyTimeFormat(fe)
+	}
+	return fe
 }
\ No newline at end of file
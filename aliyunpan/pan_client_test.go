@@ -0,0 +1,494 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+func TestPanClientDerive(t *testing.T) {
+	pc := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a"}, AppLoginToken{})
+	pc.EnableNameEncoding(true)
+
+	c := pc.CloneWithToken(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "b"})
+	if c.GetAccessToken() != "b" || pc.GetAccessToken() != "a" || !c.isNameEncoding() {
+		t.Fatal("unexpected cloned client")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c = pc.WithContext(ctx)
+	if c.Context() != ctx || pc.Context() != context.Background() {
+		t.Fatal("unexpected context")
+	}
+	if _, err := c.fetch("POST", "http://127.0.0.1:1/", nil, nil); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPanClientRequestDefaults(t *testing.T) {
+	pc := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	if pc.pageSize(0) != DefaultFileListLimit || pc.pageSize(50) != 50 {
+		t.Fatal("unexpected default page size")
+	}
+	if err := pc.SetRequestDefaults(RequestDefaults{PageSize: MaxFileListLimit + 1}); err == nil || err.Code != apierror.ApiCodeBadRequest {
+		t.Fatalf("expected page size error, got %v", err)
+	}
+	if err := pc.SetRequestDefaults(RequestDefaults{PageSize: 200}); err != nil {
+		t.Fatal(err)
+	}
+	d := pc.RequestDefaults()
+	if d.PageSize != 200 || d.UrlExpireSec != DefaultRequestDefaults().UrlExpireSec {
+		t.Fatalf("unexpected defaults %+v", d)
+	}
+	// 相簿默认使用更大的视频缩略图，自定义后使用自定义的参数
+	if d.albumVideoThumbnailProcess() != albumVideoThumbnailProcess {
+		t.Fatal("expected album video thumbnail override")
+	}
+	if d := (RequestDefaults{VideoThumbnailProcess: "custom"}); d.albumVideoThumbnailProcess() != "custom" {
+		t.Fatal("expected custom video thumbnail process")
+	}
+	if pc.CloneWithToken(WebLoginToken{}).pageSize(0) != 200 {
+		t.Fatal("clone should keep defaults")
+	}
+	if (&PanClient{}).pageSize(0) != DefaultFileListLimit {
+		t.Fatal("zero client should use library defaults")
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	p := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	c := p.CloneWithToken(WebLoginToken{AccessToken: "other"})
+	p.fetch("POST", server.URL+"/v2/file/get?x=1", map[string]string{}, nil)
+	c.fetch("POST", server.URL+"/v2/file/get", map[string]string{}, nil)
+	p.stats.cache(true)
+	p.stats.cache(false)
+
+	s := p.Stats()
+	if s.Requests["/v2/file/get"] != 2 || s.TotalRequests() != 2 || s.BytesDown != 22 || s.CacheHitRate() != 0.5 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	p.ResetStats()
+	if c.Stats().TotalRequests() != 0 {
+		t.Fatal("expected stats to be reset")
+	}
+}
+
+func TestRetryAfterWrite(t *testing.T) {
+	p := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	p.SetConsistencyRetry(ConsistencyRetry{MaxAttempts: 4, InitialDelay: time.Millisecond, Window: time.Minute})
+
+	calls := 0
+	notFoundTwice := func() (*FileEntity, *apierror.ApiError) {
+		calls++
+		if calls <= 2 {
+			return nil, apierror.NewApiError(apierror.ApiCodeFileNotFoundCode, "文件不存在")
+		}
+		return &FileEntity{FileId: "f1"}, nil
+	}
+
+	// 没有写操作时不重试
+	if _, err := p.retryAfterWrite(notFoundTwice); err == nil || calls != 1 {
+		t.Fatalf("expected no retry, calls %d", calls)
+	}
+
+	calls = 0
+	p.childrenChanged("d1", "root")
+	fe, err := p.CloneWithToken(WebLoginToken{}).retryAfterWrite(notFoundTwice)
+	if err != nil || fe.FileId != "f1" || calls != 3 {
+		t.Fatalf("unexpected result %v %v, calls %d", fe, err, calls)
+	}
+
+	calls = -10
+	if _, err := p.retryAfterWrite(notFoundTwice); err == nil || calls != -6 {
+		t.Fatalf("expected 4 attempts, calls %d", calls)
+	}
+}
+
+func TestAutoRefreshToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"AccessTokenInvalid","message":"AccessToken is invalid"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r1"}, AppLoginToken{})
+	header := map[string]string{"authorization": p.authorizationStr()}
+	body, _ := p.fetch("POST", server.URL, map[string]string{}, header)
+	if !isTokenInvalidBody(body) {
+		t.Fatalf("expected token error without auto refresh, got %s", body)
+	}
+
+	refreshed := 0
+	saved := ""
+	p.SetTokenRefreshFunc(func(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
+		refreshed++
+		if refreshToken != "r1" {
+			t.Errorf("unexpected refresh token %s", refreshToken)
+		}
+		return &WebLoginToken{AccessTokenType: "Bearer", AccessToken: "new", RefreshToken: "r2"}, nil
+	}, func(token WebLoginToken) {
+		saved = token.RefreshToken
+	})
+	c := p.WithContext(context.Background())
+	// 在派生的客户端上注册回调，原客户端刷新时也会调用
+	hooked := ""
+	c.OnTokenRefreshed(func(token WebLoginToken) {
+		hooked = token.AccessToken
+	})
+	body, err := p.fetch("POST", server.URL, map[string]string{}, header)
+	if err != nil || string(body) != `{"ok":true}` {
+		t.Fatalf("unexpected result %s %v", body, err)
+	}
+	if p.GetAccessToken() != "new" || saved != "r2" || hooked != "new" || header["authorization"] != "Bearer old" {
+		t.Fatalf("unexpected token state %s %s %s", p.GetAccessToken(), saved, header["authorization"])
+	}
+
+	// 共享的客户端使用已经刷新的 token，不会再次刷新
+	body, _ = c.fetch("POST", server.URL, map[string]string{}, header)
+	if string(body) != `{"ok":true}` || refreshed != 1 || c.GetAccessToken() != "new" {
+		t.Fatalf("unexpected shared refresh %s, refreshed %d", body, refreshed)
+	}
+	if other := p.CloneWithToken(WebLoginToken{}); other.refresher != nil || other.tokenHook == p.tokenHook {
+		t.Fatal("clone with other token should not refresh")
+	}
+}
+
+func TestPanClientClose(t *testing.T) {
+	p := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	c := p.WithContext(context.Background())
+
+	order := []string{}
+	p.RegisterCloser(CloserFunc(func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	}))
+	unregister := c.RegisterCloser(CloserFunc(func(ctx context.Context) error {
+		order = append(order, "removed")
+		return nil
+	}))
+	c.RegisterCloser(CloserFunc(func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	}))
+	unregister()
+
+	// WithContext 派生的客户端不能单独关闭
+	if err := c.Close(context.Background()); err != nil || p.isClosed() || len(order) != 0 {
+		t.Fatalf("close derived client should be a no-op, err %v order %v", err, order)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "second" || order[1] != "first" {
+		t.Fatalf("unexpected close order %v", order)
+	}
+	if _, err := p.fetch("POST", "http://127.0.0.1:1/", nil, nil); err != ErrClientClosed {
+		t.Fatalf("expected ErrClientClosed, got %v", err)
+	}
+	select {
+	case <-p.Done():
+	default:
+		t.Fatal("expected done channel to be closed")
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal("close twice should return nil")
+	}
+
+	// CloneWithToken 派生的客户端单独关闭，父客户端关闭时一起关闭
+	p = NewPanClient(WebLoginToken{}, AppLoginToken{})
+	c1 := p.CloneWithToken(WebLoginToken{AccessToken: "1"})
+	c2 := p.CloneWithToken(WebLoginToken{AccessToken: "2"})
+	closed := 0
+	c2.RegisterCloser(CloserFunc(func(ctx context.Context) error {
+		closed++
+		return nil
+	}))
+	if err := c1.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !c1.isClosed() || p.isClosed() || c2.isClosed() {
+		t.Fatal("closing a clone should not close its parent or siblings")
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !c2.isClosed() || closed != 1 {
+		t.Fatalf("clone should be closed with its parent, closed %d", closed)
+	}
+	if c3 := p.CloneWithToken(WebLoginToken{}); !c3.isClosed() {
+		t.Fatal("clone of a closed client should be closed")
+	}
+
+	// 超时后不再等待
+	p = NewPanClient(WebLoginToken{}, AppLoginToken{})
+	block := make(chan struct{})
+	defer close(block)
+	p.RegisterCloser(CloserFunc(func(ctx context.Context) error {
+		<-block
+		return nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+type countingTransport struct {
+	base  http.RoundTripper
+	count *int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(c.count, 1)
+	return c.base.RoundTrip(req)
+}
+
+func TestSetTransportWrapper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+
+	var count int32
+	SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return &countingTransport{base: base, count: &count}
+	})
+	if _, err := client.Fetch("GET", srv.URL, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHTTPClient().Fetch("GET", srv.URL, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&count) != 2 {
+		t.Fatalf("expected 2 wrapped requests, got %d", count)
+	}
+
+	// 取消包装
+	SetTransportWrapper(nil)
+	if _, err := client.Fetch("GET", srv.URL, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&count) != 2 {
+		t.Fatalf("expected wrapper removed, got %d", count)
+	}
+}
+
+func TestProactiveTokenRefresh(t *testing.T) {
+	var unauthorized int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer new" {
+			atomic.AddInt32(&unauthorized, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"AccessTokenExpired","message":"AccessToken expired"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	expiring := time.Now().Add(time.Minute).Format("2006-01-02 15:04:05")
+	token := WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r1", ExpireTime: expiring}
+	if token.ExpiresAt().IsZero() || !(&WebLoginToken{}).ExpiresAt().IsZero() {
+		t.Fatal("unexpected ExpiresAt")
+	}
+	refreshed := 0
+	refresh := func(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
+		refreshed++
+		return &WebLoginToken{AccessTokenType: "Bearer", AccessToken: "new", RefreshToken: "r2", ExpireTime: time.Now().Add(2 * time.Hour).Format("2006-01-02 15:04:05")}, nil
+	}
+
+	// token 在默认的提前刷新时间内过期，请求前先刷新
+	p := NewPanClient(token, AppLoginToken{})
+	p.SetTokenRefreshFunc(refresh, nil)
+	body, err := p.fetch("POST", server.URL, map[string]string{}, map[string]string{"authorization": p.authorizationStr()})
+	if err != nil || string(body) != `{"ok":true}` || refreshed != 1 || atomic.LoadInt32(&unauthorized) != 0 {
+		t.Fatalf("unexpected result %s %v, refreshed %d, unauthorized %d", body, err, refreshed, unauthorized)
+	}
+	// 刷新后的 token 没有即将过期，不会再次刷新
+	p.fetch("POST", server.URL, map[string]string{}, map[string]string{"authorization": p.authorizationStr()})
+	if refreshed != 1 {
+		t.Fatalf("unexpected refresh count %d", refreshed)
+	}
+
+	// 关闭提前刷新后，只在请求失败后刷新
+	c := NewPanClient(token, AppLoginToken{})
+	c.SetTokenRefreshFunc(refresh, nil)
+	c.SetTokenRefreshSkew(0)
+	c.fetch("POST", server.URL, map[string]string{}, map[string]string{"authorization": c.authorizationStr()})
+	if refreshed != 2 || atomic.LoadInt32(&unauthorized) != 1 {
+		t.Fatalf("expected reactive refresh, refreshed %d, unauthorized %d", refreshed, unauthorized)
+	}
+}
+
+func TestAppTokenClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer app1" || r.Header.Get("x-canary") != appCanary ||
+			r.Header.Get("user-agent") != appUserAgent || r.Header.Get("origin") != "" || r.Header.Get("referer") != "" {
+			t.Errorf("unexpected app request header %v", r.Header)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	p := NewPanClientWithAppToken(AppLoginToken{AccessToken: "app1", RefreshToken: "r1"})
+	if !p.IsAppMode() || !p.HasWebToken() {
+		t.Fatal("expected app mode client")
+	}
+	header := map[string]string{"authorization": p.authorizationStr(), "origin": "https://www.aliyundrive.com", "referer": "https://www.aliyundrive.com/"}
+	body, err := p.fetch("POST", server.URL, map[string]string{}, header)
+	if err != nil || string(body) != `{"ok":true}` || header["origin"] == "" {
+		t.Fatalf("unexpected result %s %v", body, err)
+	}
+
+	urlStr, _ := p.appRequest(API_URL+"/adrive/v3/file/list", map[string]string{})
+	if urlStr != APP_API_URL+"/adrive/v3/file/list" {
+		t.Fatalf("unexpected app url %s", urlStr)
+	}
+	if urlStr, _ = NewPanClient(WebLoginToken{}, AppLoginToken{}).appRequest(API_URL+"/x", nil); urlStr != API_URL+"/x" {
+		t.Fatalf("web client should not rewrite url %s", urlStr)
+	}
+
+	p.UpdateAppToken(AppLoginToken{AccessToken: "app2", RefreshToken: "r2"})
+	if p.GetAccessToken() != "app2" || p.AppToken().RefreshToken != "r2" || !p.WithContext(context.Background()).IsAppMode() {
+		t.Fatalf("unexpected app token %v", p.AppToken())
+	}
+}
+
+func TestConcurrentTokenRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"AccessTokenInvalid","message":"AccessToken is invalid"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	var refreshed int32
+	fail := int32(1)
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r1"}, AppLoginToken{})
+	p.SetTokenRefreshFunc(func(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
+		atomic.AddInt32(&refreshed, 1)
+		// 等待其他请求进入刷新流程
+		time.Sleep(50 * time.Millisecond)
+		if refreshToken != "r1" {
+			t.Errorf("refresh token reused %s", refreshToken)
+		}
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, apierror.NewFailedApiError("network error")
+		}
+		return &WebLoginToken{AccessTokenType: "Bearer", AccessToken: "new", RefreshToken: "r2"}, nil
+	}, nil)
+
+	run := func(expectOk bool) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				header := map[string]string{"authorization": "Bearer old"}
+				body, err := p.fetch("POST", server.URL, map[string]string{}, header)
+				if ok := err == nil && string(body) == `{"ok":true}`; ok != expectOk {
+					t.Errorf("unexpected result %s %v", body, err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// 刷新失败时等待的请求直接返回失败，不会重复刷新
+	run(false)
+	if n := atomic.LoadInt32(&refreshed); n != 1 {
+		t.Fatalf("expected one failed refresh, got %d", n)
+	}
+
+	// 之后的请求重新刷新，所有请求共享刷新结果
+	atomic.StoreInt32(&fail, 0)
+	run(true)
+	if n := atomic.LoadInt32(&refreshed); n != 2 || p.GetAccessToken() != "new" {
+		t.Fatalf("expected one successful refresh, got %d %s", n, p.GetAccessToken())
+	}
+}
+
+type fixedBodyTransport struct {
+	body string
+}
+
+func (f *fixedBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: 200, Status: "200 OK", Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(f.body)), Request: req}, nil
+}
+
+func TestListDecodeFileNames(t *testing.T) {
+	SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return &fixedBodyTransport{body: `{"items":[{"drive_id":"d1","file_id":"f1","name":"a：b.txt","type":"file"}]}`}
+	})
+	defer SetTransportWrapper(nil)
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	p.EnableNameEncoding(true)
+	r, err := p.FileSearch(&FileSearchParam{DriveId: "d1", Query: NameMatchQuery("a")})
+	if err != nil || len(r.FileList) != 1 || r.FileList[0].FileName != "a:b.txt" {
+		t.Fatalf("unexpected search result %+v %v", r, err)
+	}
+	r, err = p.AlbumListFile(&AlbumListFileParam{AlbumId: "a1"})
+	if err != nil || len(r.FileList) != 1 || r.FileList[0].FileName != "a:b.txt" {
+		t.Fatalf("unexpected album result %+v %v", r, err)
+	}
+}
+
+func TestPanClientTimeFormat(t *testing.T) {
+	SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return &fixedBodyTransport{body: `{"items":[{"drive_id":"d1","file_id":"f1","name":"a.txt","type":"file","updated_at":"2021-07-29T23:18:07.000Z"}]}`}
+	})
+	defer SetTransportWrapper(nil)
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "web"}, AppLoginToken{})
+	loc := time.FixedZone("UTC+8", 8*3600)
+	p.SetTimeFormat(apiutil.TimeFormat{Location: loc, Layout: "2006/01/02 15:04"})
+	r, err := p.FileList(&FileListParam{DriveId: "d1", ParentFileId: DefaultRootParentFileId})
+	if err != nil || len(r.FileList) != 1 {
+		t.Fatalf("unexpected list result %+v %v", r, err)
+	}
+	fe := r.FileList[0]
+	if fe.UpdatedAt != "2021/07/30 07:18" || fe.UpdatedTime.Location() != loc {
+		t.Fatalf("unexpected time %s %s", fe.UpdatedAt, fe.UpdatedTime)
+	}
+	if !fe.UpdatedTime.Equal(time.Date(2021, 7, 29, 23, 18, 7, 0, time.UTC)) {
+		t.Fatalf("unexpected updated time %s", fe.UpdatedTime)
+	}
+}
@@ -0,0 +1,481 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package panmock 网盘接口 aliyunpan.PanAPI 的模拟实现，用于下游项目的单元测试
+package panmock
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"io"
+	"sync"
+)
+
+type (
+	// PanClient 模拟网盘客户端。每个接口对应一个 XxxFunc 字段，设置后调用该函数，
+	// 没有设置则返回 ErrNotImplemented 错误。所有调用都会被记录，可以通过 Calls 查询调用次数
+	PanClient struct {
+		GetUserInfoFunc                            func() (*aliyunpan.UserInfo, *apierror.ApiError)
+		FileListFunc                               func(*aliyunpan.FileListParam) (*aliyunpan.FileListResult, *apierror.ApiError)
+		FileListGetAllFunc                         func(*aliyunpan.FileListParam) (aliyunpan.FileList, *apierror.ApiError)
+		FileInfoByIdFunc                           func(string, string) (*aliyunpan.FileEntity, *apierror.ApiError)
+		FileInfoByPathFunc                         func(string, string) (*aliyunpan.FileEntity, *apierror.ApiError)
+		FilesDirectoriesRecurseListFunc            func(string, string, aliyunpan.HandleFileDirectoryFunc) aliyunpan.FileList
+		FilesDirectoriesRecurseListWithContextFunc func(context.Context, string, string, aliyunpan.HandleFileDirectoryFunc) (aliyunpan.FileList, *apierror.ApiError)
+		FileGetLastCursorFunc                      func(string) (string, *apierror.ApiError)
+		FileListDeltaFunc                          func(*aliyunpan.FileListDeltaParam) (*aliyunpan.FileListDeltaResult, *apierror.ApiError)
+		FileListDeltaGetAllFunc                    func(*aliyunpan.FileListDeltaParam) ([]*aliyunpan.FileChangeEvent, string, *apierror.ApiError)
+		TreeSnapshotFunc                           func(string, string) (*aliyunpan.TreeSnapshot, *apierror.ApiError)
+		ExportTreeFunc                             func(string, string, io.Writer, aliyunpan.ExportFormat) *apierror.ApiError
+		DedupeScanFunc                             func(*aliyunpan.DedupeScanParam) (*aliyunpan.DedupeScanResult, *apierror.ApiError)
+		MkdirFunc                                  func(string, string, string) (*aliyunpan.MkdirResult, *apierror.ApiError)
+		MkdirByFullPathFunc                        func(string, string) (*aliyunpan.MkdirResult, *apierror.ApiError)
+		FileRenameFunc                             func(string, string, string) (bool, *apierror.ApiError)
+		FileMoveFunc                               func([]*aliyunpan.FileMoveParam) ([]*aliyunpan.FileMoveResult, *apierror.ApiError)
+		FileDeleteFunc                             func([]*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError)
+		FileStarredFunc                            func([]*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError)
+		FileUnstarredFunc                          func([]*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError)
+		RecycleBinFileListFunc                     func(*aliyunpan.RecycleBinFileListParam) (*aliyunpan.FileListResult, *apierror.ApiError)
+		RecycleBinFileListGetAllFunc               func(*aliyunpan.RecycleBinFileListParam) (aliyunpan.FileList, *apierror.ApiError)
+		RecycleBinFileDeleteFunc                   func([]*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError)
+		RecycleBinFileRestoreFunc                  func([]*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError)
+		RecycleBinCleanFunc                        func(*aliyunpan.RecycleBinCleanParam) (*aliyunpan.RecycleBinCleanResult, *apierror.ApiError)
+		CreateUploadFileFunc                       func(*aliyunpan.CreateFileUploadParam) (*aliyunpan.CreateFileUploadResult, *apierror.ApiError)
+		GetUploadUrlFunc                           func(*aliyunpan.GetUploadUrlParam) (*aliyunpan.GetUploadUrlResult, *apierror.ApiError)
+		UploadFileDataFunc                         func(string, aliyunpan.UploadFunc) *apierror.ApiError
+		UploadDataChunkFunc                        func(string, *aliyunpan.FileUploadChunkData) *apierror.ApiError
+		CompleteUploadFileFunc                     func(*aliyunpan.CompleteUploadFileParam) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError)
+		GetFileDownloadUrlFunc                     func(*aliyunpan.GetFileDownloadUrlParam) (*aliyunpan.GetFileDownloadUrlResult, *apierror.ApiError)
+		DownloadFileDataFunc                       func(string, aliyunpan.FileDownloadRange, aliyunpan.DownloadFuncCallback) *apierror.ApiError
+		DownloadFileDataAndSaveFunc                func(string, aliyunpan.FileDownloadRange, io.WriterAt) *apierror.ApiError
+		ShareLinkListFunc                          func(string) ([]*aliyunpan.ShareEntity, *apierror.ApiError)
+		ShareLinkCreateFunc                        func(aliyunpan.ShareCreateParam) (*aliyunpan.ShareEntity, *apierror.ApiError)
+		ShareLinkCancelFunc                        func([]string) ([]*aliyunpan.ShareCancelResult, *apierror.ApiError)
+		AlbumListFunc                              func(*aliyunpan.AlbumListParam) (*aliyunpan.AlbumListResult, *apierror.ApiError)
+		AlbumListGetAllFunc                        func(*aliyunpan.AlbumListParam) (aliyunpan.AlbumList, *apierror.ApiError)
+		AlbumCreateFunc                            func(*aliyunpan.AlbumCreateParam) (*aliyunpan.AlbumEntity, *apierror.ApiError)
+		AlbumEditFunc                              func(*aliyunpan.AlbumEditParam) (*aliyunpan.AlbumEntity, *apierror.ApiError)
+		AlbumDeleteFunc                            func(*aliyunpan.AlbumDeleteParam) (bool, *apierror.ApiError)
+		AlbumGetFunc                               func(*aliyunpan.AlbumGetParam) (*aliyunpan.AlbumEntity, *apierror.ApiError)
+		AlbumShareCreateFunc                       func(*aliyunpan.AlbumShareCreateParam) (*aliyunpan.AlbumShareCreateResult, *apierror.ApiError)
+		AlbumListFileFunc                          func(*aliyunpan.AlbumListFileParam) (*aliyunpan.FileListResult, *apierror.ApiError)
+		AlbumListFileGetAllFunc                    func(*aliyunpan.AlbumListFileParam) (aliyunpan.FileList, *apierror.ApiError)
+		AlbumAddFileFunc                           func(*aliyunpan.AlbumAddFileParam) (*aliyunpan.FileList, *apierror.ApiError)
+		AlbumDeleteFileFunc                        func(*aliyunpan.AlbumDeleteFileParam) (bool, *apierror.ApiError)
+
+		mu    sync.Mutex
+		calls map[string]int
+	}
+)
+
+var (
+	// ErrNotImplemented 没有设置模拟函数时返回的错误
+	ErrNotImplemented = apierror.NewFailedApiError("panmock: method not implemented")
+)
+
+// 编译期检查 *PanClient 实现了 aliyunpan.PanAPI
+var _ aliyunpan.PanAPI = (*PanClient)(nil)
+
+// Calls 返回指定方法被调用的次数
+func (m *PanClient) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[method]
+}
+
+func (m *PanClient) record(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+	m.calls[method]++
+}
+
+func (m *PanClient) GetUserInfo() (*aliyunpan.UserInfo, *apierror.ApiError) {
+	m.record("GetUserInfo")
+	if m.GetUserInfoFunc != nil {
+		return m.GetUserInfoFunc()
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileList(param *aliyunpan.FileListParam) (*aliyunpan.FileListResult, *apierror.ApiError) {
+	m.record("FileList")
+	if m.FileListFunc != nil {
+		return m.FileListFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileListGetAll(param *aliyunpan.FileListParam) (aliyunpan.FileList, *apierror.ApiError) {
+	m.record("FileListGetAll")
+	if m.FileListGetAllFunc != nil {
+		return m.FileListGetAllFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileInfoById(driveId string, fileId string) (*aliyunpan.FileEntity, *apierror.ApiError) {
+	m.record("FileInfoById")
+	if m.FileInfoByIdFunc != nil {
+		return m.FileInfoByIdFunc(driveId, fileId)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileInfoByPath(driveId string, pathStr string) (*aliyunpan.FileEntity, *apierror.ApiError) {
+	m.record("FileInfoByPath")
+	if m.FileInfoByPathFunc != nil {
+		return m.FileInfoByPathFunc(driveId, pathStr)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FilesDirectoriesRecurseList(driveId string, path string, handleFileDirectoryFunc aliyunpan.HandleFileDirectoryFunc) aliyunpan.FileList {
+	m.record("FilesDirectoriesRecurseList")
+	if m.FilesDirectoriesRecurseListFunc != nil {
+		return m.FilesDirectoriesRecurseListFunc(driveId, path, handleFileDirectoryFunc)
+	}
+	return nil
+}
+
+func (m *PanClient) FilesDirectoriesRecurseListWithContext(ctx context.Context, driveId string, path string, handleFileDirectoryFunc aliyunpan.HandleFileDirectoryFunc) (aliyunpan.FileList, *apierror.ApiError) {
+	m.record("FilesDirectoriesRecurseListWithContext")
+	if m.FilesDirectoriesRecurseListWithContextFunc != nil {
+		return m.FilesDirectoriesRecurseListWithContextFunc(ctx, driveId, path, handleFileDirectoryFunc)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileGetLastCursor(driveId string) (string, *apierror.ApiError) {
+	m.record("FileGetLastCursor")
+	if m.FileGetLastCursorFunc != nil {
+		return m.FileGetLastCursorFunc(driveId)
+	}
+	return "", ErrNotImplemented
+}
+
+func (m *PanClient) FileListDelta(param *aliyunpan.FileListDeltaParam) (*aliyunpan.FileListDeltaResult, *apierror.ApiError) {
+	m.record("FileListDelta")
+	if m.FileListDeltaFunc != nil {
+		return m.FileListDeltaFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileListDeltaGetAll(param *aliyunpan.FileListDeltaParam) ([]*aliyunpan.FileChangeEvent, string, *apierror.ApiError) {
+	m.record("FileListDeltaGetAll")
+	if m.FileListDeltaGetAllFunc != nil {
+		return m.FileListDeltaGetAllFunc(param)
+	}
+	return nil, "", ErrNotImplemented
+}
+
+func (m *PanClient) TreeSnapshot(driveId string, pathStr string) (*aliyunpan.TreeSnapshot, *apierror.ApiError) {
+	m.record("TreeSnapshot")
+	if m.TreeSnapshotFunc != nil {
+		return m.TreeSnapshotFunc(driveId, pathStr)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) ExportTree(driveId string, pathStr string, w io.Writer, format aliyunpan.ExportFormat) *apierror.ApiError {
+	m.record("ExportTree")
+	if m.ExportTreeFunc != nil {
+		return m.ExportTreeFunc(driveId, pathStr, w, format)
+	}
+	return ErrNotImplemented
+}
+
+func (m *PanClient) DedupeScan(param *aliyunpan.DedupeScanParam) (*aliyunpan.DedupeScanResult, *apierror.ApiError) {
+	m.record("DedupeScan")
+	if m.DedupeScanFunc != nil {
+		return m.DedupeScanFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) Mkdir(driveId string, parentFileId string, dirName string) (*aliyunpan.MkdirResult, *apierror.ApiError) {
+	m.record("Mkdir")
+	if m.MkdirFunc != nil {
+		return m.MkdirFunc(driveId, parentFileId, dirName)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) MkdirByFullPath(driveId string, fullPath string) (*aliyunpan.MkdirResult, *apierror.ApiError) {
+	m.record("MkdirByFullPath")
+	if m.MkdirByFullPathFunc != nil {
+		return m.MkdirByFullPathFunc(driveId, fullPath)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileRename(driveId string, renameFileId string, newName string) (bool, *apierror.ApiError) {
+	m.record("FileRename")
+	if m.FileRenameFunc != nil {
+		return m.FileRenameFunc(driveId, renameFileId, newName)
+	}
+	return false, ErrNotImplemented
+}
+
+func (m *PanClient) FileMove(param []*aliyunpan.FileMoveParam) ([]*aliyunpan.FileMoveResult, *apierror.ApiError) {
+	m.record("FileMove")
+	if m.FileMoveFunc != nil {
+		return m.FileMoveFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileDelete(param []*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError) {
+	m.record("FileDelete")
+	if m.FileDeleteFunc != nil {
+		return m.FileDeleteFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileStarred(param []*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError) {
+	m.record("FileStarred")
+	if m.FileStarredFunc != nil {
+		return m.FileStarredFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) FileUnstarred(param []*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError) {
+	m.record("FileUnstarred")
+	if m.FileUnstarredFunc != nil {
+		return m.FileUnstarredFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) RecycleBinFileList(param *aliyunpan.RecycleBinFileListParam) (*aliyunpan.FileListResult, *apierror.ApiError) {
+	m.record("RecycleBinFileList")
+	if m.RecycleBinFileListFunc != nil {
+		return m.RecycleBinFileListFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) RecycleBinFileListGetAll(param *aliyunpan.RecycleBinFileListParam) (aliyunpan.FileList, *apierror.ApiError) {
+	m.record("RecycleBinFileListGetAll")
+	if m.RecycleBinFileListGetAllFunc != nil {
+		return m.RecycleBinFileListGetAllFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) RecycleBinFileDelete(param []*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError) {
+	m.record("RecycleBinFileDelete")
+	if m.RecycleBinFileDeleteFunc != nil {
+		return m.RecycleBinFileDeleteFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) RecycleBinFileRestore(param []*aliyunpan.FileBatchActionParam) ([]*aliyunpan.FileBatchActionResult, *apierror.ApiError) {
+	m.record("RecycleBinFileRestore")
+	if m.RecycleBinFileRestoreFunc != nil {
+		return m.RecycleBinFileRestoreFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) RecycleBinClean(param *aliyunpan.RecycleBinCleanParam) (*aliyunpan.RecycleBinCleanResult, *apierror.ApiError) {
+	m.record("RecycleBinClean")
+	if m.RecycleBinCleanFunc != nil {
+		return m.RecycleBinCleanFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) CreateUploadFile(param *aliyunpan.CreateFileUploadParam) (*aliyunpan.CreateFileUploadResult, *apierror.ApiError) {
+	m.record("CreateUploadFile")
+	if m.CreateUploadFileFunc != nil {
+		return m.CreateUploadFileFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) GetUploadUrl(param *aliyunpan.GetUploadUrlParam) (*aliyunpan.GetUploadUrlResult, *apierror.ApiError) {
+	m.record("GetUploadUrl")
+	if m.GetUploadUrlFunc != nil {
+		return m.GetUploadUrlFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) UploadFileData(uploadUrl string, uploadFunc aliyunpan.UploadFunc) *apierror.ApiError {
+	m.record("UploadFileData")
+	if m.UploadFileDataFunc != nil {
+		return m.UploadFileDataFunc(uploadUrl, uploadFunc)
+	}
+	return ErrNotImplemented
+}
+
+func (m *PanClient) UploadDataChunk(url string, data *aliyunpan.FileUploadChunkData) *apierror.ApiError {
+	m.record("UploadDataChunk")
+	if m.UploadDataChunkFunc != nil {
+		return m.UploadDataChunkFunc(url, data)
+	}
+	return ErrNotImplemented
+}
+
+func (m *PanClient) CompleteUploadFile(param *aliyunpan.CompleteUploadFileParam) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError) {
+	m.record("CompleteUploadFile")
+	if m.CompleteUploadFileFunc != nil {
+		return m.CompleteUploadFileFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) GetFileDownloadUrl(param *aliyunpan.GetFileDownloadUrlParam) (*aliyunpan.GetFileDownloadUrlResult, *apierror.ApiError) {
+	m.record("GetFileDownloadUrl")
+	if m.GetFileDownloadUrlFunc != nil {
+		return m.GetFileDownloadUrlFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) DownloadFileData(downloadFileUrl string, fileRange aliyunpan.FileDownloadRange, downloadFunc aliyunpan.DownloadFuncCallback) *apierror.ApiError {
+	m.record("DownloadFileData")
+	if m.DownloadFileDataFunc != nil {
+		return m.DownloadFileDataFunc(downloadFileUrl, fileRange, downloadFunc)
+	}
+	return ErrNotImplemented
+}
+
+func (m *PanClient) DownloadFileDataAndSave(downloadFileUrl string, fileRange aliyunpan.FileDownloadRange, writerAt io.WriterAt) *apierror.ApiError {
+	m.record("DownloadFileDataAndSave")
+	if m.DownloadFileDataAndSaveFunc != nil {
+		return m.DownloadFileDataAndSaveFunc(downloadFileUrl, fileRange, writerAt)
+	}
+	return ErrNotImplemented
+}
+
+func (m *PanClient) ShareLinkList(userId string) ([]*aliyunpan.ShareEntity, *apierror.ApiError) {
+	m.record("ShareLinkList")
+	if m.ShareLinkListFunc != nil {
+		return m.ShareLinkListFunc(userId)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) ShareLinkCreate(param aliyunpan.ShareCreateParam) (*aliyunpan.ShareEntity, *apierror.ApiError) {
+	m.record("ShareLinkCreate")
+	if m.ShareLinkCreateFunc != nil {
+		return m.ShareLinkCreateFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) ShareLinkCancel(shareIdList []string) ([]*aliyunpan.ShareCancelResult, *apierror.ApiError) {
+	m.record("ShareLinkCancel")
+	if m.ShareLinkCancelFunc != nil {
+		return m.ShareLinkCancelFunc(shareIdList)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumList(param *aliyunpan.AlbumListParam) (*aliyunpan.AlbumListResult, *apierror.ApiError) {
+	m.record("AlbumList")
+	if m.AlbumListFunc != nil {
+		return m.AlbumListFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumListGetAll(param *aliyunpan.AlbumListParam) (aliyunpan.AlbumList, *apierror.ApiError) {
+	m.record("AlbumListGetAll")
+	if m.AlbumListGetAllFunc != nil {
+		return m.AlbumListGetAllFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumCreate(param *aliyunpan.AlbumCreateParam) (*aliyunpan.AlbumEntity, *apierror.ApiError) {
+	m.record("AlbumCreate")
+	if m.AlbumCreateFunc != nil {
+		return m.AlbumCreateFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumEdit(param *aliyunpan.AlbumEditParam) (*aliyunpan.AlbumEntity, *apierror.ApiError) {
+	m.record("AlbumEdit")
+	if m.AlbumEditFunc != nil {
+		return m.AlbumEditFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumDelete(param *aliyunpan.AlbumDeleteParam) (bool, *apierror.ApiError) {
+	m.record("AlbumDelete")
+	if m.AlbumDeleteFunc != nil {
+		return m.AlbumDeleteFunc(param)
+	}
+	return false, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumGet(param *aliyunpan.AlbumGetParam) (*aliyunpan.AlbumEntity, *apierror.ApiError) {
+	m.record("AlbumGet")
+	if m.AlbumGetFunc != nil {
+		return m.AlbumGetFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumShareCreate(param *aliyunpan.AlbumShareCreateParam) (*aliyunpan.AlbumShareCreateResult, *apierror.ApiError) {
+	m.record("AlbumShareCreate")
+	if m.AlbumShareCreateFunc != nil {
+		return m.AlbumShareCreateFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumListFile(param *aliyunpan.AlbumListFileParam) (*aliyunpan.FileListResult, *apierror.ApiError) {
+	m.record("AlbumListFile")
+	if m.AlbumListFileFunc != nil {
+		return m.AlbumListFileFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumListFileGetAll(param *aliyunpan.AlbumListFileParam) (aliyunpan.FileList, *apierror.ApiError) {
+	m.record("AlbumListFileGetAll")
+	if m.AlbumListFileGetAllFunc != nil {
+		return m.AlbumListFileGetAllFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumAddFile(param *aliyunpan.AlbumAddFileParam) (*aliyunpan.FileList, *apierror.ApiError) {
+	m.record("AlbumAddFile")
+	if m.AlbumAddFileFunc != nil {
+		return m.AlbumAddFileFunc(param)
+	}
+	return nil, ErrNotImplemented
+}
+
+func (m *PanClient) AlbumDeleteFile(param *aliyunpan.AlbumDeleteFileParam) (bool, *apierror.ApiError) {
+	m.record("AlbumDeleteFile")
+	if m.AlbumDeleteFileFunc != nil {
+		return m.AlbumDeleteFileFunc(param)
+	}
+	return false, ErrNotImplemented
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package panmock
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"testing"
+)
+
+func TestPanClient(t *testing.T) {
+	m := &PanClient{
+		FileInfoByPathFunc: func(driveId string, pathStr string) (*aliyunpan.FileEntity, *apierror.ApiError) {
+			return &aliyunpan.FileEntity{DriveId: driveId, Path: pathStr, FileName: "a.txt"}, nil
+		},
+	}
+	var api aliyunpan.PanAPI = m
+	fe, err := api.FileInfoByPath("1", "/a.txt")
+	if err != nil || fe.FileName != "a.txt" {
+		t.Fatalf("unexpected result %v %v", fe, err)
+	}
+	if _, err = api.FileInfoById("1", "x"); err != ErrNotImplemented {
+		t.Fatalf("expected ErrNotImplemented, got %v", err)
+	}
+	if m.Calls("FileInfoByPath") != 1 || m.Calls("FileInfoById") != 1 || m.Calls("FileList") != 0 {
+		t.Fatal("unexpected call count")
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pantest 使用真实账号的端到端测试工具。在网盘中创建隔离的沙盒文件夹，运行脚本化的场景并校验结果，结束后清理。
+// 用于下游项目对线上接口做冒烟测试，例如：
+//
+//	func TestSmoke(t *testing.T) {
+//		sb := pantest.Setup(t, "myapp")
+//		if report := pantest.Run(context.Background(), sb, pantest.DefaultScenario()); report.Failed() {
+//			t.Fatal(report)
+//		}
+//	}
+package pantest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+const (
+	// EnvRefreshToken 保存测试账号 refresh token 的环境变量
+	EnvRefreshToken = "ALIYUNPAN_E2E_REFRESH_TOKEN"
+	// EnvDriveId 指定测试网盘ID的环境变量，为空使用账号的文件网盘
+	EnvDriveId = "ALIYUNPAN_E2E_DRIVE_ID"
+
+	// SandboxRoot 沙盒文件夹的上级目录
+	SandboxRoot = "/pantest"
+)
+
+type (
+	// Sandbox 网盘中隔离的沙盒文件夹，场景中的所有文件都应该创建在沙盒文件夹下
+	Sandbox struct {
+		Client  *aliyunpan.PanClient
+		DriveId string
+		// Path 沙盒文件夹的绝对路径
+		Path string
+		// FolderId 沙盒文件夹ID
+		FolderId string
+
+		mu sync.Mutex
+		// shares 场景中创建的分享，清理时取消
+		shares []string
+	}
+)
+
+// NewClientFromEnv 使用环境变量 EnvRefreshToken 中的 refresh token 创建客户端，返回客户端和测试网盘ID
+func NewClientFromEnv() (*aliyunpan.PanClient, string, *apierror.ApiError) {
+	refreshToken := os.Getenv(EnvRefreshToken)
+	if refreshToken == "" {
+		return nil, "", apierror.NewFailedApiError("没有设置环境变量 " + EnvRefreshToken)
+	}
+	webToken, err := aliyunpan.GetAccessTokenFromRefreshToken(refreshToken)
+	if err != nil {
+		return nil, "", err
+	}
+	pc := aliyunpan.NewPanClient(*webToken, aliyunpan.AppLoginToken{})
+	driveId := os.Getenv(EnvDriveId)
+	if driveId == "" {
+		ui, err := pc.GetUserInfo()
+		if err != nil {
+			return nil, "", err
+		}
+		driveId = ui.FileDriveId
+	}
+	return pc, driveId, nil
+}
+
+// NewSandbox 在 SandboxRoot 下创建名称以 prefix 开头的沙盒文件夹，名称带有时间和随机数，多个测试同时运行不会冲突
+func NewSandbox(pc *aliyunpan.PanClient, driveId, prefix string) (*Sandbox, *apierror.ApiError) {
+	name := fmt.Sprintf("%s-%s-%04d", prefix, time.Now().Format("20060102150405"), rand.Intn(10000))
+	p := path.Join(SandboxRoot, name)
+	r, err := pc.MkdirByFullPath(driveId, p)
+	if err != nil {
+		return nil, err
+	}
+	return &Sandbox{
+		Client:   pc,
+		DriveId:  driveId,
+		Path:     p,
+		FolderId: r.FileId,
+	}, nil
+}
+
+// Setup 使用环境变量中的账号创建沙盒，测试结束后自动清理。没有设置账号时跳过测试
+func Setup(t testing.TB, prefix string) *Sandbox {
+	t.Helper()
+	if os.Getenv(EnvRefreshToken) == "" {
+		t.Skip("skip end-to-end test: " + EnvRefreshToken + " not set")
+	}
+	pc, driveId, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("create client: %s", err)
+	}
+	sb, err := NewSandbox(pc, driveId, prefix)
+	if err != nil {
+		t.Fatalf("create sandbox: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := sb.Cleanup(); err != nil {
+			t.Errorf("cleanup sandbox %s: %s", sb.Path, err)
+		}
+	})
+	return sb
+}
+
+// TrackShare 记录场景中创建的分享，清理沙盒时取消
+func (s *Sandbox) TrackShare(shareId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares = append(s.shares, shareId)
+}
+
+// Join 返回沙盒文件夹下的绝对路径
+func (s *Sandbox) Join(elem ...string) string {
+	return path.Join(append([]string{s.Path}, elem...)...)
+}
+
+// Cleanup 取消场景中创建的分享，删除沙盒文件夹并从回收站中彻底删除
+func (s *Sandbox) Cleanup() *apierror.ApiError {
+	s.mu.Lock()
+	shares := s.shares
+	s.shares = nil
+	s.mu.Unlock()
+	if len(shares) > 0 {
+		if _, err := s.Client.ShareLinkCancel(shares); err != nil {
+			return err
+		}
+	}
+
+	param := []*aliyunpan.FileBatchActionParam{{DriveId: s.DriveId, FileId: s.FolderId}}
+	if _, err := s.Client.FileDelete(param); err != nil {
+		return err
+	}
+	_, err := s.Client.RecycleBinFileDelete(param)
+	return err
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pantest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+)
+
+type (
+	// State 场景运行状态，步骤之间通过 Values 传递数据（例如上传后得到的文件ID）
+	State struct {
+		Sandbox *Sandbox
+		Values  map[string]string
+	}
+
+	// Step 场景中的一个步骤，返回错误时场景失败，后续步骤不再执行
+	Step struct {
+		Name string
+		Run  func(ctx context.Context, st *State) error
+	}
+
+	// Scenario 按顺序执行的一组步骤
+	Scenario struct {
+		Name  string
+		Steps []Step
+	}
+
+	// StepResult 步骤执行结果
+	StepResult struct {
+		Name     string
+		Err      error
+		Duration time.Duration
+		// Skipped 前面的步骤失败或者 ctx 取消，没有执行
+		Skipped bool
+	}
+
+	// Report 场景执行报告
+	Report struct {
+		Scenario string
+		Steps    []*StepResult
+	}
+)
+
+// Run 在沙盒中按顺序执行场景的步骤，某个步骤失败后跳过剩余的步骤
+func Run(ctx context.Context, sb *Sandbox, sc *Scenario) *Report {
+	st := &State{Sandbox: sb, Values: map[string]string{}}
+	report := &Report{Scenario: sc.Name}
+	failed := false
+	for _, step := range sc.Steps {
+		r := &StepResult{Name: step.Name}
+		report.Steps = append(report.Steps, r)
+		if failed || ctx.Err() != nil {
+			r.Skipped = true
+			continue
+		}
+		start := time.Now()
+		r.Err = step.Run(ctx, st)
+		r.Duration = time.Since(start)
+		failed = r.Err != nil
+	}
+	return report
+}
+
+// Failed 是否有步骤失败或者被跳过
+func (r *Report) Failed() bool {
+	for _, s := range r.Steps {
+		if s.Err != nil || s.Skipped {
+			return true
+		}
+	}
+	return false
+}
+
+// String 报告的文本格式，每个步骤一行
+func (r *Report) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "scenario %s:", r.Scenario)
+	for _, s := range r.Steps {
+		switch {
+		case s.Skipped:
+			fmt.Fprintf(sb, "\n  SKIP %s", s.Name)
+		case s.Err != nil:
+			fmt.Fprintf(sb, "\n  FAIL %s (%s): %s", s.Name, s.Duration, s.Err)
+		default:
+			fmt.Fprintf(sb, "\n  OK   %s (%s)", s.Name, s.Duration)
+		}
+	}
+	return sb.String()
+}
+
+const (
+	defaultFileName = "pantest.txt"
+	defaultMoveDir  = "moved"
+)
+
+// DefaultScenario 默认场景：上传→列表→移动→分享→删除到回收站→还原，每一步校验网盘中的文件状态
+func DefaultScenario() *Scenario {
+	data := []byte("aliyunpan-api end-to-end test " + time.Now().Format(time.RFC3339Nano))
+	return &Scenario{
+		Name: "upload-list-move-share-trash-restore",
+		Steps: []Step{
+			{Name: "upload", Run: func(ctx context.Context, st *State) error {
+				sb := st.Sandbox
+				r, err := transfer.UploadData(ctx, sb.Client, sb.DriveId, sb.FolderId, data, defaultFileName, nil)
+				if err != nil {
+					return err
+				}
+				st.Values["fileId"] = r.FileId
+				return nil
+			}},
+			{Name: "list", Run: func(ctx context.Context, st *State) error {
+				sb := st.Sandbox
+				fl, err := sb.Client.FileListGetAll(&aliyunpan.FileListParam{DriveId: sb.DriveId, ParentFileId: sb.FolderId})
+				if err != nil {
+					return err
+				}
+				for _, fe := range fl {
+					if fe.FileId == st.Values["fileId"] {
+						if fe.FileSize != int64(len(data)) {
+							return fmt.Errorf("file size mismatch: %d != %d", fe.FileSize, len(data))
+						}
+						return nil
+					}
+				}
+				return fmt.Errorf("uploaded file not listed")
+			}},
+			{Name: "move", Run: func(ctx context.Context, st *State) error {
+				sb := st.Sandbox
+				dir, err := sb.Client.Mkdir(sb.DriveId, sb.FolderId, defaultMoveDir)
+				if err != nil {
+					return err
+				}
+				r, err := sb.Client.FileMove([]*aliyunpan.FileMoveParam{{DriveId: sb.DriveId, FileId: st.Values["fileId"], ToParentFileId: dir.FileId}})
+				if err != nil {
+					return err
+				}
+				if len(r) != 1 || !r[0].Success {
+					return fmt.Errorf("move failed")
+				}
+				return expectFileAt(sb, sb.Join(defaultMoveDir, defaultFileName), st.Values["fileId"])
+			}},
+			{Name: "share", Run: func(ctx context.Context, st *State) error {
+				sb := st.Sandbox
+				share, err := sb.Client.ShareLinkCreate(aliyunpan.ShareCreateParam{DriveId: sb.DriveId, FileIdList: []string{st.Values["fileId"]}})
+				if err != nil {
+					return err
+				}
+				sb.TrackShare(share.ShareId)
+				if _, err = sb.Client.GetShareToken(share.ShareId, share.SharePwd); err != nil {
+					return err
+				}
+				return nil
+			}},
+			{Name: "trash", Run: func(ctx context.Context, st *State) error {
+				sb := st.Sandbox
+				if _, err := sb.Client.FileDelete([]*aliyunpan.FileBatchActionParam{{DriveId: sb.DriveId, FileId: st.Values["fileId"]}}); err != nil {
+					return err
+				}
+				fl, err := sb.Client.RecycleBinFileListGetAll(&aliyunpan.RecycleBinFileListParam{DriveId: sb.DriveId})
+				if err != nil {
+					return err
+				}
+				for _, fe := range fl {
+					if fe.FileId == st.Values["fileId"] {
+						return nil
+					}
+				}
+				return fmt.Errorf("trashed file not found in recycle bin")
+			}},
+			{Name: "restore", Run: func(ctx context.Context, st *State) error {
+				sb := st.Sandbox
+				if _, err := sb.Client.RecycleBinFileRestore([]*aliyunpan.FileBatchActionParam{{DriveId: sb.DriveId, FileId: st.Values["fileId"]}}); err != nil {
+					return err
+				}
+				return expectFileAt(sb, sb.Join(defaultMoveDir, defaultFileName), st.Values["fileId"])
+			}},
+		},
+	}
+}
+
+// expectFileAt 校验路径上的文件是指定的文件
+func expectFileAt(sb *Sandbox, p, fileId string) error {
+	fe, err := sb.Client.FileInfoByPath(sb.DriveId, p)
+	if err != nil {
+		return err
+	}
+	if fe.FileId != fileId {
+		return fmt.Errorf("unexpected file at %s: %s", p, fe.FileId)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pantest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunStopsAfterFailure(t *testing.T) {
+	calls := []string{}
+	sc := &Scenario{
+		Name: "fake",
+		Steps: []Step{
+			{Name: "a", Run: func(ctx context.Context, st *State) error {
+				calls = append(calls, "a")
+				st.Values["id"] = "f1"
+				return nil
+			}},
+			{Name: "b", Run: func(ctx context.Context, st *State) error {
+				calls = append(calls, "b:"+st.Values["id"])
+				return errors.New("boom")
+			}},
+			{Name: "c", Run: func(ctx context.Context, st *State) error {
+				calls = append(calls, "c")
+				return nil
+			}},
+		},
+	}
+
+	report := Run(context.Background(), nil, sc)
+	assert.Equal(t, []string{"a", "b:f1"}, calls)
+	assert.True(t, report.Failed())
+	assert.Nil(t, report.Steps[0].Err)
+	assert.EqualError(t, report.Steps[1].Err, "boom")
+	assert.True(t, report.Steps[2].Skipped)
+	assert.True(t, strings.Contains(report.String(), "FAIL b"))
+	assert.True(t, strings.Contains(report.String(), "SKIP c"))
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := Run(ctx, nil, &Scenario{Name: "canceled", Steps: []Step{{Name: "a", Run: func(ctx context.Context, st *State) error {
+		t.Fatal("step should not run")
+		return nil
+	}}}})
+	assert.True(t, report.Steps[0].Skipped)
+	assert.True(t, report.Failed())
+}
+
+func TestNewClientFromEnvWithoutToken(t *testing.T) {
+	if os.Getenv(EnvRefreshToken) != "" {
+		t.Skip("credentials configured")
+	}
+	_, _, err := NewClientFromEnv()
+	assert.NotNil(t, err)
+}
+
+// TestDefaultScenario 使用真实账号运行默认场景，没有设置 EnvRefreshToken 时跳过
+func TestDefaultScenario(t *testing.T) {
+	sb := Setup(t, "aliyunpan-api")
+	if report := Run(context.Background(), sb, DefaultScenario()); report.Failed() {
+		t.Fatal(report)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimiter 令牌桶限速器，限制客户端每秒发起的请求数量，避免触发服务端的限流
+	RateLimiter struct {
+		mu sync.Mutex
+		// rate 每秒生成的令牌数
+		rate  float64
+		burst float64
+		// tokens 当前可用的令牌数，为负数代表已经预约的等待中的请求
+		tokens float64
+		last   time.Time
+	}
+)
+
+// NewRateLimiter 创建限速器，perSecond 为每秒允许的请求数量，burst 为允许的突发请求数量，最少为1
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait 等待一个令牌，ctx 取消时返回错误
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l.rate <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	wait := time.Duration(0)
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 归还预约的令牌
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// SetRateLimiter 设置限速器，为nil代表不限速。派生的客户端共享限速器
+func (pc *PanClient) SetRateLimiter(l *RateLimiter) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.limiter = l
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+type (
+	// ConsistencyRetry 写后读重试配置。新建文件夹、上传文件等写操作之后，服务端需要一点时间同步数据，
+	// 立即查询文件信息可能返回文件不存在，开启后在写操作之后的 Window 时间内查询文件返回不存在时自动退避重试
+	ConsistencyRetry struct {
+		// MaxAttempts 最多查询的次数，包括第一次查询，小于等于1代表不重试
+		MaxAttempts int
+		// InitialDelay 第一次重试前的等待时间，之后每次翻倍
+		InitialDelay time.Duration
+		// MaxDelay 两次重试之间最长的等待时间
+		MaxDelay time.Duration
+		// Window 写操作之后多长时间内的查询需要重试
+		Window time.Duration
+	}
+
+	// writeTracker 记录最近一次写操作的时间
+	writeTracker struct {
+		mu   sync.Mutex
+		last time.Time
+	}
+)
+
+// DefaultConsistencyRetry 默认的写后读重试配置
+func DefaultConsistencyRetry() ConsistencyRetry {
+	return ConsistencyRetry{
+		MaxAttempts:  5,
+		InitialDelay: 200 * time.Millisecond,
+		MaxDelay:     2 * time.Second,
+		Window:       10 * time.Second,
+	}
+}
+
+// SetConsistencyRetry 设置写后读重试，FileInfoById、FileInfoByPath 在写操作之后返回文件不存在时按配置重试。
+// 传入零值关闭重试
+func (pc *PanClient) SetConsistencyRetry(retry ConsistencyRetry) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.consistency = retry
+}
+
+func (w *writeTracker) record() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = time.Now()
+}
+
+// within 最近一次写操作是否在 d 时间之内
+func (w *writeTracker) within(d time.Duration) bool {
+	if w == nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.last.IsZero() && time.Since(w.last) <= d
+}
+
+// retryAfterWrite 执行查询，如果返回文件不存在并且最近有过写操作则退避重试。
+// 绑定的上下文取消后停止重试，返回最后一次查询的结果
+func (pc *PanClient) retryAfterWrite(query func() (*FileEntity, *apierror.ApiError)) (*FileEntity, *apierror.ApiError) {
+	pc.mu.RLock()
+	retry := pc.consistency
+	pc.mu.RUnlock()
+
+	fe, err := query()
+	if retry.MaxAttempts <= 1 {
+		return fe, err
+	}
+	delay := retry.InitialDelay
+	if delay <= 0 {
+		delay = 100 * time.Millisecond
+	}
+	for attempt := 1; attempt < retry.MaxAttempts; attempt++ {
+		if err == nil || err.Code != apierror.ApiCodeFileNotFoundCode || !pc.writes.within(retry.Window) {
+			return fe, err
+		}
+		select {
+		case <-pc.Context().Done():
+			return fe, err
+		case <-time.After(delay):
+		}
+		if delay *= 2; retry.MaxDelay > 0 && delay > retry.MaxDelay {
+			delay = retry.MaxDelay
+		}
+		fe, err = query()
+	}
+	return fe, err
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"fmt"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+type (
+	// RequestDefaults 列表类请求的默认参数，请求参数没有指定时使用
+	RequestDefaults struct {
+		// PageSize 每页数量，默认为100，最大为 MaxFileListLimit。调大可以减少获取全部列表时的请求次数
+		PageSize int
+		// UrlExpireSec 文件列表中下载链接、缩略图链接的有效期，单位秒，默认为1600
+		UrlExpireSec int
+		// ImageThumbnailProcess 图片缩略图处理参数
+		ImageThumbnailProcess string
+		// ImageUrlProcess 图片预览处理参数
+		ImageUrlProcess string
+		// VideoThumbnailProcess 视频缩略图处理参数
+		VideoThumbnailProcess string
+	}
+)
+
+const (
+	// defaultVideoThumbnailProcess 默认的视频缩略图处理参数
+	defaultVideoThumbnailProcess = "video/snapshot,t_0,f_jpg,ar_auto,w_800"
+	// albumVideoThumbnailProcess 相簿文件列表默认使用更大的视频缩略图
+	albumVideoThumbnailProcess = "video/snapshot,t_0,f_jpg,ar_auto,w_1000"
+)
+
+// DefaultRequestDefaults 返回库默认的请求参数
+func DefaultRequestDefaults() RequestDefaults {
+	return RequestDefaults{
+		PageSize:              DefaultFileListLimit,
+		UrlExpireSec:          1600,
+		ImageThumbnailProcess: "image/resize,w_400/format,jpeg",
+		ImageUrlProcess:       "image/resize,w_1920/format,jpeg",
+		VideoThumbnailProcess: defaultVideoThumbnailProcess,
+	}
+}
+
+// SetRequestDefaults 设置该客户端的默认请求参数，值为零的字段使用库默认值。参数超出范围时返回 ApiCodeBadRequest 错误
+func (pc *PanClient) SetRequestDefaults(d RequestDefaults) *apierror.ApiError {
+	if d.PageSize < 0 || d.PageSize > MaxFileListLimit {
+		return apierror.NewApiError(apierror.ApiCodeBadRequest, fmt.Sprintf("每页数量必须在0到%d之间：%d", MaxFileListLimit, d.PageSize))
+	}
+	if d.UrlExpireSec < 0 {
+		return apierror.NewApiError(apierror.ApiCodeBadRequest, fmt.Sprintf("链接有效期不能为负数：%d", d.UrlExpireSec))
+	}
+	def := DefaultRequestDefaults()
+	if d.PageSize == 0 {
+		d.PageSize = def.PageSize
+	}
+	if d.UrlExpireSec == 0 {
+		d.UrlExpireSec = def.UrlExpireSec
+	}
+	if d.ImageThumbnailProcess == "" {
+		d.ImageThumbnailProcess = def.ImageThumbnailProcess
+	}
+	if d.ImageUrlProcess == "" {
+		d.ImageUrlProcess = def.ImageUrlProcess
+	}
+	if d.VideoThumbnailProcess == "" {
+		d.VideoThumbnailProcess = def.VideoThumbnailProcess
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.defaults = d
+	return nil
+}
+
+// RequestDefaults 返回该客户端的默认请求参数
+func (pc *PanClient) RequestDefaults() RequestDefaults {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if pc.defaults == (RequestDefaults{}) {
+		// 没有通过 NewPanClient 创建的客户端
+		return DefaultRequestDefaults()
+	}
+	return pc.defaults
+}
+
+// albumVideoThumbnailProcess 相簿文件列表的视频缩略图处理参数，没有自定义时使用相簿默认的参数
+func (d RequestDefaults) albumVideoThumbnailProcess() string {
+	if d.VideoThumbnailProcess == defaultVideoThumbnailProcess {
+		return albumVideoThumbnailProcess
+	}
+	return d.VideoThumbnailProcess
+}
+
+// pageSize 每页数量，limit 大于0时直接使用
+func (pc *PanClient) pageSize(limit int) int {
+	if limit > 0 {
+		return limit
+	}
+	return pc.RequestDefaults().PageSize
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"sync"
+)
+
+type (
+	// RequestClass 请求类别，调度器在不同类别之间公平分配并发名额
+	RequestClass int
+
+	// RequestScheduler 限制客户端同时进行的请求数量，并在交互请求和批量请求之间轮流分配名额，
+	// 避免大量的递归列表或者文件传输请求占满并发，导致用户的交互操作长时间等待
+	RequestScheduler struct {
+		mu          sync.Mutex
+		maxInFlight int
+		inFlight    int
+		waiting     [requestClassCount][]chan struct{}
+		// next 下一次优先分配名额的类别
+		next RequestClass
+	}
+)
+
+const (
+	// RequestClassInteractive 交互请求，例如获取文件信息、重命名等元数据操作
+	RequestClassInteractive RequestClass = iota
+	// RequestClassBulk 批量请求，例如递归获取目录、上传下载文件数据
+	RequestClassBulk
+
+	requestClassCount = 2
+)
+
+// NewRequestScheduler 创建请求调度器，maxInFlight 为最多同时进行的请求数量
+func NewRequestScheduler(maxInFlight int) *RequestScheduler {
+	if maxInFlight <= 0 {
+		maxInFlight = 1
+	}
+	return &RequestScheduler{maxInFlight: maxInFlight}
+}
+
+// InFlight 正在进行的请求数量
+func (s *RequestScheduler) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inFlight
+}
+
+// Acquire 申请一个请求名额，没有名额时等待，ctx 取消时返回错误
+func (s *RequestScheduler) Acquire(ctx context.Context, class RequestClass) error {
+	if class < 0 || class >= requestClassCount {
+		class = RequestClassInteractive
+	}
+	s.mu.Lock()
+	if s.inFlight < s.maxInFlight && len(s.waiting[RequestClassInteractive]) == 0 && len(s.waiting[RequestClassBulk]) == 0 {
+		s.inFlight++
+		s.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	s.waiting[class] = append(s.waiting[class], ch)
+	s.mu.Unlock()
+
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for i, c := range s.waiting[class] {
+			if c == ch {
+				s.waiting[class] = append(s.waiting[class][:i], s.waiting[class][i+1:]...)
+				s.mu.Unlock()
+				return ctx.Err()
+			}
+		}
+		s.mu.Unlock()
+		// 取消的同时已经分配了名额，需要归还
+		s.Release()
+		return ctx.Err()
+	}
+}
+
+// Release 归还 Acquire 申请的名额
+func (s *RequestScheduler) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	for s.inFlight < s.maxInFlight {
+		class := s.next
+		if len(s.waiting[class]) == 0 {
+			class = (class + 1) % requestClassCount
+			if len(s.waiting[class]) == 0 {
+				return
+			}
+		}
+		ch := s.waiting[class][0]
+		s.waiting[class] = s.waiting[class][1:]
+		s.next = (class + 1) % requestClassCount
+		s.inFlight++
+		close(ch)
+	}
+}
+
+// SetRequestScheduler 设置请求调度器，为nil代表不限制。派生的客户端共享调度器
+func (pc *PanClient) SetRequestScheduler(s *RequestScheduler) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.scheduler = s
+}
+
+// WithRequestClass 派生一个使用指定请求类别的客户端，例如后台的批量任务使用 RequestClassBulk
+func (pc *PanClient) WithRequestClass(class RequestClass) *PanClient {
+	c := pc.clone()
+	c.requestClass = class
+	return c
+}
+
+// acquire 申请请求名额，设置了限速器时先等待令牌，返回的函数用于归还名额
+func (pc *PanClient) acquire(class RequestClass) (func(), error) {
+	pc.mu.RLock()
+	s := pc.scheduler
+	l := pc.limiter
+	pc.mu.RUnlock()
+	if l != nil {
+		if err := l.Wait(pc.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if s == nil {
+		return func() {}, nil
+	}
+	if err := s.Acquire(pc.Context(), class); err != nil {
+		return nil, err
+	}
+	return s.Release, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestSchedulerFairness(t *testing.T) {
+	s := NewRequestScheduler(1)
+	ctx := context.Background()
+	if err := s.Acquire(ctx, RequestClassBulk); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 4)
+	start := func(name string, class RequestClass) {
+		go func() {
+			s.Acquire(ctx, class)
+			order <- name
+			s.Release()
+		}()
+	}
+	// 先排队两个批量请求，再排队一个交互请求
+	start("bulk1", RequestClassBulk)
+	time.Sleep(10 * time.Millisecond)
+	start("bulk2", RequestClassBulk)
+	time.Sleep(10 * time.Millisecond)
+	start("interactive", RequestClassInteractive)
+	time.Sleep(10 * time.Millisecond)
+	s.Release()
+
+	got := []string{<-order, <-order, <-order}
+	if got[0] != "interactive" && got[1] != "interactive" {
+		t.Fatalf("interactive request should not wait for all bulk requests, got %v", got)
+	}
+	// 等待最后一个请求归还名额
+	for i := 0; i < 100 && s.InFlight() != 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.Acquire(ctx, RequestClassBulk)
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(cctx, RequestClassInteractive); err == nil {
+		t.Fatal("expected acquire to time out")
+	}
+	s.Release()
+	if s.InFlight() != 0 {
+		t.Fatalf("expected no request in flight, got %d", s.InFlight())
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// RiskChallenge 风控验证挑战，接口要求用户完成验证码、短信等验证后才能继续请求
+	RiskChallenge struct {
+		// Code 错误码，参见 apierror.IsChallengeErrorCode
+		Code string
+		// Message 错误信息
+		Message string
+		// Url 验证页面地址，没有返回时为空
+		Url string
+		// Params 响应中的其他参数，例如会话ID、验证场景
+		Params map[string]interface{}
+		// RequestUrl 触发验证的请求地址
+		RequestUrl string
+	}
+
+	// ChallengeHandler 风控验证回调，引导用户完成验证后返回，返回的请求头附加到重试的请求中（例如验证凭证），
+	// 返回错误代表放弃验证，原请求返回验证错误。ctx 为客户端绑定的上下文
+	ChallengeHandler func(ctx context.Context, challenge *RiskChallenge) (map[string]string, error)
+
+	// challengeHook 风控验证回调，派生的客户端共享，同一时间只处理一个验证
+	challengeHook struct {
+		fnMu sync.RWMutex
+		fn   ChallengeHandler
+
+		// verifyMu 处理验证时持有，保证同一时间只处理一个验证
+		verifyMu sync.Mutex
+		// mu 保护 generation 和 header
+		mu sync.Mutex
+		// generation 完成验证的次数，请求发出后其他请求完成了验证时直接重试
+		generation int
+		// header 最近一次验证返回的请求头
+		header map[string]string
+	}
+)
+
+// riskChallengeUrlKeys 响应中验证页面地址可能使用的字段
+var riskChallengeUrlKeys = []string{"url", "verifyUrl", "verify_url", "redirectUrl", "redirect_url"}
+
+// RegisterChallengeHandler 注册风控验证回调。接口返回风控验证挑战时调用 fn，验证完成后重试原请求一次。
+// 多个请求同时触发验证时只调用一次 fn，其他请求等待验证结果。fn 为nil代表取消，之后触发验证的请求返回 ApiCodeNeedCaptchaCode 错误。
+// fn 中不要使用同一个客户端发起可能触发验证的请求
+func (pc *PanClient) RegisterChallengeHandler(fn ChallengeHandler) {
+	pc.mu.Lock()
+	if pc.challenge == nil {
+		pc.challenge = &challengeHook{}
+	}
+	h := pc.challenge
+	pc.mu.Unlock()
+
+	h.fnMu.Lock()
+	defer h.fnMu.Unlock()
+	h.fn = fn
+}
+
+// fork 复制回调，验证状态单独记录，用于其他账号的客户端
+func (h *challengeHook) fork() *challengeHook {
+	if h == nil {
+		return &challengeHook{}
+	}
+	h.fnMu.RLock()
+	defer h.fnMu.RUnlock()
+	return &challengeHook{fn: h.fn}
+}
+
+// challengeGeneration 发起请求前记录验证次数
+func (pc *PanClient) challengeGeneration() int {
+	pc.mu.RLock()
+	h := pc.challenge
+	pc.mu.RUnlock()
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.generation
+}
+
+// parseRiskChallenge 解析风控验证挑战，响应不是验证挑战返回nil
+func parseRiskChallenge(urlStr string, body []byte) *RiskChallenge {
+	errResp := &apierror.ErrorResp{}
+	if err := json.Unmarshal(body, errResp); err != nil || !apierror.IsChallengeErrorCode(errResp.ErrorCode) {
+		return nil
+	}
+	c := &RiskChallenge{
+		Code:       errResp.ErrorCode,
+		Message:    errResp.ErrorMsg,
+		Params:     map[string]interface{}{},
+		RequestUrl: urlStr,
+	}
+	json.Unmarshal(body, &c.Params)
+	delete(c.Params, "code")
+	delete(c.Params, "message")
+	for _, key := range riskChallengeUrlKeys {
+		if u, ok := c.Params[key].(string); ok && u != "" {
+			c.Url = u
+			delete(c.Params, key)
+			break
+		}
+	}
+	return c
+}
+
+// resolveChallenge 响应为风控验证挑战时等待用户完成验证，返回重试使用的请求头，不需要重试返回false。
+// generation 为请求发出前的验证次数
+func (pc *PanClient) resolveChallenge(generation int, urlStr string, header map[string]string, body []byte) (map[string]string, bool) {
+	pc.mu.RLock()
+	h := pc.challenge
+	pc.mu.RUnlock()
+	if h == nil {
+		return nil, false
+	}
+	c := parseRiskChallenge(urlStr, body)
+	if c == nil {
+		return nil, false
+	}
+
+	h.verifyMu.Lock()
+	defer h.verifyMu.Unlock()
+	h.mu.Lock()
+	verified := h.header
+	pending := h.generation == generation
+	h.mu.Unlock()
+	if pending {
+		h.fnMu.RLock()
+		fn := h.fn
+		h.fnMu.RUnlock()
+		if fn == nil {
+			return nil, false
+		}
+		var err error
+		if verified, err = fn(pc.Context(), c); err != nil {
+			logger.Verboseln("risk challenge not completed ", err)
+			return nil, false
+		}
+		h.mu.Lock()
+		h.generation++
+		h.header = verified
+		h.mu.Unlock()
+	}
+	retryHeader := copyHeader(header)
+	for k, v := range verified {
+		retryHeader[k] = v
+	}
+	return retryHeader, true
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestRiskChallenge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-captcha-token") != "passed" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NeedCaptcha","message":"need captcha","url":"https://passport.example.com/verify","sessionId":"s1"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1"}, AppLoginToken{})
+	header := map[string]string{"authorization": p.authorizationStr()}
+
+	// 没有注册回调时返回验证错误
+	body, _ := p.fetch("POST", server.URL, map[string]string{}, header)
+	if e := apierror.ParseCommonApiError(body); e == nil || e.Code != apierror.ApiCodeNeedCaptchaCode {
+		t.Fatalf("expected captcha error, got %s", body)
+	}
+
+	var calls int32
+	p.RegisterChallengeHandler(func(ctx context.Context, c *RiskChallenge) (map[string]string, error) {
+		atomic.AddInt32(&calls, 1)
+		if c.Url != "https://passport.example.com/verify" || c.Params["sessionId"] != "s1" || c.RequestUrl != server.URL {
+			t.Errorf("unexpected challenge %+v", c)
+		}
+		time.Sleep(50 * time.Millisecond)
+		return map[string]string{"x-captcha-token": "passed"}, nil
+	})
+
+	// 同时触发验证的请求只调用一次回调
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := p.WithContext(context.Background()).fetch("POST", server.URL, map[string]string{}, header)
+			if err != nil || string(body) != `{"ok":true}` {
+				t.Errorf("unexpected result %s %v", body, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected one challenge, got %d", n)
+	}
+	if _, ok := header["x-captcha-token"]; ok {
+		t.Fatal("caller header should not be modified")
+	}
+
+	// 放弃验证时返回验证错误
+	c := p.CloneWithToken(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a2"})
+	c.RegisterChallengeHandler(func(ctx context.Context, c *RiskChallenge) (map[string]string, error) {
+		return nil, errors.New("canceled")
+	})
+	body, _ = c.fetch("POST", server.URL, map[string]string{}, header)
+	if e := apierror.ParseCommonApiError(body); e == nil || e.Code != apierror.ApiCodeNeedCaptchaCode {
+		t.Fatalf("expected captcha error, got %s", body)
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatal("handler of other account should not be replaced")
+	}
+}
+
+func TestRegisterChallengeErrorCode(t *testing.T) {
+	body := []byte(`{"code":"TestRiskVerify","message":"verify"}`)
+	if apierror.IsChallengeErrorCode("TestRiskVerify") {
+		t.Fatal("unexpected challenge code")
+	}
+	if e := apierror.ParseCommonApiError(body); e == nil || e.Code == apierror.ApiCodeNeedCaptchaCode {
+		t.Fatalf("unexpected error %v", e)
+	}
+	apierror.RegisterChallengeErrorCode("TestRiskVerify")
+	if e := apierror.ParseCommonApiError(body); e == nil || e.Code != apierror.ApiCodeNeedCaptchaCode {
+		t.Fatalf("expected captcha error, got %v", e)
+	}
+}
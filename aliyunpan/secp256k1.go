@@ -0,0 +1,189 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+// secp256k1 曲线上的 ECDSA 签名，设备会话签名使用。只实现签名需要的运算，正确性由测试中的公开测试向量保证。
+//
+// 运算基于 math/big，标量乘法使用逐位的倍点-加法，耗时与私钥和随机数的比特位相关，不是常数时间实现。
+// 能够在同一台机器上精确测量大量签名耗时的攻击者理论上可以推算出设备私钥。设备私钥只用于网盘的设备会话，
+// 泄露后可以在网盘上注销设备，风险可以接受；需要抵御时间侧信道时应该改用 github.com/decred/dcrd/dcrec/secp256k1 等经过审计的实现
+
+type (
+	secpPoint struct {
+		x, y *big.Int
+	}
+
+	// secpPrivateKey secp256k1 私钥
+	secpPrivateKey struct {
+		d   *big.Int
+		pub *secpPoint
+	}
+)
+
+var (
+	secpP, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	secpN, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	secpGx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	secpGy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+	secpG     = &secpPoint{x: secpGx, y: secpGy}
+	secpHalfN = new(big.Int).Rsh(secpN, 1)
+)
+
+// secpAdd 点加法，nil 代表无穷远点
+func secpAdd(a, b *secpPoint) *secpPoint {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	var lambda *big.Int
+	if a.x.Cmp(b.x) == 0 {
+		if new(big.Int).Add(a.y, b.y).Mod(new(big.Int).Add(a.y, b.y), secpP).Sign() == 0 {
+			return nil
+		}
+		// 倍点：lambda = 3x^2 / 2y
+		num := new(big.Int).Mul(a.x, a.x)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(a.y, 1)
+		lambda = num.Mul(num, den.ModInverse(den, secpP))
+	} else {
+		num := new(big.Int).Sub(b.y, a.y)
+		den := new(big.Int).Sub(b.x, a.x)
+		den.Mod(den, secpP)
+		lambda = num.Mul(num, den.ModInverse(den, secpP))
+	}
+	lambda.Mod(lambda, secpP)
+	x := new(big.Int).Mul(lambda, lambda)
+	x.Sub(x, a.x).Sub(x, b.x).Mod(x, secpP)
+	y := new(big.Int).Sub(a.x, x)
+	y.Mul(y, lambda).Sub(y, a.y).Mod(y, secpP)
+	return &secpPoint{x: x, y: y}
+}
+
+// secpMul 标量乘法 k*p
+func secpMul(p *secpPoint, k *big.Int) *secpPoint {
+	var r *secpPoint
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r = secpAdd(r, r)
+		if k.Bit(i) == 1 {
+			r = secpAdd(r, p)
+		}
+	}
+	return r
+}
+
+// secpRandScalar 生成 [1, n-1] 之间的随机数
+func secpRandScalar() (*big.Int, error) {
+	max := new(big.Int).Sub(secpN, big.NewInt(1))
+	k, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+// newSecpPrivateKey 生成随机私钥
+func newSecpPrivateKey() (*secpPrivateKey, error) {
+	d, err := secpRandScalar()
+	if err != nil {
+		return nil, err
+	}
+	return secpPrivateKeyFromScalar(d), nil
+}
+
+func secpPrivateKeyFromScalar(d *big.Int) *secpPrivateKey {
+	return &secpPrivateKey{d: d, pub: secpMul(secpG, d)}
+}
+
+// publicKeyHex 非压缩格式的公钥，04 + X + Y
+func (k *secpPrivateKey) publicKeyHex() string {
+	buf := make([]byte, 65)
+	buf[0] = 4
+	k.pub.x.FillBytes(buf[1:33])
+	k.pub.y.FillBytes(buf[33:])
+	return hex.EncodeToString(buf)
+}
+
+// sign 对摘要签名，返回 r(32字节) + s(32字节) + v(恢复ID，1字节)，s 取较小值
+func (k *secpPrivateKey) sign(digest []byte) ([]byte, error) {
+	for i := 0; i < 16; i++ {
+		nonce, err := secpRandScalar()
+		if err != nil {
+			return nil, err
+		}
+		if sig := k.signWithNonce(digest, nonce); sig != nil {
+			return sig, nil
+		}
+	}
+	return nil, errors.New("secp256k1 sign failed")
+}
+
+// signWithNonce 使用指定的随机数签名，r 或者 s 为0时返回nil，需要换一个随机数
+func (k *secpPrivateKey) signWithNonce(digest []byte, nonce *big.Int) []byte {
+	z := new(big.Int).SetBytes(digest)
+	R := secpMul(secpG, nonce)
+	r := new(big.Int).Mod(R.x, secpN)
+	if r.Sign() == 0 {
+		return nil
+	}
+	s := new(big.Int).Mul(r, k.d)
+	s.Add(s, z).Mul(s, new(big.Int).ModInverse(nonce, secpN)).Mod(s, secpN)
+	if s.Sign() == 0 {
+		return nil
+	}
+	v := byte(R.y.Bit(0))
+	if R.x.Cmp(secpN) >= 0 {
+		v |= 2
+	}
+	if s.Cmp(secpHalfN) > 0 {
+		s.Sub(secpN, s)
+		v ^= 1
+	}
+	sig := make([]byte, 65)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	sig[64] = v
+	return sig
+}
+
+// secpVerify 校验签名，测试使用
+func secpVerify(pub *secpPoint, digest, sig []byte) bool {
+	if len(sig) < 64 {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(secpN) >= 0 || s.Cmp(secpN) >= 0 {
+		return false
+	}
+	w := new(big.Int).ModInverse(s, secpN)
+	u1 := new(big.Int).SetBytes(digest)
+	u1.Mul(u1, w).Mod(u1, secpN)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, secpN)
+	X := secpAdd(secpMul(secpG, u1), secpMul(pub, u2))
+	if X == nil {
+		return false
+	}
+	return new(big.Int).Mod(X.x, secpN).Cmp(r) == 0
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// shareSession 匿名浏览分享链接的会话，派生的客户端共享
+	shareSession struct {
+		mu       sync.Mutex
+		shareId  string
+		sharePwd string
+		// canRenew 是否知道提取码，可以重新获取 share token
+		canRenew bool
+		token    ShareToken
+	}
+
+	// ShareFileParam 获取分享链接中的文件参数
+	ShareFileParam struct {
+		ShareId string
+		// ShareToken 为空时使用匿名分享客户端的 share token
+		ShareToken string
+		FileId     string
+		// ExpireSec 下载链接的有效时间，单位秒，为0代表使用默认值
+		ExpireSec int
+	}
+
+	// ShareDownloadUrlResult 分享链接中文件的下载链接
+	ShareDownloadUrlResult struct {
+		DownloadUrl string `json:"download_url"`
+		Url         string `json:"url"`
+		// Expiration 过期时间，本地时间格式
+		Expiration string `json:"expiration"`
+	}
+)
+
+var (
+	// ErrLoginRequired 匿名分享客户端调用需要登录的接口
+	ErrLoginRequired = errors.New("匿名浏览分享链接不支持该接口，需要登录")
+)
+
+// NewPanClientWithShare 创建匿名浏览分享链接的客户端，不需要登录。sharePwd 为提取码，没有提取码时为空。
+// 客户端只能浏览分享链接中的文件和获取下载链接，其他需要登录的接口返回 ErrLoginRequired 错误，share token 过期前自动重新获取
+func NewPanClientWithShare(shareId, sharePwd string) (*PanClient, *apierror.ApiError) {
+	pc := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	token, err := pc.GetShareToken(shareId, sharePwd)
+	if err != nil {
+		return nil, err
+	}
+	pc.share = &shareSession{shareId: shareId, sharePwd: sharePwd, canRenew: true, token: *token}
+	return pc, nil
+}
+
+// NewPanClientWithShareToken 使用已经获取的 share token 创建匿名浏览分享链接的客户端，token 过期后不会自动重新获取
+func NewPanClientWithShareToken(shareId string, token ShareToken) *PanClient {
+	pc := NewPanClient(WebLoginToken{}, AppLoginToken{})
+	pc.share = &shareSession{shareId: shareId, token: token}
+	return pc
+}
+
+// IsShareOnly 是否为只能浏览分享链接的匿名客户端
+func (pc *PanClient) IsShareOnly() bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.share != nil && pc.webToken.AccessToken == ""
+}
+
+// ShareId 匿名分享客户端浏览的分享ID，不是匿名分享客户端时返回空
+func (pc *PanClient) ShareId() string {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if pc.share == nil {
+		return ""
+	}
+	return pc.share.shareId
+}
+
+// CurrentShareToken 返回匿名分享客户端当前的 share token，即将过期并且知道提取码时重新获取
+func (pc *PanClient) CurrentShareToken() (*ShareToken, *apierror.ApiError) {
+	pc.mu.RLock()
+	s := pc.share
+	pc.mu.RUnlock()
+	if s == nil {
+		return nil, apierror.NewFailedApiError("不是匿名分享客户端")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canRenew && s.expiringSoon() {
+		token, err := pc.GetShareToken(s.shareId, s.sharePwd)
+		if err != nil {
+			return nil, err
+		}
+		s.token = *token
+	}
+	token := s.token
+	return &token, nil
+}
+
+// expiringSoon share token 是否将在一分钟内过期，没有记录过期时间时返回false
+func (s *shareSession) expiringSoon() bool {
+	expireTime, err := time.ParseInLocation("2006-01-02 15:04:05", s.token.ExpireTime, time.Local)
+	if err != nil {
+		return false
+	}
+	return time.Until(expireTime) < time.Minute
+}
+
+// shareParam 参数中的分享ID和 share token 为空时使用匿名分享客户端的会话
+func (pc *PanClient) shareParam(shareId, shareToken string) (string, string, *apierror.ApiError) {
+	if shareId != "" && shareToken != "" {
+		return shareId, shareToken, nil
+	}
+	token, err := pc.CurrentShareToken()
+	if err != nil {
+		return "", "", err
+	}
+	if shareId == "" {
+		shareId = pc.ShareId()
+	}
+	if shareToken == "" {
+		shareToken = token.ShareToken
+	}
+	return shareId, shareToken, nil
+}
+
+// ShareFileInfo 获取分享链接中的文件详情
+func (p *PanClient) ShareFileInfo(param *ShareFileParam) (*FileEntity, *apierror.ApiError) {
+	shareId, shareToken, apiErr := p.shareParam(param.ShareId, param.ShareToken)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	header := map[string]string{
+		"x-share-token": shareToken,
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v2/file/get_by_share", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	postData := map[string]interface{}{
+		"share_id": shareId,
+		"file_id":  param.FileId,
+		"fields":   "*",
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get share file info error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &FileEntityRaw{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse share file info result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	return p.newFileEntity(r), nil
+}
+
+// ShareFileDownloadUrl 获取分享链接中文件的下载链接。设置了网页版token时同时携带登录信息，
+// 分享者不允许匿名下载时需要登录
+func (p *PanClient) ShareFileDownloadUrl(param *ShareFileParam) (*ShareDownloadUrlResult, *apierror.ApiError) {
+	shareId, shareToken, apiErr := p.shareParam(param.ShareId, param.ShareToken)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	header := map[string]string{
+		"x-share-token": shareToken,
+	}
+	if p.HasWebToken() {
+		header["authorization"] = p.authorizationStr()
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/file/get_share_link_download_url", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	expireSec := param.ExpireSec
+	if expireSec <= 0 {
+		expireSec = 600
+	}
+	postData := map[string]interface{}{
+		"share_id":   shareId,
+		"file_id":    param.FileId,
+		"expire_sec": expireSec,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get share file download url error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &ShareDownloadUrlResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse share file download url result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	if r.DownloadUrl == "" {
+		r.DownloadUrl = r.Url
+	}
+	r.Expiration = apiutil.UtcTime2LocalFormat(r.Expiration)
+	return r, nil
+}
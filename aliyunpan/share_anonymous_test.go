@@ -0,0 +1,64 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShareOnlyClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	expireTime := time.Now().Add(2 * time.Hour).Format("2006-01-02 15:04:05")
+	p := NewPanClientWithShareToken("s1", ShareToken{ShareToken: "st1", ExpireTime: expireTime})
+	if !p.IsShareOnly() || p.ShareId() != "s1" {
+		t.Fatal("expected share only client")
+	}
+
+	// 需要登录的接口直接返回错误
+	if _, err := p.fetch("POST", server.URL, map[string]string{}, map[string]string{"authorization": p.authorizationStr()}); err != ErrLoginRequired {
+		t.Fatalf("expected login required, got %v", err)
+	}
+	if body, err := p.fetch("POST", server.URL, map[string]string{}, map[string]string{"x-share-token": "st1"}); err != nil || string(body) != `{"ok":true}` {
+		t.Fatalf("unexpected share request result %s %v", body, err)
+	}
+
+	shareId, shareToken, err := p.shareParam("", "")
+	if err != nil || shareId != "s1" || shareToken != "st1" {
+		t.Fatalf("unexpected share param %s %s %v", shareId, shareToken, err)
+	}
+	if shareId, shareToken, _ = p.shareParam("s2", "st2"); shareId != "s2" || shareToken != "st2" {
+		t.Fatalf("explicit share param should be used %s %s", shareId, shareToken)
+	}
+
+	// 没有提取码时不会重新获取过期的 share token
+	expired := NewPanClientWithShareToken("s1", ShareToken{ShareToken: "st0", ExpireTime: "2000-01-01 00:00:00"})
+	if token, err := expired.CurrentShareToken(); err != nil || token.ShareToken != "st0" {
+		t.Fatalf("unexpected share token %v %v", token, err)
+	}
+
+	if _, err := NewPanClient(WebLoginToken{}, AppLoginToken{}).CurrentShareToken(); err == nil {
+		t.Fatal("expected error for non share client")
+	}
+	if c := p.CloneWithToken(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1"}); c.IsShareOnly() || c.ShareId() != "s1" {
+		t.Fatal("client with web token should not be share only")
+	}
+}
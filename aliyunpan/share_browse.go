@@ -0,0 +1,140 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// ShareToken 浏览分享链接使用的token
+	ShareToken struct {
+		ShareToken string `json:"share_token"`
+		// ExpireTime 过期时间，本地时间格式
+		ExpireTime string `json:"expire_time"`
+		ExpiresIn  int    `json:"expires_in"`
+	}
+
+	// ShareFileListParam 获取分享链接中的文件列表参数
+	ShareFileListParam struct {
+		ShareId    string
+		ShareToken string
+		// ParentFileId 上级文件夹ID，为空代表分享的根目录
+		ParentFileId string
+		Marker       string
+		Limit        int
+	}
+)
+
+// GetShareToken 获取浏览分享链接使用的token，sharePwd 为提取码，没有提取码时为空
+func (p *PanClient) GetShareToken(shareId, sharePwd string) (*ShareToken, *apierror.ApiError) {
+	header := map[string]string{}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/v2/share_link/get_share_token", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	postData := map[string]interface{}{
+		"share_id":  shareId,
+		"share_pwd": sharePwd,
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get share token error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &ShareToken{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse share token result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	r.ExpireTime = apiutil.UtcTime2LocalFormat(r.ExpireTime)
+	return r, nil
+}
+
+// ShareFileList 获取分享链接中的文件列表，一次获取一页。匿名分享客户端的分享ID和 share token 可以为空
+func (p *PanClient) ShareFileList(param *ShareFileListParam) (*FileListResult, *apierror.ApiError) {
+	shareId, shareToken, apiErr := p.shareParam(param.ShareId, param.ShareToken)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	header := map[string]string{
+		"x-share-token": shareToken,
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v2/file/list_by_share", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+
+	parentFileId := param.ParentFileId
+	if parentFileId == "" {
+		parentFileId = DefaultRootParentFileId
+	}
+	postData := map[string]interface{}{
+		"share_id":        shareId,
+		"parent_file_id":  parentFileId,
+		"limit":           p.pageSize(param.Limit),
+		"order_by":        "name",
+		"order_direction": "ASC",
+	}
+	if param.Marker != "" {
+		postData["marker"] = param.Marker
+	}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get share file list error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &fileListResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse share file list result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	result := &FileListResult{
+		FileList:   FileList{},
+		NextMarker: r.NextMarker,
+	}
+	for _, item := range r.Items {
+		if item == nil {
+			continue
+		}
+		result.FileList = append(result.FileList, p.newFileEntity(item))
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// SharePending 分享链接转存到网盘文件夹还需要处理的文件
+	SharePending struct {
+		// Missing 网盘文件夹中不存在的文件和文件夹
+		Missing []*SnapshotEntry
+		// Different 网盘文件夹中存在同名文件，但是大小或者内容不同
+		Different []*SnapshotEntry
+		// Present 已经存在并且一致的文件数量
+		Present int
+	}
+
+	// shareListFunc 获取分享链接中文件夹的一页文件
+	shareListFunc func(parentFileId, marker string) (*FileListResult, *apierror.ApiError)
+)
+
+// ShareSnapshot 递归获取分享链接中的所有文件，生成目录树快照。快照中的路径以分享的根目录为 "/"。
+// 分享接口通常不返回文件的 ContentHash，比较时只能使用文件大小
+func (p *PanClient) ShareSnapshot(shareId, sharePwd string) (*TreeSnapshot, *apierror.ApiError) {
+	token, err := p.GetShareToken(shareId, sharePwd)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := buildShareSnapshot(func(parentFileId, marker string) (*FileListResult, *apierror.ApiError) {
+		return p.ShareFileList(&ShareFileListParam{
+			ShareId:      shareId,
+			ShareToken:   token.ShareToken,
+			ParentFileId: parentFileId,
+			Marker:       marker,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	snapshot.ShareId = shareId
+	return snapshot, nil
+}
+
+// ShareSavePending 比较分享链接和网盘文件夹 targetPath，返回转存到该文件夹还需要处理的文件。targetPath 不存在时所有文件都需要转存
+func (p *PanClient) ShareSavePending(shareId, sharePwd, driveId, targetPath string) (*SharePending, *apierror.ApiError) {
+	share, err := p.ShareSnapshot(shareId, sharePwd)
+	if err != nil {
+		return nil, err
+	}
+	target, err := p.TreeSnapshot(driveId, targetPath)
+	if err != nil {
+		if err.Code != apierror.ApiCodeFileNotFoundCode {
+			return nil, err
+		}
+		target = nil
+	}
+	return share.Pending(target), nil
+}
+
+func buildShareSnapshot(list shareListFunc) (*TreeSnapshot, *apierror.ApiError) {
+	snapshot := &TreeSnapshot{
+		Version:   TreeSnapshotVersion,
+		RootPath:  "/",
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Entries:   []*SnapshotEntry{},
+	}
+	// 广度优先获取，queue 中为文件夹
+	queue := []*FileEntity{{FileId: DefaultRootParentFileId, Path: "/"}}
+	for len(queue) > 0 {
+		folder := queue[0]
+		queue = queue[1:]
+		pg := NewPaginator("", func(marker string) (string, *apierror.ApiError) {
+			r, err := list(folder.FileId, marker)
+			if err != nil {
+				return "", err
+			}
+			for _, fe := range r.FileList {
+				fe.Path = apiutil.JoinDrivePath(folder.Path, fe.FileName)
+				if snapshot.DriveId == "" {
+					snapshot.DriveId = fe.DriveId
+				}
+				snapshot.Entries = append(snapshot.Entries, newSnapshotEntry(fe))
+				if fe.IsFolder() {
+					queue = append(queue, fe)
+				}
+			}
+			return r.NextMarker, nil
+		})
+		if err := pg.All(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(snapshot.Entries, func(i, j int) bool {
+		return snapshot.Entries[i].Path < snapshot.Entries[j].Path
+	})
+	return snapshot, nil
+}
+
+// Pending 按相对根目录的路径比较 s 和 target，返回 s 中 target 缺少或者不一致的文件。
+// 文件大小相同时，两边都有 ContentHash 才比较内容。target 为nil代表目标文件夹不存在
+func (s *TreeSnapshot) Pending(target *TreeSnapshot) *SharePending {
+	result := &SharePending{
+		Missing:   []*SnapshotEntry{},
+		Different: []*SnapshotEntry{},
+	}
+	existing := map[string]*SnapshotEntry{}
+	if target != nil {
+		for _, e := range target.Entries {
+			if rel, ok := apiutil.RelDrivePath(target.RootPath, e.Path); ok {
+				existing[rel] = e
+			}
+		}
+	}
+	for _, e := range s.Entries {
+		rel, ok := apiutil.RelDrivePath(s.RootPath, e.Path)
+		if !ok {
+			continue
+		}
+		t, ok := existing[rel]
+		switch {
+		case !ok:
+			result.Missing = append(result.Missing, e)
+		case e.IsFolder != t.IsFolder:
+			result.Different = append(result.Different, e)
+		case e.IsFolder:
+			// 文件夹已经存在，里面的文件单独比较
+		case e.FileSize != t.FileSize,
+			e.ContentHash != "" && t.ContentHash != "" && !strings.EqualFold(e.ContentHash, t.ContentHash):
+			result.Different = append(result.Different, e)
+		default:
+			result.Present++
+		}
+	}
+	return result
+}
+
+// IsComplete 是否已经全部转存
+func (r *SharePending) IsComplete() bool {
+	return r == nil || (len(r.Missing) == 0 && len(r.Different) == 0)
+}
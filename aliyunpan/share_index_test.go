@@ -0,0 +1,61 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"testing"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestShareSnapshotPending(t *testing.T) {
+	pages := map[string]*FileListResult{
+		"root":    {FileList: FileList{{FileId: "a", FileName: "a", FileType: "folder", DriveId: "s1"}}, NextMarker: "m1"},
+		"root/m1": {FileList: FileList{{FileId: "b", FileName: "b.txt", FileType: "file", FileSize: 3}}},
+		"a": {FileList: FileList{
+			{FileId: "c", FileName: "c.txt", FileType: "file", FileSize: 5},
+			{FileId: "d", FileName: "d.txt", FileType: "file", FileSize: 7},
+		}},
+	}
+	share, err := buildShareSnapshot(func(parentFileId, marker string) (*FileListResult, *apierror.ApiError) {
+		key := parentFileId
+		if marker != "" {
+			key += "/" + marker
+		}
+		return pages[key], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if share.DriveId != "s1" || len(share.Entries) != 4 || share.Entries[1].Path != "/a/c.txt" {
+		t.Fatalf("unexpected snapshot %+v", share.Entries)
+	}
+
+	target := &TreeSnapshot{RootPath: "/save", Entries: []*SnapshotEntry{
+		{Path: "/save/a", IsFolder: true},
+		{Path: "/save/a/c.txt", FileSize: 5, ContentHash: "X"},
+		{Path: "/save/a/d.txt", FileSize: 8},
+	}}
+	pending := share.Pending(target)
+	if len(pending.Missing) != 1 || pending.Missing[0].Path != "/b.txt" {
+		t.Fatalf("unexpected missing %+v", pending.Missing)
+	}
+	if len(pending.Different) != 1 || pending.Different[0].Path != "/a/d.txt" || pending.Present != 1 {
+		t.Fatalf("unexpected pending %+v", pending)
+	}
+	if pending.IsComplete() || len(share.Pending(nil).Missing) != 4 {
+		t.Fatal("expected all files pending")
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// Closer 可以在 PanClient.Close 时停止的子系统，例如传输任务管理器、目录监听等。
+	// Close 需要停止后台协程并保存需要持久化的状态，ctx 超时后应该尽快返回
+	Closer interface {
+		Close(ctx context.Context) error
+	}
+
+	// CloserFunc 函数形式的 Closer
+	CloserFunc func(ctx context.Context) error
+
+	// CloserRegistry 可以注册子系统的客户端，PanClient 实现了该接口
+	CloserRegistry interface {
+		RegisterCloser(c Closer) (unregister func())
+	}
+
+	// lifecycle 客户端的生命周期，WithContext 等派生的客户端共享，CloneWithToken 派生的客户端单独创建
+	lifecycle struct {
+		mu      sync.Mutex
+		nextId  int
+		closers map[int]Closer
+		// order 注册顺序，关闭时按相反的顺序
+		order  []int
+		closed bool
+		done   chan struct{}
+		// parent CloneWithToken 的父客户端的生命周期，父客户端关闭时一起关闭
+		parent *lifecycle
+		// attach 保证只向父客户端注册一次，detach 用于取消注册
+		attach sync.Once
+		detach func()
+	}
+
+	// multiError 关闭多个子系统时的错误
+	multiError []error
+)
+
+var (
+	// ErrClientClosed 客户端已经关闭
+	ErrClientClosed = errors.New("pan client closed")
+)
+
+// Close 调用 f(ctx)
+func (f CloserFunc) Close(ctx context.Context) error {
+	return f(ctx)
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{
+		closers: map[int]Closer{},
+		done:    make(chan struct{}),
+	}
+}
+
+// forkLifecycle 为 CloneWithToken 派生的客户端创建单独的生命周期，父客户端关闭时一起关闭
+func (pc *PanClient) forkLifecycle(c *PanClient) {
+	c.lifecycle = newLifecycle()
+	c.lifecycle.parent = pc.lifecycle
+	c.ownsLifecycle = true
+}
+
+// attachLifecycle 派生的客户端注册子系统或者监听关闭时才注册到父客户端，
+// 避免大量没有调用 Close 的派生客户端一直被父客户端引用
+func (pc *PanClient) attachLifecycle() {
+	l := pc.lifecycle
+	if l == nil || l.parent == nil {
+		return
+	}
+	l.attach.Do(func() {
+		detach, ok := l.parent.register(CloserFunc(pc.closeLifecycle))
+		if !ok {
+			// 父客户端已经关闭
+			pc.closeLifecycle(context.Background())
+			return
+		}
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.closed {
+			detach()
+			return
+		}
+		l.detach = detach
+	})
+}
+
+func (e multiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// RegisterCloser 注册子系统，PanClient.Close 时按注册的相反顺序关闭。返回的函数用于取消注册，子系统自己停止后调用。
+// 客户端已经关闭时立即返回，c 不会被调用
+func (pc *PanClient) RegisterCloser(c Closer) (unregister func()) {
+	if pc == nil || pc.lifecycle == nil {
+		return func() {}
+	}
+	pc.attachLifecycle()
+	unregister, _ = pc.lifecycle.register(c)
+	return unregister
+}
+
+// register 注册子系统，生命周期已经结束时返回false
+func (l *lifecycle) register(c Closer) (unregister func(), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return func() {}, false
+	}
+	id := l.nextId
+	l.nextId++
+	l.closers[id] = c
+	l.order = append(l.order, id)
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.closers, id)
+		for i, v := range l.order {
+			if v == id {
+				l.order = append(l.order[:i], l.order[i+1:]...)
+				break
+			}
+		}
+	}, true
+}
+
+// Done 返回客户端关闭时关闭的通道，后台任务可以用来退出
+func (pc *PanClient) Done() <-chan struct{} {
+	if pc.lifecycle == nil {
+		return nil
+	}
+	pc.attachLifecycle()
+	return pc.lifecycle.done
+}
+
+// isClosed 客户端是否已经关闭
+func (pc *PanClient) isClosed() bool {
+	for l := pc.lifecycle; l != nil; l = l.parent {
+		select {
+		case <-l.done:
+			return true
+		default:
+		}
+	}
+	return false
+}
+
+// Close 关闭客户端以及派生的客户端：按注册的相反顺序关闭子系统，停止后台刷新任务，
+// 最后把元数据缓存写入文件。关闭后接口请求返回 ErrClientClosed。所有客户端共享同一个http连接池，关闭时不会释放。
+// ctx 超时后不再等待剩余的子系统，返回 ctx 的错误。重复调用直接返回nil。
+// CloneWithToken 派生的客户端关闭时只关闭自己，不影响父客户端和其他派生的客户端；
+// WithContext、WithRequestClass 等派生的客户端和父客户端是同一个客户端，调用 Close 没有任何作用，需要关闭父客户端
+func (pc *PanClient) Close(ctx context.Context) error {
+	if pc.lifecycle == nil || !pc.ownsLifecycle {
+		return nil
+	}
+	return pc.closeLifecycle(ctx)
+}
+
+// closeLifecycle 关闭客户端的生命周期
+func (pc *PanClient) closeLifecycle(ctx context.Context) error {
+	l := pc.lifecycle
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	detach := l.detach
+	closers := make([]Closer, 0, len(l.closers))
+	for i := len(l.order) - 1; i >= 0; i-- {
+		if c, ok := l.closers[l.order[i]]; ok {
+			closers = append(closers, c)
+		}
+	}
+	l.closers = map[int]Closer{}
+	l.order = nil
+	l.mu.Unlock()
+
+	errs := multiError{}
+	// 先关闭子系统，子系统关闭时可能还需要发起请求
+	for _, c := range closers {
+		if err := closeWithContext(ctx, c); err != nil {
+			logger.Verboseln("close subsystem error ", err)
+			errs = append(errs, err)
+			if ctx.Err() != nil {
+				break
+			}
+		}
+	}
+	close(l.done)
+	if detach != nil {
+		detach()
+	}
+
+	if f, ok := pc.MetaStore().(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs
+}
+
+// closeWithContext 关闭子系统，ctx 超时后不再等待
+func closeWithContext(ctx context.Context, c Closer) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- c.Close(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// TokenRefreshFunc 使用 refresh token 获取新的 token
+	TokenRefreshFunc func(refreshToken string) (*WebLoginToken, *apierror.ApiError)
+
+	// TokenRefreshedFunc token 自动刷新后的回调，用于保存新的 token
+	TokenRefreshedFunc func(token WebLoginToken)
+
+	// tokenRefresher 自动刷新 token，派生的客户端共享同一个 tokenRefresher，同一时间只刷新一次
+	tokenRefresher struct {
+		mu          sync.Mutex
+		refresh     TokenRefreshFunc
+		onRefreshed TokenRefreshedFunc
+		// latest 最近一次刷新得到的 token
+		latest *WebLoginToken
+		// inflight 正在进行的刷新，为nil代表没有刷新
+		inflight *refreshCall
+	}
+
+	// refreshCall 一次 token 刷新，使用同样授权信息的请求等待并共享刷新结果
+	refreshCall struct {
+		done chan struct{}
+		// used 触发刷新的请求使用的授权信息
+		used string
+		// token 刷新得到的 token，刷新失败为nil
+		token *WebLoginToken
+	}
+
+	// tokenHook token 刷新回调，派生的客户端共享，注册之前派生的客户端也会调用
+	tokenHook struct {
+		mu sync.RWMutex
+		fn TokenRefreshedFunc
+	}
+)
+
+// DefaultTokenRefreshSkew 默认提前刷新 token 的时间
+const DefaultTokenRefreshSkew = 5 * time.Minute
+
+// ExpiresAt access token 过期时间，ExpireTime 为空或者格式错误时返回零值
+func (w *WebLoginToken) ExpiresAt() time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", w.ExpireTime, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ExpiresAt access token 过期时间，ExpireTime 为空或者格式错误时返回零值
+func (t *OpenToken) ExpiresAt() time.Time {
+	expireTime, err := time.ParseInLocation("2006-01-02 15:04:05", t.ExpireTime, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return expireTime
+}
+
+// SetTokenRefreshSkew 设置提前刷新 token 的时间，默认为 DefaultTokenRefreshSkew。开启自动刷新后，
+// 发起请求时如果 token 将在 skew 内过期，先刷新 token 再请求，避免上传等长时间操作中途授权失败。skew 为0代表只在请求返回token失效后刷新
+func (pc *PanClient) SetTokenRefreshSkew(skew time.Duration) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if skew < 0 {
+		skew = 0
+	}
+	pc.refreshSkew = skew
+}
+
+// tokenExpiringSoon 开启了自动刷新并且 authorization 对应的 token 即将过期。token 没有记录过期时间时返回false
+func (pc *PanClient) tokenExpiringSoon(authorization string) bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	if pc.refresher == nil || pc.refreshSkew <= 0 || pc.webToken.GetAuthorizationStr() != authorization {
+		return false
+	}
+	expiresAt := pc.webToken.ExpiresAt()
+	return !expiresAt.IsZero() && time.Until(expiresAt) < pc.refreshSkew
+}
+
+// EnableAutoRefresh 开启 token 自动刷新。token 即将过期时提前刷新（参见 SetTokenRefreshSkew），
+// 请求返回 AccessTokenInvalid 或者 AccessTokenExpired 时，使用 refresh token 获取新的 token 并重试原请求。onRefreshed 可以为nil，不为nil时每次刷新成功后调用，用于保存新的 token
+// 使用 NewPanClientWithAppToken 创建的客户端使用手机客户端的方式刷新
+func (pc *PanClient) EnableAutoRefresh(onRefreshed TokenRefreshedFunc) {
+	if pc.IsAppMode() {
+		pc.SetTokenRefreshFunc(refreshAppToken, onRefreshed)
+		return
+	}
+	pc.SetTokenRefreshFunc(GetAccessTokenFromRefreshToken, onRefreshed)
+}
+
+// SetTokenRefreshFunc 使用自定义的刷新函数开启 token 自动刷新，refresh 为nil代表关闭
+func (pc *PanClient) SetTokenRefreshFunc(refresh TokenRefreshFunc, onRefreshed TokenRefreshedFunc) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if refresh == nil {
+		pc.refresher = nil
+		return
+	}
+	pc.refresher = &tokenRefresher{
+		refresh:     refresh,
+		onRefreshed: onRefreshed,
+	}
+}
+
+// OnTokenRefreshed 注册 token 刷新回调，token 自动刷新后立即调用，用于把新的 access token 和 refresh token
+// 保存到磁盘或者数据库，避免进程崩溃后丢失登录状态。再次调用会替换之前的回调，fn 为nil代表取消
+func (pc *PanClient) OnTokenRefreshed(fn TokenRefreshedFunc) {
+	pc.mu.Lock()
+	if pc.tokenHook == nil {
+		pc.tokenHook = &tokenHook{}
+	}
+	h := pc.tokenHook
+	pc.mu.Unlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fn = fn
+}
+
+func (h *tokenHook) call(token WebLoginToken) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	fn := h.fn
+	h.mu.RUnlock()
+	if fn != nil {
+		fn(token)
+	}
+}
+
+// DisableAutoRefresh 关闭 token 自动刷新
+func (pc *PanClient) DisableAutoRefresh() {
+	pc.SetTokenRefreshFunc(nil, nil)
+}
+
+// isTokenInvalidBody 响应是否为 token 无效或过期的错误
+func isTokenInvalidBody(body []byte) bool {
+	if !bytes.Contains(body, []byte("AccessToken")) {
+		return false
+	}
+	err := apierror.ParseCommonApiError(body)
+	return err != nil && (err.Code == apierror.ApiCodeAccessTokenInvalid || err.Code == apierror.ApiCodeTokenExpiredCode)
+}
+
+// refreshToken 刷新 token，usedAuthorization 为请求失败时使用的授权信息。同一时间只有一个请求刷新，
+// 使用同样授权信息的请求等待并共享刷新结果，刷新失败时同样返回失败，避免重复使用失效的 refresh token。
+// 其他请求已经刷新过时直接使用刷新后的 token。返回新的授权信息，刷新失败返回false
+func (pc *PanClient) refreshToken(usedAuthorization string) (string, bool) {
+	pc.mu.RLock()
+	r := pc.refresher
+	pc.mu.RUnlock()
+	if r == nil {
+		return "", false
+	}
+
+	for {
+		r.mu.Lock()
+		c := r.inflight
+		if c == nil {
+			break
+		}
+		r.mu.Unlock()
+		<-c.done
+		if c.used == usedAuthorization {
+			if c.token == nil {
+				return "", false
+			}
+			pc.UpdateToken(*c.token)
+			return c.token.GetAuthorizationStr(), true
+		}
+	}
+
+	// 持有锁并且没有正在进行的刷新
+	pc.mu.RLock()
+	current := pc.webToken
+	pc.mu.RUnlock()
+	if current.GetAuthorizationStr() != usedAuthorization {
+		// 当前客户端已经更新了 token
+		r.mu.Unlock()
+		return current.GetAuthorizationStr(), true
+	}
+	if r.latest != nil && r.latest.GetAuthorizationStr() != usedAuthorization {
+		// 共享的客户端已经刷新过
+		latest := *r.latest
+		r.mu.Unlock()
+		pc.UpdateToken(latest)
+		return latest.GetAuthorizationStr(), true
+	}
+	c := &refreshCall{done: make(chan struct{}), used: usedAuthorization}
+	r.inflight = c
+	r.mu.Unlock()
+
+	c.token = pc.doRefreshToken(r, current, usedAuthorization)
+	r.mu.Lock()
+	if c.token != nil {
+		r.latest = c.token
+	}
+	r.inflight = nil
+	r.mu.Unlock()
+	close(c.done)
+	if c.token == nil {
+		return "", false
+	}
+	return c.token.GetAuthorizationStr(), true
+}
+
+// doRefreshToken 使用 refresh token 获取新的 token，设置了 token 存储时优先使用其他进程保存的 token。
+// 返回新的 token，失败返回nil
+func (pc *PanClient) doRefreshToken(r *tokenRefresher, current WebLoginToken, usedAuthorization string) *WebLoginToken {
+	pc.mu.RLock()
+	hook := pc.tokenHook
+	store := pc.tokenStore
+	pc.mu.RUnlock()
+
+	refreshToken := current.RefreshToken
+	if store != nil {
+		unlock, err := lockTokenStore(store)
+		if err != nil {
+			logger.Verboseln("lock token store error ", err)
+			return nil
+		}
+		defer unlock()
+		if stored, err := store.Load(); err == nil {
+			if stored.GetAuthorizationStr() != usedAuthorization && !stored.IsAccessTokenExpired() {
+				// 其他进程已经刷新过并保存，并且没有过期
+				pc.UpdateToken(*stored)
+				return stored
+			}
+			if stored.RefreshToken != "" {
+				// 其他进程刷新后旧的 refresh token 已经失效，使用保存的 refresh token
+				refreshToken = stored.RefreshToken
+			}
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	token, err := r.refresh(refreshToken)
+	if err != nil || token == nil {
+		logger.Verboseln("refresh access token error ", err)
+		return nil
+	}
+	pc.UpdateToken(*token)
+	if store != nil {
+		if err := store.Save(*token); err != nil {
+			logger.Verboseln("save refreshed token error ", err)
+		}
+	}
+	if r.onRefreshed != nil {
+		r.onRefreshed(*token)
+	}
+	hook.call(*token)
+	return token
+}
@@ -0,0 +1,278 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// TokenStore token 持久化存储。设置到 PanClient 后，自动刷新得到的 token 会保存到存储中
+	TokenStore interface {
+		// Load 读取保存的 token，没有保存过返回 ErrTokenNotFound
+		Load() (*WebLoginToken, error)
+		// Save 保存 token
+		Save(token WebLoginToken) error
+		// Delete 删除保存的 token
+		Delete() error
+	}
+
+	// TokenStoreLocker 可以加锁的 TokenStore。刷新 token 期间持有锁，多个进程共享同一个存储时，
+	// 只有一个进程使用 refresh token 刷新，其他进程直接读取刷新后的 token
+	TokenStoreLocker interface {
+		Lock() (unlock func(), err error)
+	}
+
+	// MemoryTokenStore 内存 token 存储，用于测试或者同一进程的多个客户端共享 token
+	MemoryTokenStore struct {
+		mu    sync.Mutex
+		token *WebLoginToken
+		// lock 刷新 token 时使用的锁
+		lock sync.Mutex
+	}
+
+	// FileTokenStore 文件 token 存储，token 以JSON格式保存。写入时先写临时文件再替换，
+	// 加锁使用同目录下的 .lock 文件，可以在多个进程之间共享
+	FileTokenStore struct {
+		path string
+		// LockTimeout 获取锁的最长等待时间，默认为 DefaultTokenLockTimeout
+		LockTimeout time.Duration
+		// StaleLockAge 锁文件超过该时间没有释放视为持有锁的进程已经退出，默认为 DefaultStaleTokenLockAge
+		StaleLockAge time.Duration
+	}
+)
+
+const (
+	// DefaultTokenLockTimeout 获取 token 文件锁的默认等待时间
+	DefaultTokenLockTimeout = 30 * time.Second
+	// DefaultStaleTokenLockAge token 文件锁的默认过期时间
+	DefaultStaleTokenLockAge = time.Minute
+
+	tokenLockRetryInterval = 20 * time.Millisecond
+)
+
+var (
+	// ErrTokenNotFound 存储中没有保存 token
+	ErrTokenNotFound = errors.New("token not found")
+	// ErrTokenLockTimeout 等待 token 文件锁超时
+	ErrTokenLockTimeout = errors.New("wait token lock timeout")
+)
+
+// NewMemoryTokenStore 创建内存 token 存储
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Load 读取保存的 token
+func (s *MemoryTokenStore) Load() (*WebLoginToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil {
+		return nil, ErrTokenNotFound
+	}
+	t := *s.token
+	return &t, nil
+}
+
+// Save 保存 token
+func (s *MemoryTokenStore) Save(token WebLoginToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = &token
+	return nil
+}
+
+// Delete 删除保存的 token
+func (s *MemoryTokenStore) Delete() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = nil
+	return nil
+}
+
+// Lock 加锁，实现 TokenStoreLocker
+func (s *MemoryTokenStore) Lock() (func(), error) {
+	s.lock.Lock()
+	return s.lock.Unlock, nil
+}
+
+// NewFileTokenStore 创建文件 token 存储，path 为保存 token 的文件路径
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{
+		path:         path,
+		LockTimeout:  DefaultTokenLockTimeout,
+		StaleLockAge: DefaultStaleTokenLockAge,
+	}
+}
+
+// Path 保存 token 的文件路径
+func (s *FileTokenStore) Path() string {
+	return s.path
+}
+
+// Load 读取保存的 token
+func (s *FileTokenStore) Load() (*WebLoginToken, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	token := &WebLoginToken{}
+	if err = json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Save 保存 token，先写入临时文件再替换，其他进程不会读到写了一半的文件
+func (s *FileTokenStore) Save(token WebLoginToken) error {
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Delete 删除保存的 token 文件
+func (s *FileTokenStore) Delete() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Lock 创建锁文件加锁，实现 TokenStoreLocker。锁文件中写入本次加锁的持有者标识，解锁时只删除自己的锁文件。
+// 锁文件已经存在时等待，超过 StaleLockAge 的锁文件视为过期并删除
+func (s *FileTokenStore) Lock() (func(), error) {
+	lockPath := s.path + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0700); err != nil {
+		return nil, err
+	}
+	timeout := s.LockTimeout
+	if timeout <= 0 {
+		timeout = DefaultTokenLockTimeout
+	}
+	staleAge := s.StaleLockAge
+	if staleAge <= 0 {
+		staleAge = DefaultStaleTokenLockAge
+	}
+	owner := apiutil.Uuid()
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if err1 := f.Close(); err == nil {
+				err = err1
+			}
+			if err != nil {
+				removeLockFile(lockPath, owner, owner)
+				return nil, err
+			}
+			return func() {
+				if !removeLockFile(lockPath, owner, owner) {
+					logger.Verboseln("token lock was taken over by others ", lockPath)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err1 := os.Stat(lockPath); err1 == nil && time.Since(fi.ModTime()) > staleAge {
+			// 只删除判断为过期时看到的那个锁文件，期间被其他进程重新加锁时保留
+			if stale, err2 := ioutil.ReadFile(lockPath); err2 == nil && removeLockFile(lockPath, string(stale), owner) {
+				logger.Verboseln("remove stale token lock ", lockPath)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrTokenLockTimeout
+		}
+		time.Sleep(tokenLockRetryInterval)
+	}
+}
+
+// removeLockFile 锁文件的持有者标识为 holder 时删除锁文件，返回是否删除。
+// 先把锁文件原子地重命名为只属于 owner 的临时文件再检查内容，不是 holder 的锁文件时恢复，避免误删其他进程刚创建的锁
+func removeLockFile(lockPath, holder, owner string) bool {
+	tmpPath := lockPath + "." + owner
+	if err := os.Rename(lockPath, tmpPath); err != nil {
+		return false
+	}
+	data, err := ioutil.ReadFile(tmpPath)
+	if err == nil && string(data) == holder {
+		os.Remove(tmpPath)
+		return true
+	}
+	// 恢复其他持有者的锁文件，Link 不会覆盖期间新创建的锁文件
+	os.Link(tmpPath, lockPath)
+	os.Remove(tmpPath)
+	return false
+}
+
+// SetTokenStore 设置 token 存储，store 为nil代表取消。设置后自动刷新得到的 token 会保存到存储中；
+// 刷新前先读取存储，其他客户端或者其他进程已经刷新过时直接使用存储中的 token，避免 refresh token 被重复使用而失效
+func (pc *PanClient) SetTokenStore(store TokenStore) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.tokenStore = store
+}
+
+// TokenStore 返回设置的 token 存储，没有设置返回nil
+func (pc *PanClient) TokenStore() TokenStore {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	return pc.tokenStore
+}
+
+// lockTokenStore 存储支持加锁时加锁，返回解锁函数。加锁失败时返回错误，不能在没有锁的情况下刷新 token，
+// 否则多个进程可能同时使用同一个 refresh token 导致失效
+func lockTokenStore(store TokenStore) (func(), error) {
+	locker, ok := store.(TokenStoreLocker)
+	if !ok {
+		return func() {}, nil
+	}
+	return locker.Lock()
+}
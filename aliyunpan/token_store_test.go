@@ -0,0 +1,211 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewFileTokenStore(filepath.Join(dir, "sub", "token.json"))
+	if _, err := s.Load(); err != ErrTokenNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := s.Save(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a", RefreshToken: "r"}); err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.Load()
+	if err != nil || token.AccessToken != "a" || token.RefreshToken != "r" {
+		t.Fatalf("unexpected token %+v %v", token, err)
+	}
+	if err := s.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(); err != ErrTokenNotFound {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
+
+func TestFileTokenStoreLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 两个存储实例模拟两个进程
+	s1 := NewFileTokenStore(filepath.Join(dir, "token.json"))
+	s2 := NewFileTokenStore(filepath.Join(dir, "token.json"))
+	s2.LockTimeout = 50 * time.Millisecond
+
+	unlock, err := s1.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s2.Lock(); err != ErrTokenLockTimeout {
+		t.Fatalf("expected lock timeout, got %v", err)
+	}
+	unlock()
+
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(s *FileTokenStore) {
+			defer wg.Done()
+			unlock, err := s.Lock()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			holders++
+			if holders > maxHolders {
+				maxHolders = holders
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			unlock()
+		}(NewFileTokenStore(filepath.Join(dir, "token.json")))
+	}
+	wg.Wait()
+	if maxHolders != 1 {
+		t.Fatalf("expected exclusive lock, got %d holders", maxHolders)
+	}
+
+	// 持有锁的进程退出后留下的锁文件过期
+	if err := ioutil.WriteFile(filepath.Join(dir, "token.json.lock"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "token.json.lock"), old, old)
+	if unlock, err := s2.Lock(); err != nil {
+		t.Fatalf("expected stale lock removed, got %v", err)
+	} else {
+		unlock()
+	}
+
+	// 锁被其他进程当作过期锁接管后，解锁不能删除其他进程的锁
+	unlock, err = s1.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(filepath.Join(dir, "token.json.lock"), old, old)
+	unlock2, err := s2.Lock()
+	if err != nil {
+		t.Fatalf("expected stale lock taken over, got %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(filepath.Join(dir, "token.json.lock")); err != nil {
+		t.Fatal("unlock should not remove the lock of another owner")
+	}
+	unlock2()
+	if _, err := os.Stat(filepath.Join(dir, "token.json.lock")); !os.IsNotExist(err) {
+		t.Fatal("expected lock removed by its owner")
+	}
+}
+
+func TestTokenStoreRefresh(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("authorization") {
+		case "Bearer new", "Bearer other":
+			w.Write([]byte(`{"ok":true}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"AccessTokenInvalid","message":"AccessToken is invalid"}`))
+		}
+	}))
+	defer server.Close()
+
+	expire := time.Now().Add(time.Hour).Format("2006-01-02 15:04:05")
+	store := NewMemoryTokenStore()
+	refreshed := 0
+	refresh := func(refreshToken string) (*WebLoginToken, *apierror.ApiError) {
+		refreshed++
+		return &WebLoginToken{AccessTokenType: "Bearer", AccessToken: "new", RefreshToken: refreshToken + "+", ExpireTime: expire}, nil
+	}
+
+	p := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r1"}, AppLoginToken{})
+	p.SetTokenRefreshFunc(refresh, nil)
+	p.SetTokenStore(store)
+	header := map[string]string{"authorization": p.authorizationStr()}
+	if body, err := p.fetch("POST", server.URL, map[string]string{}, header); err != nil || string(body) != `{"ok":true}` {
+		t.Fatalf("unexpected result %s %v", body, err)
+	}
+	if saved, err := store.Load(); err != nil || saved.AccessToken != "new" || saved.RefreshToken != "r1+" {
+		t.Fatalf("refreshed token not saved %+v %v", saved, err)
+	}
+
+	// 另一个进程已经刷新并保存，直接使用保存的 token，不再刷新
+	store.Save(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "other", RefreshToken: "r2", ExpireTime: expire})
+	q := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r1"}, AppLoginToken{})
+	q.SetTokenRefreshFunc(refresh, nil)
+	q.SetTokenStore(store)
+	header = map[string]string{"authorization": q.authorizationStr()}
+	if body, err := q.fetch("POST", server.URL, map[string]string{}, header); err != nil || string(body) != `{"ok":true}` {
+		t.Fatalf("unexpected result %s %v", body, err)
+	}
+	if refreshed != 1 || q.GetAccessToken() != "other" {
+		t.Fatalf("expected stored token used, refreshed %d, token %s", refreshed, q.GetAccessToken())
+	}
+
+	// 保存的 token 已经过期时使用保存的 refresh token 刷新
+	store.Save(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "expired", RefreshToken: "r3", ExpireTime: "2000-01-01 00:00:00"})
+	c := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r2"}, AppLoginToken{})
+	c.SetTokenRefreshFunc(refresh, nil)
+	c.SetTokenStore(store)
+	header = map[string]string{"authorization": c.authorizationStr()}
+	c.fetch("POST", server.URL, map[string]string{}, header)
+	if saved, _ := store.Load(); refreshed != 2 || saved.RefreshToken != "r3+" {
+		t.Fatalf("expected refresh with stored refresh token, got %+v", saved)
+	}
+
+	// 获取不到锁时不刷新
+	d := NewPanClient(WebLoginToken{AccessTokenType: "Bearer", AccessToken: "old", RefreshToken: "r3+"}, AppLoginToken{})
+	d.SetTokenRefreshFunc(refresh, nil)
+	d.SetTokenStore(&lockFailTokenStore{store})
+	header = map[string]string{"authorization": d.authorizationStr()}
+	d.fetch("POST", server.URL, map[string]string{}, header)
+	if refreshed != 2 || d.GetAccessToken() != "old" {
+		t.Fatalf("expected no refresh without lock, refreshed %d", refreshed)
+	}
+}
+
+type lockFailTokenStore struct {
+	*MemoryTokenStore
+}
+
+func (s *lockFailTokenStore) Lock() (func(), error) {
+	return nil, ErrTokenLockTimeout
+}
@@ -0,0 +1,349 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	// downloadMeta 临时文件对应的网盘文件版本
+	downloadMeta struct {
+		FileId      string `json:"file_id"`
+		ContentHash string `json:"content_hash"`
+		FileSize    int64  `json:"file_size"`
+		UpdatedAt   string `json:"updated_at"`
+	}
+
+	// FileOption 文件传输选项
+	FileOption struct {
+		// Throttler 限速器，为nil代表不限速
+		Throttler *Throttler
+		// OnProgress 传输进度回调，参数为本次传输的字节数
+		OnProgress func(n int)
+		// Session 上传会话，用于查询上传状态和调整分片并发数，为nil时按顺序上传分片并且不重试
+		Session *UploadSession
+	}
+)
+
+const (
+	// DownloadTmpSuffix 下载临时文件后缀，下载完成后重命名为正式文件，存在该文件时会断点续传
+	DownloadTmpSuffix = ".aliyunpan-download"
+	// DownloadMetaSuffix 断点续传信息文件后缀，记录临时文件对应的网盘文件版本，版本不一致时重新下载
+	DownloadMetaSuffix = ".aliyunpan-download.meta"
+)
+
+func (o *FileOption) wrapReader(ctx context.Context, r io.Reader) io.Reader {
+	if o == nil {
+		return (*Throttler)(nil).NewReader(ctx, r, nil)
+	}
+	return o.Throttler.NewReader(ctx, r, o.OnProgress)
+}
+
+// session 返回上传会话，没有设置时使用按顺序上传并且不重试的会话
+func (o *FileOption) session() *UploadSession {
+	if o != nil && o.Session != nil {
+		return o.Session
+	}
+	s := NewUploadSession(1)
+	s.SetPartRetries(0)
+	return s
+}
+
+// UploadFile 上传本地文件到网盘指定文件夹，同名文件会被覆盖。支持秒传
+func UploadFile(ctx context.Context, panClient *aliyunpan.PanClient, driveId, parentFileId, localPath, fileName string, option *FileOption) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	return uploadReaderAt(ctx, panClient, driveId, parentFileId, &readerAtLen{ReaderAt: f, size: info.Size()}, fileName, localPath, option)
+}
+
+// UploadData 上传内存中的数据作为网盘文件，同名文件会被覆盖。用于保存软链接目标等少量数据
+func UploadData(ctx context.Context, panClient *aliyunpan.PanClient, driveId, parentFileId string, data []byte, fileName string, option *FileOption) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError) {
+	return uploadReaderAt(ctx, panClient, driveId, parentFileId, &readerAtLen{ReaderAt: bytes.NewReader(data), size: int64(len(data))}, fileName, fileName, option)
+}
+
+// readerAtLen 带长度的 io.ReaderAt
+type readerAtLen struct {
+	io.ReaderAt
+	size int64
+}
+
+func (r *readerAtLen) Len() int64 {
+	return r.size
+}
+
+// uploadReaderAt 上传 r 的数据，source 用于日志
+func uploadReaderAt(ctx context.Context, panClient *aliyunpan.PanClient, driveId, parentFileId string, r *readerAtLen, fileName, source string, option *FileOption) (*aliyunpan.CompleteUploadFileResult, *apierror.ApiError) {
+	sha1Str, _, err := apiutil.ComputeHashes(io.NewSectionReader(r, 0, r.size))
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+
+	blockSize := aliyunpan.DefaultChunkSize
+	createParam := &aliyunpan.CreateFileUploadParam{
+		Name:          fileName,
+		DriveId:       driveId,
+		ParentFileId:  parentFileId,
+		Size:          r.size,
+		ContentHash:   strings.ToUpper(sha1Str),
+		CheckNameMode: "overwrite",
+		ProofCode:     aliyunpan.CalcProofCode(panClient.GetAccessToken(), r, r.size),
+		BlockSize:     blockSize,
+	}
+	session := option.session()
+	createParam.ParallelUpload = option != nil && option.Session != nil
+	createResult, apierr := panClient.CreateUploadFile(createParam)
+	if apierr != nil {
+		return nil, apierr
+	}
+	session.begin(fileName, r.size, len(createResult.PartInfoList))
+	if !createResult.RapidUpload {
+		uploadUrls := map[int]string{}
+		parts := make([]int, 0, len(createResult.PartInfoList))
+		for _, part := range createResult.PartInfoList {
+			uploadUrls[part.PartNumber] = part.UploadURL
+			parts = append(parts, part.PartNumber)
+		}
+		apierr = session.run(ctx, parts, func(ctx context.Context, partNumber int, onRead func(n int)) *apierror.ApiError {
+			offset := int64(partNumber-1) * blockSize
+			chunkSize := blockSize
+			if offset+chunkSize > r.size {
+				chunkSize = r.size - offset
+			}
+			if chunkSize <= 0 {
+				return nil
+			}
+			chunk := &aliyunpan.FileUploadChunkData{
+				Reader:    &sessionReader{r: option.wrapReader(ctx, io.NewSectionReader(r, offset, chunkSize)), onRead: onRead},
+				ChunkSize: chunkSize,
+			}
+			if err := panClient.UploadDataChunk(uploadUrls[partNumber], chunk); err != nil {
+				logger.Verboseln("upload file part error ", partNumber, err)
+				return err
+			}
+			return nil
+		})
+		session.finish(false)
+		if apierr != nil {
+			return nil, apierr
+		}
+	} else {
+		logger.Verboseln("rapid upload file: " + source)
+		session.addTransferred(r.size)
+		session.finish(true)
+		if option != nil && option.OnProgress != nil && r.size > 0 {
+			option.OnProgress(int(r.size))
+		}
+	}
+
+	return panClient.CompleteUploadFile(&aliyunpan.CompleteUploadFileParam{
+		DriveId:  driveId,
+		FileId:   createResult.FileId,
+		UploadId: createResult.UploadId,
+	})
+}
+
+// DownloadFile 下载网盘文件到本地指定路径。数据先写入临时文件，完成后再重命名，并把修改时间设置为网盘文件的修改时间。
+// 如果临时文件已经存在，则从临时文件末尾断点续传
+func DownloadFile(ctx context.Context, panClient *aliyunpan.PanClient, fe *aliyunpan.FileEntity, localPath string, option *FileOption) *apierror.ApiError {
+	if fe == nil || !fe.IsFile() {
+		return apierror.NewFailedApiError("只能下载文件")
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+
+	tmpPath := localPath + DownloadTmpSuffix
+	metaPath := localPath + DownloadMetaSuffix
+	meta := newDownloadMeta(fe)
+	resume := meta.matches(metaPath)
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil || offset > fe.FileSize || !resume {
+		// 临时文件不是当前版本的网盘文件，从头下载
+		offset = 0
+	}
+	if !resume {
+		if err = f.Truncate(0); err == nil {
+			err = meta.save(metaPath)
+		}
+		if err != nil {
+			f.Close()
+			return apierror.NewApiErrorWithError(err)
+		}
+	}
+
+	apierr := downloadFileTo(ctx, panClient, fe, f, offset, option)
+	f.Close()
+	if apierr != nil {
+		// 保留临时文件用于断点续传
+		return apierr
+	}
+	if apierr = verifyDownload(fe, tmpPath); apierr != nil {
+		// 数据已经损坏，删除临时文件，下次从头下载
+		os.Remove(tmpPath)
+		os.Remove(metaPath)
+		return apierr
+	}
+	if err = os.Rename(tmpPath, localPath); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	os.Remove(metaPath)
+	if !fe.UpdatedTime.IsZero() {
+		os.Chtimes(localPath, time.Now(), fe.UpdatedTime)
+	}
+	return nil
+}
+
+func newDownloadMeta(fe *aliyunpan.FileEntity) *downloadMeta {
+	return &downloadMeta{
+		FileId:      fe.FileId,
+		ContentHash: apiutil.NormalizeSha1(fe.ContentHash),
+		FileSize:    fe.FileSize,
+		UpdatedAt:   fe.UpdatedAt,
+	}
+}
+
+// matches 续传信息文件记录的版本和 m 是否一致，文件不存在或者格式错误时返回false
+func (m *downloadMeta) matches(metaPath string) bool {
+	data, err := ioutil.ReadFile(metaPath)
+	if err != nil {
+		return false
+	}
+	saved := &downloadMeta{}
+	if err = json.Unmarshal(data, saved); err != nil {
+		return false
+	}
+	return *saved == *m
+}
+
+func (m *downloadMeta) save(metaPath string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(metaPath, data, 0644)
+}
+
+// verifyDownload 校验下载的文件和网盘文件的SHA1是否一致，网盘没有返回SHA1时不校验
+func verifyDownload(fe *aliyunpan.FileEntity, localPath string) *apierror.ApiError {
+	if fe.ContentHash == "" || (fe.ContentHashName != "" && !strings.EqualFold(fe.ContentHashName, "sha1")) {
+		return nil
+	}
+	sha1Str, _, err := apiutil.ComputeFileHashes(localPath)
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	if sha1Str != apiutil.NormalizeSha1(fe.ContentHash) {
+		return apierror.NewFailedApiError("下载的文件校验失败，SHA1不一致：" + fe.Path)
+	}
+	return nil
+}
+
+func downloadFileTo(ctx context.Context, panClient *aliyunpan.PanClient, fe *aliyunpan.FileEntity, f *os.File, offset int64, option *FileOption) *apierror.ApiError {
+	if offset >= fe.FileSize {
+		if err := f.Truncate(fe.FileSize); err != nil {
+			return apierror.NewApiErrorWithError(err)
+		}
+		return nil
+	}
+	urlResult, apierr := panClient.GetFileDownloadUrl(&aliyunpan.GetFileDownloadUrlParam{
+		DriveId: fe.DriveId,
+		FileId:  fe.FileId,
+	})
+	if apierr != nil {
+		return apierr
+	}
+	if urlResult.Url == aliyunpan.IllegalDownloadUrl {
+		return apierror.NewFailedApiError("文件已被屏蔽，无法下载：" + fe.Path)
+	}
+
+	var resp *http.Response
+	httpClient := aliyunpan.NewHTTPClient()
+	httpClient.SetTimeout(0)
+	apierr = panClient.DownloadFileData(urlResult.Url, aliyunpan.FileDownloadRange{Offset: offset}, func(httpMethod, fullUrl string, headers map[string]string) (*http.Response, error) {
+		r, err := httpClient.Req(httpMethod, fullUrl, nil, headers)
+		resp = r
+		return r, err
+	})
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if apierr != nil {
+		return apierr
+	}
+	switch resp.StatusCode {
+	case 206:
+	case 200:
+		// 服务器不支持断点续传，从头开始下载
+		offset = 0
+	default:
+		return apierror.NewFailedApiError(fmt.Sprintf("unexpected http status code, %d, %s", resp.StatusCode, resp.Status))
+	}
+	if err := f.Truncate(offset); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+
+	// 取消时关闭连接，避免阻塞在读取上
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			resp.Body.Close()
+		case <-done:
+		}
+	}()
+
+	n, err := io.Copy(f, option.wrapReader(ctx, resp.Body))
+	if ctx.Err() != nil {
+		return apierror.NewApiErrorWithError(ctx.Err())
+	}
+	if err != nil {
+		return apierror.NewApiErrorWithError(err)
+	}
+	if offset+n != fe.FileSize {
+		return apierror.NewFailedApiError(fmt.Sprintf("下载文件大小不一致，期望 %d，实际 %d", fe.FileSize, offset+n))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// serveContent 模拟下载链接接口和文件数据，返回每次数据请求的 Range 请求头
+func serveContent(t *testing.T, content *string) *[]string {
+	ranges := &[]string{}
+	aliyunpan.SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			body := `{"url":"https://data.example.com/f1"}`
+			status := http.StatusOK
+			if r.URL.Host == "data.example.com" {
+				rg := r.Header.Get("range")
+				*ranges = append(*ranges, rg)
+				body = *content
+				if rg != "" {
+					start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rg, "bytes="), "-"))
+					body = body[start:]
+					status = http.StatusPartialContent
+				}
+			}
+			return &http.Response{StatusCode: status, Status: http.StatusText(status), Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body)), Request: r}, nil
+		})
+	})
+	t.Cleanup(func() { aliyunpan.SetTransportWrapper(nil) })
+	return ranges
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadFileResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transfer")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "a.txt")
+	tmpPath := localPath + DownloadTmpSuffix
+
+	content := "new content of the file"
+	ranges := serveContent(t, &content)
+	pc := aliyunpan.NewPanClient(aliyunpan.WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1"}, aliyunpan.AppLoginToken{})
+	fe := &aliyunpan.FileEntity{DriveId: "d1", FileId: "f1", FileType: "file", FileSize: int64(len(content)), ContentHash: strings.ToUpper(sha1Hex(content)), ContentHashName: "sha1"}
+
+	// 没有续传信息的临时文件来自其他版本，从头下载
+	assert.Nil(t, ioutil.WriteFile(tmpPath, []byte("old prefix"), 0644))
+	assert.Nil(t, DownloadFile(context.Background(), pc, fe, localPath, nil))
+	data, _ := ioutil.ReadFile(localPath)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, []string{""}, *ranges)
+	_, err = os.Stat(localPath + DownloadMetaSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	// 同一版本的临时文件断点续传
+	assert.Nil(t, newDownloadMeta(fe).save(localPath+DownloadMetaSuffix))
+	assert.Nil(t, ioutil.WriteFile(tmpPath, []byte(content[:4]), 0644))
+	assert.Nil(t, DownloadFile(context.Background(), pc, fe, localPath, nil))
+	data, _ = ioutil.ReadFile(localPath)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, "bytes=4-", (*ranges)[1])
+
+	// 续传信息记录的是旧版本，从头下载
+	old := *fe
+	old.ContentHash = sha1Hex("old")
+	assert.Nil(t, newDownloadMeta(&old).save(localPath+DownloadMetaSuffix))
+	assert.Nil(t, ioutil.WriteFile(tmpPath, []byte("old "), 0644))
+	assert.Nil(t, DownloadFile(context.Background(), pc, fe, localPath, nil))
+	data, _ = ioutil.ReadFile(localPath)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, "", (*ranges)[2])
+
+	// 下载的数据和网盘文件的SHA1不一致时返回错误并删除临时文件
+	bad := *fe
+	bad.ContentHash = sha1Hex("other")
+	assert.NotNil(t, DownloadFile(context.Background(), pc, &bad, filepath.Join(dir, "b.txt"), nil))
+	_, err = os.Stat(filepath.Join(dir, "b.txt") + DownloadTmpSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
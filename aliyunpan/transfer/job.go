@@ -0,0 +1,124 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type (
+	// JobType 任务类型
+	JobType string
+
+	// JobStatus 任务状态
+	JobStatus string
+
+	// Job 传输任务
+	Job struct {
+		Id   string  `json:"id"`
+		Type JobType `json:"type"`
+		// DriveId 网盘ID
+		DriveId string `json:"driveId"`
+		// LocalPath 本地文件路径
+		LocalPath string `json:"localPath"`
+		// RemotePath 网盘文件绝对路径
+		RemotePath string    `json:"remotePath"`
+		Status     JobStatus `json:"status"`
+		// Size 文件大小，任务开始后才有值
+		Size int64 `json:"size"`
+		// Transferred 已传输的字节数
+		Transferred int64 `json:"transferred"`
+		// Retries 已重试次数
+		Retries int `json:"retries"`
+		// Error 最后一次失败的原因
+		Error     string `json:"error"`
+		CreatedAt string `json:"createdAt"`
+		UpdatedAt string `json:"updatedAt"`
+	}
+
+	// jobState 持久化的任务队列
+	jobState struct {
+		Jobs []*Job `json:"jobs"`
+	}
+)
+
+const (
+	// JobTypeUpload 上传
+	JobTypeUpload JobType = "upload"
+	// JobTypeDownload 下载
+	JobTypeDownload JobType = "download"
+
+	// JobStatusPending 等待中
+	JobStatusPending JobStatus = "pending"
+	// JobStatusRunning 传输中
+	JobStatusRunning JobStatus = "running"
+	// JobStatusCompleted 已完成
+	JobStatusCompleted JobStatus = "completed"
+	// JobStatusFailed 失败，超过重试次数
+	JobStatusFailed JobStatus = "failed"
+	// JobStatusCanceled 已取消
+	JobStatusCanceled JobStatus = "canceled"
+)
+
+// IsFinished 任务是否已经结束
+func (j *Job) IsFinished() bool {
+	return j.Status == JobStatusCompleted || j.Status == JobStatusFailed || j.Status == JobStatusCanceled
+}
+
+func (j *Job) clone() *Job {
+	c := *j
+	return &c
+}
+
+// loadJobState 读取持久化的任务队列，文件不存在返回空队列
+func loadJobState(statePath string) (*jobState, error) {
+	state := &jobState{Jobs: []*Job{}}
+	if statePath == "" {
+		return state, nil
+	}
+	data, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// saveJobState 持久化任务队列，先写临时文件再重命名，避免写入中断导致文件损坏
+func saveJobState(statePath string, state *jobState) error {
+	if statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return err
+	}
+	tmpPath := statePath + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, statePath)
+}
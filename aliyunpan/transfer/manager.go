@@ -0,0 +1,637 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transfer 上传下载任务队列管理，支持并发控制、限速、持久化和断点续传
+package transfer
+
+import (
+	"context"
+	"errors"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// EventType 任务事件类型
+	EventType string
+
+	// Event 任务生命周期事件
+	Event struct {
+		Type EventType
+		// Job 事件发生时任务的快照
+		Job *Job
+	}
+
+	// EventFunc 任务事件回调，在工作协程中同步调用，不要做耗时操作
+	EventFunc func(event *Event)
+
+	// ManagerConfig 任务管理器配置
+	ManagerConfig struct {
+		// StatePath 任务队列持久化文件路径，为空则不持久化
+		StatePath string
+		// Concurrency 同时传输的任务数，默认为2
+		Concurrency int
+		// MaxRate 总带宽限制，每秒字节数，<=0 代表不限速
+		MaxRate int64
+		// RateWindows 按时间段限速，不匹配任何时间段时使用 MaxRate
+		RateWindows []RateWindow
+		// MaxRetries 失败重试次数，默认为3
+		MaxRetries int
+		// PartConcurrency 每个上传任务同时上传的分片数，默认为1。可以通过 UploadSession 在上传过程中调整
+		PartConcurrency int
+		// QuotaCheck 开始上传前检查网盘剩余空间，空间不足时暂停上传任务，释放空间后自动恢复
+		QuotaCheck bool
+		// QuotaCheckInterval 重新获取网盘空间的间隔，默认为 DefaultQuotaCheckInterval
+		QuotaCheckInterval time.Duration
+		// SpaceInfo 获取网盘空间的函数，默认使用 PanClient.GetPersonalSpaceInfo
+		SpaceInfo SpaceInfoFunc
+		// OnEvent 任务事件回调
+		OnEvent EventFunc
+	}
+
+	// Manager 传输任务管理器
+	Manager struct {
+		panClient *aliyunpan.PanClient
+		config    ManagerConfig
+		throttler *Throttler
+		scheduler *RateScheduler
+		// quota 网盘空间检查，为nil代表不检查
+		quota *quotaGate
+
+		mu       sync.Mutex
+		jobs     []*Job
+		cancels  map[string]context.CancelFunc
+		sessions map[string]*UploadSession
+		// folderIds 预先创建的网盘文件夹，网盘ID + 路径 -> 文件夹ID
+		folderIds map[string]string
+		// quotaPaused 最近一次通知的是否因为空间不足暂停
+		quotaPaused bool
+		wakeup      chan struct{}
+
+		ctx     context.Context
+		cancel  context.CancelFunc
+		wg      sync.WaitGroup
+		running bool
+		// unregister 取消在客户端注册的关闭回调
+		unregister func()
+	}
+)
+
+const (
+	// EventAdded 任务已添加
+	EventAdded EventType = "added"
+	// EventStarted 任务开始传输
+	EventStarted EventType = "started"
+	// EventProgress 传输进度更新，每个任务每秒最多一次
+	EventProgress EventType = "progress"
+	// EventRetry 任务失败，等待重试
+	EventRetry EventType = "retry"
+	// EventCompleted 任务已完成
+	EventCompleted EventType = "completed"
+	// EventFailed 任务失败
+	EventFailed EventType = "failed"
+	// EventCanceled 任务已取消
+	EventCanceled EventType = "canceled"
+	// EventQuotaExceeded 网盘空间不足，上传任务暂停，Job 为等待的上传任务
+	EventQuotaExceeded EventType = "quota_exceeded"
+	// EventQuotaResumed 网盘空间已释放，上传任务恢复，Job 为nil
+	EventQuotaResumed EventType = "quota_resumed"
+
+	timeFormat = "2006-01-02 15:04:05"
+)
+
+var (
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("transfer job not found")
+)
+
+// NewManager 创建任务管理器，如果存在持久化文件则恢复之前未完成的任务
+func NewManager(panClient *aliyunpan.PanClient, config ManagerConfig) (*Manager, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 2
+	}
+	if config.PartConcurrency <= 0 {
+		config.PartConcurrency = 1
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+
+	state, err := loadJobState(config.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range state.Jobs {
+		if job.Status == JobStatusRunning {
+			// 上次退出时正在传输的任务，重新排队
+			job.Status = JobStatusPending
+		}
+	}
+
+	m := &Manager{
+		panClient: panClient,
+		config:    config,
+		throttler: NewThrottler(config.MaxRate),
+		jobs:      state.Jobs,
+		cancels:   map[string]context.CancelFunc{},
+		sessions:  map[string]*UploadSession{},
+		folderIds: map[string]string{},
+		wakeup:    make(chan struct{}, 1),
+	}
+	if config.QuotaCheck {
+		fetch := config.SpaceInfo
+		if fetch == nil {
+			fetch = panClient.GetPersonalSpaceInfo
+		}
+		m.quota = newQuotaGate(fetch, config.QuotaCheckInterval)
+	}
+	if len(config.RateWindows) > 0 {
+		if m.scheduler, err = NewRateScheduler(m.throttler, config.MaxRate, config.RateWindows); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Throttler 返回共享的限速器，可以在运行中调整速率
+func (m *Manager) Throttler() *Throttler {
+	return m.throttler
+}
+
+// AddUpload 添加上传任务，remotePath 为网盘文件绝对路径，上级文件夹不存在会自动创建
+func (m *Manager) AddUpload(driveId, localPath, remotePath string) (*Job, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errors.New("只能上传文件：" + localPath)
+	}
+	return m.addJob(&Job{
+		Type:       JobTypeUpload,
+		DriveId:    driveId,
+		LocalPath:  localPath,
+		RemotePath: path.Clean("/" + remotePath),
+		Size:       info.Size(),
+	})
+}
+
+// AddUploadTree 添加 PlanRemoteTree 扫描到的所有文件的上传任务，上传时直接使用已经创建好的文件夹
+func (m *Manager) AddUploadTree(tree *RemoteTree) ([]*Job, error) {
+	m.mu.Lock()
+	for p, id := range tree.FolderIds {
+		m.folderIds[tree.DriveId+p] = id
+	}
+	m.mu.Unlock()
+
+	jobs := make([]*Job, 0, len(tree.Files))
+	for _, rel := range tree.Files {
+		job, err := m.AddUpload(tree.DriveId, filepath.Join(tree.LocalRoot, filepath.FromSlash(rel)), apiutil.JoinDrivePath(tree.RemoteRoot, rel))
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// AddDownload 添加下载任务，remotePath 为网盘文件绝对路径
+func (m *Manager) AddDownload(driveId, remotePath, localPath string) (*Job, error) {
+	return m.addJob(&Job{
+		Type:       JobTypeDownload,
+		DriveId:    driveId,
+		LocalPath:  localPath,
+		RemotePath: path.Clean("/" + remotePath),
+	})
+}
+
+func (m *Manager) addJob(job *Job) (*Job, error) {
+	now := time.Now().Format(timeFormat)
+	job.Id = apiutil.Uuid()
+	job.Status = JobStatusPending
+	job.CreatedAt = now
+	job.UpdatedAt = now
+
+	m.mu.Lock()
+	m.jobs = append(m.jobs, job)
+	snapshot := job.clone()
+	err := m.persistLocked()
+	m.mu.Unlock()
+
+	m.emit(EventAdded, snapshot)
+	m.notify()
+	return snapshot, err
+}
+
+// Jobs 返回所有任务的快照
+func (m *Manager) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		r = append(r, job.clone())
+	}
+	return r
+}
+
+// Job 返回指定任务的快照
+func (m *Manager) Job(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job := m.findLocked(id); job != nil {
+		return job.clone(), nil
+	}
+	return nil, ErrJobNotFound
+}
+
+// UploadSession 返回正在上传的任务的上传会话，用于查询分片进度、速度和调整分片并发数。
+// 任务不存在、不是上传任务或者没有在上传时返回 ErrJobNotFound
+func (m *Manager) UploadSession(id string) (*UploadSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; ok {
+		return s, nil
+	}
+	return nil, ErrJobNotFound
+}
+
+// QueueStatus 返回任务队列状态，可以查询是否因为网盘空间不足暂停了上传
+func (m *Manager) QueueStatus() *QueueStatus {
+	return m.quota.status()
+}
+
+// Cancel 取消任务，正在传输的任务会立即中断
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	job := m.findLocked(id)
+	if job == nil {
+		m.mu.Unlock()
+		return ErrJobNotFound
+	}
+	if job.IsFinished() {
+		m.mu.Unlock()
+		return nil
+	}
+	if cancel, ok := m.cancels[id]; ok {
+		cancel()
+	}
+	job.Status = JobStatusCanceled
+	job.UpdatedAt = time.Now().Format(timeFormat)
+	snapshot := job.clone()
+	err := m.persistLocked()
+	m.mu.Unlock()
+
+	m.emit(EventCanceled, snapshot)
+	return err
+}
+
+// ClearFinished 移除已经结束的任务
+func (m *Manager) ClearFinished() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if !job.IsFinished() {
+			jobs = append(jobs, job)
+		}
+	}
+	m.jobs = jobs
+	return m.persistLocked()
+}
+
+// Start 启动工作协程开始处理任务
+func (m *Manager) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return
+	}
+	m.running = true
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	if m.scheduler != nil {
+		m.scheduler.Start()
+	}
+	for i := 0; i < m.config.Concurrency; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	if m.quota != nil {
+		m.wg.Add(1)
+		go m.quotaLoop()
+	}
+	if m.panClient != nil {
+		m.unregister = m.panClient.RegisterCloser(m)
+	}
+}
+
+// quotaLoop 空间不足暂停时定时重新获取网盘空间，空间足够后唤醒工作协程
+func (m *Manager) quotaLoop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.quota.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			if m.quota.isExceeded() {
+				m.quota.refresh(true)
+				m.notify()
+			}
+		}
+	}
+}
+
+// Stop 停止所有工作协程，正在传输的任务会被中断并重新排队，下次启动时继续
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return nil
+	}
+	m.running = false
+	m.cancel()
+	unregister := m.unregister
+	m.unregister = nil
+	m.mu.Unlock()
+
+	if unregister != nil {
+		unregister()
+	}
+
+	if m.scheduler != nil {
+		m.scheduler.Stop()
+	}
+
+	m.wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.persistLocked()
+}
+
+// Close 实现 aliyunpan.Closer，客户端关闭时停止所有工作协程并保存任务队列。ctx 超时后不再等待
+func (m *Manager) Close(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Stop()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		m.quota.refresh(false)
+		job, ctx, cancel := m.nextJob()
+		m.emitQuotaState()
+		if job == nil {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-m.wakeup:
+				continue
+			}
+		}
+		// 可能还有其他等待中的任务，唤醒空闲的工作协程
+		m.notify()
+		m.emit(EventStarted, m.snapshot(job))
+		err := m.runJob(ctx, job)
+		cancel()
+		m.finishJob(job, err)
+	}
+}
+
+// nextJob 取出下一个等待中的任务并标记为传输中
+func (m *Manager) nextJob() (*Job, context.Context, context.CancelFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ctx.Err() != nil {
+		return nil, nil, nil
+	}
+	uploadsBlocked := false
+	for _, job := range m.jobs {
+		if job.Status != JobStatusPending {
+			continue
+		}
+		if job.Type == JobTypeUpload && m.quota != nil {
+			// 保持上传顺序，前面的任务空间不足时后面的上传任务也等待
+			if uploadsBlocked || !m.quota.admit(job.Id, uint64(job.Size), m.reservedLocked()) {
+				uploadsBlocked = true
+				continue
+			}
+		}
+		job.Status = JobStatusRunning
+		job.UpdatedAt = time.Now().Format(timeFormat)
+		ctx, cancel := context.WithCancel(m.ctx)
+		m.cancels[job.Id] = cancel
+		m.persistLocked()
+		return job, ctx, cancel
+	}
+	if !uploadsBlocked {
+		// 等待的上传任务已经取消
+		m.quota.clear()
+	}
+	return nil, nil, nil
+}
+
+func (m *Manager) runJob(ctx context.Context, job *Job) *apierror.ApiError {
+	jobCopy := m.snapshot(job)
+
+	var transferred int64
+	lastEmit := time.Now()
+	option := &FileOption{
+		Throttler: m.throttler,
+		OnProgress: func(n int) {
+			t := atomic.AddInt64(&transferred, int64(n))
+			m.mu.Lock()
+			job.Transferred = t
+			snapshot := job.clone()
+			m.mu.Unlock()
+			if time.Since(lastEmit) >= time.Second {
+				lastEmit = time.Now()
+				m.emit(EventProgress, snapshot)
+			}
+		},
+	}
+
+	switch jobCopy.Type {
+	case JobTypeUpload:
+		dir, name := path.Split(jobCopy.RemotePath)
+		m.mu.Lock()
+		parentId, ok := m.folderIds[jobCopy.DriveId+path.Clean(dir)]
+		m.mu.Unlock()
+		if !ok {
+			parentId = aliyunpan.DefaultRootParentFileId
+			if dir != "/" {
+				r, err := m.panClient.MkdirByFullPath(jobCopy.DriveId, path.Clean(dir))
+				if err != nil {
+					return err
+				}
+				parentId = r.FileId
+			}
+		}
+		option.Session = NewUploadSession(m.config.PartConcurrency)
+		m.mu.Lock()
+		m.sessions[job.Id] = option.Session
+		m.mu.Unlock()
+		_, err := UploadFile(ctx, m.panClient, jobCopy.DriveId, parentId, jobCopy.LocalPath, name, option)
+		return err
+	case JobTypeDownload:
+		fe, err := m.panClient.FileInfoByPath(jobCopy.DriveId, jobCopy.RemotePath)
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		job.Size = fe.FileSize
+		m.mu.Unlock()
+		return DownloadFile(ctx, m.panClient, fe, jobCopy.LocalPath, option)
+	}
+	return apierror.NewFailedApiError("未知的任务类型：" + string(jobCopy.Type))
+}
+
+// finishJob 更新任务结束后的状态
+func (m *Manager) finishJob(job *Job, err *apierror.ApiError) {
+	m.mu.Lock()
+	delete(m.cancels, job.Id)
+	delete(m.sessions, job.Id)
+	eventType := EventCompleted
+	switch {
+	case job.Status == JobStatusCanceled:
+		// 已经在 Cancel 中处理
+		m.mu.Unlock()
+		return
+	case err == nil:
+		job.Status = JobStatusCompleted
+		job.Error = ""
+		job.Transferred = job.Size
+		if job.Type == JobTypeUpload {
+			m.quota.consume(uint64(job.Size))
+		}
+	case m.ctx.Err() != nil:
+		// 管理器停止，重新排队等待下次启动
+		job.Status = JobStatusPending
+		m.persistLocked()
+		m.mu.Unlock()
+		return
+	default:
+		logger.Verboseln("transfer job error ", job.Id, err)
+		job.Error = err.Error()
+		if job.Retries < m.config.MaxRetries {
+			job.Retries++
+			job.Status = JobStatusPending
+			eventType = EventRetry
+		} else {
+			job.Status = JobStatusFailed
+			eventType = EventFailed
+		}
+	}
+	job.UpdatedAt = time.Now().Format(timeFormat)
+	snapshot := job.clone()
+	m.persistLocked()
+	m.mu.Unlock()
+
+	m.emit(eventType, snapshot)
+	if eventType == EventRetry {
+		m.notify()
+	}
+}
+
+// reservedLocked 正在上传的任务需要的空间
+func (m *Manager) reservedLocked() uint64 {
+	var reserved uint64
+	for _, job := range m.jobs {
+		if job.Type == JobTypeUpload && job.Status == JobStatusRunning {
+			reserved += uint64(job.Size)
+		}
+	}
+	return reserved
+}
+
+// emitQuotaState 空间不足暂停或者恢复时发送事件
+func (m *Manager) emitQuotaState() {
+	status := m.quota.status()
+	exceeded := status.State == QueueStateQuotaExceeded
+	m.mu.Lock()
+	if exceeded == m.quotaPaused {
+		m.mu.Unlock()
+		return
+	}
+	m.quotaPaused = exceeded
+	var snapshot *Job
+	if exceeded {
+		if job := m.findLocked(status.Quota.JobId); job != nil {
+			snapshot = job.clone()
+		}
+	}
+	m.mu.Unlock()
+
+	if exceeded {
+		logger.Verboseln("transfer queue paused, quota exceeded ", status.Quota.Required, status.Quota.Free)
+		m.emit(EventQuotaExceeded, snapshot)
+	} else {
+		m.emit(EventQuotaResumed, nil)
+	}
+}
+
+func (m *Manager) snapshot(job *Job) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return job.clone()
+}
+
+func (m *Manager) findLocked(id string) *Job {
+	for _, job := range m.jobs {
+		if job.Id == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (m *Manager) persistLocked() error {
+	err := saveJobState(m.config.StatePath, &jobState{Jobs: m.jobs})
+	if err != nil {
+		logger.Verboseln("save transfer job state error ", err)
+	}
+	return err
+}
+
+func (m *Manager) notify() {
+	select {
+	case m.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) emit(eventType EventType, job *Job) {
+	if m.config.OnEvent != nil {
+		m.config.OnEvent(&Event{Type: eventType, Job: job})
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/library-go/logger"
+)
+
+type (
+	// QueueState 任务队列状态
+	QueueState string
+
+	// QuotaExceeded 网盘空间不足时的状态
+	QuotaExceeded struct {
+		// JobId 因为空间不足等待的上传任务
+		JobId string
+		// Required 开始该任务需要的空间，包括正在上传的任务
+		Required uint64
+		// Free 网盘剩余空间
+		Free uint64
+		// Since 开始暂停的时间
+		Since time.Time
+	}
+
+	// QueueStatus 任务队列状态快照
+	QueueStatus struct {
+		State QueueState
+		// Quota 网盘空间不足暂停时的详情，State 为 QueueStateQuotaExceeded 时不为nil
+		Quota *QuotaExceeded
+	}
+
+	// SpaceInfoFunc 获取网盘空间使用情况
+	SpaceInfoFunc func() (*aliyunpan.PersonalSpaceInfo, *apierror.ApiError)
+
+	// quotaGate 根据网盘剩余空间决定是否可以开始上传任务
+	quotaGate struct {
+		fetch    SpaceInfoFunc
+		interval time.Duration
+
+		mu        sync.Mutex
+		info      *aliyunpan.PersonalSpaceInfo
+		checkedAt time.Time
+		exceeded  *QuotaExceeded
+	}
+)
+
+const (
+	// QueueStateRunning 正常处理任务
+	QueueStateRunning QueueState = "running"
+	// QueueStateQuotaExceeded 网盘空间不足，上传任务暂停，下载任务不受影响。释放空间后自动恢复
+	QueueStateQuotaExceeded QueueState = "quota_exceeded"
+
+	// DefaultQuotaCheckInterval 默认重新获取网盘空间的间隔
+	DefaultQuotaCheckInterval = time.Minute
+)
+
+func newQuotaGate(fetch SpaceInfoFunc, interval time.Duration) *quotaGate {
+	if interval <= 0 {
+		interval = DefaultQuotaCheckInterval
+	}
+	return &quotaGate{
+		fetch:    fetch,
+		interval: interval,
+	}
+}
+
+// refresh 缓存过期或者 force 为true时重新获取网盘空间，获取失败时保留之前的数据
+func (g *quotaGate) refresh(force bool) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	stale := force || g.info == nil || time.Since(g.checkedAt) >= g.interval
+	g.mu.Unlock()
+	if !stale {
+		return
+	}
+	info, err := g.fetch()
+	if err != nil {
+		logger.Verboseln("get space info error ", err)
+		return
+	}
+	g.mu.Lock()
+	g.info = info
+	g.checkedAt = time.Now()
+	g.mu.Unlock()
+}
+
+// admit 判断是否可以开始大小为 size 的上传任务，reserved 为正在上传的任务占用的空间。
+// 没有获取到网盘空间时允许上传
+func (g *quotaGate) admit(jobId string, size, reserved uint64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.info == nil || g.info.FreeSize() >= size+reserved {
+		g.exceeded = nil
+		return true
+	}
+	if g.exceeded == nil {
+		g.exceeded = &QuotaExceeded{Since: time.Now()}
+	}
+	g.exceeded.JobId = jobId
+	g.exceeded.Required = size + reserved
+	g.exceeded.Free = g.info.FreeSize()
+	return false
+}
+
+// clear 没有等待的上传任务时清除空间不足状态
+func (g *quotaGate) clear() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.exceeded = nil
+}
+
+// consume 上传完成后更新缓存的已用空间，避免每个任务完成都重新获取
+func (g *quotaGate) consume(size uint64) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.info != nil {
+		info := *g.info
+		info.UsedSize += size
+		g.info = &info
+	}
+}
+
+func (g *quotaGate) status() *QueueStatus {
+	if g == nil {
+		return &QueueStatus{State: QueueStateRunning}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.exceeded == nil {
+		return &QueueStatus{State: QueueStateRunning}
+	}
+	q := *g.exceeded
+	return &QueueStatus{State: QueueStateQuotaExceeded, Quota: &q}
+}
+
+func (g *quotaGate) isExceeded() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.exceeded != nil
+}
@@ -0,0 +1,66 @@
+package transfer
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestManagerQuotaAdmission(t *testing.T) {
+	dir, err := ioutil.TempDir("", "transfer-quota")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	localPath := filepath.Join(dir, "a.bin")
+	assert.Nil(t, ioutil.WriteFile(localPath, make([]byte, 80), 0644))
+
+	space := &aliyunpan.PersonalSpaceInfo{TotalSize: 100, UsedSize: 50}
+	events := []EventType{}
+	m, err := NewManager(nil, ManagerConfig{
+		QuotaCheck: true,
+		SpaceInfo: func() (*aliyunpan.PersonalSpaceInfo, *apierror.ApiError) {
+			s := *space
+			return &s, nil
+		},
+		OnEvent: func(event *Event) {
+			events = append(events, event.Type)
+		},
+	})
+	assert.Nil(t, err)
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	defer m.cancel()
+
+	up, _ := m.AddUpload("d1", localPath, "/a.bin")
+	down, _ := m.AddDownload("d1", "/b.bin", filepath.Join(dir, "b.bin"))
+
+	// 空间不足，跳过上传任务，下载任务不受影响
+	m.quota.refresh(false)
+	job, _, cancel := m.nextJob()
+	m.emitQuotaState()
+	assert.Equal(t, down.Id, job.Id)
+	cancel()
+	status := m.QueueStatus()
+	assert.Equal(t, QueueStateQuotaExceeded, status.State)
+	assert.Equal(t, up.Id, status.Quota.JobId)
+	assert.Equal(t, uint64(80), status.Quota.Required)
+	assert.Equal(t, uint64(50), status.Quota.Free)
+
+	// 释放空间后恢复
+	space.UsedSize = 10
+	m.quota.refresh(true)
+	job, _, cancel = m.nextJob()
+	m.emitQuotaState()
+	assert.Equal(t, up.Id, job.Id)
+	cancel()
+	assert.Equal(t, QueueStateRunning, m.QueueStatus().State)
+	assert.Equal(t, []EventType{EventAdded, EventAdded, EventQuotaExceeded, EventQuotaResumed}, events)
+
+	// 完成后更新缓存的已用空间
+	m.finishJob(job, nil)
+	assert.Equal(t, uint64(10), m.quota.info.FreeSize())
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+)
+
+type (
+	// RemoteTree 批量上传前预先创建好的网盘文件夹结构
+	RemoteTree struct {
+		DriveId string
+		// LocalRoot 本地根目录
+		LocalRoot string
+		// RemoteRoot 网盘根目录，对应 LocalRoot
+		RemoteRoot string
+		// FolderIds 网盘文件夹绝对路径 -> 文件夹ID，包含 RemoteRoot 及其上级文件夹
+		FolderIds map[string]string
+		// Created 本次新创建的文件夹路径，上级文件夹在前
+		Created []string
+		// Files 需要上传的本地文件，相对 LocalRoot 的路径，使用 / 分隔
+		Files []string
+	}
+
+	// treePlanner 逐层创建文件夹，同一层的文件夹使用一次批量请求创建
+	treePlanner struct {
+		list  func(parentFileId string) (aliyunpan.FileList, *apierror.ApiError)
+		mkdir func(params []*aliyunpan.MkdirParam) ([]*aliyunpan.MkdirResult, *apierror.ApiError)
+	}
+)
+
+// PlanRemoteTree 扫描本地目录 localRoot，在网盘 remoteRoot 下创建对应的所有文件夹。
+// 同一层的文件夹批量创建，共享的上级文件夹只处理一次，新创建的文件夹不再查询是否存在。
+// 返回的 RemoteTree 可以通过 ParentId 直接获取文件所在文件夹的ID，上传时不需要再逐个检查文件夹是否存在
+func PlanRemoteTree(panClient *aliyunpan.PanClient, driveId, localRoot, remoteRoot string) (*RemoteTree, *apierror.ApiError) {
+	dirs, files, err := scanLocalTree(localRoot)
+	if err != nil {
+		return nil, apierror.NewApiErrorWithError(err)
+	}
+	planner := &treePlanner{
+		list: func(parentFileId string) (aliyunpan.FileList, *apierror.ApiError) {
+			return panClient.FileListGetAll(&aliyunpan.FileListParam{DriveId: driveId, ParentFileId: parentFileId})
+		},
+		mkdir: func(params []*aliyunpan.MkdirParam) ([]*aliyunpan.MkdirResult, *apierror.ApiError) {
+			return panClient.MkdirBatch(driveId, params)
+		},
+	}
+	remoteRoot = apiutil.JoinDrivePath("/", remoteRoot)
+	remoteDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		remoteDirs = append(remoteDirs, apiutil.JoinDrivePath(remoteRoot, dir))
+	}
+	tree, apierr := planner.plan(remoteDirs)
+	if apierr != nil {
+		return nil, apierr
+	}
+	tree.DriveId = driveId
+	tree.LocalRoot = localRoot
+	tree.RemoteRoot = remoteRoot
+	tree.Files = files
+	return tree, nil
+}
+
+// ParentId 返回网盘文件所在文件夹的ID，文件夹不在预先创建的结构中时返回false
+func (t *RemoteTree) ParentId(remotePath string) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	dir, _ := apiutil.SplitDrivePath(remotePath)
+	id, ok := t.FolderIds[dir]
+	return id, ok
+}
+
+// scanLocalTree 返回本地目录下所有文件夹和文件相对根目录的路径，根目录本身为空字符串
+func scanLocalTree(localRoot string) (dirs, files []string, err error) {
+	err = filepath.Walk(localRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localRoot, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			rel = ""
+		}
+		if info.IsDir() {
+			dirs = append(dirs, rel)
+		} else if info.Mode().IsRegular() {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return dirs, files, err
+}
+
+// plan 创建 remoteDirs 以及它们的上级文件夹
+func (tp *treePlanner) plan(remoteDirs []string) (*RemoteTree, *apierror.ApiError) {
+	tree := &RemoteTree{
+		FolderIds: map[string]string{"/": aliyunpan.DefaultRootParentFileId},
+		Created:   []string{},
+	}
+	// 补全上级文件夹并去重，按深度分层
+	levels := map[int][]string{}
+	seen := map[string]bool{"/": true}
+	maxDepth := 0
+	for _, dir := range remoteDirs {
+		for p := dir; !seen[p]; p, _ = apiutil.SplitDrivePath(p) {
+			seen[p] = true
+			depth := strings.Count(p, "/")
+			levels[depth] = append(levels[depth], p)
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+		}
+	}
+	created := map[string]bool{}
+	for depth := 1; depth <= maxDepth; depth++ {
+		level := levels[depth]
+		sort.Strings(level)
+		missing := []string{}
+		// 已有的上级文件夹，获取一次文件列表查询哪些文件夹已经存在
+		children := map[string]map[string]*aliyunpan.FileEntity{}
+		for _, p := range level {
+			dir, name := apiutil.SplitDrivePath(p)
+			if created[dir] {
+				missing = append(missing, p)
+				continue
+			}
+			parentId := tree.FolderIds[dir]
+			names, ok := children[parentId]
+			if !ok {
+				fl, err := tp.list(parentId)
+				if err != nil {
+					return nil, err
+				}
+				names = map[string]*aliyunpan.FileEntity{}
+				for _, fe := range fl {
+					names[fe.FileName] = fe
+				}
+				children[parentId] = names
+			}
+			fe, ok := names[name]
+			if !ok {
+				missing = append(missing, p)
+				continue
+			}
+			if !fe.IsFolder() {
+				return nil, apierror.NewFailedApiError("网盘已存在同名文件，无法创建文件夹：" + p)
+			}
+			tree.FolderIds[p] = fe.FileId
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		params := make([]*aliyunpan.MkdirParam, 0, len(missing))
+		for _, p := range missing {
+			dir, name := apiutil.SplitDrivePath(p)
+			params = append(params, &aliyunpan.MkdirParam{ParentFileId: tree.FolderIds[dir], Name: name})
+		}
+		results, err := tp.mkdir(params)
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range missing {
+			if i >= len(results) || results[i] == nil {
+				return nil, apierror.NewFailedApiError("创建文件夹失败：" + p)
+			}
+			tree.FolderIds[p] = results[i].FileId
+			tree.Created = append(tree.Created, p)
+			created[p] = true
+		}
+	}
+	return tree, nil
+}
@@ -0,0 +1,72 @@
+package transfer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestTreePlannerPlan(t *testing.T) {
+	// 网盘上已经存在 /backup 和 /backup/photos
+	existing := map[string]aliyunpan.FileList{
+		aliyunpan.DefaultRootParentFileId: {{FileId: "backup", FileName: "backup", FileType: "folder"}},
+		"backup":                          {{FileId: "photos", FileName: "photos", FileType: "folder"}},
+	}
+	listed := []string{}
+	mkdirCalls := 0
+	nextId := 0
+	tp := &treePlanner{
+		list: func(parentFileId string) (aliyunpan.FileList, *apierror.ApiError) {
+			listed = append(listed, parentFileId)
+			return existing[parentFileId], nil
+		},
+		mkdir: func(params []*aliyunpan.MkdirParam) ([]*aliyunpan.MkdirResult, *apierror.ApiError) {
+			mkdirCalls++
+			results := []*aliyunpan.MkdirResult{}
+			for range params {
+				nextId++
+				results = append(results, &aliyunpan.MkdirResult{FileId: fmt.Sprint("new", nextId)})
+			}
+			return results, nil
+		},
+	}
+	tree, err := tp.plan([]string{
+		"/backup/photos",
+		"/backup/photos/2021",
+		"/backup/photos/2021/08",
+		"/backup/photos/2022",
+		"/backup/docs",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/backup/docs", "/backup/photos/2021", "/backup/photos/2022", "/backup/photos/2021/08"}, tree.Created)
+	// 新创建的文件夹下不再查询文件列表
+	assert.Equal(t, []string{aliyunpan.DefaultRootParentFileId, "backup", "photos"}, listed)
+	// 每一层有需要创建的文件夹时一次批量请求
+	assert.Equal(t, 3, mkdirCalls)
+	assert.Equal(t, "photos", tree.FolderIds["/backup/photos"])
+
+	id, ok := tree.ParentId("/backup/photos/2021/08/a.jpg")
+	assert.True(t, ok)
+	assert.Equal(t, tree.FolderIds["/backup/photos/2021/08"], id)
+	_, ok = tree.ParentId("/other/a.jpg")
+	assert.False(t, ok)
+}
+
+func TestScanLocalTree(t *testing.T) {
+	root, err := ioutil.TempDir("", "remote-tree")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	assert.Nil(t, os.MkdirAll(filepath.Join(root, "a", "b"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "a", "b", "c.txt"), []byte("c"), 0644))
+
+	dirs, files, err := scanLocalTree(root)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"", "a", "a/b"}, dirs)
+	assert.Equal(t, []string{"a/b/c.txt"}, files)
+}
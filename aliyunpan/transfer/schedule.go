@@ -0,0 +1,152 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// RateWindow 时间段限速规则
+	RateWindow struct {
+		// Start 开始时间，格式为 HH:MM，包含
+		Start string
+		// End 结束时间，格式为 HH:MM，不包含。End 小于 Start 代表跨越午夜，例如 22:00 - 06:00
+		End string
+		// Rate 该时间段的速率，每秒字节数，<=0 代表不限速
+		Rate int64
+
+		start, end int
+	}
+
+	// RateScheduler 按一天中的时间段调整限速器的速率，例如夜间不限速，工作时间限速 1MB/s
+	RateScheduler struct {
+		throttler   *Throttler
+		windows     []*RateWindow
+		defaultRate int64
+
+		mu   sync.Mutex
+		stop chan struct{}
+		done chan struct{}
+	}
+)
+
+const (
+	// rateScheduleInterval 检查时间段的间隔
+	rateScheduleInterval = 30 * time.Second
+)
+
+// NewRateScheduler 创建时间段限速调度器。windows 按顺序匹配，第一个匹配的规则生效，都不匹配时使用 defaultRate
+func NewRateScheduler(throttler *Throttler, defaultRate int64, windows []RateWindow) (*RateScheduler, error) {
+	if throttler == nil {
+		return nil, fmt.Errorf("throttler is nil")
+	}
+	s := &RateScheduler{
+		throttler:   throttler,
+		windows:     make([]*RateWindow, 0, len(windows)),
+		defaultRate: defaultRate,
+	}
+	for i := range windows {
+		w := windows[i]
+		var err error
+		if w.start, err = parseClock(w.Start); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClock(w.End); err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, &w)
+	}
+	return s, nil
+}
+
+// parseClock 解析 HH:MM 格式的时间，返回一天中的分钟数
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, must be HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains 时间段是否包含一天中的指定分钟
+func (w *RateWindow) contains(minute int) bool {
+	if w.start == w.end {
+		// 全天
+		return true
+	}
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// RateAt 指定时间应该使用的速率
+func (s *RateScheduler) RateAt(t time.Time) int64 {
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s.windows {
+		if w.contains(minute) {
+			return w.Rate
+		}
+	}
+	return s.defaultRate
+}
+
+// Apply 立即按当前时间设置限速器的速率
+func (s *RateScheduler) Apply() {
+	s.throttler.SetRate(s.RateAt(time.Now()))
+}
+
+// Start 启动后台协程，定期按当前时间调整速率
+func (s *RateScheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	s.Apply()
+	go s.run(s.stop, s.done)
+}
+
+// Stop 停止调度，限速器保持最后一次设置的速率
+func (s *RateScheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+func (s *RateScheduler) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(rateScheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.Apply()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRateSchedulerRateAt(t *testing.T) {
+	s, err := NewRateScheduler(NewThrottler(0), 0, []RateWindow{
+		{Start: "09:00", End: "18:00", Rate: 1024 * 1024},
+		{Start: "22:00", End: "06:00", Rate: 0},
+		{Start: "18:00", End: "22:00", Rate: 4 * 1024 * 1024},
+	})
+	assert.Nil(t, err)
+
+	day := time.Date(2021, 8, 1, 0, 0, 0, 0, time.Local)
+	assert.Equal(t, int64(1024*1024), s.RateAt(day.Add(9*time.Hour)))
+	assert.Equal(t, int64(4*1024*1024), s.RateAt(day.Add(18*time.Hour)))
+	assert.Equal(t, int64(0), s.RateAt(day.Add(23*time.Hour)))
+	assert.Equal(t, int64(0), s.RateAt(day.Add(7*time.Hour)))
+
+	_, err = NewRateScheduler(NewThrottler(0), 0, []RateWindow{{Start: "9am", End: "18:00"}})
+	assert.NotNil(t, err)
+}
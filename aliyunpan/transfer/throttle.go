@@ -0,0 +1,137 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type (
+	// Throttler 令牌桶限速器，上传和下载共享同一个限速器即可限制总带宽。可以在运行中修改速率
+	Throttler struct {
+		mu     sync.Mutex
+		rate   int64
+		tokens float64
+		last   time.Time
+	}
+
+	// throttledReader 限速读取
+	throttledReader struct {
+		ctx       context.Context
+		r         io.Reader
+		throttler *Throttler
+		onRead    func(n int)
+	}
+)
+
+// NewThrottler 创建限速器，rate 为每秒字节数，<=0 代表不限速
+func NewThrottler(rate int64) *Throttler {
+	return &Throttler{
+		rate: rate,
+		last: time.Now(),
+	}
+}
+
+// SetRate 修改速率，<=0 代表不限速
+func (t *Throttler) SetRate(rate int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rate != t.rate {
+		t.rate = rate
+		t.tokens = 0
+		t.last = time.Now()
+	}
+}
+
+// Rate 当前速率，每秒字节数
+func (t *Throttler) Rate() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.rate
+}
+
+// WaitN 等待直到允许传输n个字节
+func (t *Throttler) WaitN(ctx context.Context, n int) error {
+	if t == nil {
+		return nil
+	}
+	for n > 0 {
+		t.mu.Lock()
+		rate := t.rate
+		if rate <= 0 {
+			t.mu.Unlock()
+			return nil
+		}
+		now := time.Now()
+		t.tokens += now.Sub(t.last).Seconds() * float64(rate)
+		if t.tokens > float64(rate) {
+			// 最多积累1秒的令牌
+			t.tokens = float64(rate)
+		}
+		t.last = now
+
+		take := n
+		if int64(take) > rate {
+			take = int(rate)
+		}
+		t.tokens -= float64(take)
+		wait := time.Duration(0)
+		if t.tokens < 0 {
+			wait = time.Duration(-t.tokens / float64(rate) * float64(time.Second))
+		}
+		t.mu.Unlock()
+
+		n -= take
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return nil
+}
+
+// NewReader 返回限速的Reader，throttler 为nil时不限速。onRead 可以为nil
+func (t *Throttler) NewReader(ctx context.Context, r io.Reader, onRead func(n int)) io.Reader {
+	return &throttledReader{
+		ctx:       ctx,
+		r:         r,
+		throttler: t,
+		onRead:    onRead,
+	}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if err := tr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		if werr := tr.throttler.WaitN(tr.ctx, n); werr != nil {
+			return n, werr
+		}
+		if tr.onRead != nil {
+			tr.onRead(n)
+		}
+	}
+	return n, err
+}
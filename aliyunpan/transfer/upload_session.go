@@ -0,0 +1,387 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfer
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+type (
+	// UploadSession 单个文件的上传会话，可以在上传过程中查询分片进度、重试次数和速度，并调整同时上传的分片数
+	UploadSession struct {
+		mu   sync.Mutex
+		cond *sync.Cond
+
+		concurrency int
+		partRetries int
+
+		fileName       string
+		size           int64
+		partCount      int
+		completedParts int
+		activeParts    map[int]bool
+		retries        int
+		transferred    int64
+		rapidUpload    bool
+		started        time.Time
+		finished       time.Time
+		// samples 最近一段时间的传输量采样，用于计算瞬时速度
+		samples []rateSample
+	}
+
+	// UploadSessionStats 上传会话的状态快照
+	UploadSessionStats struct {
+		FileName string
+		Size     int64
+		// Transferred 已上传的字节数，失败重传的分片不重复计算
+		Transferred int64
+		// PartCount 分片总数
+		PartCount int
+		// CompletedParts 已完成的分片数
+		CompletedParts int
+		// CurrentPart 正在上传的最小分片号，没有正在上传的分片时为0
+		CurrentPart int
+		// ActiveParts 正在上传的分片号
+		ActiveParts []int
+		// Retries 分片重试的总次数
+		Retries int
+		// Concurrency 同时上传的分片数
+		Concurrency int
+		// RapidUpload 是否秒传
+		RapidUpload bool
+		// InstantRate 最近几秒的速度，每秒字节数
+		InstantRate int64
+		// AverageRate 开始上传以来的平均速度，每秒字节数
+		AverageRate int64
+		// Elapsed 已用时间
+		Elapsed time.Duration
+		// Finished 是否已经结束
+		Finished bool
+	}
+
+	rateSample struct {
+		at    time.Time
+		total int64
+	}
+
+	// partUploadFunc 上传一个分片，每上传n个字节调用一次 onRead
+	partUploadFunc func(ctx context.Context, partNumber int, onRead func(n int)) *apierror.ApiError
+
+	// sessionReader 统计会话传输量
+	sessionReader struct {
+		r      io.Reader
+		onRead func(n int)
+	}
+)
+
+const (
+	// DefaultPartRetries 分片上传失败默认的重试次数
+	DefaultPartRetries = 3
+
+	// rateWindow 计算瞬时速度的时间窗口
+	rateWindow = 5 * time.Second
+	// sampleInterval 采样间隔
+	sampleInterval = 200 * time.Millisecond
+)
+
+var (
+	// partRetryDelay 分片重试前等待的时间，每次重试递增
+	partRetryDelay = time.Second
+)
+
+// NewUploadSession 创建上传会话，concurrency 为同时上传的分片数，小于1时为1
+func NewUploadSession(concurrency int) *UploadSession {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	s := &UploadSession{
+		concurrency: concurrency,
+		partRetries: DefaultPartRetries,
+		activeParts: map[int]bool{},
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// SetConcurrency 调整同时上传的分片数，可以在上传过程中调用，减少时正在上传的分片会继续完成
+func (s *UploadSession) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.concurrency = n
+	s.cond.Broadcast()
+}
+
+// Concurrency 同时上传的分片数
+func (s *UploadSession) Concurrency() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.concurrency
+}
+
+// SetPartRetries 设置分片上传失败的重试次数，需要在开始上传前调用
+func (s *UploadSession) SetPartRetries(n int) {
+	if n < 0 {
+		n = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partRetries = n
+}
+
+// CurrentPart 正在上传的最小分片号，没有正在上传的分片时为0
+func (s *UploadSession) CurrentPart() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.currentPartLocked()
+}
+
+// Retries 分片重试的总次数
+func (s *UploadSession) Retries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retries
+}
+
+// Throughput 返回瞬时速度和平均速度，每秒字节数
+func (s *UploadSession) Throughput() (instant, average int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.instantRateLocked(time.Now()), s.averageRateLocked(time.Now())
+}
+
+// Stats 返回会话状态快照
+func (s *UploadSession) Stats() *UploadSessionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	active := make([]int, 0, len(s.activeParts))
+	for part := range s.activeParts {
+		active = append(active, part)
+	}
+	sort.Ints(active)
+	return &UploadSessionStats{
+		FileName:       s.fileName,
+		Size:           s.size,
+		Transferred:    s.transferred,
+		PartCount:      s.partCount,
+		CompletedParts: s.completedParts,
+		CurrentPart:    s.currentPartLocked(),
+		ActiveParts:    active,
+		Retries:        s.retries,
+		Concurrency:    s.concurrency,
+		RapidUpload:    s.rapidUpload,
+		InstantRate:    s.instantRateLocked(now),
+		AverageRate:    s.averageRateLocked(now),
+		Elapsed:        s.elapsedLocked(now),
+		Finished:       !s.finished.IsZero(),
+	}
+}
+
+func (s *UploadSession) currentPartLocked() int {
+	current := 0
+	for part := range s.activeParts {
+		if current == 0 || part < current {
+			current = part
+		}
+	}
+	return current
+}
+
+func (s *UploadSession) elapsedLocked(now time.Time) time.Duration {
+	if s.started.IsZero() {
+		return 0
+	}
+	if !s.finished.IsZero() {
+		return s.finished.Sub(s.started)
+	}
+	return now.Sub(s.started)
+}
+
+func (s *UploadSession) averageRateLocked(now time.Time) int64 {
+	elapsed := s.elapsedLocked(now)
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(s.transferred) / elapsed.Seconds())
+}
+
+func (s *UploadSession) instantRateLocked(now time.Time) int64 {
+	if len(s.samples) == 0 || !s.finished.IsZero() {
+		return 0
+	}
+	first := s.samples[0]
+	elapsed := now.Sub(first.at)
+	if elapsed < sampleInterval {
+		return 0
+	}
+	return int64(float64(s.transferred-first.total) / elapsed.Seconds())
+}
+
+// begin 开始上传
+func (s *UploadSession) begin(fileName string, size int64, partCount int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.fileName = fileName
+	s.size = size
+	s.partCount = partCount
+	s.started = now
+	s.samples = []rateSample{{at: now}}
+}
+
+// finish 上传结束
+func (s *UploadSession) finish(rapidUpload bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rapidUpload = rapidUpload
+	s.finished = time.Now()
+}
+
+// addTransferred 记录传输量，n 可以为负数，用于扣除失败分片已经统计的数据
+func (s *UploadSession) addTransferred(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transferred += n
+	now := time.Now()
+	if now.Sub(s.samples[len(s.samples)-1].at) >= sampleInterval {
+		s.samples = append(s.samples, rateSample{at: now, total: s.transferred})
+	}
+	// 丢弃时间窗口之外的采样，至少保留一个
+	i := 0
+	for i < len(s.samples)-1 && now.Sub(s.samples[i].at) > rateWindow {
+		i++
+	}
+	s.samples = s.samples[i:]
+}
+
+// acquirePart 等待空闲的并发位置，ctx 取消时返回false
+func (s *UploadSession) acquirePart(ctx context.Context, partNumber int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.activeParts) >= s.concurrency && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	s.activeParts[partNumber] = true
+	return true
+}
+
+func (s *UploadSession) releasePart(partNumber int, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.activeParts, partNumber)
+	if ok {
+		s.completedParts++
+	}
+	s.cond.Broadcast()
+}
+
+// run 按会话的并发数上传所有分片，任意分片超过重试次数后停止上传并返回该错误
+func (s *UploadSession) run(ctx context.Context, parts []int, upload partUploadFunc) *apierror.ApiError {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr *apierror.ApiError
+	)
+	// ctx 取消时唤醒等待并发位置的协程
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+	for _, part := range parts {
+		if !s.acquirePart(ctx, part) {
+			break
+		}
+		wg.Add(1)
+		go func(part int) {
+			defer wg.Done()
+			err := s.uploadPart(ctx, part, upload)
+			s.releasePart(part, err == nil)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(part)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	if ctx.Err() != nil {
+		return apierror.NewApiErrorWithError(ctx.Err())
+	}
+	return nil
+}
+
+// uploadPart 上传一个分片，失败时重试
+func (s *UploadSession) uploadPart(ctx context.Context, partNumber int, upload partUploadFunc) *apierror.ApiError {
+	s.mu.Lock()
+	partRetries := s.partRetries
+	s.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		var n int64
+		err := upload(ctx, partNumber, func(read int) {
+			n += int64(read)
+			s.addTransferred(int64(read))
+		})
+		if err == nil {
+			return nil
+		}
+		s.addTransferred(-n)
+		if ctx.Err() != nil {
+			return apierror.NewApiErrorWithError(ctx.Err())
+		}
+		if attempt >= partRetries {
+			return err
+		}
+		s.mu.Lock()
+		s.retries++
+		s.mu.Unlock()
+
+		timer := time.NewTimer(partRetryDelay * time.Duration(attempt+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return apierror.NewApiErrorWithError(ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *sessionReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.onRead(n)
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package transfer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+)
+
+func TestUploadSessionRun(t *testing.T) {
+	partRetryDelay = time.Millisecond
+	defer func() { partRetryDelay = time.Second }()
+
+	s := NewUploadSession(2)
+	s.begin("a.bin", 500, 5)
+
+	var (
+		mu        sync.Mutex
+		active    int
+		maxActive int
+		attempts  = map[int]int{}
+	)
+	err := s.run(context.Background(), []int{1, 2, 3, 4, 5}, func(ctx context.Context, partNumber int, onRead func(n int)) *apierror.ApiError {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		attempts[partNumber]++
+		attempt := attempts[partNumber]
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		if partNumber == 1 {
+			// 第一个分片上传过程中提高并发数
+			s.SetConcurrency(3)
+		}
+		time.Sleep(10 * time.Millisecond)
+		onRead(100)
+		if partNumber == 3 && attempt == 1 {
+			return apierror.NewFailedApiError("network error")
+		}
+		return nil
+	})
+	s.finish(false)
+	assert.Nil(t, err)
+
+	stats := s.Stats()
+	assert.Equal(t, int64(500), stats.Transferred)
+	assert.Equal(t, 5, stats.CompletedParts)
+	assert.Equal(t, 1, stats.Retries)
+	assert.Equal(t, 3, stats.Concurrency)
+	assert.Equal(t, 0, stats.CurrentPart)
+	assert.True(t, stats.Finished)
+	assert.True(t, maxActive <= 3)
+	assert.True(t, stats.AverageRate > 0)
+
+	// 超过重试次数后返回错误
+	s = NewUploadSession(1)
+	s.SetPartRetries(1)
+	s.begin("b.bin", 100, 1)
+	err = s.run(context.Background(), []int{1}, func(ctx context.Context, partNumber int, onRead func(n int)) *apierror.ApiError {
+		onRead(50)
+		return apierror.NewFailedApiError("network error")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, s.Retries())
+	assert.Equal(t, int64(0), s.Stats().Transferred)
+}
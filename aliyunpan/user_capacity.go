@@ -0,0 +1,165 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apiutil"
+	"github.com/tickstep/library-go/logger"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// CapacitySource 容量来源
+	CapacitySource string
+
+	// CapacityDetail 一项容量
+	CapacityDetail struct {
+		// Source 来源
+		Source CapacitySource `json:"source"`
+		// Name 显示名称，例如：签到奖励
+		Name string `json:"name"`
+		// Size 容量大小
+		Size uint64 `json:"size"`
+		// ExpiredAt 过期时间，永久有效时为零值
+		ExpiredAt time.Time `json:"expiredAt"`
+	}
+
+	// CapacityInfo 网盘容量明细
+	CapacityInfo struct {
+		// TotalSize 网盘空间总大小
+		TotalSize uint64 `json:"totalSize"`
+		// UsedSize 网盘已使用空间大小
+		UsedSize uint64 `json:"usedSize"`
+		// Details 按来源划分的容量明细
+		Details []*CapacityDetail `json:"details"`
+	}
+
+	capacityDetailResult struct {
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Size      uint64 `json:"size"`
+		ExpiredAt string `json:"expired_at"`
+	}
+
+	capacityInfoResult struct {
+		TotalSize       uint64                  `json:"total_size"`
+		UsedSize        uint64                  `json:"used_size"`
+		CapacityDetails []*capacityDetailResult `json:"capacity_details"`
+	}
+)
+
+const (
+	// CapacitySourceBase 基础容量
+	CapacitySourceBase CapacitySource = "base"
+	// CapacitySourceVip 会员容量
+	CapacitySourceVip CapacitySource = "vip"
+	// CapacitySourceReward 活动奖励等福利容量
+	CapacitySourceReward CapacitySource = "reward"
+)
+
+// ExpiringWithin 返回在 d 时间内将要过期的容量，按过期时间升序排列
+func (c *CapacityInfo) ExpiringWithin(d time.Duration) []*CapacityDetail {
+	r := []*CapacityDetail{}
+	if c == nil {
+		return r
+	}
+	deadline := time.Now().Add(d)
+	for _, item := range c.Details {
+		if item == nil || item.ExpiredAt.IsZero() || item.ExpiredAt.After(deadline) {
+			continue
+		}
+		r = append(r, item)
+	}
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].ExpiredAt.Before(r[j].ExpiredAt)
+	})
+	return r
+}
+
+// TotalSizeAt 返回指定时间点的网盘总容量，即扣除在此之前过期的容量
+func (c *CapacityInfo) TotalSizeAt(t time.Time) uint64 {
+	if c == nil {
+		return 0
+	}
+	total := c.TotalSize
+	for _, item := range c.Details {
+		if item == nil || item.ExpiredAt.IsZero() || item.ExpiredAt.After(t) {
+			continue
+		}
+		if item.Size >= total {
+			return 0
+		}
+		total -= item.Size
+	}
+	return total
+}
+
+// WillExceedAt 在指定时间点，因为容量过期，已使用空间是否会超出总容量
+func (c *CapacityInfo) WillExceedAt(t time.Time) bool {
+	return c != nil && c.UsedSize > c.TotalSizeAt(t)
+}
+
+// GetCapacityInfo 获取网盘容量明细，包括基础容量、会员容量、福利容量以及过期时间
+func (p *PanClient) GetCapacityInfo() (*CapacityInfo, *apierror.ApiError) {
+	header := map[string]string{
+		"authorization": p.authorizationStr(),
+	}
+
+	fullUrl := &strings.Builder{}
+	fmt.Fprintf(fullUrl, "%s/adrive/v1/user/capacity/details", API_URL)
+	logger.Verboseln("do request url: " + fullUrl.String())
+	postData := map[string]string{}
+
+	// request
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	if err != nil {
+		logger.Verboseln("get capacity info error ", err)
+		return nil, apierror.NewFailedApiError(err.Error())
+	}
+
+	// handler common error
+	if err1 := apierror.ParseCommonApiError(body); err1 != nil {
+		return nil, err1
+	}
+
+	// parse result
+	r := &capacityInfoResult{}
+	if err2 := json.Unmarshal(body, r); err2 != nil {
+		logger.Verboseln("parse capacity info result json error ", err2)
+		return nil, apierror.NewFailedApiError(err2.Error())
+	}
+	info := &CapacityInfo{
+		TotalSize: r.TotalSize,
+		UsedSize:  r.UsedSize,
+		Details:   []*CapacityDetail{},
+	}
+	for _, item := range r.CapacityDetails {
+		if item == nil {
+			continue
+		}
+		info.Details = append(info.Details, &CapacityDetail{
+			Source:    CapacitySource(item.Type),
+			Name:      item.Name,
+			Size:      item.Size,
+			ExpiredAt: apiutil.ParseUtcTime(item.ExpiredAt),
+		})
+	}
+	return info, nil
+}
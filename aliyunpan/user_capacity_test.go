@@ -0,0 +1,43 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyunpan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapacityInfoExpiring(t *testing.T) {
+	now := time.Now()
+	c := &CapacityInfo{
+		TotalSize: 1000,
+		UsedSize:  700,
+		Details: []*CapacityDetail{
+			{Source: CapacitySourceBase, Size: 500},
+			{Source: CapacitySourceReward, Size: 300, ExpiredAt: now.Add(48 * time.Hour)},
+			{Source: CapacitySourceVip, Size: 200, ExpiredAt: now.Add(24 * time.Hour)},
+		},
+	}
+	expiring := c.ExpiringWithin(72 * time.Hour)
+	if len(expiring) != 2 || expiring[0].Source != CapacitySourceVip {
+		t.Fatalf("unexpected expiring list %v", expiring)
+	}
+	if c.WillExceedAt(now.Add(time.Hour)) {
+		t.Fatal("should not exceed before any capacity expires")
+	}
+	if size := c.TotalSizeAt(now.Add(72 * time.Hour)); size != 500 || !c.WillExceedAt(now.Add(72*time.Hour)) {
+		t.Fatalf("expected to exceed after rewards expire, total %d", size)
+	}
+}
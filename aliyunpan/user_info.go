@@ -60,6 +60,14 @@ type (
 		UsedSize uint64 `json:"usedSize"`
 	}
 
+	// PersonalSpaceInfo 网盘空间使用情况
+	PersonalSpaceInfo struct {
+		// TotalSize 网盘空间总大小
+		TotalSize uint64 `json:"totalSize"`
+		// UsedSize 网盘已使用空间大小
+		UsedSize uint64 `json:"usedSize"`
+	}
+
 	// userInfoResult 用户信息返回实体
 	userInfoResult struct {
 		DomainId                    string `json:"domain_id"`
@@ -161,8 +169,11 @@ func parseUserStatus(status string) UserStatus {
 	return UnknownStatus
 }
 
-// GetUserInfo 获取用户信息
+// GetUserInfo 获取用户信息。只设置了开放平台token时使用开放平台的网盘信息填充
 func (p *PanClient) GetUserInfo() (*UserInfo, *apierror.ApiError) {
+	if p.webTokenMissing() {
+		return p.openUserInfo(p.OpenClient())
+	}
 	userInfo := &UserInfo{}
 
 	if r, err := p.getUserInfoReq(); err == nil {
@@ -202,10 +213,30 @@ func (p *PanClient) GetUserInfo() (*UserInfo, *apierror.ApiError) {
 	return userInfo, nil
 }
 
+// GetPersonalSpaceInfo 获取网盘空间使用情况，只请求一次接口，比 GetUserInfo 轻量
+func (p *PanClient) GetPersonalSpaceInfo() (*PersonalSpaceInfo, *apierror.ApiError) {
+	r, err := p.getPersonalInfoReq()
+	if err != nil {
+		return nil, err
+	}
+	return &PersonalSpaceInfo{
+		TotalSize: r.PersonalSpaceInfo.TotalSize,
+		UsedSize:  r.PersonalSpaceInfo.UsedSize,
+	}, nil
+}
+
+// FreeSize 网盘剩余空间大小
+func (s *PersonalSpaceInfo) FreeSize() uint64 {
+	if s == nil || s.UsedSize >= s.TotalSize {
+		return 0
+	}
+	return s.TotalSize - s.UsedSize
+}
+
 // getUserInfoReq 获取用户基本信息
 func (p *PanClient) getUserInfoReq() (*userInfoResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -214,7 +245,7 @@ func (p *PanClient) getUserInfoReq() (*userInfoResult, *apierror.ApiError) {
 	postData := map[string]string{}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get user info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -237,7 +268,7 @@ func (p *PanClient) getUserInfoReq() (*userInfoResult, *apierror.ApiError) {
 // getPersonalInfoReq 获取用户网盘基本信息，包括配额，上传下载等权限限制
 func (p *PanClient) getPersonalInfoReq() (*personalInfoResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -246,7 +277,7 @@ func (p *PanClient) getPersonalInfoReq() (*personalInfoResult, *apierror.ApiErro
 	postData := map[string]string{}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get person info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -269,7 +300,7 @@ func (p *PanClient) getPersonalInfoReq() (*personalInfoResult, *apierror.ApiErro
 // getSafeBoxInfoReq 获取保险箱信息
 func (p *PanClient) getSafeBoxInfoReq() (*safeBoxInfoResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -278,7 +309,7 @@ func (p *PanClient) getSafeBoxInfoReq() (*safeBoxInfoResult, *apierror.ApiError)
 	postData := map[string]string{}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get safe box info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -300,7 +331,7 @@ func (p *PanClient) getSafeBoxInfoReq() (*safeBoxInfoResult, *apierror.ApiError)
 
 func (p *PanClient) getAlbumInfoReq() (*albumInfoResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -309,7 +340,7 @@ func (p *PanClient) getAlbumInfoReq() (*albumInfoResult, *apierror.ApiError) {
 	postData := map[string]string{}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get album info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
@@ -326,7 +357,7 @@ func (p *PanClient) getAlbumInfoReq() (*albumInfoResult, *apierror.ApiError) {
 
 func (p *PanClient) getVipInfoReq() (*vipInfoResult, *apierror.ApiError) {
 	header := map[string]string{
-		"authorization": p.webToken.GetAuthorizationStr(),
+		"authorization": p.authorizationStr(),
 	}
 
 	fullUrl := &strings.Builder{}
@@ -335,7 +366,7 @@ func (p *PanClient) getVipInfoReq() (*vipInfoResult, *apierror.ApiError) {
 	postData := map[string]string{}
 
 	// request
-	body, err := client.Fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
+	body, err := p.fetch("POST", fullUrl.String(), postData, apiutil.AddCommonHeader(header))
 	if err != nil {
 		logger.Verboseln("get vip info error ", err)
 		return nil, apierror.NewFailedApiError(err.Error())
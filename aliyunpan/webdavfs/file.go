@@ -0,0 +1,320 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webdavfs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/chunkcache"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/transfer"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+)
+
+type (
+	// readFile 只读文件，按需发起Range请求读取网盘文件内容。文件夹可以通过 Readdir 获取文件列表
+	readFile struct {
+		fs *FileSystem
+		fe *aliyunpan.FileEntity
+		// cached 通过分块缓存读取，为nil时直接发起Range请求
+		cached *chunkcache.Reader
+
+		offset    int64
+		resp      *http.Response
+		dirOffset int
+
+		// urlMu 保护 downloadUrl，ReadAt 可以并发调用
+		urlMu       sync.Mutex
+		downloadUrl string
+	}
+
+	// writeFile 只写文件，先写入本地临时文件，Close 时上传到网盘
+	writeFile struct {
+		fs      *FileSystem
+		name    string
+		tmpFile *os.File
+	}
+)
+
+var (
+	errReadOnly  = errors.New("file is read only")
+	errWriteOnly = errors.New("file is write only")
+)
+
+func (f *readFile) Close() error {
+	f.closeResp()
+	return nil
+}
+
+func (f *readFile) closeResp() {
+	if f.resp != nil {
+		f.resp.Body.Close()
+		f.resp = nil
+	}
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if f.fe.IsFolder() {
+		return 0, os.ErrInvalid
+	}
+	if f.cached != nil {
+		return f.cached.Read(p)
+	}
+	if f.offset >= f.fe.FileSize {
+		return 0, io.EOF
+	}
+	if f.resp == nil {
+		if err := f.openRange(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.resp.Body.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF && f.offset < f.fe.FileSize {
+		// 连接提前结束，下次读取重新请求
+		f.closeResp()
+		err = nil
+	}
+	return n, err
+}
+
+// openRange 从当前偏移位置开始请求文件数据
+func (f *readFile) openRange() error {
+	resp, err := f.requestRange(aliyunpan.FileDownloadRange{Offset: f.offset})
+	if err != nil {
+		return err
+	}
+	f.resp = resp
+	return nil
+}
+
+// getDownloadUrl 获取下载链接，获取后缓存
+func (f *readFile) getDownloadUrl() (string, error) {
+	f.urlMu.Lock()
+	defer f.urlMu.Unlock()
+	if f.downloadUrl == "" {
+		r, apierr := f.fs.panClient.GetFileDownloadUrl(&aliyunpan.GetFileDownloadUrlParam{
+			DriveId: f.fe.DriveId,
+			FileId:  f.fe.FileId,
+		})
+		if apierr != nil {
+			return "", apierr
+		}
+		f.downloadUrl = r.Url
+	}
+	return f.downloadUrl, nil
+}
+
+// requestRange 请求指定范围的文件数据，不修改读取偏移位置
+func (f *readFile) requestRange(fileRange aliyunpan.FileDownloadRange) (*http.Response, error) {
+	downloadUrl, err := f.getDownloadUrl()
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := aliyunpan.NewHTTPClient()
+	httpClient.SetTimeout(0)
+	var resp *http.Response
+	apierr := f.fs.panClient.DownloadFileData(downloadUrl, fileRange, func(httpMethod, fullUrl string, headers map[string]string) (*http.Response, error) {
+		r, err := httpClient.Req(httpMethod, fullUrl, nil, headers)
+		resp = r
+		return r, err
+	})
+	if apierr != nil {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, apierr
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 206 {
+		resp.Body.Close()
+		if resp.StatusCode == 403 {
+			// 下载链接过期，下次重新获取
+			f.urlMu.Lock()
+			if f.downloadUrl == downloadUrl {
+				f.downloadUrl = ""
+			}
+			f.urlMu.Unlock()
+		}
+		return nil, fmt.Errorf("unexpected http status code, %d, %s", resp.StatusCode, resp.Status)
+	}
+	return resp, nil
+}
+
+// ReadAt 实现 io.ReaderAt，每次调用单独请求指定范围的数据，不影响 Read 的偏移位置，可以并发调用
+func (f *readFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.fe.IsFolder() || off < 0 {
+		return 0, os.ErrInvalid
+	}
+	if f.cached != nil {
+		return f.cached.ReadAt(p, off)
+	}
+	if off >= f.fe.FileSize {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	want := int64(len(p))
+	if off+want > f.fe.FileSize {
+		want = f.fe.FileSize - off
+	}
+	resp, err := f.requestRange(aliyunpan.FileDownloadRange{Offset: off, End: off + want - 1})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	n, err := io.ReadFull(resp.Body, p[:want])
+	if err == nil && int64(len(p)) > want {
+		// 读取到文件末尾
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	if f.cached != nil {
+		return f.cached.Seek(offset, whence)
+	}
+	newOffset := f.offset
+	switch whence {
+	case io.SeekStart:
+		newOffset = offset
+	case io.SeekCurrent:
+		newOffset += offset
+	case io.SeekEnd:
+		newOffset = f.fe.FileSize + offset
+	default:
+		return 0, os.ErrInvalid
+	}
+	if newOffset < 0 {
+		return 0, os.ErrInvalid
+	}
+	if newOffset != f.offset {
+		f.closeResp()
+		f.offset = newOffset
+	}
+	return f.offset, nil
+}
+
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.fe.IsFolder() {
+		return nil, os.ErrInvalid
+	}
+	fileList, apierr := f.fs.panClient.FileListGetAll(&aliyunpan.FileListParam{
+		DriveId:      f.fs.driveId,
+		ParentFileId: f.fe.FileId,
+	})
+	if apierr != nil {
+		return nil, apierr
+	}
+
+	if f.dirOffset >= len(fileList) {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return []os.FileInfo{}, nil
+	}
+	fileList = fileList[f.dirOffset:]
+	if count > 0 && count < len(fileList) {
+		fileList = fileList[:count]
+	}
+	f.dirOffset += len(fileList)
+
+	r := make([]os.FileInfo, 0, len(fileList))
+	for _, fe := range fileList {
+		r = append(r, newFileInfo(fe))
+	}
+	return r, nil
+}
+
+func (f *readFile) Stat() (os.FileInfo, error) {
+	return newFileInfo(f.fe), nil
+}
+
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, errReadOnly
+}
+
+func newWriteFile(fs *FileSystem, name string) (*writeFile, error) {
+	tmpFile, err := ioutil.TempFile("", "aliyunpan-webdav-")
+	if err != nil {
+		return nil, err
+	}
+	return &writeFile{
+		fs:      fs,
+		name:    name,
+		tmpFile: tmpFile,
+	}, nil
+}
+
+// Close 上传临时文件到网盘，并删除临时文件。没有写入数据时上传空文件，覆盖 O_TRUNC 打开的已存在文件
+func (f *writeFile) Close() error {
+	tmpPath := f.tmpFile.Name()
+	defer os.Remove(tmpPath)
+	if err := f.tmpFile.Close(); err != nil {
+		return err
+	}
+
+	dir, name := path.Split(f.name)
+	parent, err := f.fs.stat(dir)
+	if err != nil {
+		return err
+	}
+	if _, apierr := transfer.UploadFile(f.fs.panClient.Context(), f.fs.panClient, f.fs.driveId, parent.FileId, tmpPath, name, nil); apierr != nil {
+		return apierr
+	}
+	return nil
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	return 0, errWriteOnly
+}
+
+func (f *writeFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, errWriteOnly
+}
+
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) {
+	return f.tmpFile.Seek(offset, whence)
+}
+
+func (f *writeFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *writeFile) Stat() (os.FileInfo, error) {
+	info, err := f.tmpFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(&aliyunpan.FileEntity{
+		FileName:    path.Base(f.name),
+		FileSize:    info.Size(),
+		FileType:    "file",
+		Path:        f.name,
+		UpdatedAt:   info.ModTime().Format("2006-01-02 15:04:05"),
+		UpdatedTime: info.ModTime(),
+	}), nil
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	return f.tmpFile.Write(p)
+}
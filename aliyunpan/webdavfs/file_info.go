@@ -0,0 +1,60 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webdavfs
+
+import (
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"os"
+	"time"
+)
+
+type (
+	// fileInfo 网盘文件信息，实现 os.FileInfo 接口
+	fileInfo struct {
+		fe *aliyunpan.FileEntity
+	}
+)
+
+func newFileInfo(fe *aliyunpan.FileEntity) *fileInfo {
+	return &fileInfo{fe: fe}
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.fe.FileName
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.fe.FileSize
+}
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.fe.IsFolder() {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.fe.UpdatedTime
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.fe.IsFolder()
+}
+
+// Sys 返回对应的 *aliyunpan.FileEntity
+func (fi *fileInfo) Sys() interface{} {
+	return fi.fe
+}
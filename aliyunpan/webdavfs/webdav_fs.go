@@ -0,0 +1,221 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webdavfs 基于 PanClient 实现的 webdav.FileSystem，可以通过任意WebDAV客户端挂载网盘
+//
+//	handler := &webdav.Handler{
+//		FileSystem: webdavfs.NewFileSystem(panClient, driveId),
+//		LockSystem: webdav.NewMemLS(),
+//	}
+//	http.ListenAndServe(":8080", handler)
+package webdavfs
+
+import (
+	"context"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/apierror"
+	"github.com/tickstep/aliyunpan-api/aliyunpan/chunkcache"
+	"golang.org/x/net/webdav"
+	"os"
+	"path"
+)
+
+type (
+	// FileSystem 网盘WebDAV文件系统
+	FileSystem struct {
+		panClient *aliyunpan.PanClient
+		driveId   string
+		// chunkCache 分块缓存，为nil时直接读取
+		chunkCache *chunkcache.Cache
+	}
+)
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+// NewFileSystem 创建网盘WebDAV文件系统
+func NewFileSystem(panClient *aliyunpan.PanClient, driveId string) *FileSystem {
+	return &FileSystem{
+		panClient: panClient,
+		driveId:   driveId,
+	}
+}
+
+// SetChunkCache 设置分块缓存，设置后读取文件时通过缓存按块读取，适合视频播放器拖动进度条等随机读取的场景
+func (fs *FileSystem) SetChunkCache(cache *chunkcache.Cache) {
+	fs.chunkCache = cache
+}
+
+// cleanPath 转换为网盘绝对路径
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+// toOsError 把网盘错误转换为 os 包的错误，webdav 依赖 os.IsNotExist 等判断返回的状态码
+func toOsError(err *apierror.ApiError) error {
+	if err == nil {
+		return nil
+	}
+	switch err.Code {
+	case apierror.ApiCodeFileNotFoundCode:
+		return os.ErrNotExist
+	case apierror.ApiCodeFileAlreadyExisted:
+		return os.ErrExist
+	}
+	return err
+}
+
+// withContext 返回通过 ctx 发起网盘请求的文件系统，WebDAV请求取消后网盘请求也随之停止
+func (fs *FileSystem) withContext(ctx context.Context) *FileSystem {
+	c := *fs
+	c.panClient = fs.panClient.WithContext(ctx)
+	return &c
+}
+
+func (fs *FileSystem) stat(name string) (*aliyunpan.FileEntity, error) {
+	fe, err := fs.panClient.FileInfoByPath(fs.driveId, cleanPath(name))
+	if err != nil {
+		return nil, toOsError(err)
+	}
+	return fe, nil
+}
+
+// Mkdir 创建文件夹，上级文件夹必须存在
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	fs = fs.withContext(ctx)
+	name = cleanPath(name)
+	if _, err := fs.stat(name); err == nil {
+		return os.ErrExist
+	}
+	dir, dirName := path.Split(name)
+	parent, err := fs.stat(dir)
+	if err != nil {
+		return err
+	}
+	if !parent.IsFolder() {
+		return os.ErrInvalid
+	}
+	_, apierr := fs.panClient.Mkdir(fs.driveId, parent.FileId, dirName)
+	return toOsError(apierr)
+}
+
+// OpenFile 打开文件。只有 O_TRUNC 写模式打开已存在的文件，或者 O_CREATE 创建不存在的文件时才可以写入，
+// 数据先缓存到本地临时文件，Close 时才上传到网盘，O_TRUNC 打开后没有写入数据也会上传空文件。已存在的文件使用其他写模式打开时只读，
+// 避免 PROPPATCH 等只修改属性的请求覆盖文件内容
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	fs = fs.withContext(ctx)
+	name = cleanPath(name)
+	fe, err := fs.stat(name)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		if flag&os.O_CREATE == 0 {
+			return nil, os.ErrNotExist
+		}
+		return newWriteFile(fs, name)
+	}
+
+	if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+		return nil, os.ErrExist
+	}
+	if flag&os.O_TRUNC != 0 && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if fe.IsFolder() {
+			return nil, os.ErrInvalid
+		}
+		return newWriteFile(fs, name)
+	}
+	f := &readFile{
+		fs: fs,
+		fe: fe,
+	}
+	if fs.chunkCache != nil && fe.IsFile() {
+		f.cached = fs.chunkCache.NewReader(fs.panClient, fe)
+	}
+	return f, nil
+}
+
+// RemoveAll 删除文件或文件夹到回收站
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	fs = fs.withContext(ctx)
+	name = cleanPath(name)
+	if name == "/" {
+		return os.ErrInvalid
+	}
+	fe, err := fs.stat(name)
+	if err != nil {
+		return err
+	}
+	r, apierr := fs.panClient.FileDelete([]*aliyunpan.FileBatchActionParam{
+		{DriveId: fs.driveId, FileId: fe.FileId},
+	})
+	if apierr != nil {
+		return toOsError(apierr)
+	}
+	if len(r) == 0 || !r[0].Success {
+		return os.ErrPermission
+	}
+	return nil
+}
+
+// Rename 移动或重命名文件
+func (fs *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	fs = fs.withContext(ctx)
+	oldName, newName = cleanPath(oldName), cleanPath(newName)
+	if oldName == "/" || newName == "/" {
+		return os.ErrInvalid
+	}
+	fe, err := fs.stat(oldName)
+	if err != nil {
+		return err
+	}
+
+	oldDir, oldBase := path.Split(oldName)
+	newDir, newBase := path.Split(newName)
+	if oldDir != newDir {
+		toParent, err := fs.stat(newDir)
+		if err != nil {
+			return err
+		}
+		r, apierr := fs.panClient.FileMove([]*aliyunpan.FileMoveParam{
+			{
+				DriveId:        fs.driveId,
+				FileId:         fe.FileId,
+				ToDriveId:      fs.driveId,
+				ToParentFileId: toParent.FileId,
+			},
+		})
+		if apierr != nil {
+			return toOsError(apierr)
+		}
+		if len(r) == 0 || !r[0].Success {
+			return os.ErrExist
+		}
+	}
+	if oldBase != newBase {
+		if _, apierr := fs.panClient.FileRename(fs.driveId, fe.FileId, newBase); apierr != nil {
+			return toOsError(apierr)
+		}
+	}
+	return nil
+}
+
+// Stat 获取文件信息
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	fs = fs.withContext(ctx)
+	fe, err := fs.stat(name)
+	if err != nil {
+		return nil, err
+	}
+	return newFileInfo(fe), nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2020 tickstep.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webdavfs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tickstep/aliyunpan-api/aliyunpan"
+	"golang.org/x/net/webdav"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newCachedFileSystem 文件信息都从元数据缓存读取，发起的网络请求计入 requests 并返回错误
+func newCachedFileSystem(t *testing.T, requests *int32) *FileSystem {
+	aliyunpan.SetTransportWrapper(func(base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			atomic.AddInt32(requests, 1)
+			return nil, errors.New("unexpected request " + r.URL.String())
+		})
+	})
+	t.Cleanup(func() { aliyunpan.SetTransportWrapper(nil) })
+
+	pc := aliyunpan.NewPanClient(aliyunpan.WebLoginToken{AccessTokenType: "Bearer", AccessToken: "a1"}, aliyunpan.AppLoginToken{})
+	store := aliyunpan.NewMemoryMetaStore()
+	store.PutChildren("d1", aliyunpan.DefaultRootParentFileId, aliyunpan.FileList{
+		{DriveId: "d1", FileId: "f1", FileName: "a.txt", FileType: "file", FileSize: 3, ParentFileId: aliyunpan.DefaultRootParentFileId},
+		{DriveId: "d1", FileId: "f2", FileName: "dir", FileType: "folder", ParentFileId: aliyunpan.DefaultRootParentFileId},
+	})
+	pc.SetMetaStore(store)
+	return NewFileSystem(pc, "d1")
+}
+
+func TestOpenFileFlags(t *testing.T) {
+	var requests int32
+	fs := newCachedFileSystem(t, &requests)
+	ctx := context.Background()
+
+	// 已存在的文件不带 O_TRUNC 写模式打开时只读，关闭不会上传
+	for _, flag := range []int{os.O_RDWR, os.O_WRONLY, os.O_RDWR | os.O_CREATE, os.O_WRONLY | os.O_APPEND} {
+		f, err := fs.OpenFile(ctx, "/a.txt", flag, 0)
+		assert.NoError(t, err)
+		_, ok := f.(*readFile)
+		assert.True(t, ok, "flag %d", flag)
+		assert.NoError(t, f.Close())
+	}
+
+	// O_TRUNC 打开后没有写入数据，关闭时也会上传空文件覆盖原文件
+	f, err := fs.OpenFile(ctx, "/a.txt", os.O_RDWR|os.O_TRUNC, 0)
+	assert.NoError(t, err)
+	_, ok := f.(*writeFile)
+	assert.True(t, ok)
+	assert.Error(t, f.Close())
+	assert.NotEqual(t, int32(0), atomic.SwapInt32(&requests, 0))
+
+	_, err = fs.OpenFile(ctx, "/a.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0)
+	assert.Equal(t, os.ErrExist, err)
+	_, err = fs.OpenFile(ctx, "/dir", os.O_RDWR|os.O_TRUNC, 0)
+	assert.Equal(t, os.ErrInvalid, err)
+
+	// 不存在的文件只有 O_CREATE 时才创建
+	_, err = fs.OpenFile(ctx, "/missing.txt", os.O_RDWR, 0)
+	assert.True(t, os.IsNotExist(err))
+	f, err = fs.OpenFile(ctx, "/missing.txt", os.O_RDWR|os.O_CREATE, 0)
+	assert.NoError(t, err)
+	w, ok := f.(*writeFile)
+	assert.True(t, ok)
+	os.Remove(w.tmpFile.Name())
+	w.tmpFile.Close()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestRequestContext(t *testing.T) {
+	var requests int32
+	fs := newCachedFileSystem(t, &requests)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// 请求已取消，不会再发起网盘请求
+	_, err := fs.Stat(ctx, "/missing/b.txt")
+	assert.Error(t, err)
+	assert.Error(t, fs.Mkdir(ctx, "/new", 0))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestProppatchKeepsContent(t *testing.T) {
+	var requests int32
+	handler := &webdav.Handler{
+		FileSystem: newCachedFileSystem(t, &requests),
+		LockSystem: webdav.NewMemLS(),
+	}
+	body := `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="urn:schemas-microsoft-com:">
+<D:set><D:prop><Z:Win32LastModifiedTime>Mon, 01 Jan 2024 00:00:00 GMT</Z:Win32LastModifiedTime></D:prop></D:set>
+</D:propertyupdate>`
+	req := httptest.NewRequest("PROPPATCH", "/a.txt", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	// 只修改属性，不会上传覆盖文件
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
@@ -3,10 +3,14 @@ module github.com/tickstep/aliyunpan-api
 go 1.16
 
 require (
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/json-iterator/go v1.1.10
 	github.com/satori/go.uuid v1.2.0
+	github.com/spf13/afero v1.6.0
 	github.com/stretchr/testify v1.6.1
 	github.com/tickstep/library-go v0.0.5
+	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
+	golang.org/x/text v0.3.6
 )
 
-//replace github.com/tickstep/library-go => /Users/tickstep/Documents/Workspace/go/projects/library-go
\ No newline at end of file
+//replace github.com/tickstep/library-go => /Users/tickstep/Documents/Workspace/go/projects/library-go